@@ -0,0 +1,5 @@
+// Package gatewaypb gRPC interface of the gateway, mirroring ETHService for clients in other languages.
+// the Go code is generated from gateway.proto, regenerate it with go generate.
+package gatewaypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gateway.proto
@@ -0,0 +1,219 @@
+//go:build grpc
+
+// Package grpc gRPC API of the gateway, see gatewaypb/gateway.proto. the gateway serves it only when
+// built with the grpc tag: go build -tags grpc
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/grpc/gatewaypb"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// logger of the gRPC API.
+var logger = logging.Std()
+
+const (
+	// defaultPageLimit transactions per page when the request sets no limit.
+	defaultPageLimit = 100
+	// maxPageLimit at most transactions per page.
+	maxPageLimit = 1000
+)
+
+// Server gatewaypb.GatewayServer delegating to an ETHService.
+type Server struct {
+	gatewaypb.UnimplementedGatewayServer
+	start func() (*service.ETHService, error)
+}
+
+// NewServer return a gRPC server serving the Gateway service backed by svc.
+func NewServer(svc *service.ETHService, opts ...grpc.ServerOption) *grpc.Server {
+	return NewLazyServer(func() (*service.ETHService, error) { return svc, nil }, opts...)
+}
+
+// NewLazyServer return the server of NewServer backed by the service start returns, such as
+// service.ETHServiceInstance. start is called on each call until it succeeds, meanwhile calls fail with
+// codes.Unavailable like the REST API answers 503.
+func NewLazyServer(start func() (*service.ETHService, error), opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(opts...)
+	gatewaypb.RegisterGatewayServer(srv, &Server{start: start})
+	return srv
+}
+
+// service the ETHService, or an Unavailable status if it can't be started.
+func (s *Server) service(ctx context.Context) (*service.ETHService, error) {
+	svc, err := s.start()
+	if err != nil {
+		logger.Error(ctx, "[Server]: Error start service", "err", err)
+		return nil, status.Error(codes.Unavailable, "service unavailable: "+err.Error())
+	}
+	return svc, nil
+}
+
+// Subscribe subscribe the address or ENS name of the request.
+func (s *Server) Subscribe(ctx context.Context, req *gatewaypb.SubscribeRequest) (*gatewaypb.SubscribeResponse, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	address := req.GetAddress()
+	if util.IsENSName(address) {
+		address = strings.ToLower(address)
+	} else if address, err = util.NormalizeAddress(address); err != nil {
+		return nil, statusOf(err)
+	}
+	if err := svc.Subscribe(ctx, address); err != nil {
+		logger.Error(ctx, "[Subscribe]: Error Subscribe", "err", err)
+		return nil, statusOf(err)
+	}
+	return &gatewaypb.SubscribeResponse{}, nil
+}
+
+// GetCurrentBlock the most recent block of the node.
+func (s *Server) GetCurrentBlock(ctx context.Context, req *gatewaypb.GetCurrentBlockRequest) (*gatewaypb.Block, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockInfo, err := svc.GetCurrentBlock(ctx)
+	if err != nil {
+		logger.Error(ctx, "[GetCurrentBlock]: Error GetCurrentBlock", "err", err)
+		return nil, statusOf(err)
+	}
+	return toBlock(blockInfo), nil
+}
+
+// GetTransactions page of the transactions of a subscribed address, NotFound if it isn't.
+func (s *Server) GetTransactions(ctx context.Context, req *gatewaypb.GetTransactionsRequest) (*gatewaypb.GetTransactionsResponse, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	direction := model.Direction(req.GetDirection())
+	if direction != "" && direction != model.DirectionInbound && direction != model.DirectionOutbound {
+		return nil, status.Error(codes.InvalidArgument, "direction should be in or out")
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 0 || limit > maxPageLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit should be between 1 and %d", maxPageLimit)
+	}
+	transactions, next, err := svc.FilterTransactionsPage(ctx, req.GetAddress(), model.TxFilter{Direction: direction}, req.GetCursor(), limit)
+	if err != nil {
+		logger.Error(ctx, "[GetTransactions]: Error FilterTransactionsPage", "err", err)
+		return nil, statusOf(err)
+	}
+	// like GetTransactions, the history of an unsubscribed address is served until purged.
+	if len(transactions) == 0 && !svc.IsSubscribed(ctx, req.GetAddress()) {
+		return nil, statusOf(service.ErrNotSubscribed)
+	}
+	resp := &gatewaypb.GetTransactionsResponse{NextCursor: next}
+	for _, tx := range transactions {
+		resp.Transactions = append(resp.Transactions, toTransaction(tx))
+	}
+	return resp, nil
+}
+
+// StreamTransactions push the transactions of the address of the request, or of every subscribed address
+// if it has none, as they are stored. the stream of the service is released once the client goes away,
+// its buffer dropping transactions rather than holding back parsing while the client is slow.
+func (s *Server) StreamTransactions(req *gatewaypb.StreamTransactionsRequest, stream gatewaypb.Gateway_StreamTransactionsServer) error {
+	// canceled when the client disconnects or the call returns, which closes live.
+	ctx := stream.Context()
+	svc, err := s.service(ctx)
+	if err != nil {
+		return err
+	}
+	var live <-chan *model.ETHTransaction
+	if len(req.GetAddress()) == 0 {
+		live = svc.Events(ctx)
+	} else if live, err = svc.SubscribeChan(ctx, req.GetAddress()); err != nil {
+		return statusOf(err)
+	}
+	for tx := range live {
+		if err := stream.Send(toTransaction(tx)); err != nil {
+			logger.Error(ctx, "[StreamTransactions]: Error Send", "err", err)
+			return err
+		}
+	}
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+// statusOf the gRPC status of a service error, the codes match the REST statuses of http.errorStatus.
+func statusOf(err error) error {
+	var rpcErr *remote.RPCError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, service.ErrInvalidAddress), errors.Is(err, store.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrNotSubscribed), errors.Is(err, service.ErrBlockNotFound),
+		errors.Is(err, service.ErrENSNotResolved):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrRPCUnavailable), errors.Is(err, service.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.As(err, &rpcErr), errors.Is(err, remote.ErrMalformedResponse):
+		// the node answered wrong, nothing the client can fix.
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// toBlock the header of blockInfo.
+func toBlock(blockInfo *model.ETHBlockInfo) *gatewaypb.Block {
+	return &gatewaypb.Block{
+		Number:           blockInfo.Number,
+		Hash:             blockInfo.Hash,
+		ParentHash:       blockInfo.ParentHash,
+		Timestamp:        blockInfo.Timestamp,
+		GasUsed:          blockInfo.GasUsed,
+		GasLimit:         blockInfo.GasLimit,
+		BaseFeePerGas:    blockInfo.BaseFeePerGas,
+		TransactionCount: int32(len(blockInfo.Transactions)),
+	}
+}
+
+// toTransaction the message of tx.
+func toTransaction(tx *model.ETHTransaction) *gatewaypb.Transaction {
+	pb := &gatewaypb.Transaction{
+		Hash:              tx.Hash,
+		BlockHash:         tx.BlockHash,
+		BlockNumber:       tx.BlockNumber,
+		TransactionIndex:  tx.TransactionIndex,
+		From:              tx.From,
+		To:                tx.To,
+		Value:             tx.Value,
+		Gas:               tx.Gas,
+		GasPrice:          tx.GasPrice,
+		Input:             tx.Input,
+		Nonce:             tx.Nonce,
+		Direction:         string(tx.Direction),
+		State:             string(tx.State),
+		Status:            string(tx.Status),
+		GasUsed:           tx.GasUsed,
+		EffectiveGasPrice: tx.EffectiveGasPrice,
+		ContractAddress:   tx.ContractAddress,
+		Internal:          tx.Internal,
+		TraceId:           tx.TraceID,
+		MethodId:          tx.MethodID,
+		MethodName:        tx.MethodName,
+		Confirmations:     tx.Confirmations,
+	}
+	if tx.Timestamp != nil {
+		pb.Timestamp = tx.Timestamp.Unix()
+	}
+	return pb
+}
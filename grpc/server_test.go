@@ -0,0 +1,139 @@
+//go:build grpc
+
+// go test -tags grpc ./grpc/
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/grpc/gatewaypb"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/tj/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	addrA = "0x00000000000000000000000000000000000000aa"
+	addrB = "0x00000000000000000000000000000000000000bb"
+)
+
+// fakeETHClient chain at block 1 with a single transaction.
+type fakeETHClient struct{}
+
+func (fakeETHClient) ETHBlockDecimalNumber(ctx context.Context) (int64, error) { return 1, nil }
+func (fakeETHClient) EthBlockNumber(ctx context.Context) (string, error)       { return "0x1", nil }
+func (fakeETHClient) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	if number != "0x1" {
+		return nil, errors.New("unknown block")
+	}
+	return &model.ETHBlockInfo{
+		Number:    "0x1",
+		Hash:      "0xh1",
+		Timestamp: "0x65920080",
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0xt1", BlockNumber: "0x1", From: addrA, To: addrB, Value: "0x10"},
+		},
+	}, nil
+}
+func (fakeETHClient) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	return []*model.ETHTransactionReceipt{}, nil
+}
+
+func newTestServer(t *testing.T) (*service.ETHService, *Server) {
+	conf := service.DefaultConfig()
+	conf.Confirmations = 0
+	svc, err := service.NewETHService(fakeETHClient{}, service.WithConfig(conf))
+	assert.Nil(t, err)
+	return svc, &Server{start: func() (*service.ETHService, error) { return svc, nil }}
+}
+
+// fakeStream server side of a StreamTransactions call, handing what is sent to sent.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *gatewaypb.Transaction
+}
+
+func (f *fakeStream) Context() context.Context { return f.ctx }
+
+func (f *fakeStream) Send(tx *gatewaypb.Transaction) error {
+	select {
+	case f.sent <- tx:
+		return nil
+	case <-f.ctx.Done():
+		return status.FromContextError(f.ctx.Err()).Err()
+	}
+}
+
+func TestServer_GetCurrentBlock(t *testing.T) {
+	_, s := newTestServer(t)
+	block, err := s.GetCurrentBlock(context.Background(), &gatewaypb.GetCurrentBlockRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, "0x1", block.Number)
+	assert.Equal(t, int32(1), block.TransactionCount)
+}
+
+func TestServer_GetTransactions(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestServer(t)
+	_, err := s.Subscribe(ctx, &gatewaypb.SubscribeRequest{Address: "0xnope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.GetTransactions(ctx, &gatewaypb.GetTransactionsRequest{Address: addrA})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.Subscribe(ctx, &gatewaypb.SubscribeRequest{Address: addrA})
+	assert.Nil(t, err)
+	assert.Nil(t, svc.ParseTransactions(ctx, 1))
+	resp, err := s.GetTransactions(ctx, &gatewaypb.GetTransactionsRequest{Address: addrA, Direction: "out"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp.Transactions))
+	assert.Equal(t, "0xt1", resp.Transactions[0].Hash)
+	assert.Equal(t, "out", resp.Transactions[0].Direction)
+	assert.Equal(t, int64(0x65920080), resp.Transactions[0].Timestamp)
+	assert.Equal(t, "", resp.NextCursor)
+
+	_, err = s.GetTransactions(ctx, &gatewaypb.GetTransactionsRequest{Address: addrA, Direction: "up"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.GetTransactions(ctx, &gatewaypb.GetTransactionsRequest{Address: addrA, Limit: maxPageLimit + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_StreamTransactions(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestServer(t)
+	err := s.StreamTransactions(&gatewaypb.StreamTransactionsRequest{Address: addrA}, &fakeStream{ctx: ctx})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.Nil(t, svc.Subscribe(ctx, addrA))
+	cctx, cancel := context.WithCancel(ctx)
+	stream := &fakeStream{ctx: cctx, sent: make(chan *gatewaypb.Transaction, 1)}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.StreamTransactions(&gatewaypb.StreamTransactionsRequest{Address: addrA}, stream)
+	}()
+	// the stream is opened in background, parse until a transaction is pushed.
+	var tx *gatewaypb.Transaction
+	for tx == nil {
+		assert.Nil(t, svc.ParseTransactions(ctx, 1))
+		select {
+		case tx = <-stream.sent:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, "0xt1", tx.Hash)
+
+	// the client going away ends the call.
+	cancel()
+	select {
+	case err := <-done:
+		assert.Equal(t, codes.Canceled, status.Code(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream still open after the client went away")
+	}
+}
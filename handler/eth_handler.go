@@ -3,12 +3,17 @@ package handler
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
 	"github.com/sugarshop/token-gateway/service"
 	"github.com/sugarshop/token-gateway/util"
-	"log"
+	"strconv"
 	"strings"
 )
 
+// logger of the handlers.
+var logger = logging.Std()
+
 type ETHHandler struct {
 
 }
@@ -20,50 +25,334 @@ func NewETHHandler() *ETHHandler {
 
 func (eth *ETHHandler) Register(e *gin.Engine) {
 	e.GET("/v1/get_current_block", JSONWrapper(eth.GetCurrentBlock))
+	e.GET("/v1/get_last_processed_block", JSONWrapper(eth.GetLastProcessedBlock))
 	e.POST("/v1/subscribe", JSONWrapper(eth.Subscribe))
+	e.POST("/v1/unsubscribe", JSONWrapper(eth.Unsubscribe))
 	e.GET("/v1/get_transactions", JSONWrapper(eth.GetTransactions))
+	e.GET("/v1/get_transactions_page", JSONWrapper(eth.GetTransactionsPage))
+	e.GET("/v1/get_transactions_paged", JSONWrapper(eth.GetTransactionsPaged))
+	e.GET("/v1/get_token_transfers", JSONWrapper(eth.GetTokenTransfers))
+	e.GET("/v1/get_nft_transfers", JSONWrapper(eth.GetNFTTransfers))
+	e.GET("/v1/get_nft_transfers_page", JSONWrapper(eth.GetNFTTransfersPage))
+	e.GET("/v1/list_subscriptions", JSONWrapper(eth.ListSubscriptions))
+	e.GET("/v1/get_backfill_status", JSONWrapper(eth.GetBackfillStatus))
+	e.GET("/v1/get_webhook_stats", JSONWrapper(eth.GetWebhookStats))
 }
 
 // GetCurrentBlock get last parsed block.
 func (eth *ETHHandler) GetCurrentBlock(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
-	blockInfo, err := service.ETHServiceInstance().GetCurrentBlock(ctx)
+	svc, err := service.ETHServiceInstance()
 	if err != nil {
-		log.Println("[GetCurrentBlock]: GetCurrentBlock err: ", err)
+		logger.Error(ctx, "[GetCurrentBlock]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	blockInfo, err := svc.GetCurrentBlock(ctx)
+	if err != nil {
+		logger.Error(ctx, "[GetCurrentBlock]: Error GetCurrentBlock", "err", err)
 		return nil, err
 	}
 	return blockInfo, nil
 }
 
-// Subscribe subscribe address to server.
+// GetLastProcessedBlock get number of the last block parsed by server.
+func (eth *ETHHandler) GetLastProcessedBlock(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetLastProcessedBlock]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"number": svc.LastProcessedBlock(ctx),
+	}, nil
+}
+
+// Subscribe subscribe address to server, its history is backfilled from from_block if set.
+// its transactions are posted to webhook as well if set.
 func (eth *ETHHandler) Subscribe(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[Subscribe]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[Subscribe]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	// no backfill unless from_block is set.
+	from := int64(-1)
+	if fromBlock := c.Request.Form.Get("from_block"); len(fromBlock) > 0 {
+		if from, err = strconv.ParseInt(fromBlock, 10, 64); err != nil || from < 0 {
+			logger.Warn(ctx, "[Subscribe]: Error parse from_block param", "err", err)
+			return nil, errors.New("parse from_block param err")
+		}
+	}
+	if err := svc.SubscribeFromWithWebhook(ctx, address, from, c.Request.Form.Get("webhook")); err != nil {
+		logger.Error(ctx, "[Subscribe]: Error SubscribeFromWithWebhook", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{}, nil
+}
+
+// Unsubscribe unsubscribe address from server, purge=true drops its collected transactions.
+func (eth *ETHHandler) Unsubscribe(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[Unsubscribe]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
 	address := c.Request.Form.Get("address")
 	if len(address) == 0 {
-		log.Println(ctx, "[Subscribe]: parse address param err")
+		logger.Warn(ctx, "[Unsubscribe]: Error parse address param")
 		return nil, errors.New("parse address param err")
 	}
-	if err := service.ETHServiceInstance().Subscribe(ctx, strings.ToLower(address)); err != nil {
-		log.Println(ctx, "[Subscribe]: Subscribe err: ", err)
+	purge := c.Request.Form.Get("purge") == "true"
+	if err := svc.Unsubscribe(ctx, address, purge); err != nil {
+		logger.Error(ctx, "[Unsubscribe]: Error Unsubscribe", "err", err)
 		return nil, err
 	}
 	return map[string]interface{}{}, nil
 }
 
+// ListSubscriptions list addresses subscribed to server.
+func (eth *ETHHandler) ListSubscriptions(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[ListSubscriptions]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"addresses": svc.ListSubscriptions(ctx),
+	}, nil
+}
+
+// GetBackfillStatus progress of scanning an address's history, requested by subscribe with from_block.
+func (eth *ETHHandler) GetBackfillStatus(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetBackfillStatus]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetBackfillStatus]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	status, ok := svc.BackfillStatus(ctx, strings.ToLower(address))
+	if !ok {
+		logger.Warn(ctx, "[GetBackfillStatus]: no backfill of address", "address", address)
+		return nil, errors.New("no backfill of address")
+	}
+	return status, nil
+}
+
+// GetWebhookStats deliveries to the webhook of an address, requested by subscribe with webhook.
+func (eth *ETHHandler) GetWebhookStats(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetWebhookStats]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetWebhookStats]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	stats, ok := svc.WebhookStats(ctx, address)
+	if !ok {
+		logger.Warn(ctx, "[GetWebhookStats]: no webhook of address", "address", address)
+		return nil, errors.New("no webhook of address")
+	}
+	return stats, nil
+}
+
 // GetTransactions list of inbound or outbound transactions for an address.
 func (eth *ETHHandler) GetTransactions(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetTransactions]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
 	address := c.Request.Form.Get("address")
 	if len(address) == 0 {
-		log.Println(ctx, "[GetTransactions]: parse address param err")
+		logger.Warn(ctx, "[GetTransactions]: Error parse address param")
 		return nil, errors.New("parse address param err")
 	}
-	transactions, err := service.ETHServiceInstance().GetTransactions(ctx, strings.ToLower(address))
+	// direction is optional, in or out.
+	direction := model.Direction(c.Request.Form.Get("direction"))
+	if direction != "" && direction != model.DirectionInbound && direction != model.DirectionOutbound {
+		logger.Warn(ctx, "[GetTransactions]: Error parse direction param", "direction", direction)
+		return nil, errors.New("parse direction param err")
+	}
+	// status is optional, success or failed.
+	status := model.TxStatus(c.Request.Form.Get("status"))
+	if status != "" && status != model.TxStatusSuccess && status != model.TxStatusFailed {
+		logger.Warn(ctx, "[GetTransactions]: Error parse status param", "status", status)
+		return nil, errors.New("parse status param err")
+	}
+	// state is optional, pending, confirmed or dropped.
+	state := model.TxState(c.Request.Form.Get("state"))
+	if state != "" && state != model.TxStatePending && state != model.TxStateConfirmed && state != model.TxStateDropped {
+		logger.Warn(ctx, "[GetTransactions]: Error parse state param", "state", state)
+		return nil, errors.New("parse state param err")
+	}
+	// method is optional, a selector such as 0xa9059cbb or a well-known name such as transfer.
+	// exclude_failed=true leaves the reverted transactions out.
+	filter := model.TxFilter{Direction: direction, Status: status, Method: c.Request.Form.Get("method"),
+		ExcludeFailed: c.Request.Form.Get("exclude_failed") == "true", State: state}
+	transactions, err := svc.FilterTransactions(ctx, address, filter)
 	if err != nil {
-		log.Println(ctx, "[GetTransactions]: GetTransactions err: ", err)
+		logger.Error(ctx, "[GetTransactions]: Error GetTransactions", "err", err)
 		return nil, err
 	}
 	return map[string]interface{} {
 		"transactions": transactions,
 	}, nil
-}
\ No newline at end of file
+}
+
+// GetTransactionsPage page of inbound or outbound transactions for an address, cursor is the next_cursor of previous page.
+func (eth *ETHHandler) GetTransactionsPage(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetTransactionsPage]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetTransactionsPage]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	limit, err := strconv.Atoi(c.Request.Form.Get("limit"))
+	if err != nil {
+		logger.Warn(ctx, "[GetTransactionsPage]: Error parse limit param", "err", err)
+		return nil, errors.New("parse limit param err")
+	}
+	cursor := c.Request.Form.Get("cursor")
+	transactions, next, err := svc.GetTransactionsPage(ctx, address, cursor, limit)
+	if err != nil {
+		logger.Error(ctx, "[GetTransactionsPage]: Error GetTransactionsPage", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"transactions": transactions,
+		"next_cursor":  next,
+	}, nil
+}
+
+// GetTransactionsPaged offset/limit page of transactions for an address, most recent first.
+func (eth *ETHHandler) GetTransactionsPaged(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetTransactionsPaged]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetTransactionsPaged]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	offset, err := strconv.Atoi(c.Request.Form.Get("offset"))
+	if err != nil {
+		logger.Warn(ctx, "[GetTransactionsPaged]: Error parse offset param", "err", err)
+		return nil, errors.New("parse offset param err")
+	}
+	limit, err := strconv.Atoi(c.Request.Form.Get("limit"))
+	if err != nil {
+		logger.Warn(ctx, "[GetTransactionsPaged]: Error parse limit param", "err", err)
+		return nil, errors.New("parse limit param err")
+	}
+	transactions, total, err := svc.GetTransactionsPaged(ctx, address, offset, limit)
+	if err != nil {
+		logger.Error(ctx, "[GetTransactionsPaged]: Error GetTransactionsPaged", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"transactions": transactions,
+		"total":        total,
+	}, nil
+}
+
+// GetTokenTransfers list of inbound or outbound ERC-20 transfers for an address.
+func (eth *ETHHandler) GetTokenTransfers(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetTokenTransfers]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetTokenTransfers]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	transfers, err := svc.GetTokenTransfers(ctx, address)
+	if err != nil {
+		logger.Error(ctx, "[GetTokenTransfers]: Error GetTokenTransfers", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"transfers": transfers,
+	}, nil
+}
+
+// GetNFTTransfers list of inbound or outbound ERC-721 and ERC-1155 transfers for an address.
+func (eth *ETHHandler) GetNFTTransfers(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetNFTTransfers]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetNFTTransfers]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	transfers, err := svc.GetNFTTransfers(ctx, address)
+	if err != nil {
+		logger.Error(ctx, "[GetNFTTransfers]: Error GetNFTTransfers", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"transfers": transfers,
+	}, nil
+}
+
+// GetNFTTransfersPage cursor page of NFT transfers for an address, like GetTransactionsPage.
+func (eth *ETHHandler) GetNFTTransfersPage(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	svc, err := service.ETHServiceInstance()
+	if err != nil {
+		logger.Error(ctx, "[GetNFTTransfersPage]: Error ETHServiceInstance", "err", err)
+		return nil, err
+	}
+	address := c.Request.Form.Get("address")
+	if len(address) == 0 {
+		logger.Warn(ctx, "[GetNFTTransfersPage]: Error parse address param")
+		return nil, errors.New("parse address param err")
+	}
+	limit, err := strconv.Atoi(c.Request.Form.Get("limit"))
+	if err != nil {
+		logger.Warn(ctx, "[GetNFTTransfersPage]: Error parse limit param", "err", err)
+		return nil, errors.New("parse limit param err")
+	}
+	transfers, next, err := svc.GetNFTTransfersPage(ctx, address, c.Request.Form.Get("cursor"), limit)
+	if err != nil {
+		logger.Error(ctx, "[GetNFTTransfersPage]: Error GetNFTTransfersPage", "err", err)
+		return nil, err
+	}
+	return map[string]interface{}{
+		"transfers":   transfers,
+		"next_cursor": next,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/tj/assert"
+)
+
+// fakeChain chain whose blocks are added by the test.
+type fakeChain struct {
+	mu     sync.Mutex
+	blocks map[string]*model.ETHBlockInfo
+}
+
+func (f *fakeChain) ETHBlockDecimalNumber(ctx context.Context) (int64, error) { return 0, nil }
+func (f *fakeChain) EthBlockNumber(ctx context.Context) (string, error)       { return "0x0", nil }
+func (f *fakeChain) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if block, ok := f.blocks[number]; ok {
+		return block, nil
+	}
+	return nil, errors.New("unknown block")
+}
+func (f *fakeChain) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	return []*model.ETHTransactionReceipt{}, nil
+}
+
+// addBlock add block number with a transaction to address per hash.
+func (f *fakeChain) addBlock(number int64, address string, hashes ...string) {
+	block := &model.ETHBlockInfo{Number: fmt.Sprintf("0x%x", number), Hash: fmt.Sprintf("0xh%d", number)}
+	for i, hash := range hashes {
+		block.Transactions = append(block.Transactions, &model.ETHTransaction{
+			Hash: hash, BlockNumber: block.Number, TransactionIndex: fmt.Sprintf("0x%x", i), To: address,
+		})
+	}
+	f.mu.Lock()
+	f.blocks[block.Number] = block
+	f.mu.Unlock()
+}
+
+// readEvent the next event of the stream, comments included.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		if line == "\n" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+func TestServer_StreamEvents(t *testing.T) {
+	defer func(d time.Duration) { sseHeartbeat = d }(sseHeartbeat)
+	sseHeartbeat = 50 * time.Millisecond
+	ctx := context.Background()
+	address := "0x00000000000000000000000000000000000000aa"
+	chain := &fakeChain{blocks: map[string]*model.ETHBlockInfo{}}
+	conf := service.DefaultConfig()
+	conf.Confirmations = 0
+	svc, err := service.NewETHService(chain, service.WithConfig(conf))
+	assert.Nil(t, err)
+	server := httptest.NewServer(NewServer(svc))
+	defer server.Close()
+	url := server.URL + "/v1/addresses/" + address + "/events"
+
+	resp, err := http.Get(url)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	assert.Nil(t, svc.Subscribe(ctx, address))
+	chain.addBlock(1, address, "0xa", "0xb", "0xc")
+	assert.Nil(t, svc.ParseTransactions(ctx, 1))
+
+	// resume after the first transaction of block 1.
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Last-Event-ID", "1-0")
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err = http.DefaultClient.Do(req.WithContext(reqCtx))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+	assert.True(t, strings.HasPrefix(readEvent(t, r), "id: 1-1\nevent: transaction\ndata: {"))
+	assert.True(t, strings.HasPrefix(readEvent(t, r), "id: 1-2\n"))
+
+	chain.addBlock(2, address, "0xd")
+	assert.Nil(t, svc.ParseTransactions(ctx, 2))
+	event := readEvent(t, r)
+	for event == ": heartbeat" {
+		event = readEvent(t, r)
+	}
+	assert.True(t, strings.HasPrefix(event, "id: 2-0\n"))
+	assert.True(t, strings.Contains(event, `"hash":"0xd"`))
+	assert.Equal(t, ": heartbeat", readEvent(t, r))
+
+	req.Header.Set("Last-Event-ID", "garbage")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_StreamEvents_ResumeUnconfirmed(t *testing.T) {
+	ctx := context.Background()
+	address := "0x00000000000000000000000000000000000000aa"
+	chain := &fakeChain{blocks: map[string]*model.ETHBlockInfo{}}
+	// block 1 is within the confirmation window, its transactions were streamed live as they were stored.
+	svc, err := service.NewETHService(chain, service.WithConfig(service.DefaultConfig()))
+	assert.Nil(t, err)
+	server := httptest.NewServer(NewServer(svc))
+	defer server.Close()
+	assert.Nil(t, svc.Subscribe(ctx, address))
+	chain.addBlock(1, address, "0xa", "0xb", "0xc")
+	assert.Nil(t, svc.ParseTransactions(ctx, 1))
+
+	// the client saw the first one before disconnecting.
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/addresses/"+address+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1-0")
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	r := bufio.NewReader(resp.Body)
+	assert.True(t, strings.HasPrefix(readEvent(t, r), "id: 1-1\n"))
+	assert.True(t, strings.HasPrefix(readEvent(t, r), "id: 1-2\n"))
+}
@@ -0,0 +1,247 @@
+// Package http REST API of the gateway, to be mounted on any mux.
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sugarshop/token-gateway/handler"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// logger of the REST API.
+var logger = logging.Std()
+
+// Server REST API of an ETHService.
+type Server struct {
+	svc *service.ETHService
+}
+
+// SubscribeRequest body of POST /subscribe.
+type SubscribeRequest struct {
+	Address string `json:"address"`
+}
+
+// NewServer return the handler of the REST API backed by svc:
+//
+//	GET    /block/current                        the most recent block of the node.
+//	POST   /subscribe                            {"address":"0x..."} subscribe an address or an ENS name.
+//	GET    /transactions/{address}               transactions of a subscribed address, ?order=asc|desc, 404 if it isn't.
+//	GET    /v1/block/current                     the most recent block of the node.
+//	POST   /v1/subscriptions                     {"address":"0x..."} subscribe an address or an ENS name.
+//	GET    /v1/subscriptions                     subscriptions with their metadata, ?prefix=, and the ENS names.
+//	DELETE /v1/subscriptions/{address}           unsubscribe an address, 404 if it isn't subscribed.
+//	GET    /v1/addresses/{address}/transactions  page of transactions, ?direction=&cursor=&limit=.
+//	GET    /v1/addresses/{address}/events        server-sent events of new transactions, see StreamEvents.
+//
+// every response is a JSON envelope, a malformed address is rejected with 400 and an unreachable node
+// answered with 503, see errorStatus. each request is
+// logged along with its X-Request-Id, generated when the client sets none.
+func NewServer(svc *service.ETHService) http.Handler {
+	s := &Server{svc: svc}
+	engine := gin.New()
+	engine.Use(logRequests, gin.Recovery())
+	engine.NoRoute(func(c *gin.Context) {
+		writeError(c, http.StatusNotFound, errors.New("not found"))
+	})
+	engine.GET("/healthz", s.Healthz)
+	engine.GET("/block/current", s.GetCurrentBlock)
+	engine.POST("/subscribe", s.Subscribe)
+	engine.GET("/transactions/:address", s.GetTransactions)
+
+	v1 := engine.Group("/v1")
+	v1.GET("/block/current", s.GetCurrentBlock)
+	v1.POST("/subscriptions", s.CreateSubscription)
+	v1.GET("/subscriptions", s.ListSubscriptions)
+	v1.DELETE("/subscriptions/:address", s.DeleteSubscription)
+	v1.GET("/addresses/:address/transactions", s.ListTransactions)
+	v1.GET("/addresses/:address/events", s.StreamEvents)
+	return engine
+}
+
+// lazyServer Server of a service started on first use, see NewLazyServer.
+type lazyServer struct {
+	start   func() (*service.ETHService, error)
+	mutex   sync.Mutex
+	handler http.Handler // nil until start succeeds.
+}
+
+// NewLazyServer return the handler of NewServer backed by the service start returns, such as
+// service.ETHServiceInstance. start is called again on each request until it succeeds, meanwhile
+// requests are answered with 503 so that an unreachable node doesn't keep the process from serving.
+func NewLazyServer(start func() (*service.ETHService, error)) http.Handler {
+	return &lazyServer{start: start}
+}
+
+func (l *lazyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mutex.Lock()
+	if l.handler == nil {
+		svc, err := l.start()
+		if err != nil {
+			l.mutex.Unlock()
+			logger.Error(r.Context(), "[lazyServer]: Error start service", "err", err)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(&handler.ErrResp{
+				Code:   model.RESPONSE_FAILD,
+				Msg:    "service unavailable",
+				Detail: err.Error(),
+			})
+			return
+		}
+		l.handler = NewServer(svc)
+	}
+	h := l.handler
+	l.mutex.Unlock()
+	h.ServeHTTP(w, r)
+}
+
+// requestIDHeader header carrying the id of a request.
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// RequestID id of the request ctx belongs to, empty outside of a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logRequests keep the request id in the request context and echo it back, then log the outcome.
+func logRequests(c *gin.Context) {
+	start := time.Now()
+	id := c.GetHeader(requestIDHeader)
+	if len(id) == 0 {
+		raw := make([]byte, 8)
+		rand.Read(raw)
+		id = hex.EncodeToString(raw)
+	}
+	c.Header(requestIDHeader, id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+	c.Next()
+	logger.Info(c.Request.Context(), "[logRequests]: request", "method", c.Request.Method, "path", c.Request.URL.Path,
+		"status", c.Writer.Status(), "latency", time.Since(start), "request_id", id)
+}
+
+// GetCurrentBlock get the most recent block.
+func (s *Server) GetCurrentBlock(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	blockInfo, err := s.svc.GetCurrentBlock(ctx)
+	if err != nil {
+		logger.Error(ctx, "[GetCurrentBlock]: Error GetCurrentBlock", "err", err)
+		writeError(c, errorStatus(err, http.StatusBadGateway), err)
+		return
+	}
+	writeData(c, http.StatusOK, blockInfo)
+}
+
+// Healthz health of the service for load balancers, 503 if the node is unreachable or parsing lags.
+func (s *Server) Healthz(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	status, err := s.svc.Health(ctx)
+	if err != nil || !status.Healthy {
+		c.PureJSON(http.StatusServiceUnavailable, &handler.DataResp{Code: model.RESPONSE_FAILD, Data: status})
+		return
+	}
+	writeData(c, http.StatusOK, status)
+}
+
+// Subscribe subscribe the address in the JSON body.
+func (s *Server) Subscribe(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	req := &SubscribeRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		logger.Warn(ctx, "[Subscribe]: Error parse body", "err", err)
+		writeError(c, http.StatusBadRequest, errors.New("parse body err"))
+		return
+	}
+	address, err := subscribeAddress(req.Address)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.svc.Subscribe(ctx, address); err != nil {
+		logger.Error(ctx, "[Subscribe]: Error Subscribe", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeData(c, http.StatusOK, map[string]interface{}{})
+}
+
+// GetTransactions list of inbound or outbound transactions for the address in path.
+func (s *Server) GetTransactions(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	address, err := util.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	order := model.Order(c.DefaultQuery("order", string(model.OrderAscending)))
+	if order != model.OrderAscending && order != model.OrderDescending {
+		writeError(c, http.StatusBadRequest, errors.New("order should be asc or desc"))
+		return
+	}
+	transactions, err := s.svc.GetTransactionsInOrder(ctx, address, order)
+	if err != nil {
+		logger.Error(ctx, "[GetTransactions]: Error GetTransactionsInOrder", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeData(c, http.StatusOK, map[string]interface{}{
+		"transactions": transactions,
+	})
+}
+
+// subscribeAddress address in lowercase, or as is if it is an ENS name, which the service resolves.
+func subscribeAddress(address string) (string, error) {
+	if util.IsENSName(address) {
+		return strings.ToLower(address), nil
+	}
+	return util.NormalizeAddress(address)
+}
+
+// writeData write data in the same envelope as the gin handlers.
+func writeData(c *gin.Context, status int, data interface{}) {
+	c.PureJSON(status, &handler.DataResp{
+		Code: model.RESPONSE_OK,
+		Data: data,
+	})
+}
+
+func writeError(c *gin.Context, status int, err error) {
+	c.PureJSON(status, &handler.ErrResp{
+		Code:   model.RESPONSE_FAILD,
+		Msg:    err.Error(),
+		Detail: err.Error(),
+	})
+}
+
+// errorStatus http status of err returned by the service, fallback if it isn't one of the typed errors.
+func errorStatus(err error, fallback int) int {
+	var rpcErr *remote.RPCError
+	switch {
+	case errors.Is(err, service.ErrInvalidAddress), errors.Is(err, store.ErrInvalidCursor):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotSubscribed), errors.Is(err, service.ErrBlockNotFound),
+		errors.Is(err, service.ErrENSNotResolved):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrRPCUnavailable), errors.Is(err, service.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &rpcErr), errors.Is(err, remote.ErrMalformedResponse):
+		return http.StatusBadGateway
+	}
+	return fallback
+}
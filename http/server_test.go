@@ -0,0 +1,232 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+	"github.com/tj/assert"
+)
+
+// fakeETHClient chain at block 1 with a single transaction.
+type fakeETHClient struct{}
+
+func (fakeETHClient) ETHBlockDecimalNumber(ctx context.Context) (int64, error) { return 1, nil }
+func (fakeETHClient) EthBlockNumber(ctx context.Context) (string, error)       { return "0x1", nil }
+func (fakeETHClient) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	if number != "0x1" {
+		return nil, errors.New("unknown block")
+	}
+	return &model.ETHBlockInfo{
+		Number: "0x1",
+		Hash:   "0xh1",
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0xt1", BlockNumber: "0x1", From: "0x00000000000000000000000000000000000000AA", To: "0x00000000000000000000000000000000000000bb"},
+		},
+	}, nil
+}
+func (fakeETHClient) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	return []*model.ETHTransactionReceipt{}, nil
+}
+
+func newTestServer(t *testing.T) (*service.ETHService, http.Handler) {
+	conf := service.DefaultConfig()
+	conf.Confirmations = 0
+	svc, err := service.NewETHService(fakeETHClient{}, service.WithConfig(conf))
+	assert.Nil(t, err)
+	return svc, NewServer(svc)
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestServer_GetCurrentBlock(t *testing.T) {
+	_, h := newTestServer(t)
+	w := serve(h, http.MethodGet, "/block/current", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+	resp := struct {
+		Data *model.ETHBlockInfo `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "0xh1", resp.Data.Hash)
+}
+
+func TestServer_SubscribeAndGetTransactions(t *testing.T) {
+	ctx := context.Background()
+	svc, h := newTestServer(t)
+	address := "0x00000000000000000000000000000000000000AA"
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, "/subscribe", `{"address":"0x123"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, "/subscribe", `not json`).Code)
+	// mixed case with a wrong EIP-55 checksum.
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, "/subscribe", `{"address":"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}`).Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodPost, "/subscribe", `{"address":"`+address+`"}`).Code)
+	assert.Equal(t, []string{strings.ToLower(address)}, svc.ListSubscriptions(ctx))
+	assert.Nil(t, svc.ParseTransactions(ctx, 1))
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodGet, "/transactions/0xzz", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodGet, "/transactions/0x00000000000000000000000000000000000000cc", "").Code)
+	w := serve(h, http.MethodGet, "/transactions/"+address, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := struct {
+		Data struct {
+			Transactions []*model.ETHTransaction `json:"transactions"`
+		} `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, len(resp.Data.Transactions))
+	assert.Equal(t, "0xt1", resp.Data.Transactions[0].Hash)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/transactions/"+address+"?order=desc", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodGet, "/transactions/"+address+"?order=up", "").Code)
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, errorStatus(fmt.Errorf("%w: 0x123", service.ErrInvalidAddress), 500))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(store.ErrInvalidCursor, 500))
+	assert.Equal(t, http.StatusNotFound, errorStatus(service.ErrNotSubscribed, 500))
+	assert.Equal(t, http.StatusNotFound, errorStatus(fmt.Errorf("%w: 0x10", service.ErrBlockNotFound), 500))
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(&remote.HTTPStatusError{StatusCode: 429}, 500))
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(service.ErrClosed, 500))
+	assert.Equal(t, http.StatusBadGateway, errorStatus(&remote.RPCError{Code: -32000, Message: "header not found"}, 500))
+	assert.Equal(t, http.StatusInternalServerError, errorStatus(errors.New("disk full"), 500))
+}
+
+func TestServer_V1Subscriptions(t *testing.T) {
+	ctx := context.Background()
+	svc, h := newTestServer(t)
+	address := "0x00000000000000000000000000000000000000AA"
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, "/v1/subscriptions", `{"address":"0x123"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(h, http.MethodPost, "/v1/subscriptions", `{"address":"`+address+`"}`).Code)
+	w := serve(h, http.MethodPost, "/v1/subscriptions", `{"address":"`+strings.ToLower(address)+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	created := struct {
+		Data struct {
+			Address string `json:"address"`
+		} `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, util.ChecksumAddress(address), created.Data.Address)
+
+	w = serve(h, http.MethodGet, "/v1/subscriptions", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := struct {
+		Data struct {
+			Subscriptions []model.Subscription `json:"subscriptions"`
+		} `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, len(resp.Data.Subscriptions))
+	assert.Equal(t, util.ChecksumAddress(address), resp.Data.Subscriptions[0].Address)
+	assert.NotNil(t, resp.Data.Subscriptions[0].SubscribedAt)
+	w = serve(h, http.MethodGet, "/v1/subscriptions?prefix=0x00000000000000000000000000000000000000B", "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 0, len(resp.Data.Subscriptions))
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodDelete, "/v1/subscriptions/0xzz", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodDelete, "/v1/subscriptions/0x00000000000000000000000000000000000000bb", "").Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodDelete, "/v1/subscriptions/"+address, "").Code)
+	assert.Equal(t, []string{}, svc.ListSubscriptions(ctx))
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodDelete, "/v1/subscriptions/"+address, "").Code)
+}
+
+func TestServer_V1Transactions(t *testing.T) {
+	ctx := context.Background()
+	svc, h := newTestServer(t)
+	address := "0x00000000000000000000000000000000000000aa"
+	assert.Nil(t, svc.Subscribe(ctx, address))
+	assert.Nil(t, svc.ParseTransactions(ctx, 1))
+
+	type page struct {
+		Data struct {
+			Transactions []*model.ETHTransaction `json:"transactions"`
+			NextCursor   string                  `json:"next_cursor"`
+		} `json:"data"`
+	}
+	get := func(query string) (int, page) {
+		w := serve(h, http.MethodGet, "/v1/addresses/"+address+"/transactions"+query, "")
+		resp := page{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+	code, resp := get("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, len(resp.Data.Transactions))
+	assert.Equal(t, "", resp.Data.NextCursor)
+	code, resp = get("?direction=in")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, len(resp.Data.Transactions))
+	code, resp = get("?direction=out&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "0xt1", resp.Data.Transactions[0].Hash)
+
+	for _, query := range []string{"?direction=sideways", "?limit=0", "?limit=x", "?limit=1001", "?cursor=garbage"} {
+		code, _ = get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodGet, "/v1/addresses/0xzz/transactions", "").Code)
+}
+
+func TestServer_V1Envelope(t *testing.T) {
+	_, h := newTestServer(t)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/v1/block/current", "").Code)
+
+	w := serve(h, http.MethodGet, "/v1/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	resp := struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "not found", resp.Msg)
+
+	// a generated request id is echoed back, the client's one is kept.
+	assert.Equal(t, 16, len(w.Header().Get("X-Request-Id")))
+	r := httptest.NewRequest(http.MethodGet, "/v1/subscriptions", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-Id"))
+}
+
+func TestServer_Healthz(t *testing.T) {
+	_, h := newTestServer(t)
+	w := serve(h, http.MethodGet, "/healthz", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := struct {
+		Data service.HealthStatus `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Data.Healthy)
+	assert.Equal(t, int64(1), resp.Data.ChainHead)
+}
+
+func TestNewLazyServer(t *testing.T) {
+	svc, _ := newTestServer(t)
+	starts := 0
+	h := NewLazyServer(func() (*service.ETHService, error) {
+		starts++
+		if starts == 1 {
+			return nil, errors.New("node unreachable")
+		}
+		return svc, nil
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, serve(h, http.MethodGet, "/block/current", "").Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/block/current", "").Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/block/current", "").Code)
+	assert.Equal(t, 2, starts)
+}
@@ -0,0 +1,236 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/service"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+const (
+	// defaultPageLimit transactions per page when the limit query is not set.
+	defaultPageLimit = 100
+	// maxPageLimit at most transactions per page.
+	maxPageLimit = 1000
+)
+
+// CreateSubscription subscribe the address in the JSON body, 201 unless it was already subscribed. the
+// address is echoed in its EIP-55 form whatever its case in the body, along with the name if it was an ENS
+// name.
+func (s *Server) CreateSubscription(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	req := &SubscribeRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		logger.Warn(ctx, "[CreateSubscription]: Error parse body", "err", err)
+		writeError(c, http.StatusBadRequest, errors.New("parse body err"))
+		return
+	}
+	address, err := subscribeAddress(req.Address)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	added, err := s.svc.SubscribeIfNew(ctx, address)
+	if err != nil {
+		logger.Error(ctx, "[CreateSubscription]: Error SubscribeIfNew", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	data := map[string]interface{}{}
+	if util.IsENSName(address) {
+		data["name"] = address
+		// empty if the name was unsubscribed meanwhile.
+		address = s.svc.ENSNames(ctx)[address]
+	}
+	if len(address) > 0 {
+		data["address"] = util.ChecksumAddress(address)
+	}
+	writeData(c, status, data)
+}
+
+// DeleteSubscription unsubscribe the address in path, purge=true drops its collected transactions.
+func (s *Server) DeleteSubscription(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	address, err := util.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !s.svc.IsSubscribed(ctx, address) {
+		writeError(c, http.StatusNotFound, service.ErrNotSubscribed)
+		return
+	}
+	if err := s.svc.Unsubscribe(ctx, address, c.Query("purge") == "true"); err != nil {
+		logger.Error(ctx, "[DeleteSubscription]: Error Unsubscribe", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeData(c, http.StatusOK, map[string]interface{}{})
+}
+
+// ListSubscriptions subscriptions in sorted order of address, with their webhook, ENS names and stored
+// transactions count, and the address of each ENS name subscribed by name, addresses in their EIP-55 form.
+// query: prefix lists the addresses starting with it only.
+func (s *Server) ListSubscriptions(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	subscriptions, err := s.svc.ListSubscriptionsWithPrefix(ctx, c.Query("prefix"))
+	if err != nil {
+		logger.Error(ctx, "[ListSubscriptions]: Error ListSubscriptionsWithPrefix", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Address = util.ChecksumAddress(subscriptions[i].Address)
+	}
+	names := s.svc.ENSNames(ctx)
+	for name, address := range names {
+		names[name] = util.ChecksumAddress(address)
+	}
+	writeData(c, http.StatusOK, map[string]interface{}{
+		"subscriptions": subscriptions,
+		"names":         names,
+	})
+}
+
+// ListTransactions page of transactions for the address in path. query: direction in or out,
+// method a selector or a well-known name such as transfer, exclude_failed=true leaves the reverted
+// transactions out, cursor the next_cursor of the previous page, limit defaultPageLimit by default.
+func (s *Server) ListTransactions(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	address, err := util.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	direction := model.Direction(c.Query("direction"))
+	if direction != "" && direction != model.DirectionInbound && direction != model.DirectionOutbound {
+		writeError(c, http.StatusBadRequest, errors.New("direction should be in or out"))
+		return
+	}
+	limit := defaultPageLimit
+	if raw := c.Query("limit"); len(raw) > 0 {
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 || limit > maxPageLimit {
+			writeError(c, http.StatusBadRequest, errors.New("limit should be between 1 and "+strconv.Itoa(maxPageLimit)))
+			return
+		}
+	}
+	filter := model.TxFilter{Direction: direction, Method: c.Query("method"), ExcludeFailed: c.Query("exclude_failed") == "true"}
+	transactions, next, err := s.svc.FilterTransactionsPage(ctx, address, filter, c.Query("cursor"), limit)
+	if err != nil {
+		logger.Error(ctx, "[ListTransactions]: Error FilterTransactionsPage", "err", err)
+		writeError(c, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeData(c, http.StatusOK, map[string]interface{}{
+		"transactions": transactions,
+		"next_cursor":  next,
+	})
+}
+
+// sseHeartbeat how often a comment is sent on an idle event stream.
+var sseHeartbeat = 15 * time.Second
+
+// StreamEvents stream the transactions of the address in path as server-sent events, one "transaction"
+// event per transaction with id blockNumber-txIndex in decimal. a client reconnecting with Last-Event-ID
+// is replayed the stored transactions after that id first, then the live ones.
+func (s *Server) StreamEvents(c *gin.Context) {
+	ctx := util.RPCContext(c)
+	address, err := util.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, err)
+		return
+	}
+	var last store.Position
+	resume := c.GetHeader("Last-Event-ID")
+	if len(resume) > 0 {
+		if _, err := fmt.Sscanf(resume, "%d-%d", &last.Block, &last.Index); err != nil {
+			writeError(c, http.StatusBadRequest, errors.New("Last-Event-ID should be blockNumber-txIndex"))
+			return
+		}
+	}
+	// subscribe before replaying, nothing stored meanwhile is missed.
+	live, err := s.svc.SubscribeChan(ctx, address)
+	if err != nil {
+		writeError(c, http.StatusNotFound, err)
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	if len(resume) > 0 {
+		cursor := store.EncodeCursor(last)
+		for {
+			// live events aren't held back until confirmed, neither is the replay.
+			transactions, next, err := s.svc.GetStoredTransactionsPage(ctx, address, cursor, maxPageLimit)
+			if err != nil {
+				logger.Error(ctx, "[StreamEvents]: Error GetStoredTransactionsPage", "err", err)
+				return
+			}
+			for _, tx := range transactions {
+				if !writeEvent(c, tx) {
+					return
+				}
+				last = store.PositionOf(tx)
+			}
+			if len(next) == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tx, ok := <-live:
+			if !ok {
+				return
+			}
+			// already replayed.
+			if len(resume) > 0 && !store.PositionOf(tx).After(last) {
+				continue
+			}
+			if !writeEvent(c, tx) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeEvent write tx as a server-sent event, false if the client is gone.
+func writeEvent(c *gin.Context, tx *model.ETHTransaction) bool {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		logger.Error(c.Request.Context(), "[writeEvent]: Error Marshal", "err", err)
+		return true
+	}
+	pos := store.PositionOf(tx)
+	if _, err := fmt.Fprintf(c.Writer, "id: %d-%d\nevent: transaction\ndata: %s\n\n", pos.Block, pos.Index, data); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
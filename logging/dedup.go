@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// dedupMaxLines lines Dedup remembers, the ones seen longest ago are forgotten past it.
+const dedupMaxLines = 1024
+
+// Dedup Logger passing a line to logger only if the same one, level, message and keyvals alike, wasn't
+// passed within window, such as the error of a node down logged on every poll. the first line after window
+// carries how many were dropped meanwhile under the key "repeated".
+func Dedup(logger Logger, window time.Duration) Logger {
+	return &dedupLogger{logger: logger, window: window, now: time.Now, lines: map[string]*dedupLine{}}
+}
+
+type dedupLogger struct {
+	logger Logger
+	window time.Duration
+	now    func() time.Time
+	mutex  sync.Mutex
+	lines  map[string]*dedupLine // by the format of the line.
+}
+
+// dedupLine when a line was last passed, and how many times it was dropped since.
+type dedupLine struct {
+	passed  time.Time
+	dropped int
+}
+
+func (d *dedupLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	if keyvals, ok := d.pass("DEBUG", msg, keyvals); ok {
+		d.logger.Debug(ctx, msg, keyvals...)
+	}
+}
+
+func (d *dedupLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	if keyvals, ok := d.pass("INFO", msg, keyvals); ok {
+		d.logger.Info(ctx, msg, keyvals...)
+	}
+}
+
+func (d *dedupLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	if keyvals, ok := d.pass("WARN", msg, keyvals); ok {
+		d.logger.Warn(ctx, msg, keyvals...)
+	}
+}
+
+func (d *dedupLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	if keyvals, ok := d.pass("ERROR", msg, keyvals); ok {
+		d.logger.Error(ctx, msg, keyvals...)
+	}
+}
+
+// pass whether the line is logged, with its keyvals followed by the count of the lines dropped before it.
+func (d *dedupLogger) pass(level, msg string, keyvals []interface{}) ([]interface{}, bool) {
+	key := format(level, msg, keyvals)
+	now := d.now()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	line, ok := d.lines[key]
+	if ok && now.Sub(line.passed) < d.window {
+		line.dropped++
+		return nil, false
+	}
+	if !ok {
+		d.forget(now)
+		line = &dedupLine{}
+		d.lines[key] = line
+	}
+	dropped := line.dropped
+	line.passed, line.dropped = now, 0
+	if dropped == 0 {
+		return keyvals, true
+	}
+	all := append(make([]interface{}, 0, len(keyvals)+3), keyvals...)
+	if len(all)%2 == 1 {
+		all = append(all[:len(all)-1], "extra", keyvals[len(keyvals)-1])
+	}
+	return append(all, "repeated", dropped), true
+}
+
+// forget the lines passed longer than window ago once dedupMaxLines are remembered, then the oldest ones
+// if it is still full. the caller holds mutex.
+func (d *dedupLogger) forget(now time.Time) {
+	if len(d.lines) < dedupMaxLines {
+		return
+	}
+	var oldestKey string
+	var oldest time.Time
+	for key, line := range d.lines {
+		if now.Sub(line.passed) >= d.window {
+			delete(d.lines, key)
+		} else if len(oldestKey) == 0 || line.passed.Before(oldest) {
+			oldestKey, oldest = key, line.passed
+		}
+	}
+	if len(d.lines) >= dedupMaxLines {
+		delete(d.lines, oldestKey)
+	}
+}
@@ -0,0 +1,94 @@
+// Package logging leveled logging with key-value fields, injectable into the services and clients.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Logger leveled logger, keyvals alternate keys and values such as "block", 12, "err", err.
+// implementations may read request scoped values from ctx.
+type Logger interface {
+	Debug(ctx context.Context, msg string, keyvals ...interface{})
+	Info(ctx context.Context, msg string, keyvals ...interface{})
+	Warn(ctx context.Context, msg string, keyvals ...interface{})
+	Error(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+// format the line of msg at level, a value without key is logged under the key "extra".
+func format(level, msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := "extra", keyvals[i]
+		if i+1 < len(keyvals) {
+			key, value = fmt.Sprint(keyvals[i]), keyvals[i+1]
+		}
+		if s, ok := value.(fmt.Stringer); ok && s != nil {
+			value = s.String()
+		}
+		if err, ok := value.(error); ok && err != nil {
+			value = err.Error()
+		}
+		if str, ok := value.(string); ok && (len(str) == 0 || strings.ContainsAny(str, " \"=")) {
+			value = fmt.Sprintf("%q", str)
+		}
+		fmt.Fprintf(&b, " %s=%v", key, value)
+	}
+	return b.String()
+}
+
+// Nop Logger discarding every line, such as in tests.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {}
+func (nopLogger) Info(ctx context.Context, msg string, keyvals ...interface{})  {}
+func (nopLogger) Warn(ctx context.Context, msg string, keyvals ...interface{})  {}
+func (nopLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {}
+
+// With Logger adding keyvals to every line of logger, after the keyvals of the line.
+func With(logger Logger, keyvals ...interface{}) Logger {
+	if w, ok := logger.(withLogger); ok {
+		return withLogger{logger: w.logger, keyvals: append(append([]interface{}(nil), w.keyvals...), keyvals...)}
+	}
+	return withLogger{logger: logger, keyvals: keyvals}
+}
+
+type withLogger struct {
+	logger  Logger
+	keyvals []interface{}
+}
+
+func (w withLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	w.logger.Debug(ctx, msg, w.with(keyvals)...)
+}
+
+func (w withLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	w.logger.Info(ctx, msg, w.with(keyvals)...)
+}
+
+func (w withLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	w.logger.Warn(ctx, msg, w.with(keyvals)...)
+}
+
+func (w withLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	w.logger.Error(ctx, msg, w.with(keyvals)...)
+}
+
+// with keyvals followed by the ones of w, a value without key in keyvals gets one so that the keys of w
+// stay paired.
+func (w withLogger) with(keyvals []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(keyvals)+1+len(w.keyvals))
+	all = append(all, keyvals...)
+	if len(keyvals)%2 == 1 {
+		all = append(all[:len(all)-1], "extra", keyvals[len(keyvals)-1])
+	}
+	return append(all, w.keyvals...)
+}
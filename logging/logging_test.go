@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, `ERROR [load]: Error EthBlockNumber err="connection refused" block=12`,
+		format("ERROR", "[load]: Error EthBlockNumber", []interface{}{"err", errors.New("connection refused"), "block", 12}))
+	assert.Equal(t, `INFO [poll]: retry delay=1.5s extra=3`, format("INFO", "[poll]: retry", []interface{}{"delay", 1500 * time.Millisecond, 3}))
+	assert.Equal(t, `DEBUG [x]: y address=""`, format("DEBUG", "[x]: y", []interface{}{"address", ""}))
+}
+
+// lines Logger keeping the lines it logs.
+type lines []string
+
+func (l *lines) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	*l = append(*l, format("DEBUG", msg, keyvals))
+}
+
+func (l *lines) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	*l = append(*l, format("INFO", msg, keyvals))
+}
+
+func (l *lines) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	*l = append(*l, format("WARN", msg, keyvals))
+}
+
+func (l *lines) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	*l = append(*l, format("ERROR", msg, keyvals))
+}
+
+func TestWith(t *testing.T) {
+	ctx := context.Background()
+	logged := &lines{}
+	logger := With(logged, "chain_id", 1)
+	logger.Error(ctx, "[load]: Error", "block", 12)
+	logger.Info(ctx, "[load]: odd", 3)
+	With(logger, "chain", "mainnet").Debug(ctx, "[load]: done")
+	assert.Equal(t, []string{
+		"ERROR [load]: Error block=12 chain_id=1",
+		"INFO [load]: odd extra=3 chain_id=1",
+		"DEBUG [load]: done chain_id=1 chain=mainnet",
+	}, []string(*logged))
+}
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	logged := &lines{}
+	now := time.Unix(0, 0)
+	logger := Dedup(logged, time.Minute)
+	logger.(*dedupLogger).now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		logger.Error(ctx, "[poll]: Error load", "err", "connection refused")
+		logger.Warn(ctx, "[poll]: Error load", "err", "connection refused")
+		now = now.Add(time.Second)
+	}
+	logger.Error(ctx, "[poll]: Error load", "err", "timeout")
+	now = now.Add(time.Minute)
+	logger.Error(ctx, "[poll]: Error load", "err", "connection refused")
+	logger.Error(ctx, "[poll]: Error load", "err", "timeout")
+	assert.Equal(t, []string{
+		`ERROR [poll]: Error load err="connection refused"`,
+		`WARN [poll]: Error load err="connection refused"`,
+		`ERROR [poll]: Error load err=timeout`,
+		`ERROR [poll]: Error load err="connection refused" repeated=2`,
+		`ERROR [poll]: Error load err=timeout`,
+	}, []string(*logged))
+}
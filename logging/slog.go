@@ -0,0 +1,61 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Std Logger of the default slog.Logger at the time of each line, so that a handler installed by
+// slog.SetDefault, such as a JSON one or a bridge to zap, gets the lines of the loggers created before.
+// the default handler writes lines like `INFO [loadTo]: block processed block=12` to the standard logger
+// of package log, and drops Debug.
+func Std() Logger {
+	return slogLogger{}
+}
+
+// Slog Logger of l, the default slog.Logger at the time of each line if l is nil. ctx is handed to the
+// handler, keyvals become attributes, a value without key goes under the key "extra".
+func Slog(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	s.log(ctx, slog.LevelDebug, msg, keyvals)
+}
+
+func (s slogLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	s.log(ctx, slog.LevelInfo, msg, keyvals)
+}
+
+func (s slogLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	s.log(ctx, slog.LevelWarn, msg, keyvals)
+}
+
+func (s slogLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	s.log(ctx, slog.LevelError, msg, keyvals)
+}
+
+func (s slogLogger) log(ctx context.Context, level slog.Level, msg string, keyvals []interface{}) {
+	l := s.l
+	if l == nil {
+		l = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	if len(keyvals)%2 == 1 {
+		// slog would log it under !BADKEY.
+		keyvals = append(append(make([]interface{}, 0, len(keyvals)+1), keyvals[:len(keyvals)-1]...),
+			"extra", keyvals[len(keyvals)-1])
+	}
+	l.Log(ctx, level, msg, keyvals...)
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestSlog(t *testing.T) {
+	ctx := context.Background()
+	var b bytes.Buffer
+	handler := slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := With(Slog(slog.New(handler)), "chain_id", 1)
+	logger.Info(ctx, "[loadTo]: block processed", "block", 12)
+	logger.Warn(ctx, "[postWithRetry]: retry", "attempt", 2, 3)
+	logger.Error(ctx, "[load]: Error EthBlockNumber", "err", errors.New("connection refused"))
+	assert.Equal(t, `level=WARN msg="[postWithRetry]: retry" attempt=2 extra=3 chain_id=1
+level=ERROR msg="[load]: Error EthBlockNumber" err="connection refused" chain_id=1
+`, b.String())
+}
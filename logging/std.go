@@ -0,0 +1,32 @@
+//go:build !go1.21
+
+package logging
+
+import (
+	"context"
+	"log"
+)
+
+// Std Logger writing lines like `ERROR [load]: Error EthBlockNumber err="connection refused"` to the
+// standard logger of package log. built with go1.21 or later, it logs through log/slog instead, see Slog.
+func Std() Logger {
+	return stdLogger{}
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	log.Println(format("DEBUG", msg, keyvals))
+}
+
+func (stdLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	log.Println(format("INFO", msg, keyvals))
+}
+
+func (stdLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	log.Println(format("WARN", msg, keyvals))
+}
+
+func (stdLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	log.Println(format("ERROR", msg, keyvals))
+}
@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sugarshop/env"
 	"github.com/sugarshop/token-gateway/handler"
+	gwhttp "github.com/sugarshop/token-gateway/http"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/metrics"
 	"github.com/sugarshop/token-gateway/mw"
 	"github.com/sugarshop/token-gateway/remote"
 	"github.com/sugarshop/token-gateway/service"
 )
 
+// logger of the process.
+var logger = logging.Std()
+
 func main() {
 	// start config
 	var conf string
@@ -26,6 +34,8 @@ func main() {
 
 	engine := gin.New()
 	engine.Use(mw.ParseFormMiddleware)
+	// Prometheus metrics of block processing and JSON-RPC calls.
+	engine.GET("/metrics", gin.WrapH(metrics.Handler()))
 	engine.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "pong",
@@ -35,13 +45,40 @@ func main() {
 	Init()
 	// register other api
 	handler.Register(engine)
+	// the REST API of the other chains is served under /chains/<chain>.
+	// a service failing to start answers 503 until it is started on a later request.
+	for _, chain := range service.Chains() {
+		chain := chain
+		prefix := "/chains/" + chain
+		start := func() (*service.ETHService, error) { return service.ChainService(chain) }
+		engine.Any(prefix+"/*path", gin.WrapH(http.StripPrefix(prefix, gwhttp.NewLazyServer(start))))
+	}
+	// the REST API serves whatever the api above doesn't.
+	engine.NoRoute(gin.WrapH(gwhttp.NewLazyServer(service.ETHServiceInstance)))
 
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
 	// listen and serve on 0.0.0.0:8080
+	addr := ":8080"
+	if port := os.Getenv("PORT"); len(port) > 0 {
+		addr = ":" + port
+	}
+	srv := &http.Server{Addr: addr, Handler: engine}
 	go func() {
-		engine.Run()
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error(context.Background(), "[main]: Error ListenAndServe", "err", err)
+			os.Exit(1)
+		}
 	}()
+	for _, extra := range extraServers {
+		extra := extra
+		go func() {
+			if err := extra.ListenAndServe(); err != nil {
+				logger.Error(context.Background(), "[main]: Error ListenAndServe", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
@@ -51,9 +88,41 @@ func main() {
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// finish the requests in flight, then stop parsing.
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error(ctx, "[main]: Error Shutdown server", "err", err)
+	}
+	for _, extra := range extraServers {
+		if err := extra.Shutdown(ctx); err != nil {
+			logger.Error(ctx, "[main]: Error Shutdown server", "err", err)
+		}
+	}
+	if err := service.Stop(ctx); err != nil {
+		logger.Error(ctx, "[main]: Error Stop service", "err", err)
+	}
 }
 
-func Init()  {
-	remote.Init()
-	service.Init()
+// extraServer server run along with the REST API until shutdown, such as the gRPC API.
+type extraServer interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+// extraServers registered by the files built with an optional tag, see main_grpc.go.
+var extraServers []extraServer
+
+func Init() {
+	ctx := context.Background()
+	if err := remote.Init(); err != nil {
+		// a setting missing, no node to serve.
+		logger.Error(ctx, "[main]: Error Init remote", "err", err)
+		os.Exit(1)
+	}
+	// keep serving if the node is unreachable, services are started again on their next use.
+	if err := service.Init(); err != nil {
+		logger.Error(ctx, "[main]: Error Init service", "err", err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+//go:build grpc
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+
+	gwgrpc "github.com/sugarshop/token-gateway/grpc"
+	"github.com/sugarshop/token-gateway/service"
+	"google.golang.org/grpc"
+)
+
+// the gRPC API is served on GRPC_ADDR, such as :9090, when built with the grpc tag. no address, no server.
+func init() {
+	extraServers = append(extraServers, &grpcServer{srv: gwgrpc.NewLazyServer(service.ETHServiceInstance)})
+}
+
+type grpcServer struct {
+	srv *grpc.Server
+}
+
+func (g *grpcServer) ListenAndServe() error {
+	addr := os.Getenv("GRPC_ADDR")
+	if len(addr) == 0 {
+		return nil
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return g.srv.Serve(lis)
+}
+
+// Shutdown wait for the calls in flight until ctx is done, then cancel them, streams included.
+func (g *grpcServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		g.srv.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,297 @@
+// Package metrics counters, gauges and histograms exposed in the Prometheus text format, see Handler.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets default buckets of a Histogram, in seconds, the same as the Prometheus client's.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry metrics written together, see Default.
+type Registry struct {
+	mutex    sync.Mutex
+	families []*family
+}
+
+// NewRegistry return an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default Registry of the metrics of the gateway.
+var Default = NewRegistry()
+
+// Handler serve the metrics of Default, to be mounted at /metrics.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// family metrics of a name, one series per combination of label values.
+type family struct {
+	name       string
+	help       string
+	kind       string // counter, gauge or histogram.
+	labelNames []string
+	buckets    []float64 // upper bounds of a histogram, increasing.
+
+	mutex  sync.Mutex
+	series map[string]*series // by joined label values.
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter or gauge value, sum of a histogram.
+	count       uint64   // observations of a histogram.
+	counts      []uint64 // observations of a histogram per bucket, not cumulative.
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, known := range r.families {
+		if known.name == f.name {
+			panic("metrics: " + f.name + " registered twice")
+		}
+	}
+	f.series = map[string]*series{}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get the series of labelValues, created on first use.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// find the series of labelValues, nil if none was recorded yet.
+func (f *family) find(labelValues []string) *series {
+	return f.series[strings.Join(labelValues, "\xff")]
+}
+
+// Counter value only going up, such as the blocks processed.
+type Counter struct {
+	f *family
+}
+
+// NewCounter register a counter named name, with a value per combination of labelNames values.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, kind: "counter", labelNames: labelNames})}
+}
+
+// Inc add 1 to the counter of labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add add v to the counter of labelValues, a negative v is ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.mutex.Lock()
+	defer c.f.mutex.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Value current value of the counter of labelValues.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.f.mutex.Lock()
+	defer c.f.mutex.Unlock()
+	if s := c.f.find(labelValues); s != nil {
+		return s.value
+	}
+	return 0
+}
+
+// Gauge value going up and down, such as the lag behind chain head.
+type Gauge struct {
+	f *family
+}
+
+// NewGauge register a gauge named name, with a value per combination of labelNames values.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, kind: "gauge", labelNames: labelNames})}
+}
+
+// Set set the gauge of labelValues to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mutex.Lock()
+	defer g.f.mutex.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+// Value current value of the gauge of labelValues.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.f.mutex.Lock()
+	defer g.f.mutex.Unlock()
+	if s := g.f.find(labelValues); s != nil {
+		return s.value
+	}
+	return 0
+}
+
+// Histogram distribution of observations, such as latencies, counted in buckets.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram register a histogram named name counting observations in buckets, upper bounds in
+// increasing order, DefBuckets if nil.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " aren't sorted")
+	}
+	return &Histogram{r.register(&family{name: name, help: help, kind: "histogram", labelNames: labelNames,
+		buckets: append([]float64(nil), buckets...)})}
+}
+
+// Observe count v in the histogram of labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mutex.Lock()
+	defer h.f.mutex.Unlock()
+	s := h.f.get(labelValues)
+	s.value += v
+	s.count++
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+}
+
+// Count observations in the histogram of labelValues.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.f.mutex.Lock()
+	defer h.f.mutex.Unlock()
+	if s := h.f.find(labelValues); s != nil {
+		return s.count
+	}
+	return 0
+}
+
+// Handler serve the metrics of r in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// WriteTo write the metrics of r in the Prometheus text format, families in registration order and series
+// sorted by label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mutex.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.writeTo(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (f *family) writeTo(w *countingWriter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.series) == 0 {
+		return
+	}
+	w.printf("# HELP %s %s\n", f.name, escape(f.help, false))
+	w.printf("# TYPE %s %s\n", f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			w.printf("%s%s %s\n", f.name, labels(f.labelNames, s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		cumulative := uint64(0)
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			w.printf("%s_bucket%s %d\n", f.name, labels(f.labelNames, s.labelValues, formatFloat(bound)), cumulative)
+		}
+		w.printf("%s_bucket%s %d\n", f.name, labels(f.labelNames, s.labelValues, "+Inf"), s.count)
+		w.printf("%s_sum%s %s\n", f.name, labels(f.labelNames, s.labelValues, ""), formatFloat(s.value))
+		w.printf("%s_count%s %d\n", f.name, labels(f.labelNames, s.labelValues, ""), s.count)
+	}
+}
+
+// labels {name="value",...} of a series, with the le label of a histogram bucket if le isn't empty.
+func labels(names, values []string, le string) string {
+	if len(names) == 0 && len(le) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escape(values[i], true)+`"`)
+	}
+	if len(le) > 0 {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escape backslashes and newlines, and double quotes in a label value.
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter keep the first write error and the bytes written.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
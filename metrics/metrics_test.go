@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	blocks := r.NewCounter("blocks_total", "Blocks processed.", "chain")
+	lag := r.NewGauge("lag", "Blocks behind head.")
+	latency := r.NewHistogram("parse_seconds", "Parse latency.", []float64{0.1, 1}, "chain")
+	r.NewCounter("unused_total", "Never incremented.")
+
+	blocks.Inc("polygon")
+	blocks.Add(2, "default")
+	blocks.Add(-1, "default")
+	lag.Set(3)
+	latency.Observe(0.05, "default")
+	latency.Observe(0.5, "default")
+	latency.Observe(5, "default")
+	errors := r.NewCounter("errors_total", "Errors.", "method")
+	errors.Inc(`say "hi"\`)
+
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+	assert.Equal(t, `# HELP blocks_total Blocks processed.
+# TYPE blocks_total counter
+blocks_total{chain="default"} 2
+blocks_total{chain="polygon"} 1
+# HELP lag Blocks behind head.
+# TYPE lag gauge
+lag 3
+# HELP parse_seconds Parse latency.
+# TYPE parse_seconds histogram
+parse_seconds_bucket{chain="default",le="0.1"} 1
+parse_seconds_bucket{chain="default",le="1"} 2
+parse_seconds_bucket{chain="default",le="+Inf"} 3
+parse_seconds_sum{chain="default"} 5.55
+parse_seconds_count{chain="default"} 3
+# HELP errors_total Errors.
+# TYPE errors_total counter
+errors_total{method="say \"hi\"\\"} 1
+`, b.String())
+	assert.Equal(t, 2.0, blocks.Value("default"))
+	assert.Equal(t, 0.0, blocks.Value("mainnet"))
+	assert.Equal(t, uint64(3), latency.Count("default"))
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("calls_total", "Calls.").Inc()
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Contains(t, w.Body.String(), "calls_total 1\n")
+
+	assert.Panics(t, func() { r.NewGauge("calls_total", "Again.") })
+}
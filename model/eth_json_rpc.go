@@ -1,5 +1,11 @@
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // JSONRPCRequest represents the structure of the JSON-RPC request
 type JSONRPCRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
@@ -8,11 +14,88 @@ type JSONRPCRequest struct {
 	ID      int           `json:"id"`
 }
 
+// JSONRPCError error object of a failed JSON-RPC request
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// JSONRPCResponse response of any JSON-RPC request, result left to decode
+type JSONRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *JSONRPCError   `json:"error"`
+}
+
+// ETHCallResponse response of the eth_call request, result is the hex encoded return data
+type ETHCallResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  string        `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHSubscribeResponse response of the eth_subscribe request, result is the subscription id
+type ETHSubscribeResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  string        `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHSubscriptionNotification notification pushed by a subscription, result depends on what is subscribed
+type ETHSubscriptionNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// ETHGetTransactionByHashResponse response of the eth_getTransactionByHash request, result is null for an unknown transaction
+type ETHGetTransactionByHashResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  *ETHTransaction `json:"result"`
+	Error   *JSONRPCError   `json:"error"`
+}
+
+// ETHBlockHeader block header, without transactions
+type ETHBlockHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
+
 // ETHBlockNumberResponse response of the ethBlockNumber request
 type ETHBlockNumberResponse struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      int    `json:"id"`
-	Result  string `json:"result"`
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  string        `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHTransactionCountResponse response of the eth_getTransactionCount request, result is the hex encoded nonce.
+type ETHTransactionCountResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  string        `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHChainIDResponse response of the eth_chainId request, result is the hex encoded chain id.
+type ETHChainIDResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  string        `json:"result"`
+	Error   *JSONRPCError `json:"error"`
 }
 
 // ETHGetBlockByNumberResponse response of the eth_getBlockByNumber request
@@ -20,6 +103,87 @@ type ETHGetBlockByNumberResponse struct {
 	JSONRPC string `json:"jsonrpc"`
 	ID      int    `json:"id"`
 	Result  *ETHBlockInfo `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHGetBlockReceiptsResponse response of the eth_getBlockReceipts request
+type ETHGetBlockReceiptsResponse struct {
+	JSONRPC string                   `json:"jsonrpc"`
+	ID      int                      `json:"id"`
+	Result  []*ETHTransactionReceipt `json:"result"`
+	Error   *JSONRPCError            `json:"error"`
+}
+
+// ETHGetLogsResponse response of the eth_getLogs request.
+type ETHGetLogsResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  []*ETHLog     `json:"result"`
+	Error   *JSONRPCError `json:"error"`
+}
+
+// ETHGetTransactionReceiptResponse response of the eth_getTransactionReceipt request, result is null until the transaction is mined
+type ETHGetTransactionReceiptResponse struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      int                    `json:"id"`
+	Result  *ETHTransactionReceipt `json:"result"`
+	Error   *JSONRPCError          `json:"error"`
+}
+
+// ETHTraceBlockResponse response of the debug_traceBlockByNumber request with the callTracer, a trace per transaction in block order
+type ETHTraceBlockResponse struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      int                    `json:"id"`
+	Result  []*ETHTransactionTrace `json:"result"`
+	Error   *JSONRPCError          `json:"error"`
+}
+
+// ETHTransactionTrace call tree of a transaction, txHash is missing on older nodes
+type ETHTransactionTrace struct {
+	TxHash string        `json:"txHash"`
+	Result *ETHCallFrame `json:"result"`
+}
+
+// ETHCallFrame call of the callTracer, the root one is the transaction itself
+type ETHCallFrame struct {
+	Type  string          `json:"type"` // CALL, DELEGATECALL, STATICCALL, CREATE, CREATE2, SELFDESTRUCT...
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Value string          `json:"value"`
+	Input string          `json:"input"`
+	Error string          `json:"error"` // set if the call reverted, along with its subcalls.
+	Calls []*ETHCallFrame `json:"calls"`
+}
+
+// ETHTransactionReceipt receipt of a mined transaction.
+type ETHTransactionReceipt struct {
+	BlockHash         string    `json:"blockHash"`
+	BlockNumber       string    `json:"blockNumber"`
+	ContractAddress   string    `json:"contractAddress"`
+	CumulativeGasUsed string    `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string    `json:"effectiveGasPrice"`
+	From              string    `json:"from"`
+	GasUsed           string    `json:"gasUsed"`
+	Logs              []*ETHLog `json:"logs"`
+	LogsBloom         string    `json:"logsBloom"`
+	Status            string    `json:"status"`
+	To                string    `json:"to"`
+	TransactionHash   string    `json:"transactionHash"`
+	TransactionIndex  string    `json:"transactionIndex"`
+	Type              string    `json:"type"`
+}
+
+// ETHLog event log emitted by a transaction.
+type ETHLog struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
 }
 
 type ETHTransaction struct {
@@ -38,11 +202,45 @@ type ETHTransaction struct {
 	Value                string   `json:"value"`
 	Type                 string   `json:"type"`
 	AccessList           []interface{} `json:"accessList"`
+	MaxFeePerBlobGas     string   `json:"maxFeePerBlobGas,omitempty"`    // blob transactions only.
+	BlobVersionedHashes  []string `json:"blobVersionedHashes,omitempty"` // blob transactions only.
 	ChainID              string   `json:"chainId"`
 	V                    string   `json:"v"`
 	YParity              string   `json:"yParity"`
 	R                    string   `json:"r"`
 	S                    string   `json:"s"`
+
+	// fields below are filled by gateway for a subscribed address, not by the node.
+	Direction Direction `json:"direction,omitempty"`
+	State     TxState   `json:"state,omitempty"`   // TxStatePending until the transaction is mined.
+	Status    TxStatus  `json:"status,omitempty"`  // from the receipt, empty if unknown.
+	GasUsed   string    `json:"gasUsed,omitempty"` // from the receipt.
+	// EffectiveGasPrice wei paid per unit of gas, from the receipt.
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	// ContractAddress address of the contract the transaction created, from the receipt, empty for a call.
+	ContractAddress string `json:"contractAddress,omitempty"`
+	// Timestamp time the block of the transaction was mined, from the block, nil while pending.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Internal value moved by a call of a contract within transaction Hash, From and To being the
+	// caller and callee, rather than by the transaction itself.
+	Internal bool `json:"internal,omitempty"`
+	// TraceID identifier of an internal transfer, Hash then the index of the call at each depth of the
+	// call tree, such as 0xabc:0.1 for the 2nd call made by the 1st call of the transaction.
+	TraceID string `json:"traceId,omitempty"`
+	// MethodID selector of the called function, see MethodSelector, empty for a plain transfer.
+	MethodID string `json:"methodId,omitempty"`
+	// MethodName name of MethodID when it is well-known, see MethodName.
+	MethodName string `json:"methodName,omitempty"`
+	// Confirmations blocks parsed on top of the transaction's block when it was queried.
+	Confirmations int64 `json:"confirmations"`
+	// BlockNum BlockNumber parsed, 0 while pending. transactions are ordered by BlockNum then TxIndex.
+	BlockNum int64 `json:"blockNum,omitempty"`
+	// TxIndex TransactionIndex parsed.
+	TxIndex int64 `json:"txIndex,omitempty"`
+	// Quantities value, gas and fee quantities parsed, nil for a transaction stored before they were.
+	Quantities *Quantities `json:"quantities,omitempty"`
+	// TypeName name of Type, see TxType.
+	TypeName string `json:"typeName,omitempty"`
 }
 
 type ETHBlockInfo struct {
@@ -0,0 +1,32 @@
+package model
+
+import "strings"
+
+// methodNames function names of well-known selectors, ERC-20, ERC-721, ERC-1155 and WETH.
+var methodNames = map[string]string{
+	"0xa9059cbb": "transfer",              // transfer(address,uint256)
+	"0x095ea7b3": "approve",               // approve(address,uint256)
+	"0x23b872dd": "transferFrom",          // transferFrom(address,address,uint256)
+	"0x42842e0e": "safeTransferFrom",      // safeTransferFrom(address,address,uint256)
+	"0xb88d4fde": "safeTransferFrom",      // safeTransferFrom(address,address,uint256,bytes)
+	"0xf242432a": "safeTransferFrom",      // safeTransferFrom(address,address,uint256,uint256,bytes)
+	"0x2eb2c2d6": "safeBatchTransferFrom", // safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)
+	"0xa22cb465": "setApprovalForAll",     // setApprovalForAll(address,bool)
+	"0xd0e30db0": "deposit",               // deposit()
+	"0x2e1a7d4d": "withdraw",              // withdraw(uint256)
+}
+
+// MethodSelector first 4 bytes of a transaction input, in lowercase hex with 0x prefix. empty for a plain
+// transfer, whose input is empty or 0x, and for an input too short to hold a selector.
+func MethodSelector(input string) string {
+	input = strings.TrimPrefix(strings.ToLower(input), "0x")
+	if len(input) < 8 {
+		return ""
+	}
+	return "0x" + input[:8]
+}
+
+// MethodName function name of a well-known selector, empty if unknown.
+func MethodName(selector string) string {
+	return methodNames[strings.ToLower(selector)]
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"math/big"
+	"time"
+)
+
+// Subscription a subscribed address, along with what is attached to it and a summary of its stored transactions.
+type Subscription struct {
+	Address string `json:"address"`
+	// SubscribedAt when the address was subscribed, nil if it wasn't through this process, such as a
+	// subscription restored from storage after a restart or made by another instance sharing it.
+	SubscribedAt *time.Time `json:"subscribedAt,omitempty"`
+	Label        string     `json:"label,omitempty"`
+	Webhook      string     `json:"webhook,omitempty"`  // callback URL the transactions are posted to, empty if none.
+	ENSNames     []string   `json:"ensNames,omitempty"` // ENS names subscribed for the address, in sorted order.
+	// TransactionCount stored transactions, the ones still waiting for confirmations included.
+	TransactionCount int `json:"transactionCount"`
+	// LastMatchBlock block of the most recent stored transaction, 0 if none.
+	LastMatchBlock int64 `json:"lastMatchBlock"`
+}
+
+// SubscriptionOptions how the transactions of a subscribed address are handled, zero value stores every
+// transaction of the address.
+type SubscriptionOptions struct {
+	// Direction DirectionInbound or DirectionOutbound to store the transfers in that direction only, empty for
+	// both. a self transfer meets both. applies to transactions, internal, token and NFT transfers.
+	Direction Direction `json:"direction,omitempty"`
+	// MinValue transactions and internal transfers moving less wei are ignored, nil for the service wide
+	// minimum. token amounts aren't compared, their unit depends on the token.
+	MinValue *big.Int `json:"minValue,omitempty"`
+	// StartBlock backfill the history of the address from this block, 0 for none.
+	StartBlock int64 `json:"startBlock,omitempty"`
+	// Label human readable name of the subscription.
+	Label string `json:"label,omitempty"`
+	// WebhookURL callback URL the transactions of the address are posted to, empty for the service wide one.
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
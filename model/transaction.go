@@ -0,0 +1,212 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// Direction direction of a transaction relative to the subscribed address.
+type Direction string
+
+const (
+	DirectionInbound  Direction = "in"   // transfer to the address.
+	DirectionOutbound Direction = "out"  // transfer from the address.
+	DirectionSelf     Direction = "self" // transfer from the address to itself, counts as both inbound and outbound.
+)
+
+// TxStatus execution result of a transaction, from its receipt.
+type TxStatus string
+
+const (
+	TxStatusSuccess TxStatus = "success"
+	TxStatusFailed  TxStatus = "failed" // reverted, the transaction is mined but has no effect besides gas.
+)
+
+// TxState whether a transaction is mined yet.
+type TxState string
+
+const (
+	TxStatePending   TxState = "pending"   // seen in the mempool, not mined yet.
+	TxStateConfirmed TxState = "confirmed" // mined.
+	TxStateDropped   TxState = "dropped"   // pending for longer than the horizon, likely replaced or evicted by the node.
+)
+
+// Order order of queried transactions.
+type Order string
+
+const (
+	OrderAscending  Order = "asc"  // chain order, oldest first.
+	OrderDescending Order = "desc" // most recent first.
+)
+
+// TxFilter conditions of the transactions to query, zero value matches every transaction.
+type TxFilter struct {
+	// Direction DirectionInbound or DirectionOutbound, empty for both.
+	Direction Direction
+	// Status TxStatusSuccess or TxStatusFailed, empty for any. a transaction without known status matches none.
+	Status TxStatus
+	// ExcludeFailed leave out the failed transactions, the ones without known status are kept.
+	ExcludeFailed bool
+	// Method selector of the called function, like 0xa9059cbb, or its well-known name, like transfer.
+	// empty for any, plain transfers match none else.
+	Method string
+	// State TxStatePending, TxStateConfirmed or TxStateDropped, empty for any.
+	State TxState
+}
+
+// Match whether tx meets the filter.
+func (f TxFilter) Match(tx *ETHTransaction) bool {
+	if !f.MatchDirection(tx.Direction) {
+		return false
+	}
+	if len(f.Status) > 0 && tx.Status != f.Status {
+		return false
+	}
+	if f.ExcludeFailed && tx.Status == TxStatusFailed {
+		return false
+	}
+	if len(f.Method) > 0 && !strings.EqualFold(f.Method, tx.MethodID) && f.Method != tx.MethodName {
+		return false
+	}
+	// transactions stored before states existed are mined ones.
+	if len(f.State) > 0 && f.State != tx.State && (f.State != TxStateConfirmed || len(tx.State) > 0) {
+		return false
+	}
+	return true
+}
+
+// MatchDirection whether a transfer in direction meets the filter's Direction, a self transfer meets both.
+func (f TxFilter) MatchDirection(direction Direction) bool {
+	return len(f.Direction) == 0 || direction == f.Direction || direction == DirectionSelf
+}
+
+// TxType EIP-2718 type of a transaction, deciding which fee fields it has.
+type TxType int64
+
+const (
+	TxTypeLegacy     TxType = 0 // GasPrice only.
+	TxTypeAccessList TxType = 1 // EIP-2930, GasPrice and AccessList.
+	TxTypeDynamicFee TxType = 2 // EIP-1559, MaxFeePerGas and MaxPriorityFeePerGas instead of GasPrice.
+	TxTypeBlob       TxType = 3 // EIP-4844, a dynamic fee transaction carrying blobs.
+)
+
+// String name of t, such as "dynamicFee", or its number for a type unknown yet.
+func (t TxType) String() string {
+	switch t {
+	case TxTypeLegacy:
+		return "legacy"
+	case TxTypeAccessList:
+		return "accessList"
+	case TxTypeDynamicFee:
+		return "dynamicFee"
+	case TxTypeBlob:
+		return "blob"
+	}
+	return fmt.Sprintf("0x%x", int64(t))
+}
+
+// TxType type of tx, legacy if it has none, as before EIP-2718.
+func (tx *ETHTransaction) TxType() TxType {
+	t, err := util.HexToInt64(tx.Type)
+	if err != nil {
+		return TxTypeLegacy
+	}
+	return TxType(t)
+}
+
+// Clone copy of tx sharing nothing with it, a stored transaction is handed out as a clone so that
+// callers modifying it leave the stored one untouched. the items of AccessList are decoded JSON,
+// which is never modified in place.
+func (tx *ETHTransaction) Clone() *ETHTransaction {
+	clone := *tx
+	if tx.AccessList != nil {
+		clone.AccessList = append([]interface{}{}, tx.AccessList...)
+	}
+	if tx.BlobVersionedHashes != nil {
+		clone.BlobVersionedHashes = append([]string{}, tx.BlobVersionedHashes...)
+	}
+	if tx.Timestamp != nil {
+		timestamp := *tx.Timestamp
+		clone.Timestamp = &timestamp
+	}
+	if tx.Quantities != nil {
+		clone.Quantities = tx.Quantities.clone()
+	}
+	return &clone
+}
+
+// TransferEventTopic keccak256 of Transfer(address,address,uint256), topic0 of ERC-20 transfer logs.
+const TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// TokenTransfer ERC-20 transfer of a subscribed address, decoded from a Transfer log.
+type TokenTransfer struct {
+	Contract         string    `json:"contract"` // token contract address.
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	Amount           string    `json:"amount"` // raw uint256 amount in decimal, not scaled by token decimals.
+	TransactionHash  string    `json:"transactionHash"`
+	TransactionIndex string    `json:"transactionIndex"`
+	LogIndex         string    `json:"logIndex"`
+	BlockNumber      string    `json:"blockNumber"`
+	BlockHash        string    `json:"blockHash"`
+	Direction        Direction `json:"direction"`
+	// Symbol, Decimals and FormattedAmount, Amount scaled by Decimals, come from the TokenMetadata of
+	// Contract, they are left empty while it isn't resolved or when the token doesn't tell them.
+	Symbol          string `json:"symbol,omitempty"`
+	Decimals        *int   `json:"decimals,omitempty"`
+	FormattedAmount string `json:"formattedAmount,omitempty"`
+}
+
+// TokenMetadata ERC-20 metadata of a token contract, read with eth_call. a field the contract doesn't
+// implement, or reverts on, is left empty.
+type TokenMetadata struct {
+	Contract string `json:"contract"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+}
+
+// TransferSingleEventTopic keccak256 of TransferSingle(address,address,address,uint256,uint256), ERC-1155.
+const TransferSingleEventTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+
+// TransferBatchEventTopic keccak256 of TransferBatch(address,address,address,uint256[],uint256[]), ERC-1155.
+const TransferBatchEventTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+
+// NFTStandard token standard of an NFTTransfer.
+type NFTStandard string
+
+const (
+	NFTStandardERC721  NFTStandard = "erc721"
+	NFTStandardERC1155 NFTStandard = "erc1155"
+)
+
+// NFTTransfer ERC-721 or ERC-1155 transfer of a subscribed address, decoded from a Transfer,
+// TransferSingle or TransferBatch log. a TransferBatch is kept whole, with parallel TokenIDs and Amounts.
+type NFTTransfer struct {
+	Standard         NFTStandard `json:"standard"`
+	Contract         string      `json:"contract"`           // token contract address.
+	Operator         string      `json:"operator,omitempty"` // ERC-1155 only, the address which moved the tokens.
+	From             string      `json:"from"`
+	To               string      `json:"to"`
+	TokenIDs         []string    `json:"tokenIds"` // uint256 in decimal.
+	Amounts          []string    `json:"amounts"`  // uint256 in decimal, the amount of each of TokenIDs, 1 for ERC-721.
+	Mint             bool        `json:"mint"`     // From is the zero address.
+	Burn             bool        `json:"burn"`     // To is the zero address.
+	TransactionHash  string      `json:"transactionHash"`
+	TransactionIndex string      `json:"transactionIndex"`
+	LogIndex         string      `json:"logIndex"`
+	BlockNumber      string      `json:"blockNumber"`
+	BlockHash        string      `json:"blockHash"`
+	Direction        Direction   `json:"direction"`
+}
+
+// BackfillStatus progress of scanning the history of a subscribed address.
+type BackfillStatus struct {
+	FromBlock    int64  `json:"from_block"`
+	ToBlock      int64  `json:"to_block"`      // the last block parsed when the subscription started, live parsing covers the later ones.
+	ScannedBlock int64  `json:"scanned_block"` // the last block scanned, FromBlock-1 before the first one.
+	Done         bool   `json:"done"`
+	Error        string `json:"error,omitempty"` // why the backfill stopped before ToBlock.
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// etherDecimals wei per ether is 10^etherDecimals.
+const etherDecimals = 18
+
+var weiPerEther = new(big.Int).Exp(big.NewInt(10), big.NewInt(etherDecimals), nil)
+
+// ValueWei value of tx in wei, 0 if it is missing or malformed.
+func (tx *ETHTransaction) ValueWei() *big.Int {
+	if tx == nil || len(tx.Value) == 0 || tx.Value == "0x" {
+		return new(big.Int)
+	}
+	wei, err := util.HexToBigInt(tx.Value)
+	if err != nil {
+		return new(big.Int)
+	}
+	return wei
+}
+
+// ValueEther value of tx in ether, precise enough for Text('f', 18) to print every wei, see
+// ValueEtherString for the exact decimal.
+func (tx *ETHTransaction) ValueEther() *big.Float {
+	wei := tx.ValueWei()
+	// one wei is still a significant bit of the quotient.
+	prec := uint(wei.BitLen() + 64)
+	ether := new(big.Float).SetPrec(prec).SetInt(wei)
+	return ether.Quo(ether, new(big.Float).SetPrec(prec).SetInt(weiPerEther))
+}
+
+// ValueEtherString value of tx in ether as an exact decimal without trailing zeros, such as "1.5" or "0".
+func (tx *ETHTransaction) ValueEtherString() string {
+	return FormatEther(tx.ValueWei())
+}
+
+// FormatEther wei in ether as an exact decimal without trailing zeros.
+func FormatEther(wei *big.Int) string {
+	quo, rem := new(big.Int).QuoRem(wei, weiPerEther, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo.String()
+	}
+	frac := rem.String()
+	frac = strings.Repeat("0", etherDecimals-len(frac)) + frac
+	return quo.String() + "." + strings.TrimRight(frac, "0")
+}
+
+// blobGasPerBlob gas of a blob, the blob gas of a transaction is blobGasPerBlob per versioned hash.
+const blobGasPerBlob = 1 << 17
+
+// MaxFee the most wei tx may pay in fees: its gas limit at MaxFeePerGas, or GasPrice if it has none, plus
+// the blob gas of its blobs at MaxFeePerBlobGas. the fee actually paid is GasUsed at EffectiveGasPrice, plus
+// the blob fee. missing or malformed quantities count as 0.
+func (tx *ETHTransaction) MaxFee() *big.Int {
+	price := tx.MaxFeePerGas
+	if len(price) == 0 {
+		price = tx.GasPrice
+	}
+	fee := new(big.Int).Mul(hexOrZero(tx.Gas), hexOrZero(price))
+	if len(tx.BlobVersionedHashes) > 0 {
+		blobGas := big.NewInt(int64(len(tx.BlobVersionedHashes)) * blobGasPerBlob)
+		fee.Add(fee, blobGas.Mul(blobGas, hexOrZero(tx.MaxFeePerBlobGas)))
+	}
+	return fee
+}
+
+// hexOrZero the hex quantity, 0 if it is missing or malformed.
+func hexOrZero(hex string) *big.Int {
+	v, err := util.HexToBigInt(hex)
+	if err != nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+// Decimal 256-bit quantity, a decimal string in JSON such as "1500000000000000000", so that clients
+// without big numbers don't lose precision.
+type Decimal struct {
+	big.Int
+}
+
+// ParseDecimal Decimal of the hex quantity, nil if it is missing or malformed.
+func ParseDecimal(hex string) *Decimal {
+	v, err := util.HexToBigInt(hex)
+	if err != nil {
+		return nil
+	}
+	return &Decimal{Int: *v}
+}
+
+func (d *Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if _, ok := d.SetString(s, 10); !ok {
+		return fmt.Errorf("invalid decimal %q", s)
+	}
+	return nil
+}
+
+// Quantities hex quantities of a transaction parsed, nil when the node returned none, such as the gas
+// of an internal transfer or the fee caps of a legacy transaction.
+type Quantities struct {
+	Value                *Decimal `json:"value,omitempty"`
+	Gas                  *Decimal `json:"gas,omitempty"`
+	GasPrice             *Decimal `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *Decimal `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *Decimal `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas     *Decimal `json:"maxFeePerBlobGas,omitempty"`
+	GasUsed              *Decimal `json:"gasUsed,omitempty"`           // from the receipt.
+	EffectiveGasPrice    *Decimal `json:"effectiveGasPrice,omitempty"` // from the receipt.
+}
+
+// ParseQuantities Quantities of tx.
+func ParseQuantities(tx *ETHTransaction) *Quantities {
+	return &Quantities{
+		Value:                ParseDecimal(tx.Value),
+		Gas:                  ParseDecimal(tx.Gas),
+		GasPrice:             ParseDecimal(tx.GasPrice),
+		MaxFeePerGas:         ParseDecimal(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: ParseDecimal(tx.MaxPriorityFeePerGas),
+		MaxFeePerBlobGas:     ParseDecimal(tx.MaxFeePerBlobGas),
+		GasUsed:              ParseDecimal(tx.GasUsed),
+		EffectiveGasPrice:    ParseDecimal(tx.EffectiveGasPrice),
+	}
+}
+
+// clone copy of q sharing nothing with it.
+func (q *Quantities) clone() *Quantities {
+	return &Quantities{
+		Value:                q.Value.clone(),
+		Gas:                  q.Gas.clone(),
+		GasPrice:             q.GasPrice.clone(),
+		MaxFeePerGas:         q.MaxFeePerGas.clone(),
+		MaxPriorityFeePerGas: q.MaxPriorityFeePerGas.clone(),
+		MaxFeePerBlobGas:     q.MaxFeePerBlobGas.clone(),
+		GasUsed:              q.GasUsed.clone(),
+		EffectiveGasPrice:    q.EffectiveGasPrice.clone(),
+	}
+}
+
+// clone copy of d, nil if d is.
+func (d *Decimal) clone() *Decimal {
+	if d == nil {
+		return nil
+	}
+	c := &Decimal{}
+	c.Set(&d.Int)
+	return c
+}
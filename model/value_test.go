@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestETHTransaction_ValueEther(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		wei   string
+		ether string
+	}{
+		{"0xde0b6b3a7640000", "1000000000000000000", "1"},
+		{"0x14d1120d7b160000", "1500000000000000000", "1.5"},
+		{"0x1", "1", "0.000000000000000001"},
+		// more than float64 holds, every wei is kept.
+		{"0xa364c98227eaa6adcbae1", "12345678901234567890123489", "12345678.901234567890123489"},
+		{"0x0", "0", "0"},
+		{"0x", "0", "0"},
+		{"", "0", "0"},
+		{"bad", "0", "0"},
+	} {
+		tx := &ETHTransaction{Value: c.value}
+		assert.Equal(t, c.wei, tx.ValueWei().String(), c.value)
+		assert.Equal(t, c.ether, tx.ValueEtherString(), c.value)
+		want, _ := new(big.Float).SetPrec(200).SetString(c.ether)
+		assert.Equal(t, want.Text('f', 18), tx.ValueEther().Text('f', 18), c.value)
+	}
+	var tx *ETHTransaction
+	assert.Equal(t, "0", tx.ValueEtherString())
+}
+
+func TestDecimal(t *testing.T) {
+	assert.Nil(t, ParseDecimal(""))
+	assert.Nil(t, ParseDecimal("0x"))
+	assert.Nil(t, ParseDecimal("12"))
+	d := ParseDecimal("0xa364c98227eaa6adcbae1")
+	data, err := json.Marshal(d)
+	assert.Nil(t, err)
+	assert.Equal(t, `"12345678901234567890123489"`, string(data))
+
+	var decoded Decimal
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 0, decoded.Cmp(&d.Int))
+	assert.NotNil(t, json.Unmarshal([]byte(`"0x10"`), &decoded))
+	assert.NotNil(t, json.Unmarshal([]byte(`16`), &decoded))
+}
+
+func TestETHTransaction_MaxFee(t *testing.T) {
+	assert.Equal(t, "0", (&ETHTransaction{}).MaxFee().String())
+	assert.Equal(t, "0", (&ETHTransaction{Gas: "0x5208", GasPrice: "bad"}).MaxFee().String())
+	// maxFeePerGas wins over the gasPrice the node reports for a mined dynamic fee transaction.
+	tx := &ETHTransaction{Type: "0x2", Gas: "0x2", GasPrice: "0x3", MaxFeePerGas: "0x5"}
+	assert.Equal(t, "10", tx.MaxFee().String())
+	assert.Equal(t, TxTypeDynamicFee, tx.TxType())
+	assert.Equal(t, TxTypeLegacy, (&ETHTransaction{}).TxType())
+	assert.Equal(t, "0x7e", (&ETHTransaction{Type: "0x7e"}).TxType().String())
+}
@@ -2,10 +2,10 @@ package mw
 
 import (
 	"io"
-	"log"
 
 	"bytes"
 	"github.com/gin-gonic/gin"
+	"github.com/sugarshop/token-gateway/logging"
 	"github.com/sugarshop/token-gateway/util"
 )
 
@@ -13,12 +13,12 @@ import (
 func ParseFormMiddleware(c *gin.Context) {
 	ctx := util.RPCContext(c)
 	if err := c.Request.ParseForm(); err != nil {
-		log.Println(ctx, "parse form failed ", err)
+		logging.Std().Warn(ctx, "[ParseFormMiddleware]: Error ParseForm", "err", err)
 	}
 
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		log.Println(ctx, "read request body error: ", err)
+		logging.Std().Error(ctx, "[ParseFormMiddleware]: Error read request body", "err", err)
 	}
 	// rewrite body after read it.
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
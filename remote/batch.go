@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// BatchElem a call of a JSON-RPC batch, see BatchCall.
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	// Result pointer the result is decoded into, such as a **model.ETHBlockInfo which a null result leaves nil.
+	Result interface{}
+	// Error set by BatchCall if this call failed, a *model.JSONRPCError when the node answered one.
+	Error error
+}
+
+// BatchCaller client able to send several calls in one round trip, implemented by ETHRPCService.
+type BatchCaller interface {
+	BatchCall(ctx context.Context, elems []BatchElem) error
+}
+
+var _ BatchCaller = (*ETHRPCService)(nil)
+
+// BatchCall send elems in a single JSON-RPC batch and decode each response into the Result of its element.
+// an element failing gets its Error set while the others succeed. the returned error is the failure of the
+// batch as a whole, such as the endpoint being unreachable, the elements are left untouched then.
+func (s *ETHRPCService) BatchCall(ctx context.Context, elems []BatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+	requests := make([]*model.JSONRPCRequest, 0, len(elems))
+	for i, elem := range elems {
+		requests = append(requests, &model.JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  elem.Method,
+			Params:  elem.Params,
+			ID:      i, // index in elems, matches the response whatever its position in the batch.
+		})
+	}
+	body, err := s.jsonRPCBatchPOST(ctx, requests)
+	if err != nil {
+		s.logger.Error(ctx, "[BatchCall]: Error jsonRPCBatchPOST", "err", err)
+		return err
+	}
+	var resps []*model.JSONRPCResponse
+	if err := json.Unmarshal(body, &resps); err != nil {
+		// a node not supporting batches answers with a single error object.
+		s.logger.Error(ctx, "[BatchCall]: Error Unmarshal", "err", err)
+		return malformed(err)
+	}
+	answered := make([]bool, len(elems))
+	for _, resp := range resps {
+		if resp == nil || resp.ID < 0 || resp.ID >= len(elems) || answered[resp.ID] {
+			continue
+		}
+		answered[resp.ID] = true
+		elem := &elems[resp.ID]
+		if resp.Error != nil {
+			elem.Error = resp.Error
+			continue
+		}
+		if elem.Result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, elem.Result); err != nil {
+				elem.Error = err
+			}
+		}
+	}
+	for i := range elems {
+		if !answered[i] {
+			elems[i].Error = fmt.Errorf("no response to %s in batch", elems[i].Method)
+		}
+	}
+	return nil
+}
+
+// BlockBatcher client able to fetch several blocks in one round trip, implemented by ETHRPCService.
+type BlockBatcher interface {
+	EthGetBlocksByNumber(ctx context.Context, numbers []string) ([]*model.ETHBlockInfo, error)
+}
+
+var _ BlockBatcher = (*ETHRPCService)(nil)
+
+// EthGetBlocksByNumber returns the blocks by number, in the order of numbers, with a JSON-RPC batch of
+// eth_getBlockByNumber. the entries failing or returning a null block are sent again in a smaller batch,
+// following the retry policy. a block still missing after that is nil in the result and err names it.
+func (s *ETHRPCService) EthGetBlocksByNumber(ctx context.Context, numbers []string) ([]*model.ETHBlockInfo, error) {
+	blocks := make([]*model.ETHBlockInfo, len(numbers))
+	pending := make([]int, 0, len(numbers))
+	for i, number := range numbers {
+		if blockInfo, ok := s.blocks.get(number); ok {
+			blocks[i] = blockInfo
+			continue
+		}
+		pending = append(pending, i)
+	}
+	var lastErr error
+	for attempt := 1; len(pending) > 0; attempt++ {
+		results := make([]*model.ETHBlockInfo, len(pending))
+		elems := make([]BatchElem, 0, len(pending))
+		for j, i := range pending {
+			elems = append(elems, BatchElem{Method: "eth_getBlockByNumber", Params: []interface{}{numbers[i], true}, Result: &results[j]})
+		}
+		if err := s.BatchCall(ctx, elems); err != nil {
+			s.logger.Error(ctx, "[EthGetBlocksByNumber]: Error BatchCall", "err", err)
+			return blocks, err
+		}
+		for j, i := range pending {
+			if elems[j].Error != nil {
+				lastErr = fmt.Errorf("block %s: %w", numbers[i], elems[j].Error)
+				continue
+			}
+			if results[j] != nil {
+				blocks[i] = results[j]
+				s.blocks.add(numbers[i], results[j])
+			}
+		}
+		failed := pending[:0]
+		for _, i := range pending {
+			if blocks[i] == nil {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 || attempt >= s.retry.MaxAttempts {
+			break
+		}
+		s.logger.Warn(ctx, "[EthGetBlocksByNumber]: retry missing blocks", "missing", len(pending), "blocks", len(numbers), "attempt", attempt)
+		timer := time.NewTimer(s.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return blocks, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if len(pending) > 0 {
+		missing := make([]string, 0, len(pending))
+		for _, i := range pending {
+			missing = append(missing, numbers[i])
+		}
+		if lastErr == nil {
+			lastErr = ErrBlockNotFound
+		}
+		return blocks, fmt.Errorf("blocks %v missing, last err: %w", missing, lastErr)
+	}
+	return blocks, nil
+}
+
+// EthGetBlocksByNumbers EthGetBlocksByNumber by decimal numbers.
+func (s *ETHRPCService) EthGetBlocksByNumbers(ctx context.Context, numbers []int64) ([]*model.ETHBlockInfo, error) {
+	hexNumbers := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		hexNumbers = append(hexNumbers, fmt.Sprintf("0x%x", number))
+	}
+	return s.EthGetBlocksByNumber(ctx, hexNumbers)
+}
@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthGetBlocksByNumber(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]string
+	failures := map[string]int{"0x2": 1, "0x3": 100}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []*model.JSONRPCRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&requests))
+		mutex.Lock()
+		defer mutex.Unlock()
+		numbers := make([]string, 0, len(requests))
+		resps := make([]string, 0, len(requests))
+		// answered in reverse order.
+		for i := len(requests) - 1; i >= 0; i-- {
+			number := requests[i].Params[0].(string)
+			numbers = append([]string{number}, numbers...)
+			if failures[number] > 0 {
+				failures[number]--
+				resps = append(resps, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32000,"message":"header not found"}}`, requests[i].ID))
+				continue
+			}
+			resps = append(resps, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"number":"%s"}}`, requests[i].ID, number))
+		}
+		batches = append(batches, numbers)
+		fmt.Fprintf(w, "[%s]", strings.Join(resps, ","))
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	blocks, err := s.EthGetBlocksByNumber(context.Background(), []string{"0x1", "0x2", "0x3", "0x4"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "0x3")
+	assert.Equal(t, "0x1", blocks[0].Number)
+	assert.Equal(t, "0x2", blocks[1].Number)
+	assert.Nil(t, blocks[2])
+	assert.Equal(t, "0x4", blocks[3].Number)
+	// only the failed entries are sent again.
+	assert.Equal(t, [][]string{{"0x1", "0x2", "0x3", "0x4"}, {"0x2", "0x3"}, {"0x3"}}, batches)
+
+	blocks, err = s.EthGetBlocksByNumber(context.Background(), []string{"0x5", "0x6"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0x5", blocks[0].Number)
+	assert.Equal(t, "0x6", blocks[1].Number)
+}
+
+func TestETHRPCService_BatchCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []*model.JSONRPCRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&requests))
+		assert.Equal(t, 4, len(requests))
+		// the 3rd call is left unanswered.
+		fmt.Fprintf(w, `[{"jsonrpc":"2.0","id":%d,"error":{"code":-32000,"message":"execution reverted"}},
+			{"jsonrpc":"2.0","id":%d,"result":"0x10"},{"jsonrpc":"2.0","id":%d,"result":null}]`,
+			requests[1].ID, requests[0].ID, requests[3].ID)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	var number string
+	var block *model.ETHBlockInfo
+	elems := []BatchElem{
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_call", Params: []interface{}{map[string]string{"to": "0x1"}, "latest"}},
+		{Method: "eth_chainId"},
+		{Method: "eth_getBlockByNumber", Params: []interface{}{"0x99", true}, Result: &block},
+	}
+	assert.Nil(t, s.BatchCall(context.Background(), elems))
+	assert.Nil(t, elems[0].Error)
+	assert.Equal(t, "0x10", number)
+	var rpcErr *model.JSONRPCError
+	assert.True(t, errors.As(elems[1].Error, &rpcErr))
+	assert.Equal(t, -32000, rpcErr.Code)
+	assert.NotNil(t, elems[2].Error)
+	assert.Nil(t, elems[3].Error)
+	assert.Nil(t, block)
+}
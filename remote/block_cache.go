@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// BlockCache client caching the blocks it fetched, implemented by ETHRPCService.
+type BlockCache interface {
+	// InvalidateBlocksFrom drop the cached blocks at height number and above, they may be orphaned.
+	InvalidateBlocksFrom(number int64)
+}
+
+var _ BlockCache = (*ETHRPCService)(nil)
+
+// WithBlockCache keep the size most recently fetched blocks, by number, rather than fetching them again.
+// blocks asked by tag, like latest, aren't cached. size 0 disables the cache.
+func WithBlockCache(size int) Option {
+	return func(s *ETHRPCService) {
+		s.blocks = newBlockCache(size)
+	}
+}
+
+// InvalidateBlocksFrom drop the cached blocks at height number and above, called on chain reorgs.
+func (s *ETHRPCService) InvalidateBlocksFrom(number int64) {
+	s.blocks.invalidateFrom(number)
+}
+
+type cachedBlock struct {
+	number int64
+	block  *model.ETHBlockInfo
+}
+
+// blockCache LRU of blocks by number, safe for concurrent use. the blocks are shared between callers,
+// they must not be modified. a nil blockCache caches nothing.
+type blockCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *cachedBlock, the most recently used first.
+	items map[int64]*list.Element
+}
+
+func newBlockCache(size int) *blockCache {
+	if size <= 0 {
+		return nil
+	}
+	return &blockCache{size: size, order: list.New(), items: map[int64]*list.Element{}}
+}
+
+// blockHeight height of a hex block number, false for tags.
+func blockHeight(number string) (int64, bool) {
+	if !strings.HasPrefix(number, "0x") {
+		return 0, false
+	}
+	height, err := strconv.ParseInt(number[2:], 16, 64)
+	return height, err == nil
+}
+
+func (c *blockCache) get(number string) (*model.ETHBlockInfo, bool) {
+	height, ok := blockHeight(number)
+	if c == nil || !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[height]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cachedBlock).block, true
+}
+
+func (c *blockCache) add(number string, block *model.ETHBlockInfo) {
+	height, ok := blockHeight(number)
+	if c == nil || !ok || block == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[height]; ok {
+		e.Value.(*cachedBlock).block = block
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[height] = c.order.PushFront(&cachedBlock{number: height, block: block})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cachedBlock).number)
+	}
+}
+
+func (c *blockCache) invalidateFrom(number int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for height, e := range c.items {
+		if height >= number {
+			c.order.Remove(e)
+			delete(c.items, height)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestBlockCache(t *testing.T) {
+	c := newBlockCache(2)
+	c.add("0x1", &model.ETHBlockInfo{Hash: "0xh1"})
+	c.add("0x2", &model.ETHBlockInfo{Hash: "0xh2"})
+	c.add("latest", &model.ETHBlockInfo{Hash: "0xh3"})
+	_, ok := c.get("latest")
+	assert.False(t, ok)
+
+	// 0x1 is used, 0x2 is the least recently used one.
+	block, ok := c.get("0x1")
+	assert.True(t, ok)
+	assert.Equal(t, "0xh1", block.Hash)
+	c.add("0x3", &model.ETHBlockInfo{Hash: "0xh3"})
+	_, ok = c.get("0x2")
+	assert.False(t, ok)
+	_, ok = c.get("0x3")
+	assert.True(t, ok)
+
+	c.invalidateFrom(3)
+	_, ok = c.get("0x3")
+	assert.False(t, ok)
+	_, ok = c.get("0x1")
+	assert.True(t, ok)
+
+	// disabled.
+	c = newBlockCache(0)
+	c.add("0x1", &model.ETHBlockInfo{})
+	_, ok = c.get("0x1")
+	assert.False(t, ok)
+	c.invalidateFrom(0)
+}
+
+func TestETHRPCService_BlockCache(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":84,"result":{"number":"0x5","hash":"0xh%d"}}`, n)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithBlockCache(8))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		block, err := s.EthGetBlockByNumber(ctx, "0x5")
+		assert.Nil(t, err)
+		assert.Equal(t, "0xh1", block.Hash)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// a reorg at 5 or below drops it.
+	s.InvalidateBlocksFrom(4)
+	block, err := s.EthGetBlockByNumber(ctx, "0x5")
+	assert.Nil(t, err)
+	assert.Equal(t, "0xh2", block.Hash)
+	_, err = s.EthGetBlockByNumber(ctx, "latest")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
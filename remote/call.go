@@ -0,0 +1,42 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// ContractCaller client able to call contracts without a transaction, implemented by ETHRPCService.
+type ContractCaller interface {
+	EthCall(ctx context.Context, to, data string) (string, error)
+}
+
+var _ ContractCaller = (*ETHRPCService)(nil)
+
+// EthCall returns the hex encoded return data of calling contract to with the hex encoded data, at the
+// latest block. a call the node rejects, a revert included, fails with the *model.JSONRPCError it
+// answered, which isn't retried.
+func (s *ETHRPCService) EthCall(ctx context.Context, to, data string) (string, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_call",
+		Params:  []interface{}{map[string]string{"to": to, "data": data}, "latest"},
+		ID:      86, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthCall]: Error jsonRPCPOST", "err", err)
+		return "", err
+	}
+	resp := &model.ETHCallResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthCall]: Error Unmarshal", "err", err)
+		return "", malformed(err)
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,44 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthCall(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_call", request.Method)
+		assert.Equal(t, "latest", request.Params[1])
+		call := request.Params[0].(map[string]interface{})
+		if call["data"] == "0x95d89b41" {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":86,"error":{"code":3,"message":"execution reverted"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":86,"result":"0x%064x"}`, 18)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	result, err := s.EthCall(context.Background(), "0xtoken", "0x313ce567")
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("0x%064x", 18), result)
+
+	_, err = s.EthCall(context.Background(), "0xtoken", "0x95d89b41")
+	var rpcErr *model.JSONRPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, 3, rpcErr.Code)
+	// a revert isn't retried.
+	assert.Equal(t, 2, calls)
+}
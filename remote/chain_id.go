@@ -0,0 +1,46 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// ChainIDGetter client able to tell the chain of its node, implemented by ETHRPCService.
+type ChainIDGetter interface {
+	EthChainID(ctx context.Context) (int64, error)
+}
+
+var _ ChainIDGetter = (*ETHRPCService)(nil)
+
+// EthChainID returns the EIP-155 chain id of the node, such as 1 for mainnet.
+func (s *ETHRPCService) EthChainID(ctx context.Context) (int64, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_chainId",
+		Params:  []interface{}{},
+		ID:      90, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthChainID]: Error jsonRPCPOST", "err", err)
+		return 0, err
+	}
+	resp := &model.ETHChainIDResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthChainID]: Error Unmarshal", "err", err)
+		return 0, malformed(err)
+	}
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	chainID, err := util.HexToInt64(resp.Result)
+	if err != nil {
+		s.logger.Error(ctx, "[EthChainID]: Error HexToInt64", "result", resp.Result, "err", err)
+		return 0, malformed(err)
+	}
+	return chainID, nil
+}
@@ -0,0 +1,36 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthChainID(t *testing.T) {
+	result := `"result":"0x89"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_chainId", request.Method)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":90,%s}`, result)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	chainID, err := s.EthChainID(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(137), chainID)
+
+	result = `"error":{"code":-32601,"message":"method not found"}`
+	_, err = s.EthChainID(context.Background())
+	assert.EqualError(t, err, "json-rpc error -32601: method not found")
+	result = `"result":"137"`
+	_, err = s.EthChainID(context.Background())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"sync"
+	"time"
+)
+
+// FailoverPolicy when a JSON-RPC endpoint is ejected, calls go to the next healthy endpoint meanwhile.
+// once its cooldown is over, an ejected endpoint is on probation: the next call to it is a probe, a
+// success fails back to it while a failure ejects it again right away.
+type FailoverPolicy struct {
+	// MaxFailures consecutive failures before the endpoint is ejected.
+	MaxFailures int
+	// Cooldown how long an ejected endpoint is skipped.
+	Cooldown time.Duration
+	// ErrorWindow how many of the last calls of an endpoint its error rate is computed over, 0 ejects
+	// on consecutive failures only.
+	ErrorWindow int
+	// MaxErrorRate share of failed calls among the last ErrorWindow ones above which the endpoint is
+	// ejected, even though its failures aren't consecutive.
+	MaxErrorRate float64
+}
+
+// DefaultFailoverPolicy failover policy of ETHRPCService.
+func DefaultFailoverPolicy() FailoverPolicy {
+	return FailoverPolicy{
+		MaxFailures:  3,
+		Cooldown:     30 * time.Second,
+		ErrorWindow:  20,
+		MaxErrorRate: 0.5,
+	}
+}
+
+// EndpointStatus health of a JSON-RPC endpoint.
+type EndpointStatus struct {
+	URL          string    `json:"url"` // password redacted.
+	Healthy      bool      `json:"healthy"`
+	Failures     int       `json:"failures"`                // consecutive failures.
+	ErrorRate    float64   `json:"error_rate"`              // share of failed calls among the recent ones.
+	Probing      bool      `json:"probing,omitempty"`       // cooldown over, the next call decides.
+	EjectedUntil time.Time `json:"ejected_until,omitempty"` // zero unless ejected or on probation.
+}
+
+// EndpointReporter client calling several JSON-RPC endpoints, implemented by ETHRPCService.
+type EndpointReporter interface {
+	// Endpoints health of the JSON-RPC endpoints, the primary one first.
+	Endpoints() []EndpointStatus
+}
+
+var _ EndpointReporter = (*ETHRPCService)(nil)
+
+type endpoint struct {
+	url          string
+	failures     int
+	ejectedUntil time.Time // zero unless ejected, or on probation once passed.
+	recent       []bool    // whether each of the last ErrorWindow calls failed, oldest first.
+}
+
+// errorRate share of failed calls among the recent ones.
+func (e *endpoint) errorRate() float64 {
+	if len(e.recent) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, f := range e.recent {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(e.recent))
+}
+
+// eject skip e until now + cooldown, its record starts over.
+func (e *endpoint) eject(now time.Time, cooldown time.Duration) {
+	e.failures = 0
+	e.recent = e.recent[:0]
+	e.ejectedUntil = now.Add(cooldown)
+}
+
+// endpointPool JSON-RPC endpoints in order of preference, the first one is the primary.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	policy    FailoverPolicy
+	now       func() time.Time
+}
+
+func newEndpointPool(urls []string, policy FailoverPolicy) *endpointPool {
+	p := &endpointPool{policy: policy, now: time.Now}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: url})
+	}
+	return p
+}
+
+// pick the first healthy endpoint other than exclude. exclude is still picked if it's the only healthy
+// one, and if every endpoint is ejected the one back the soonest is picked.
+func (p *endpointPool) pick(exclude *endpoint) *endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var fallback, soonest *endpoint
+	for _, e := range p.endpoints {
+		if now.Before(e.ejectedUntil) {
+			if soonest == nil || e.ejectedUntil.Before(soonest.ejectedUntil) {
+				soonest = e
+			}
+			continue
+		}
+		if e != exclude {
+			return e
+		}
+		fallback = e
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return soonest
+}
+
+// report the result of a call to e, only failures of the endpoint itself should be reported as err.
+func (p *endpointPool) report(e *endpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if !e.ejectedUntil.IsZero() && !now.Before(e.ejectedUntil) {
+		// the probe of an endpoint on probation.
+		if err != nil {
+			e.eject(now, p.policy.Cooldown)
+			return
+		}
+		e.ejectedUntil = time.Time{}
+	}
+	if p.policy.ErrorWindow > 0 {
+		if len(e.recent) >= p.policy.ErrorWindow {
+			e.recent = append(e.recent[:0], e.recent[len(e.recent)-p.policy.ErrorWindow+1:]...)
+		}
+		e.recent = append(e.recent, err != nil)
+	}
+	if err == nil {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if p.policy.MaxFailures > 0 && e.failures >= p.policy.MaxFailures {
+		e.eject(now, p.policy.Cooldown)
+		return
+	}
+	if p.policy.MaxErrorRate > 0 && len(e.recent) == p.policy.ErrorWindow && e.errorRate() > p.policy.MaxErrorRate {
+		e.eject(now, p.policy.Cooldown)
+	}
+}
+
+func (p *endpointPool) status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		statuses = append(statuses, EndpointStatus{
+			URL:          redactURL(e.url),
+			Healthy:      !now.Before(e.ejectedUntil),
+			Failures:     e.failures,
+			ErrorRate:    e.errorRate(),
+			Probing:      !e.ejectedUntil.IsZero() && !now.Before(e.ejectedUntil),
+			EjectedUntil: e.ejectedUntil,
+		})
+	}
+	return statuses
+}
@@ -0,0 +1,111 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+// errCall error of a failed call to an endpoint.
+var errCall = errors.New("call failed")
+
+func TestETHRPCService_Failover(t *testing.T) {
+	var primaryHits, fallbackHits int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	fallback := newStatusServer(&fallbackHits)
+	defer fallback.Close()
+	s := NewETHRPCService(primary.URL,
+		WithFallbackURLs(fallback.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+		WithFailoverPolicy(FailoverPolicy{MaxFailures: 2, Cooldown: time.Hour}))
+
+	// every call tries the primary first until it's ejected.
+	for i := 0; i < 3; i++ {
+		dec, err := s.ETHBlockDecimalNumber(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(16), dec)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fallbackHits))
+
+	statuses := s.Endpoints()
+	assert.Equal(t, 2, len(statuses))
+	assert.False(t, statuses[0].Healthy)
+	assert.True(t, statuses[1].Healthy)
+}
+
+func TestEndpointPool_Cooldown(t *testing.T) {
+	now := time.Unix(0, 0)
+	p := newEndpointPool([]string{"a", "b"}, FailoverPolicy{MaxFailures: 1, Cooldown: time.Minute})
+	p.now = func() time.Time { return now }
+	a := p.pick(nil)
+	assert.Equal(t, "a", a.url)
+
+	p.report(a, errCall)
+	b := p.pick(nil)
+	assert.Equal(t, "b", b.url)
+	// b is the only healthy endpoint, it's picked even when excluded.
+	assert.Equal(t, b, p.pick(b))
+
+	// every endpoint ejected, a is back first.
+	now = now.Add(10 * time.Second)
+	p.report(b, errCall)
+	assert.Equal(t, a, p.pick(nil))
+
+	now = now.Add(50 * time.Second)
+	assert.Equal(t, a, p.pick(nil))
+	assert.True(t, p.status()[0].Healthy)
+	assert.False(t, p.status()[1].Healthy)
+	p.report(a, nil)
+	assert.Equal(t, 0, p.status()[0].Failures)
+}
+
+func TestEndpointPool_ErrorRate(t *testing.T) {
+	p := newEndpointPool([]string{"a", "b"}, FailoverPolicy{MaxFailures: 3, Cooldown: time.Minute, ErrorWindow: 4,
+		MaxErrorRate: 0.5})
+	a := p.pick(nil)
+	// never 3 failures in a row, but 3 of the last 4 calls fail.
+	p.report(a, errCall)
+	p.report(a, nil)
+	p.report(a, errCall)
+	assert.Equal(t, 2.0/3, p.status()[0].ErrorRate)
+	assert.Equal(t, a, p.pick(nil))
+	p.report(a, errCall)
+	assert.Equal(t, "b", p.pick(nil).url)
+	assert.False(t, p.status()[0].Healthy)
+	assert.Equal(t, 0.0, p.status()[0].ErrorRate)
+}
+
+func TestEndpointPool_Probe(t *testing.T) {
+	now := time.Unix(0, 0)
+	p := newEndpointPool([]string{"a", "b"}, FailoverPolicy{MaxFailures: 2, Cooldown: time.Minute})
+	p.now = func() time.Time { return now }
+	a := p.pick(nil)
+	p.report(a, errCall)
+	p.report(a, errCall)
+	assert.Equal(t, "b", p.pick(nil).url)
+
+	// cooldown over, a single failed probe ejects the primary again.
+	now = now.Add(time.Minute)
+	assert.Equal(t, a, p.pick(nil))
+	assert.True(t, p.status()[0].Probing)
+	p.report(a, errCall)
+	assert.Equal(t, "b", p.pick(nil).url)
+	assert.False(t, p.status()[0].Probing)
+
+	// a successful probe fails back to the primary.
+	now = now.Add(time.Minute)
+	p.report(a, nil)
+	assert.Equal(t, a, p.pick(nil))
+	assert.Equal(t, EndpointStatus{URL: "a", Healthy: true}, p.status()[0])
+}
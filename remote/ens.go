@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ENSRegistry address of the ENS registry, the same on mainnet and its testnets.
+const ENSRegistry = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+
+const (
+	// resolverSelector selector of resolver(bytes32) of the registry.
+	resolverSelector = "0x0178b8bf"
+	// addrSelector selector of addr(bytes32) of a resolver.
+	addrSelector = "0x3b3b57de"
+	// zeroAddress what the registry and resolvers answer for a name they don't know.
+	zeroAddress = "0x0000000000000000000000000000000000000000"
+)
+
+// ErrENSNotResolved the ENS name has no resolver, or its resolver no address.
+var ErrENSNotResolved = errors.New("ens name not resolved")
+
+// ENSResolver client able to resolve ENS names, implemented by ETHRPCService.
+type ENSResolver interface {
+	ResolveENS(ctx context.Context, name string) (string, error)
+}
+
+var _ ENSResolver = (*ETHRPCService)(nil)
+
+// ResolveENS returns the lowercase address name points to, asking the registry for the resolver of
+// the name then the resolver for its address, at the latest block. a name without either fails with
+// ErrENSNotResolved. names are lowercased, not otherwise normalized.
+func (s *ETHRPCService) ResolveENS(ctx context.Context, name string) (string, error) {
+	node := Namehash(name)
+	resolver, err := s.callAddress(ctx, ENSRegistry, resolverSelector+node[2:])
+	if err != nil {
+		s.logger.Error(ctx, "[ResolveENS]: Error resolver", "name", name, "err", err)
+		return "", err
+	}
+	if resolver == zeroAddress {
+		return "", fmt.Errorf("%w: %s has no resolver", ErrENSNotResolved, name)
+	}
+	address, err := s.callAddress(ctx, resolver, addrSelector+node[2:])
+	if err != nil {
+		s.logger.Error(ctx, "[ResolveENS]: Error addr", "name", name, "resolver", resolver, "err", err)
+		return "", err
+	}
+	if address == zeroAddress {
+		return "", fmt.Errorf("%w: %s has no address", ErrENSNotResolved, name)
+	}
+	return address, nil
+}
+
+// callAddress call contract to with data, and decode the address it returns.
+func (s *ETHRPCService) callAddress(ctx context.Context, to, data string) (string, error) {
+	result, err := s.EthCall(ctx, to, data)
+	if err != nil {
+		return "", err
+	}
+	raw := strings.TrimPrefix(result, "0x")
+	if len(raw) < 64 {
+		return "", malformed(fmt.Errorf("%q isn't an address", result))
+	}
+	return "0x" + strings.ToLower(raw[24:64]), nil
+}
+
+// Namehash EIP-137 namehash of name, 0x prefixed hex: keccak256 of the namehash of the parent name and
+// keccak256 of the label, from the root, whose namehash is 32 zero bytes.
+func Namehash(name string) string {
+	node := make([]byte, 32)
+	name = strings.ToLower(name)
+	if len(name) > 0 {
+		labels := strings.Split(name, ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			node = keccak256(node, keccak256([]byte(labels[i])))
+		}
+	}
+	return "0x" + hex.EncodeToString(node)
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return hash.Sum(nil)
+}
@@ -0,0 +1,51 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestNamehash(t *testing.T) {
+	assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000000", Namehash(""))
+	assert.Equal(t, "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae", Namehash("eth"))
+	assert.Equal(t, "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f", Namehash("foo.eth"))
+	assert.Equal(t, Namehash("foo.eth"), Namehash("FOO.eth"))
+}
+
+func TestETHRPCService_ResolveENS(t *testing.T) {
+	const resolver = "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"
+	const target = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	foo, bar := Namehash("foo.eth")[2:], Namehash("bar.eth")[2:]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		call := request.Params[0].(map[string]interface{})
+		result := zeroAddress
+		switch call["to"].(string) + call["data"].(string) {
+		case ENSRegistry + resolverSelector + foo, ENSRegistry + resolverSelector + bar:
+			result = resolver
+		case resolver + addrSelector + foo:
+			result = target
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":86,"result":"0x%064s"}`, result[2:])
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	address, err := s.ResolveENS(context.Background(), "Foo.eth")
+	assert.Nil(t, err)
+	assert.Equal(t, target, address)
+	_, err = s.ResolveENS(context.Background(), "bar.eth")
+	assert.True(t, errors.Is(err, ErrENSNotResolved))
+	assert.EqualError(t, err, "ens name not resolved: bar.eth has no address")
+	_, err = s.ResolveENS(context.Background(), "baz.eth")
+	assert.EqualError(t, err, "ens name not resolved: baz.eth has no resolver")
+}
@@ -0,0 +1,28 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+var (
+	// ErrRPCUnavailable no endpoint answered the call, or they answered with a server error or asked to
+	// slow down, even after retrying. the errors of such calls match it with errors.Is.
+	ErrRPCUnavailable = errors.New("rpc unavailable")
+	// ErrBlockNotFound the node doesn't have the block, not mined yet or pruned.
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrMalformedResponse the node answered something which isn't a valid response of the method, such
+	// as a quantity without 0x prefix.
+	ErrMalformedResponse = errors.New("malformed rpc response")
+)
+
+// RPCError error the node answered a call with, carrying its JSON-RPC code and message. a call the node
+// rejects fails with a *RPCError, which isn't retried.
+type RPCError = model.JSONRPCError
+
+// malformed err wrapped in ErrMalformedResponse.
+func malformed(err error) error {
+	return fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+}
@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_TypedErrors(t *testing.T) {
+	ctx := context.Background()
+	var hits int32
+	unavailable := newStatusServer(&hits, 503, 503)
+	defer unavailable.Close()
+	_, err := NewETHRPCService(unavailable.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 2})).EthBlockNumber(ctx)
+	assert.True(t, errors.Is(err, ErrRPCUnavailable))
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	_, err = NewETHRPCService(down.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 1})).EthBlockNumber(ctx)
+	assert.True(t, errors.Is(err, ErrRPCUnavailable))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		json.NewDecoder(r.Body).Decode(request)
+		switch request.Method {
+		case "eth_blockNumber":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":83,"result":"10"}`))
+		case "eth_getBlockByNumber":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+		default:
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+		}
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	_, err = s.ETHBlockDecimalNumber(ctx)
+	assert.True(t, errors.Is(err, ErrMalformedResponse))
+	_, err = s.EthGetBlockByNumber(ctx, "0x10")
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+	assert.False(t, errors.Is(err, ErrRPCUnavailable))
+	_, err = s.EthGetBlockReceipts(ctx, "0x10")
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32601, rpcErr.Code)
+}
+
+func TestETHRPCService_EthGetBlockByNumberError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":84,"error":{"code":-32005,"message":"rate limit exceeded"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewETHRPCService(server.URL).EthGetBlockByNumber(context.Background(), "0x10")
+	// the node failing isn't the block missing.
+	assert.False(t, errors.Is(err, ErrBlockNotFound))
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32005, rpcErr.Code)
+}
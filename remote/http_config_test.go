@@ -0,0 +1,83 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_HTTPConfigHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{"jsonrpc":"2.0","id":83,"result":"0x10"}`))
+	}))
+	defer server.Close()
+
+	s := NewETHRPCService(server.URL, WithHTTPConfig(HTTPConfig{Headers: map[string]string{
+		"Authorization": "Bearer secret",
+		"X-Api-Key":     "key",
+		"Content-Type":  "text/plain",
+	}}))
+	_, err := s.EthBlockNumber(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer secret", got.Get("Authorization"))
+	assert.Equal(t, "key", got.Get("X-Api-Key"))
+	// the body is JSON whatever the headers say.
+	assert.Equal(t, "application/json", got.Get("Content-Type"))
+
+	// credentials of the url go as basic auth, and aren't reported.
+	withAuth := strings.Replace(server.URL, "http://", "http://user:password@", 1)
+	s = NewETHRPCService(withAuth)
+	_, err = s.EthBlockNumber(context.Background())
+	assert.Nil(t, err)
+	user, password, ok := (&http.Request{Header: got}).BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "password", password)
+	assert.Equal(t, strings.Replace(server.URL, "http://", "http://user:xxxxx@", 1), s.Endpoints()[0].URL)
+}
+
+func TestETHRPCService_HTTPConfigTimeout(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":83,"result":"0x10"}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// the first attempt hangs past the timeout, the retry goes through.
+	s := NewETHRPCService(server.URL, WithHTTPConfig(HTTPConfig{Timeout: 50 * time.Millisecond, MaxIdleConnsPerHost: 16}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	start := time.Now()
+	head, err := s.EthBlockNumber(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "0x10", head)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 16, s.httpClient.Transport.(*http.Transport).MaxIdleConnsPerHost)
+
+	// without retry the timeout fails the call.
+	atomic.StoreInt32(&hits, 0)
+	s = NewETHRPCService(server.URL, WithHTTPConfig(HTTPConfig{Timeout: 50 * time.Millisecond}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	_, err = s.EthBlockNumber(context.Background())
+	var tErr *transportError
+	assert.True(t, errors.As(err, &tErr))
+	assert.Nil(t, s.httpClient.Transport)
+}
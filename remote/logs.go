@@ -0,0 +1,53 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// LogFilter logs asked by eth_getLogs, either from a block range or from a single block by hash.
+type LogFilter struct {
+	FromBlock string `json:"fromBlock,omitempty"` // a number in hex or a tag such as latest.
+	ToBlock   string `json:"toBlock,omitempty"`
+	// BlockHash the logs of this block only, rather than of FromBlock to ToBlock.
+	BlockHash string   `json:"blockHash,omitempty"`
+	Addresses []string `json:"address,omitempty"` // contracts emitting the logs, any if empty.
+	// Topics the topics matched at each position, a log matching one of the topics of every position.
+	// a nil position matches any topic.
+	Topics [][]string `json:"topics,omitempty"`
+}
+
+// LogGetter client able to filter logs, implemented by ETHRPCService.
+type LogGetter interface {
+	EthGetLogs(ctx context.Context, filter LogFilter) ([]*model.ETHLog, error)
+}
+
+var _ LogGetter = (*ETHRPCService)(nil)
+
+// EthGetLogs returns the logs matching filter. nodes cap the logs of a call, a call over the cap fails
+// with the *model.JSONRPCError the node answered.
+func (s *ETHRPCService) EthGetLogs(ctx context.Context, filter LogFilter) ([]*model.ETHLog, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getLogs",
+		Params:  []interface{}{filter},
+		ID:      92, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetLogs]: Error jsonRPCPOST", "err", err)
+		return nil, err
+	}
+	resp := &model.ETHGetLogsResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthGetLogs]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
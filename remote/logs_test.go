@@ -0,0 +1,42 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthGetLogs(t *testing.T) {
+	var params string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_getLogs", request.Method)
+		params = string(request.Params)
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":92,"result":[{"address":"0xaa","topics":["0x01","0x02"],"data":"0x","blockNumber":"0x10","logIndex":"0x0"}]}`)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	logs, err := s.EthGetLogs(context.Background(), LogFilter{
+		FromBlock: "0x10",
+		ToBlock:   "0x11",
+		Topics:    [][]string{{"0x01"}, nil, {"0x03", "0x04"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, []string{"0x01", "0x02"}, logs[0].Topics)
+	assert.JSONEq(t, `[{"fromBlock":"0x10","toBlock":"0x11","topics":[["0x01"],null,["0x03","0x04"]]}]`, params)
+
+	_, err = s.EthGetLogs(context.Background(), LogFilter{BlockHash: "0xh1", Addresses: []string{"0xaa"}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"blockHash":"0xh1","address":["0xaa"]}]`, params)
+}
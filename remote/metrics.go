@@ -0,0 +1,82 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sugarshop/token-gateway/metrics"
+)
+
+// Metrics sink of the metrics of JSON-RPC calls, labeled by method, a batch by "batch of" its first method.
+// PrometheusMetrics exposes them in the Prometheus text format, see WithMetrics.
+type Metrics interface {
+	// RPCRequest an attempt of a call to method which took d, err nil if it succeeded.
+	RPCRequest(method string, d time.Duration, err error)
+}
+
+// PrometheusMetrics Metrics kept in a metrics.Registry.
+type PrometheusMetrics struct {
+	requests *metrics.Counter
+	errors   *metrics.Counter
+	seconds  *metrics.Histogram
+}
+
+// NewPrometheusMetrics register the metrics of JSON-RPC calls in r, once per registry.
+func NewPrometheusMetrics(r *metrics.Registry) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		requests: r.NewCounter("token_gateway_rpc_requests_total",
+			"JSON-RPC requests sent, every attempt counts.", "method"),
+		errors: r.NewCounter("token_gateway_rpc_errors_total",
+			"JSON-RPC requests failed, every attempt counts, by type of error.", "method", "type"),
+		seconds: r.NewHistogram("token_gateway_rpc_request_seconds",
+			"Time to send a JSON-RPC request and read its response, every attempt counts.", nil, "method"),
+	}
+}
+
+var defaultMetrics = NewPrometheusMetrics(metrics.Default)
+
+// DefaultMetrics the Metrics of metrics.Default, used unless WithMetrics.
+func DefaultMetrics() *PrometheusMetrics {
+	return defaultMetrics
+}
+
+// RPCRequest count the request and its error, by ErrorType.
+func (m *PrometheusMetrics) RPCRequest(method string, d time.Duration, err error) {
+	m.requests.Inc(method)
+	m.seconds.Observe(d.Seconds(), method)
+	if err != nil {
+		m.errors.Inc(method, ErrorType(err))
+	}
+}
+
+// NopMetrics Metrics dropping everything.
+type NopMetrics struct{}
+
+// RPCRequest do nothing.
+func (NopMetrics) RPCRequest(method string, d time.Duration, err error) {}
+
+// ErrorType type of an error of a call, the label of the errors of PrometheusMetrics: timeout, canceled,
+// transport when the endpoint couldn't be reached, http_status, rpc when the node answered an error,
+// malformed, or other.
+func ErrorType(err error) string {
+	var tErr *transportError
+	var statusErr *HTTPStatusError
+	var rpcErr *RPCError
+	switch {
+	// a transport error may wrap the deadline of HTTPConfig.Timeout.
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &tErr):
+		return "transport"
+	case errors.As(err, &statusErr):
+		return "http_status"
+	case errors.As(err, &rpcErr):
+		return "rpc"
+	case errors.Is(err, ErrMalformedResponse):
+		return "malformed"
+	}
+	return "other"
+}
@@ -0,0 +1,41 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/metrics"
+	"github.com/tj/assert"
+)
+
+func TestErrorType(t *testing.T) {
+	for err, want := range map[error]string{
+		fmt.Errorf("call: %w", context.DeadlineExceeded): "timeout",
+		&transportError{context.DeadlineExceeded}:        "timeout",
+		context.Canceled: "canceled",
+		&transportError{errors.New("connection refused")}:         "transport",
+		&HTTPStatusError{StatusCode: 429}:                         "http_status",
+		&RPCError{Code: -32000, Message: "header not found"}:      "rpc",
+		malformed(errors.New("quantity without 0x prefix")):       "malformed",
+		errors.New("json: cannot unmarshal string into Go value"): "other",
+	} {
+		assert.Equal(t, want, ErrorType(err), err.Error())
+	}
+}
+
+func TestETHRPCService_WithMetrics(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, 503)
+	defer server.Close()
+	m := NewPrometheusMetrics(metrics.NewRegistry())
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}), WithMetrics(m))
+
+	_, err := s.EthBlockNumber(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2.0, m.requests.Value("eth_blockNumber"))
+	assert.Equal(t, 1.0, m.errors.Value("eth_blockNumber", "http_status"))
+	assert.Equal(t, uint64(2), m.seconds.Count("eth_blockNumber"))
+}
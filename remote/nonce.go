@@ -0,0 +1,46 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// NonceGetter client able to tell how many transactions an address sent, implemented by ETHRPCService.
+type NonceGetter interface {
+	EthGetTransactionCount(ctx context.Context, address, block string) (int64, error)
+}
+
+var _ NonceGetter = (*ETHRPCService)(nil)
+
+// EthGetTransactionCount returns the nonce of address at block, a number in hex or a tag such as latest.
+func (s *ETHRPCService) EthGetTransactionCount(ctx context.Context, address, block string) (int64, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getTransactionCount",
+		Params:  []interface{}{address, block},
+		ID:      91, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionCount]: Error jsonRPCPOST", "err", err)
+		return 0, err
+	}
+	resp := &model.ETHTransactionCountResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionCount]: Error Unmarshal", "err", err)
+		return 0, malformed(err)
+	}
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	nonce, err := util.HexToInt64(resp.Result)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionCount]: Error HexToInt64", "result", resp.Result, "err", err)
+		return 0, malformed(err)
+	}
+	return nonce, nil
+}
@@ -0,0 +1,42 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthGetTransactionCount(t *testing.T) {
+	result := `"result":"0x1a"`
+	var params []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_getTransactionCount", request.Method)
+		params = request.Params
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":91,%s}`, result)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+	address := "0x00000000000000000000000000000000000000aa"
+
+	nonce, err := s.EthGetTransactionCount(context.Background(), address, "latest")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(26), nonce)
+	assert.Equal(t, []interface{}{address, "latest"}, params)
+
+	result = `"error":{"code":-32602,"message":"invalid argument"}`
+	_, err = s.EthGetTransactionCount(context.Background(), address, "latest")
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	result = `"result":"26"`
+	_, err = s.EthGetTransactionCount(context.Background(), address, "latest")
+	assert.True(t, errors.Is(err, ErrMalformedResponse))
+}
@@ -0,0 +1,86 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// PendingSubscriber client able to push the transactions entering the node's mempool, implemented by ETHRPCService.
+type PendingSubscriber interface {
+	SubscribePendingTransactions(ctx context.Context) (<-chan *model.ETHTransaction, error)
+}
+
+var _ PendingSubscriber = (*ETHRPCService)(nil)
+
+// SubscribePendingTransactions subscribe the transactions entering the mempool via
+// eth_subscribe("newPendingTransactions", true) over websocket, asking for whole transactions. a node
+// pushing hashes only costs an eth_getTransactionByHash per transaction, the ones mined or dropped
+// meanwhile are skipped. transactions are sent to the returned channel, which is closed once ctx is
+// done or the connection drops.
+func (s *ETHRPCService) SubscribePendingTransactions(ctx context.Context) (<-chan *model.ETHTransaction, error) {
+	results, err := s.subscribe(ctx, "newPendingTransactions", true)
+	if err != nil {
+		s.logger.Error(ctx, "[SubscribePendingTransactions]: Error subscribe", "err", err)
+		return nil, err
+	}
+	txs := make(chan *model.ETHTransaction, 256)
+	go func() {
+		defer close(txs)
+		for result := range results {
+			tx, err := s.pendingTransaction(ctx, result)
+			if err != nil {
+				s.logger.Error(ctx, "[SubscribePendingTransactions]: Error pendingTransaction", "err", err)
+				continue
+			}
+			if tx == nil {
+				continue
+			}
+			select {
+			case txs <- tx:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return txs, nil
+}
+
+// pendingTransaction transaction of a newPendingTransactions notification, a whole transaction or its hash.
+func (s *ETHRPCService) pendingTransaction(ctx context.Context, result json.RawMessage) (*model.ETHTransaction, error) {
+	var hash string
+	if err := json.Unmarshal(result, &hash); err == nil {
+		return s.EthGetTransactionByHash(ctx, hash)
+	}
+	tx := &model.ETHTransaction{}
+	if err := json.Unmarshal(result, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// EthGetTransactionByHash returns a transaction by hash, pending or mined, nil if the node doesn't know it.
+func (s *ETHRPCService) EthGetTransactionByHash(ctx context.Context, hash string) (*model.ETHTransaction, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getTransactionByHash",
+		Params:  []interface{}{hash},
+		ID:      87, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionByHash]: Error jsonRPCPOST", "err", err)
+		return nil, err
+	}
+	resp := &model.ETHGetTransactionByHashResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionByHash]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
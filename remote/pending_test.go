@@ -0,0 +1,63 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_SubscribePendingTransactions(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, conn.ReadJSON(request))
+		assert.Equal(t, []interface{}{"newPendingTransactions", true}, request.Params)
+		conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": "0xsub"})
+		// a whole transaction, then hashes of a known and an unknown one.
+		for _, result := range []interface{}{
+			map[string]string{"hash": "0x1", "from": "0xaa"},
+			"0x2",
+			"0x3",
+		} {
+			conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params":  map[string]interface{}{"subscription": "0xsub", "result": result},
+			})
+		}
+	}))
+	defer ws.Close()
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_getTransactionByHash", request.Method)
+		if request.Params[0] == "0x2" {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":87,"result":{"hash":"0x2","from":"0xbb"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":87,"result":null}`)
+	}))
+	defer node.Close()
+	s := NewETHRPCService(node.URL, WithWebSocketURL("ws"+strings.TrimPrefix(ws.URL, "http")))
+
+	txs, err := s.SubscribePendingTransactions(context.Background())
+	assert.Nil(t, err)
+	var got []string
+	for tx := range txs {
+		got = append(got, tx.Hash+" "+tx.From)
+	}
+	assert.Equal(t, []string{"0x1 0xaa", "0x2 0xbb"}, got)
+}
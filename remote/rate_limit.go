@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// RateLimit how many JSON-RPC calls ETHRPCService sends per second, across every endpoint. a call waits
+// for its turn rather than failing, as long as its context allows.
+type RateLimit struct {
+	// RequestsPerSecond calls sent per second on average, 0 doesn't limit calls.
+	RequestsPerSecond float64
+	// Burst calls sent at once after a quiet while, at least 1.
+	Burst int
+	// Weights how many calls a request of each method counts for, such as the compute units the provider
+	// charges for it. a method not listed counts for 1, a batch for the sum of its requests.
+	Weights map[string]float64
+}
+
+// weight how many calls payload counts for, a request or a batch of requests.
+func (r RateLimit) weight(payload interface{}) float64 {
+	switch p := payload.(type) {
+	case *model.JSONRPCRequest:
+		return r.methodWeight(p.Method)
+	case []*model.JSONRPCRequest:
+		w := 0.0
+		for _, request := range p {
+			w += r.methodWeight(request.Method)
+		}
+		return w
+	}
+	return 1
+}
+
+func (r RateLimit) methodWeight(method string) float64 {
+	if w, ok := r.Weights[method]; ok && w >= 0 {
+		return w
+	}
+	return 1
+}
+
+// rateLimiter token bucket refilled at rate tokens per second up to burst tokens. a call may take more
+// tokens than there are, the bucket goes in debt and later calls wait until it is paid back.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time // when tokens was last refilled.
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// newRateLimiter return a limiter of limit, nil if it doesn't limit calls.
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: limit.RequestsPerSecond, burst: burst, tokens: burst, now: time.Now, sleep: sleepContext}
+}
+
+// wait take n tokens, waiting until the bucket isn't in debt anymore. the tokens are given back if ctx
+// is done first, its error is returned. a nil limiter never waits.
+func (l *rateLimiter) wait(ctx context.Context, n float64) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	// a call heavier than the bucket still goes, once the bucket is full.
+	if n > l.burst {
+		n = l.burst
+	}
+	l.mu.Lock()
+	now := l.now()
+	if l.last.IsZero() {
+		l.last = now
+	}
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+	}
+	l.tokens -= n
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	if err := l.sleep(ctx, delay); err != nil {
+		l.mu.Lock()
+		if l.tokens += n; l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sleepContext wait for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
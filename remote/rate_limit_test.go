@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// fakeTime time of a rateLimiter only moving when it sleeps.
+type fakeTime struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeTime) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeTime) Sleep(ctx context.Context, d time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return nil
+}
+
+func newFakeTimeService(url string, limit RateLimit) (*ETHRPCService, *fakeTime) {
+	s := NewETHRPCService(url, WithRateLimit(limit))
+	clock := &fakeTime{now: time.Unix(0, 0)}
+	s.limiter.now = clock.Now
+	s.limiter.sleep = clock.Sleep
+	return s, clock
+}
+
+func TestETHRPCService_RateLimit(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits)
+	defer server.Close()
+	ctx := context.Background()
+
+	// 100 calls at 10 per second: the first goes right away, each next one 100ms later.
+	s, clock := newFakeTimeService(server.URL, RateLimit{RequestsPerSecond: 10, Burst: 1})
+	for i := 0; i < 100; i++ {
+		_, err := s.EthBlockNumber(ctx)
+		assert.Nil(t, err)
+	}
+	elapsed := clock.Now().Sub(time.Unix(0, 0))
+	assert.GreaterOrEqual(t, elapsed, 9900*time.Millisecond)
+	assert.LessOrEqual(t, elapsed, 10*time.Second)
+	assert.Equal(t, int32(100), atomic.LoadInt32(&hits))
+
+	// a burst of 10 goes right away, the other 90 calls take 9s.
+	s, clock = newFakeTimeService(server.URL, RateLimit{RequestsPerSecond: 10, Burst: 10})
+	for i := 0; i < 100; i++ {
+		_, err := s.EthBlockNumber(ctx)
+		assert.Nil(t, err)
+	}
+	elapsed = clock.Now().Sub(time.Unix(0, 0))
+	assert.GreaterOrEqual(t, elapsed, 8900*time.Millisecond)
+	assert.LessOrEqual(t, elapsed, 9*time.Second)
+}
+
+func TestETHRPCService_RateLimitContext(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits)
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRateLimit(RateLimit{RequestsPerSecond: 1, Burst: 1}))
+
+	_, err := s.EthBlockNumber(context.Background())
+	assert.Nil(t, err)
+	// the next call waits for a second, past its deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = s.EthBlockNumber(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestRateLimit_Weight(t *testing.T) {
+	limit := RateLimit{Weights: map[string]float64{"eth_getBlockReceipts": 5, "eth_blockNumber": 0}}
+	assert.Equal(t, 5.0, limit.weight(&model.JSONRPCRequest{Method: "eth_getBlockReceipts"}))
+	assert.Equal(t, 0.0, limit.weight(&model.JSONRPCRequest{Method: "eth_blockNumber"}))
+	assert.Equal(t, 1.0, limit.weight(&model.JSONRPCRequest{Method: "eth_call"}))
+	assert.Equal(t, 7.0, limit.weight([]*model.JSONRPCRequest{
+		{Method: "eth_getBlockReceipts"}, {Method: "eth_call"}, {Method: "eth_call"},
+	}))
+
+	// a receipts call takes 5 tokens of 10 per second.
+	l := newRateLimiter(RateLimit{RequestsPerSecond: 10, Burst: 5})
+	clock := &fakeTime{now: time.Unix(0, 0)}
+	l.now, l.sleep = clock.Now, clock.Sleep
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.wait(context.Background(), 5))
+	}
+	assert.Equal(t, time.Second, clock.Now().Sub(time.Unix(0, 0)))
+	assert.Nil(t, newRateLimiter(RateLimit{}).wait(context.Background(), 5))
+}
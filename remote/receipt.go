@@ -0,0 +1,47 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// ReceiptGetter client able to fetch receipts one transaction at a time, for the nodes without
+// eth_getBlockReceipts. implemented by ETHRPCService.
+type ReceiptGetter interface {
+	EthGetTransactionReceipt(ctx context.Context, hash string) (*model.ETHTransactionReceipt, error)
+}
+
+var _ ReceiptGetter = (*ETHRPCService)(nil)
+
+// EthGetTransactionReceipt returns the receipt of a transaction by hash. a receipt the node doesn't
+// have yet is an error, it may show up on the next call.
+func (s *ETHRPCService) EthGetTransactionReceipt(ctx context.Context, hash string) (*model.ETHTransactionReceipt, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getTransactionReceipt",
+		Params:  []interface{}{hash},
+		ID:      88, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionReceipt]: Error jsonRPCPOST", "err", err)
+		return nil, err
+	}
+	resp := &model.ETHGetTransactionReceiptResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[EthGetTransactionReceipt]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if resp.Result == nil {
+		s.logger.Warn(ctx, "[EthGetTransactionReceipt]: empty receipt, should retry", "transaction", hash)
+		return nil, errors.New("empty receipt")
+	}
+	return resp.Result, nil
+}
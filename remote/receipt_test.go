@@ -0,0 +1,43 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_EthGetTransactionReceipt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		switch {
+		case request.Method == "eth_getBlockReceipts":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":85,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist"}}`)
+		case request.Params[0] == "0x1":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":88,"result":{"transactionHash":"0x1","status":"0x1","effectiveGasPrice":"0x3b9aca00"}}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":88,"result":null}`)
+		}
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	receipt, err := s.EthGetTransactionReceipt(context.Background(), "0x1")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x3b9aca00", receipt.EffectiveGasPrice)
+	// not mined yet.
+	_, err = s.EthGetTransactionReceipt(context.Background(), "0x2")
+	assert.NotNil(t, err)
+
+	_, err = s.EthGetBlockReceipts(context.Background(), "0x1")
+	var rpcErr *model.JSONRPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32601, rpcErr.Code)
+}
@@ -1,5 +1,7 @@
 package remote
 
-func Init()  {
-	ETHRPCServiceInstance()
+// Init create the ETHRPCService singleton, ErrNoRPCURL if the env has no endpoint.
+func Init() error {
+	_, err := ETHRPCServiceInstance()
+	return err
 }
@@ -0,0 +1,161 @@
+// Package remotetest JSON-RPC node replaying responses recorded from a live one, so that clients are tested
+// against real block shapes without network.
+package remotetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// RecordEnv env holding the JSON-RPC url of a live node, NewFixtureServer records its responses rather
+// than replaying them when it is set, such as ETH_FIXTURE_RECORD_URL=https://... go test ./service/.
+const RecordEnv = "ETH_FIXTURE_RECORD_URL"
+
+// missingFixture JSON-RPC error code answered for a request without fixture.
+const missingFixture = -32099
+
+// maxFixtureName longest file name made of the params, longer params are hashed.
+const maxFixtureName = 100
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// fixture content of a fixture file, the response to a request without its id.
+type fixture struct {
+	Result json.RawMessage     `json:"result,omitempty"`
+	Error  *model.JSONRPCError `json:"error,omitempty"`
+}
+
+// FixtureNode http.Handler answering JSON-RPC requests, single or batched, with the responses recorded in
+// dir, one file per method and params, see FixtureFile.
+type FixtureNode struct {
+	dir      string
+	upstream string // live node responses are recorded from, empty to replay.
+	mutex    sync.Mutex
+}
+
+// NewFixtureNode return a FixtureNode replaying the responses of dir, or recording the responses of the
+// live node at upstream into dir if it isn't empty.
+func NewFixtureNode(dir, upstream string) *FixtureNode {
+	return &FixtureNode{dir: dir, upstream: upstream}
+}
+
+// NewFixtureServer serve a FixtureNode of dir until t ends, recording from the node at RecordEnv if set.
+func NewFixtureServer(t *testing.T, dir string) *httptest.Server {
+	server := httptest.NewServer(NewFixtureNode(dir, os.Getenv(RecordEnv)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// FixtureFile name of the file of the response to method called with params, such as
+// eth_getBlockByNumber_0x10_true.json.
+func FixtureFile(method string, params []interface{}) string {
+	if len(params) == 0 {
+		return method + ".json"
+	}
+	raw, _ := json.Marshal(params)
+	name := strings.Trim(unsafeChars.ReplaceAllString(string(raw), "_"), "_")
+	if len(name) > maxFixtureName {
+		sum := sha256.Sum256(raw)
+		name = hex.EncodeToString(sum[:8])
+	}
+	return method + "_" + name + ".json"
+}
+
+func (n *FixtureNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var requests []*model.JSONRPCRequest
+		if err := json.Unmarshal(trimmed, &requests); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responses := make([]*model.JSONRPCResponse, 0, len(requests))
+		for _, request := range requests {
+			responses = append(responses, n.answer(request))
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+	request := &model.JSONRPCRequest{}
+	if err := json.Unmarshal(body, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(n.answer(request))
+}
+
+// answer the response to request, recorded first when recording.
+func (n *FixtureNode) answer(request *model.JSONRPCRequest) *model.JSONRPCResponse {
+	path := filepath.Join(n.dir, FixtureFile(request.Method, request.Params))
+	response := &model.JSONRPCResponse{JSONRPC: "2.0", ID: request.ID}
+	if len(n.upstream) > 0 {
+		if err := n.record(request, path); err != nil {
+			response.Error = &model.JSONRPCError{Code: missingFixture, Message: "record " + path + ": " + err.Error()}
+			return response
+		}
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		response.Error = &model.JSONRPCError{Code: missingFixture, Message: "no fixture " + path}
+		return response
+	}
+	f := &fixture{}
+	if err := json.Unmarshal(raw, f); err != nil {
+		response.Error = &model.JSONRPCError{Code: missingFixture, Message: "bad fixture " + path + ": " + err.Error()}
+		return response
+	}
+	response.Result, response.Error = f.Result, f.Error
+	if response.Result == nil && response.Error == nil {
+		response.Result = json.RawMessage("null")
+	}
+	return response
+}
+
+// record ask the live node for request and write its response to path.
+func (n *FixtureNode) record(request *model.JSONRPCRequest, path string) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(n.upstream, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected http status %d", resp.StatusCode)
+	}
+	response := &model.JSONRPCResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(&fixture{Result: response.Result, Error: response.Error}, "", "  ")
+	if err != nil {
+		return err
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if err := os.MkdirAll(n.dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(raw, '\n'), 0644)
+}
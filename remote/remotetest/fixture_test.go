@@ -0,0 +1,90 @@
+package remotetest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/tj/assert"
+)
+
+func TestFixtureFile(t *testing.T) {
+	assert.Equal(t, "eth_blockNumber.json", FixtureFile("eth_blockNumber", nil))
+	assert.Equal(t, "eth_blockNumber.json", FixtureFile("eth_blockNumber", []interface{}{}))
+	assert.Equal(t, "eth_getBlockByNumber_0x10_true.json", FixtureFile("eth_getBlockByNumber", []interface{}{"0x10", true}))
+	assert.Equal(t, "eth_call_data_0x01_to_0xaa_latest.json",
+		FixtureFile("eth_call", []interface{}{map[string]string{"to": "0xaa", "data": "0x01"}, "latest"}))
+	long := FixtureFile("eth_call", []interface{}{map[string]string{"data": string(make([]byte, 200))}})
+	assert.Equal(t, len("eth_call_")+16+len(".json"), len(long))
+}
+
+func TestFixtureNode_Record(t *testing.T) {
+	var hits int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		request := &model.JSONRPCRequest{}
+		json.NewDecoder(r.Body).Decode(request)
+		if request.Method == "eth_getBlockReceipts" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer upstream.Close()
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	ctx := context.Background()
+
+	recorder := httptest.NewServer(NewFixtureNode(dir, upstream.URL))
+	defer recorder.Close()
+	head, err := remote.NewETHRPCService(recorder.URL).EthBlockNumber(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x10", head)
+	_, err = remote.NewETHRPCService(recorder.URL).EthGetBlockReceipts(ctx, "0x10")
+	assert.NotNil(t, err)
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "eth_blockNumber.json"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"result":"0x10"}`, string(raw))
+
+	// replayed without the live node, errors included.
+	replay := httptest.NewServer(NewFixtureNode(dir, ""))
+	defer replay.Close()
+	client := remote.NewETHRPCService(replay.URL)
+	head, err = client.EthBlockNumber(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x10", head)
+	_, err = client.EthGetBlockReceipts(ctx, "0x10")
+	rpcErr, ok := err.(*model.JSONRPCError)
+	assert.True(t, ok)
+	assert.Equal(t, -32601, rpcErr.Code)
+	assert.Equal(t, 2, hits)
+
+	// a request without fixture gets an error rather than a made up result.
+	_, err = client.EthGetBlockByNumber(ctx, "0x11")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, hits)
+}
+
+func TestFixtureNode_Batch(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "eth_chainId.json"), []byte(`{"result":"0x1"}`), 0644))
+	server := httptest.NewServer(NewFixtureNode(dir, ""))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(
+		`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_gasPrice","params":[]}]`))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var responses []*model.JSONRPCResponse
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&responses))
+	assert.Equal(t, 2, len(responses))
+	assert.Equal(t, `"0x1"`, string(responses[0].Result))
+	assert.Nil(t, responses[0].Error)
+	assert.Equal(t, missingFixture, responses[1].Error.Code)
+}
@@ -0,0 +1,246 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// RetryPolicy how failed RPC calls are retried, network errors, 429 and 5xx responses are retried,
+// other failures are returned right away. every method of ETHRPCService is a read, so any of them may be
+// retried.
+type RetryPolicy struct {
+	// MaxAttempts the most attempts per call, including the first one, 0 means only one attempt.
+	MaxAttempts int
+	// BaseDelay delay before the first retry, doubled after each retry.
+	BaseDelay time.Duration
+	// MaxDelay the upper bound of the delay between two attempts. a Retry-After asking to wait longer
+	// fails the call rather than retrying early.
+	MaxDelay time.Duration
+}
+
+// RetryHook called before each retry of method, attempt being the number of the failed attempt.
+type RetryHook func(method string, attempt int, err error)
+
+// RetryStats retries of an ETHRPCService since its creation.
+type RetryStats struct {
+	Calls     int64 `json:"calls"`     // calls posted, a batch counts once.
+	Retries   int64 `json:"retries"`   // attempts made after the first one of a call.
+	Failovers int64 `json:"failovers"` // retries sent right away to another endpoint, part of Retries.
+	GaveUp    int64 `json:"gaveUp"`    // calls failing with an error worth retrying, out of attempts or time.
+}
+
+// DefaultRetryPolicy retry policy of ETHRPCService.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// backoff delay before retry number attempt (1-based), a random one between half and the full
+// exponential delay so clients failing together don't retry together.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// HTTPStatusError the JSON-RPC endpoint answered with a non 2xx status code.
+type HTTPStatusError struct {
+	StatusCode int
+	// RetryAfter how long the endpoint asked to wait with the Retry-After header, 0 if it didn't.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected http status %d", e.StatusCode)
+}
+
+// Is an endpoint answering with a server error, or asking to slow down, is unavailable.
+func (e *HTTPStatusError) Is(target error) bool {
+	return target == ErrRPCUnavailable && (e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500)
+}
+
+// transportError the request didn't get any response.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// Is an endpoint which can't be reached is unavailable.
+func (e *transportError) Is(target error) bool { return target == ErrRPCUnavailable }
+
+// retryable whether err is worth trying again, it doesn't retry once ctx is done.
+func retryable(err error) bool {
+	// an attempt timing out on HTTPConfig.Timeout, rather than on ctx, is a transport error.
+	var tErr *transportError
+	if errors.As(err, &tErr) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return false
+}
+
+// parseRetryAfter the wait asked by a Retry-After header value, in seconds or an HTTP date, 0 if
+// there is none.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// retryAfter the wait asked by the endpoint along with err, 0 if none.
+func retryAfter(err error) time.Duration {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// RetryStats retries made so far.
+func (s *ETHRPCService) RetryStats() RetryStats {
+	return RetryStats{
+		Calls:     atomic.LoadInt64(&s.calls),
+		Retries:   atomic.LoadInt64(&s.retries),
+		Failovers: atomic.LoadInt64(&s.failovers),
+		GaveUp:    atomic.LoadInt64(&s.gaveUp),
+	}
+}
+
+// retrying count a retry of method and call the retry hook.
+func (s *ETHRPCService) retrying(method string, attempt int, err error, failover bool) {
+	atomic.AddInt64(&s.retries, 1)
+	if failover {
+		atomic.AddInt64(&s.failovers, 1)
+	}
+	if s.retryHook != nil {
+		s.retryHook(method, attempt, err)
+	}
+}
+
+// jsonRPCPOST post request, retrying it following the retry policy.
+func (s *ETHRPCService) jsonRPCPOST(ctx context.Context, request *model.JSONRPCRequest) ([]byte, error) {
+	return s.postWithRetry(ctx, request.Method, request)
+}
+
+// jsonRPCBatchPOST post requests as a single batch, retrying it as a whole following the retry policy.
+// the responses may come in any order.
+func (s *ETHRPCService) jsonRPCBatchPOST(ctx context.Context, requests []*model.JSONRPCRequest) ([]byte, error) {
+	return s.postWithRetry(ctx, "batch of "+requests[0].Method, requests)
+}
+
+// postWithRetry post payload, a request or a batch of method, within the call timeout. a call running
+// out of it fails with an error matching ErrRPCUnavailable, unless ctx is done as well.
+func (s *ETHRPCService) postWithRetry(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	atomic.AddInt64(&s.calls, 1)
+	if s.callTimeout <= 0 {
+		return s.postAttempts(ctx, method, payload)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, s.callTimeout)
+	defer cancel()
+	body, err := s.postAttempts(callCtx, method, payload)
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		// the node hung rather than the caller giving up.
+		s.logger.Error(ctx, "[postWithRetry]: call timed out", "method", method, "timeout", s.callTimeout, "err", err)
+		atomic.AddInt64(&s.gaveUp, 1)
+		return nil, fmt.Errorf("%w: %s timed out after %s", ErrRPCUnavailable, method, s.callTimeout)
+	}
+	return body, err
+}
+
+// postAttempts post payload, retrying it following the retry policy. an attempt goes to the first healthy
+// endpoint, a retry goes to another endpoint if there is one, right away. otherwise it waits for the
+// backoff delay, or longer if the endpoint asked to with Retry-After. a call doesn't wait past the
+// deadline of ctx, the last error is returned right away instead.
+func (s *ETHRPCService) postAttempts(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	weight := s.rateLimit.weight(payload)
+	var last *endpoint
+	for attempt := 1; ; attempt++ {
+		if err := s.limiter.wait(ctx, weight); err != nil {
+			s.logger.Error(ctx, "[postWithRetry]: Error waiting for the rate limit", "method", method, "err", err)
+			return nil, err
+		}
+		e := s.endpoints.pick(last)
+		start := time.Now()
+		body, err := s.httpJsonRPCPOST(ctx, e.url, payload)
+		s.metrics.RPCRequest(method, time.Since(start), err)
+		if err == nil || retryable(err) {
+			// other failures are caused by the request or ctx, not by the endpoint.
+			s.endpoints.report(e, err)
+		}
+		if err == nil || !retryable(err) {
+			return body, err
+		}
+		if attempt >= s.retry.MaxAttempts {
+			atomic.AddInt64(&s.gaveUp, 1)
+			return body, err
+		}
+		last = e
+		if s.endpoints.pick(e) != e {
+			s.logger.Warn(ctx, "[postWithRetry]: fail over", "method", method, "attempt", attempt, "err", err)
+			s.retrying(method, attempt, err, true)
+			continue
+		}
+		delay := s.retry.backoff(attempt)
+		if after := retryAfter(err); after > delay {
+			if s.retry.MaxDelay > 0 && after > s.retry.MaxDelay {
+				s.logger.Error(ctx, "[postWithRetry]: Retry-After longer than MaxDelay, give up", "method", method,
+					"retry_after", after, "err", err)
+				atomic.AddInt64(&s.gaveUp, 1)
+				return nil, err
+			}
+			delay = after
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			s.logger.Error(ctx, "[postWithRetry]: no time left to retry, give up", "method", method, "delay", delay,
+				"err", err)
+			atomic.AddInt64(&s.gaveUp, 1)
+			return nil, err
+		}
+		s.logger.Warn(ctx, "[postWithRetry]: retry", "method", method, "delay", delay, "attempt", attempt, "err", err)
+		s.retrying(method, attempt, err, false)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+// newStatusServer answer with statuses in order, then with a block number.
+func newStatusServer(hits *int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(hits, 1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":83,"result":"0x10"}`))
+	}))
+}
+
+func TestETHRPCService_RetryServerError(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, http.StatusBadGateway, http.StatusServiceUnavailable)
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	requests, errors := defaultMetrics.requests.Value("eth_blockNumber"), defaultMetrics.errors.Value("eth_blockNumber", "http_status")
+	observed := defaultMetrics.seconds.Count("eth_blockNumber")
+
+	dec, err := s.ETHBlockDecimalNumber(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(16), dec)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	assert.Equal(t, requests+3, defaultMetrics.requests.Value("eth_blockNumber"))
+	assert.Equal(t, errors+2, defaultMetrics.errors.Value("eth_blockNumber", "http_status"))
+	assert.Equal(t, observed+3, defaultMetrics.seconds.Count("eth_blockNumber"))
+}
+
+func TestETHRPCService_RetryMaxAttempts(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, 500, 500, 500)
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+
+	_, err := s.EthBlockNumber(context.Background())
+	assert.Equal(t, &HTTPStatusError{StatusCode: 500}, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestETHRPCService_RetryClientError(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, http.StatusBadRequest)
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	_, err := s.EthBlockNumber(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestETHRPCService_RetryContextCanceled(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, 500, 500, 500)
+	defer server.Close()
+	// no call timeout, the retry waits for the cancellation.
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}),
+		WithCallTimeout(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := s.EthBlockNumber(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestETHRPCService_RetryDeadline(t *testing.T) {
+	var hits int32
+	server := newStatusServer(&hits, 500, 500, 500)
+	defer server.Close()
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}))
+
+	// the retry would outlive the caller, the error is returned right away.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := s.EthBlockNumber(ctx)
+	assert.Equal(t, &HTTPStatusError{StatusCode: 500}, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	assert.Equal(t, RetryStats{Calls: 1, GaveUp: 1}, s.RetryStats())
+}
+
+func TestETHRPCService_CallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a node which hangs.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	s := NewETHRPCService(server.URL, WithCallTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := s.EthBlockNumber(context.Background())
+	assert.True(t, errors.Is(err, ErrRPCUnavailable))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, RetryStats{Calls: 1, GaveUp: 1}, s.RetryStats())
+
+	// the caller giving up first isn't a timeout of the node.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = s.EthBlockNumber(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestETHRPCService_RetryAfter(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":83,"result":"0x10"}`))
+	}))
+	defer server.Close()
+	var retried []string
+	s := NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond,
+		MaxDelay: 5 * time.Second}), WithRetryHook(func(method string, attempt int, err error) {
+		retried = append(retried, method)
+		assert.Equal(t, 1, attempt)
+		assert.Equal(t, &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}, err)
+	}))
+
+	start := time.Now()
+	dec, err := s.ETHBlockDecimalNumber(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(16), dec)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"eth_blockNumber"}, retried)
+	assert.Equal(t, RetryStats{Calls: 1, Retries: 1}, s.RetryStats())
+
+	// the endpoint asks to wait longer than MaxDelay.
+	atomic.StoreInt32(&hits, 0)
+	s = NewETHRPCService(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MaxDelay: 100 * time.Millisecond}))
+	_, err = s.EthBlockNumber(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond} {
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, want/2)
+		assert.LessOrEqual(t, d, want)
+	}
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(1))
+}
@@ -5,58 +5,207 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sugarshop/env"
+	"github.com/sugarshop/token-gateway/logging"
 	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
 )
 
+// ETHClient ETH JSON-RPC methods used by service, implemented by ETHRPCService.
+type ETHClient interface {
+	ETHBlockDecimalNumber(ctx context.Context) (int64, error)
+	EthBlockNumber(ctx context.Context) (string, error)
+	EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error)
+	EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error)
+}
+
+var _ ETHClient = (*ETHRPCService)(nil)
+
 // ETHRPCService ETH RPC service.
 type ETHRPCService struct {
-	ethJsonRPCURL string
+	// 64-bit atomically accessed fields first to keep them aligned on 32-bit platforms, see RetryStats.
+	calls     int64
+	retries   int64
+	failovers int64
+	gaveUp    int64
+
+	ethJsonRPCURLs []string // the primary endpoint first, then the fallbacks.
+	ethWsURL       string   // optional websocket endpoint to subscribe new heads.
+	retry          RetryPolicy
+	retryHook      RetryHook // nil unless WithRetryHook.
+	rateLimit      RateLimit
+	limiter        *rateLimiter // nil unless WithRateLimit.
+	failover       FailoverPolicy
+	endpoints      *endpointPool
+	blocks         *blockCache   // nil unless WithBlockCache.
+	callTimeout    time.Duration // 0 lets a call run as long as its context allows.
+	httpConfig     HTTPConfig
+	httpClient     *http.Client
+	header         http.Header // HTTPConfig.Headers, nil if none.
+	logger         logging.Logger
+	metrics        Metrics
+}
+
+// Option ETHRPCService option.
+type Option func(*ETHRPCService)
+
+// WithWebSocketURL subscribe new heads from the websocket endpoint url.
+func WithWebSocketURL(url string) Option {
+	return func(s *ETHRPCService) {
+		s.ethWsURL = url
+	}
+}
+
+// WithRetryPolicy retry failed calls following policy instead of DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *ETHRPCService) {
+		s.retry = policy
+	}
+}
+
+// WithRetryHook call hook before each retry, to watch how flaky the endpoints are. see RetryStats for
+// the totals.
+func WithRetryHook(hook RetryHook) Option {
+	return func(s *ETHRPCService) {
+		s.retryHook = hook
+	}
+}
+
+// WithRateLimit send calls at the rate of limit at most, every attempt of a call counts.
+func WithRateLimit(limit RateLimit) Option {
+	return func(s *ETHRPCService) {
+		s.rateLimit = limit
+	}
 }
 
+// WithFallbackURLs fail over to the JSON-RPC endpoints urls, in order, while the primary one is ejected.
+func WithFallbackURLs(urls ...string) Option {
+	return func(s *ETHRPCService) {
+		s.ethJsonRPCURLs = append(s.ethJsonRPCURLs, urls...)
+	}
+}
+
+// WithFailoverPolicy eject failing endpoints following policy instead of DefaultFailoverPolicy.
+func WithFailoverPolicy(policy FailoverPolicy) Option {
+	return func(s *ETHRPCService) {
+		s.failover = policy
+	}
+}
+
+// WithCallTimeout give up a call, its retries included, after d rather than DefaultCallTimeout, so that a
+// node which hangs fails the call instead of blocking the caller. 0 waits as long as the context of the
+// call allows.
+func WithCallTimeout(d time.Duration) Option {
+	return func(s *ETHRPCService) {
+		s.callTimeout = d
+	}
+}
+
+// WithLogger log with logger instead of logging.Std.
+func WithLogger(logger logging.Logger) Option {
+	return func(s *ETHRPCService) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics record the metrics of calls in m rather than in DefaultMetrics.
+func WithMetrics(m Metrics) Option {
+	return func(s *ETHRPCService) {
+		s.metrics = m
+	}
+}
+
+// NewETHRPCService create an ETHRPCService calling the JSON-RPC endpoint url.
+func NewETHRPCService(url string, opts ...Option) *ETHRPCService {
+	s := &ETHRPCService{
+		ethJsonRPCURLs: []string{url},
+		retry:          DefaultRetryPolicy(),
+		failover:       DefaultFailoverPolicy(),
+		callTimeout:    DefaultCallTimeout,
+		logger:         logging.Std(),
+		metrics:        defaultMetrics,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.endpoints = newEndpointPool(s.ethJsonRPCURLs, s.failover)
+	s.limiter = newRateLimiter(s.rateLimit)
+	s.httpClient = s.httpConfig.client()
+	s.header = s.httpConfig.header()
+	return s
+}
+
+// Endpoints health of the JSON-RPC endpoints, the primary one first.
+func (s *ETHRPCService) Endpoints() []EndpointStatus {
+	return s.endpoints.status()
+}
+
+// DefaultCallTimeout how long a call of ETHRPCService may take, retries included, unless WithCallTimeout.
+const DefaultCallTimeout = 10 * time.Second
+
+// defaultBlockCacheSize blocks cached by ETHRPCServiceInstance unless BLOCK_CACHE_SIZE is set.
+const defaultBlockCacheSize = 128
+
 var (
 	ethRPCServiceInstance *ETHRPCService
 	ethRPCServiceOnce     sync.Once
 )
 
-// ETHRPCServiceInstance ETHRPCService singleton
-func ETHRPCServiceInstance() *ETHRPCService {
+// ErrNoRPCURL ETHRPCServiceInstance has no endpoint, the ETHJSONRPCURL env isn't set.
+var ErrNoRPCURL = errors.New("no ETHJSONRPCURL env set")
+
+// ETHRPCServiceInstance ETHRPCService singleton, ErrNoRPCURL unless ETHJSONRPCURL is set.
+func ETHRPCServiceInstance() (*ETHRPCService, error) {
 	url, ok := env.GlobalEnv().Get("ETHJSONRPCURL")
-	if !ok {
-		panic("no ETHJSONRPCURL env set")
+	if !ok || len(strings.TrimSpace(url)) == 0 {
+		return nil, ErrNoRPCURL
 	}
 
 	ethRPCServiceOnce.Do(func() {
-		ethRPCServiceInstance = &ETHRPCService{
-			ethJsonRPCURL: url,
+		wsURL, _ := env.GlobalEnv().Get("ETHWSURL")
+		// ETHJSONRPCURL may list fallback endpoints after the primary one, separated by comma.
+		urls := strings.Split(url, ",")
+		for i := range urls {
+			urls[i] = strings.TrimSpace(urls[i])
 		}
+		// BLOCK_CACHE_SIZE blocks kept in memory, 0 disables the cache.
+		cacheSize := defaultBlockCacheSize
+		if raw, ok := env.GlobalEnv().Get("BLOCK_CACHE_SIZE"); ok {
+			if size, err := strconv.Atoi(raw); err == nil && size >= 0 {
+				cacheSize = size
+			}
+		}
+		ethRPCServiceInstance = NewETHRPCService(urls[0], WithFallbackURLs(urls[1:]...), WithWebSocketURL(wsURL),
+			WithBlockCache(cacheSize))
 	})
 
-	return ethRPCServiceInstance
+	return ethRPCServiceInstance, nil
 }
 
 // ETHBlockDecimalNumber return the decimal number of the most recent block.
 func (s *ETHRPCService) ETHBlockDecimalNumber(ctx context.Context) (int64, error) {
 	hexStr, err := s.EthBlockNumber(ctx)
 	if err != nil {
-		log.Println(ctx, "[ETHBlockDecimalNumber]: Error EthBlockNumber request:", err)
+		s.logger.Error(ctx, "[ETHBlockDecimalNumber]: Error EthBlockNumber", "err", err)
 		return 0, err
 	}
 	if len(hexStr) == 0 {
-		log.Println(ctx, "[ETHBlockDecimalNumber]: Error EthBlockNumber request, hexStr length is 0")
-		return 0, errors.New("hexStr length is 0")
+		s.logger.Error(ctx, "[ETHBlockDecimalNumber]: Error EthBlockNumber request, hexStr length is 0")
+		return 0, malformed(errors.New("hexStr length is 0"))
 	}
 	// Convert hexadecimal string to decimal integer
-	dec, err := strconv.ParseInt(hexStr[2:], 16, 64)
+	dec, err := util.HexToInt64(hexStr)
 	if err != nil {
-		log.Println(ctx, "[ETHBlockDecimalNumber]: Error ParseInt, err: ", err)
-		return 0, err
+		s.logger.Error(ctx, "[ETHBlockDecimalNumber]: Error ParseInt", "err", err)
+		return 0, malformed(err)
 	}
 	return dec, nil
 }
@@ -70,17 +219,21 @@ func (s *ETHRPCService) EthBlockNumber(ctx context.Context) (string, error) {
 		ID:      83, // match response, debug, support multi-request, should be a uniq random number.
 	}
 
-	body, err := s.httpJsonRPCPOST(ctx, request)
+	body, err := s.jsonRPCPOST(ctx, request)
 	if err != nil {
-		log.Println(ctx, "[EthBlockNumber]: Error httpJsonRPCPOST request:", err)
+		s.logger.Error(ctx, "[EthBlockNumber]: Error jsonRPCPOST", "err", err)
 		return "", err
 	}
 
 	resp := &model.ETHBlockNumberResponse{}
 	err = json.Unmarshal(body, resp)
 	if err != nil {
-		log.Println(ctx, "[EthBlockNumber]: Error Unmarshal, err: ", err)
-		return "", err
+		s.logger.Error(ctx, "[EthBlockNumber]: Error Unmarshal", "err", err)
+		return "", malformed(err)
+	}
+	if resp.Error != nil {
+		s.logger.Error(ctx, "[EthBlockNumber]: Error response", "err", resp.Error)
+		return "", resp.Error
 	}
 	hexNumber := resp.Result
 
@@ -89,6 +242,9 @@ func (s *ETHRPCService) EthBlockNumber(ctx context.Context) (string, error) {
 
 // EthGetBlockByNumber returns information about a block by number.
 func (s *ETHRPCService) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	if blockInfo, ok := s.blocks.get(number); ok {
+		return blockInfo, nil
+	}
 	request := &model.JSONRPCRequest{
 		JSONRPC: "2.0",
 		Method:  "eth_getBlockByNumber",
@@ -96,55 +252,105 @@ func (s *ETHRPCService) EthGetBlockByNumber(ctx context.Context, number string)
 		ID:      84, // match response, debug, support multi-request, should be a uniq random number.
 	}
 
-	body, err := s.httpJsonRPCPOST(ctx, request)
+	body, err := s.jsonRPCPOST(ctx, request)
 	if err != nil {
-		log.Println(ctx, "[EthGetBlockByNumber]: Error httpJsonRPCPOST request:", err)
+		s.logger.Error(ctx, "[EthGetBlockByNumber]: Error jsonRPCPOST", "err", err)
 		return nil, err
 	}
 	resp := &model.ETHGetBlockByNumberResponse{}
 	err = json.Unmarshal(body, resp)
 	if err != nil {
-		log.Println(ctx, "[EthGetBlockByNumber]: Error Unmarshal, err: ", err)
-		return nil, err
+		s.logger.Error(ctx, "[EthGetBlockByNumber]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	// such as a rate limit, not a block missing.
+	if resp.Error != nil {
+		s.logger.Error(ctx, "[EthGetBlockByNumber]: Error response", "err", resp.Error)
+		return nil, resp.Error
 	}
 	blockInfo := resp.Result
 	// TODO: if jsonrpc return nil result, retry it.
 	if blockInfo == nil {
-		log.Println(ctx, "[EthGetBlockByNumber]: empty blockInfo, should retry, block number ", number)
-		return nil, errors.New("empty blockInfo")
+		s.logger.Warn(ctx, "[EthGetBlockByNumber]: empty blockInfo, should retry", "block", number)
+		return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, number)
 	}
+	s.blocks.add(number, blockInfo)
 	return blockInfo, nil
 }
 
-func (s *ETHRPCService) httpJsonRPCPOST(ctx context.Context, request *model.JSONRPCRequest) ([]byte, error) {
-	jsonData, err := json.Marshal(request)
+// EthGetBlockReceipts returns the receipts of every transaction in a block by number.
+func (s *ETHRPCService) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getBlockReceipts",
+		Params:  []interface{}{number},
+		ID:      85, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetBlockReceipts]: Error jsonRPCPOST", "err", err)
+		return nil, err
+	}
+	resp := &model.ETHGetBlockReceiptsResponse{}
+	err = json.Unmarshal(body, resp)
+	if err != nil {
+		s.logger.Error(ctx, "[EthGetBlockReceipts]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	// such as a node without eth_getBlockReceipts.
+	if resp.Error != nil {
+		s.logger.Error(ctx, "[EthGetBlockReceipts]: Error response", "err", resp.Error)
+		return nil, resp.Error
+	}
+	// a block without transaction returns an empty list, null means the block isn't available.
+	if resp.Result == nil {
+		s.logger.Warn(ctx, "[EthGetBlockReceipts]: empty receipts, should retry", "block", number)
+		return nil, fmt.Errorf("%w: receipts of %s", ErrBlockNotFound, number)
+	}
+	return resp.Result, nil
+}
+
+// httpJsonRPCPOST post a request, or a batch of requests.
+func (s *ETHRPCService) httpJsonRPCPOST(ctx context.Context, url string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		log.Println(ctx, "[httpJsonRPCPOST]: Error marshaling request:", err)
+		s.logger.Error(ctx, "[httpJsonRPCPOST]: Error marshaling request", "err", err)
 		return nil, err
 	}
 
 	// create HTTP POST request
-	req, err := http.NewRequest("POST", s.ethJsonRPCURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Println(ctx, "[httpJsonRPCPOST]: Error creating request:", err)
+		s.logger.Error(ctx, "[httpJsonRPCPOST]: Error creating request", "err", err)
 		return nil, err
 	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// HTTP Request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		log.Println(ctx, "[httpJsonRPCPOST]: Error sending request:", err)
-		return nil, err
+		s.logger.Error(ctx, "[httpJsonRPCPOST]: Error sending request", "err", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &transportError{err: err}
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.logger.Error(ctx, "[httpJsonRPCPOST]: Error response status", "status", resp.StatusCode)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
 
 	// read resp data.
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Println(ctx, "[httpJsonRPCPOST]: Error reading response:", err)
-		return nil, err
+		s.logger.Error(ctx, "[httpJsonRPCPOST]: Error reading response", "err", err)
+		return nil, &transportError{err: err}
 	}
 
 	return body, nil
@@ -2,14 +2,29 @@ package remote
 
 import (
 	"context"
-	"github.com/tj/assert"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
 )
 
+// rpcServiceInstance ETHRPCServiceInstance, the test fails unless ETHJSONRPCURL is set.
+func rpcServiceInstance(t *testing.T) *ETHRPCService {
+	s, err := ETHRPCServiceInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
 func TestRPCService_EthBlockNumber(t *testing.T) {
 	ctx := context.Background()
-	hexStr, err := ETHRPCServiceInstance().EthBlockNumber(ctx)
+	hexStr, err := rpcServiceInstance(t).EthBlockNumber(ctx)
 	assert.Nil(t, err)
 	assert.NotEqual(t, hexStr, "")
 	assert.Condition(t, func() (success bool) {
@@ -19,10 +34,10 @@ func TestRPCService_EthBlockNumber(t *testing.T) {
 
 func TestRPCService_EthGetBlockByNumber(t *testing.T) {
 	ctx := context.Background()
-	hexStr, err := ETHRPCServiceInstance().EthBlockNumber(ctx)
+	hexStr, err := rpcServiceInstance(t).EthBlockNumber(ctx)
 	assert.Nil(t, err)
 	assert.NotEqual(t, hexStr, "")
-	resp, err := ETHRPCServiceInstance().EthGetBlockByNumber(ctx, hexStr)
+	resp, err := rpcServiceInstance(t).EthGetBlockByNumber(ctx, hexStr)
 	assert.Nil(t, err)
 	assert.NotNil(t, resp)
 	assert.Equal(t, resp.Number, hexStr)
@@ -30,7 +45,29 @@ func TestRPCService_EthGetBlockByNumber(t *testing.T) {
 
 func TestRPCService_ETHBlockDecimalNumber(t *testing.T) {
 	ctx := context.Background()
-	hexStr, err := ETHRPCServiceInstance().ETHBlockDecimalNumber(ctx)
+	hexStr, err := rpcServiceInstance(t).ETHBlockDecimalNumber(ctx)
 	assert.Nil(t, err)
 	assert.NotNil(t, hexStr, 0)
 }
+
+func TestETHRPCService_EthGetBlockReceipts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "eth_getBlockReceipts", request.Method)
+		assert.Equal(t, []interface{}{"0x10"}, request.Params)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":[`+
+			`{"transactionHash":"0x1","blockNumber":"0x10","status":"0x1"},`+
+			`{"transactionHash":"0x2","blockNumber":"0x10","status":"0x0"}]}`, request.ID)
+	}))
+	defer server.Close()
+
+	receipts, err := NewETHRPCService(server.URL).EthGetBlockReceipts(context.Background(), "0x10")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(receipts))
+	for i, receipt := range receipts {
+		assert.Equal(t, fmt.Sprintf("0x%d", i+1), receipt.TransactionHash)
+		assert.Equal(t, "0x10", receipt.BlockNumber)
+	}
+	assert.Equal(t, "0x0", receipts[1].Status)
+}
@@ -0,0 +1,43 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// BlockTracer client able to trace the calls of every transaction of a block, for the internal
+// transfers. implemented by ETHRPCService, the node needs the debug namespace.
+type BlockTracer interface {
+	TraceBlockByNumber(ctx context.Context, number string) ([]*model.ETHTransactionTrace, error)
+}
+
+var _ BlockTracer = (*ETHRPCService)(nil)
+
+// TraceBlockByNumber returns the call tree of each transaction of block number, in block order, using
+// debug_traceBlockByNumber with the callTracer. a node without the method fails with the
+// *model.JSONRPCError it answered.
+func (s *ETHRPCService) TraceBlockByNumber(ctx context.Context, number string) ([]*model.ETHTransactionTrace, error) {
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "debug_traceBlockByNumber",
+		Params:  []interface{}{number, map[string]string{"tracer": "callTracer"}},
+		ID:      89, // match response, debug, support multi-request, should be a uniq random number.
+	}
+
+	body, err := s.jsonRPCPOST(ctx, request)
+	if err != nil {
+		s.logger.Error(ctx, "[TraceBlockByNumber]: Error jsonRPCPOST", "err", err)
+		return nil, err
+	}
+	resp := &model.ETHTraceBlockResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		s.logger.Error(ctx, "[TraceBlockByNumber]: Error Unmarshal", "err", err)
+		return nil, malformed(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,42 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHRPCService_TraceBlockByNumber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		assert.Equal(t, "debug_traceBlockByNumber", request.Method)
+		if request.Params[0] != "0x1" {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":89,"error":{"code":-32601,"message":"the method debug_traceBlockByNumber does not exist"}}`)
+			return
+		}
+		assert.Equal(t, map[string]interface{}{"tracer": "callTracer"}, request.Params[1])
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":89,"result":[{"txHash":"0xa","result":{"type":"CALL","from":"0x1","to":"0x2","value":"0x0",
+			"calls":[{"type":"CALL","from":"0x2","to":"0x3","value":"0x10"}]}}]}`)
+	}))
+	defer server.Close()
+	s := NewETHRPCService(server.URL)
+
+	traces, err := s.TraceBlockByNumber(context.Background(), "0x1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(traces))
+	assert.Equal(t, "0xa", traces[0].TxHash)
+	assert.Equal(t, "0x10", traces[0].Result.Calls[0].Value)
+
+	_, err = s.TraceBlockByNumber(context.Background(), "0x2")
+	var rpcErr *model.JSONRPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32601, rpcErr.Code)
+}
@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/gorilla/websocket"
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// ErrWebSocketUnsupported no websocket endpoint is configured, new blocks can only be polled.
+var ErrWebSocketUnsupported = errors.New("websocket endpoint not configured")
+
+// HeadSubscriber client able to push new block headers, implemented by ETHRPCService.
+type HeadSubscriber interface {
+	SubscribeNewHeads(ctx context.Context) (<-chan *model.ETHBlockHeader, error)
+}
+
+var _ HeadSubscriber = (*ETHRPCService)(nil)
+
+// SubscribeNewHeads subscribe new block headers via eth_subscribe("newHeads") over websocket.
+// headers are sent to the returned channel, which is closed once ctx is done or the connection drops.
+func (s *ETHRPCService) SubscribeNewHeads(ctx context.Context) (<-chan *model.ETHBlockHeader, error) {
+	results, err := s.subscribe(ctx, "newHeads")
+	if err != nil {
+		s.logger.Error(ctx, "[SubscribeNewHeads]: Error subscribe", "err", err)
+		return nil, err
+	}
+	heads := make(chan *model.ETHBlockHeader, 16)
+	go func() {
+		defer close(heads)
+		for result := range results {
+			head := &model.ETHBlockHeader{}
+			if err := json.Unmarshal(result, head); err != nil {
+				s.logger.Error(ctx, "[SubscribeNewHeads]: Error Unmarshal", "err", err)
+				continue
+			}
+			select {
+			case heads <- head:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return heads, nil
+}
+
+// subscribe eth_subscribe params over websocket. the result of each notification is sent to the returned
+// channel, which is closed once ctx is done or the connection drops.
+func (s *ETHRPCService) subscribe(ctx context.Context, params ...interface{}) (<-chan json.RawMessage, error) {
+	if len(s.ethWsURL) == 0 {
+		return nil, ErrWebSocketUnsupported
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.ethWsURL, s.header)
+	if err != nil {
+		s.logger.Error(ctx, "[subscribe]: Error Dial", "err", err)
+		return nil, err
+	}
+
+	request := &model.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_subscribe",
+		Params:  params,
+		ID:      86, // match response, debug, support multi-request, should be a uniq random number.
+	}
+	if err := conn.WriteJSON(request); err != nil {
+		s.logger.Error(ctx, "[subscribe]: Error WriteJSON", "err", err)
+		conn.Close()
+		return nil, err
+	}
+	resp := &model.ETHSubscribeResponse{}
+	if err := conn.ReadJSON(resp); err != nil {
+		s.logger.Error(ctx, "[subscribe]: Error ReadJSON", "err", err)
+		conn.Close()
+		return nil, err
+	}
+	if resp.Error != nil || len(resp.Result) == 0 {
+		s.logger.Error(ctx, "[subscribe]: eth_subscribe rejected", "err", resp.Error)
+		conn.Close()
+		return nil, errors.New("eth_subscribe rejected")
+	}
+	subID := resp.Result
+
+	results := make(chan json.RawMessage, 16)
+	done := make(chan struct{})
+	go func() {
+		// closing the connection unblocks ReadJSON below.
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(results)
+		defer close(done)
+		defer conn.Close()
+		for {
+			notification := &model.ETHSubscriptionNotification{}
+			if err := conn.ReadJSON(notification); err != nil {
+				if ctx.Err() == nil {
+					s.logger.Error(ctx, "[subscribe]: connection dropped", "err", err)
+				}
+				return
+			}
+			if notification.Params.Subscription != subID || len(notification.Params.Result) == 0 ||
+				string(notification.Params.Result) == "null" {
+				continue
+			}
+			select {
+			case results <- notification.Params.Result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
@@ -0,0 +1,65 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// newHeadsServer websocket node accepting eth_subscribe and pushing the given heads, then hanging up.
+func newHeadsServer(t *testing.T, heads ...string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, conn.ReadJSON(request))
+		assert.Equal(t, "eth_subscribe", request.Method)
+		assert.Equal(t, []interface{}{"newHeads"}, request.Params)
+		conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": "0xsub"})
+		// a notification of another subscription is ignored.
+		conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "eth_subscription",
+			"params":  map[string]interface{}{"subscription": "0xother", "result": map[string]string{"number": "0x0"}},
+		})
+		for _, head := range heads {
+			conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params":  map[string]interface{}{"subscription": "0xsub", "result": map[string]string{"number": head}},
+			})
+		}
+	}))
+}
+
+func TestETHRPCService_SubscribeNewHeads(t *testing.T) {
+	ctx := context.Background()
+	server := newHeadsServer(t, "0x1", "0x2")
+	defer server.Close()
+	s := NewETHRPCService("", WithWebSocketURL("ws"+strings.TrimPrefix(server.URL, "http")))
+
+	heads, err := s.SubscribeNewHeads(ctx)
+	assert.Nil(t, err)
+	var numbers []string
+	// the channel is closed once the node hangs up.
+	for head := range heads {
+		numbers = append(numbers, head.Number)
+	}
+	assert.Equal(t, []string{"0x1", "0x2"}, numbers)
+}
+
+func TestETHRPCService_SubscribeNewHeadsUnsupported(t *testing.T) {
+	s := NewETHRPCService("http://localhost:8545")
+	_, err := s.SubscribeNewHeads(context.Background())
+	assert.Equal(t, ErrWebSocketUnsupported, err)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/store"
+)
+
+// ErrChainIDMismatch the node or the storage is on another chain than Config.ChainID.
+var ErrChainIDMismatch = errors.New("chain id mismatch")
+
+// checkChainID make sure the node and the storage are on Config.ChainID, the chain id is saved to a
+// storage which doesn't have one yet. nothing is checked while Config.ChainID is 0.
+func (s *ETHService) checkChainID(ctx context.Context) error {
+	if s.conf.ChainID == 0 {
+		return nil
+	}
+	getter, ok := s.client.(remote.ChainIDGetter)
+	if !ok {
+		return errors.New("client can't tell its chain id")
+	}
+	chainID, err := getter.EthChainID(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[checkChainID]: Error EthChainID", "err", err)
+		return err
+	}
+	if chainID != s.conf.ChainID {
+		return fmt.Errorf("%w: node is on chain %d, expected %d", ErrChainIDMismatch, chainID, s.conf.ChainID)
+	}
+	chainIDStore, ok := s.storage.(store.ChainIDStore)
+	if !ok {
+		return nil
+	}
+	stored, ok, err := chainIDStore.GetChainID(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[checkChainID]: Error GetChainID", "err", err)
+		return err
+	}
+	if !ok {
+		return chainIDStore.SaveChainID(ctx, s.conf.ChainID)
+	}
+	if stored != s.conf.ChainID {
+		return fmt.Errorf("%w: storage holds chain %d, expected %d", ErrChainIDMismatch, stored, s.conf.ChainID)
+	}
+	return nil
+}
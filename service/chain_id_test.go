@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/remote/remotetest"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/tj/assert"
+)
+
+// chainIDMemory store.Memory remembering a chain id.
+type chainIDMemory struct {
+	*store.Memory
+	chainID int64
+}
+
+func (m *chainIDMemory) GetChainID(ctx context.Context) (int64, bool, error) {
+	return m.chainID, m.chainID != 0, nil
+}
+
+func (m *chainIDMemory) SaveChainID(ctx context.Context, chainID int64) error {
+	m.chainID = chainID
+	return nil
+}
+
+func TestNewETHService_ChainID(t *testing.T) {
+	server := remotetest.NewFixtureServer(t, "testdata/fixtures")
+	newService := func(chainID int64, storage store.Storage) (*ETHService, error) {
+		conf := testConfig()
+		conf.ChainID = chainID
+		return NewETHService(remote.NewETHRPCService(server.URL), WithConfig(conf), WithStorage(storage),
+			WithLogger(logging.Nop()))
+	}
+
+	// the node of the fixtures is on mainnet, an empty storage is tagged with it.
+	storage := &chainIDMemory{Memory: store.NewMemory(0)}
+	_, err := newService(1, storage)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), storage.chainID)
+	_, err = newService(1, storage)
+	assert.Nil(t, err)
+
+	_, err = newService(5, &chainIDMemory{Memory: store.NewMemory(0)})
+	assert.True(t, errors.Is(err, ErrChainIDMismatch))
+	assert.EqualError(t, err, "chain id mismatch: node is on chain 1, expected 5")
+
+	// a storage of another chain is refused.
+	_, err = newService(1, &chainIDMemory{Memory: store.NewMemory(0), chainID: 137})
+	assert.EqualError(t, err, "chain id mismatch: storage holds chain 137, expected 1")
+
+	// not checked without chain id.
+	_, err = newService(0, &chainIDMemory{Memory: store.NewMemory(0), chainID: 137})
+	assert.Nil(t, err)
+	_, err = NewETHService(newFakeETHClient(0), WithConfig(Config{ChainID: 1}), WithLogger(logging.Nop()))
+	assert.EqualError(t, err, "client can't tell its chain id")
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/store"
+)
+
+// NewETHServiceFromConfig return an ETHService of its own polling the node at conf.RPCURL, with its own
+// subscriptions and storage, the one conf.Storage selects, so that a process may follow several chains.
+// opts apply after conf.
+func NewETHServiceFromConfig(conf Config, opts ...Option) (*ETHService, error) {
+	return newETHServiceFromConfig(context.Background(), conf, opts...)
+}
+
+// newETHServiceFromConfig NewETHServiceFromConfig asking the node and storage with ctx.
+func newETHServiceFromConfig(ctx context.Context, conf Config, opts ...Option) (*ETHService, error) {
+	var urls []string
+	for _, url := range strings.Split(conf.RPCURL, ",") {
+		if url = strings.TrimSpace(url); len(url) > 0 {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no JSON-RPC url for chain " + conf.Chain)
+	}
+	// the client logs with the logger of opts, if any.
+	probe := &ETHService{conf: conf, logger: logging.Std(), metrics: defaultMetrics}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	clientLogger := probe.logger
+	if probe.conf.LogRepeatWindow > 0 {
+		clientLogger = logging.Dedup(clientLogger, probe.conf.LogRepeatWindow)
+	}
+	client := remote.NewETHRPCService(urls[0], remote.WithFallbackURLs(urls[1:]...), remote.WithWebSocketURL(conf.WSURL),
+		remote.WithBlockCache(conf.BlockCacheSize), remote.WithLogger(clientLogger), remote.WithMetrics(probe.metrics),
+		remote.WithCallTimeout(conf.RPCCallTimeout),
+		remote.WithRateLimit(remote.RateLimit{RequestsPerSecond: conf.RPCRateLimit, Burst: conf.RPCBurst,
+			Weights: conf.RPCWeights}),
+		remote.WithHTTPConfig(remote.HTTPConfig{Timeout: conf.RPCTimeout,
+			MaxIdleConnsPerHost: conf.RPCMaxIdleConnsPerHost, Headers: conf.RPCHeaders}))
+	base := []Option{WithConfig(conf)}
+	storage, closer, err := openStorage(ctx, conf)
+	if err != nil {
+		probe.logger.Error(ctx, "[NewETHServiceFromConfig]: Error openStorage", "err", err)
+		return nil, err
+	}
+	if storage != nil {
+		base = append(base, WithStorage(storage))
+	}
+	if len(conf.CheckpointFile) > 0 {
+		base = append(base, WithCheckpointer(store.NewFileCheckpointer(conf.CheckpointFile)))
+	}
+	s, err := newETHService(ctx, client, append(base, opts...)...)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	s.storageCloser = closer
+	return s, nil
+}
+
+// Chain name of the chain s follows, empty for the default one.
+func (s *ETHService) Chain() string {
+	return s.conf.Chain
+}
+
+var (
+	chainServices      = map[string]*ETHService{}
+	chainServicesMutex sync.Mutex
+)
+
+// Chains names of the chains listed by the CHAINS env, comma separated, besides the default one of
+// ETHServiceInstance, sorted. see loadChainConfig for their settings.
+func Chains() []string {
+	var chains []string
+	seen := map[string]bool{}
+	for _, chain := range strings.Split(envString("CHAINS", ""), ",") {
+		chain = strings.ToLower(strings.TrimSpace(chain))
+		if len(chain) == 0 || seen[chain] {
+			continue
+		}
+		seen[chain] = true
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	return chains
+}
+
+// ChainService started ETHService of chain, one of Chains. like ETHServiceInstance, an error is
+// returned rather than cached, the next call tries again.
+func ChainService(chain string) (*ETHService, error) {
+	chainServicesMutex.Lock()
+	defer chainServicesMutex.Unlock()
+	if instance := chainServices[chain]; instance != nil {
+		return instance, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	instance, err := startETHService(ctx, loadChainConfig(chain))
+	if err != nil {
+		return nil, err
+	}
+	chainServices[chain] = instance
+	return instance, nil
+}
+
+// startedServices ETHService already started by ETHServiceInstance or ChainService.
+func startedServices() []*ETHService {
+	var services []*ETHService
+	eTHServiceMutex.Lock()
+	if eTHServiceInstance != nil {
+		services = append(services, eTHServiceInstance)
+	}
+	eTHServiceMutex.Unlock()
+	chainServicesMutex.Lock()
+	for _, chain := range Chains() {
+		if instance := chainServices[chain]; instance != nil {
+			services = append(services, instance)
+		}
+	}
+	chainServicesMutex.Unlock()
+	return services
+}
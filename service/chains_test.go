@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// newChainServer JSON-RPC node of a chain at height head.
+func newChainServer(t *testing.T, head int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &model.JSONRPCRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(request))
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"0x%x"}`, request.ID, head)
+	}))
+}
+
+func TestNewETHServiceFromConfig(t *testing.T) {
+	ctx := context.Background()
+	mainnet := newChainServer(t, 100)
+	defer mainnet.Close()
+	polygon := newChainServer(t, 5000)
+	defer polygon.Close()
+
+	conf := testConfig()
+	conf.RPCURL = mainnet.URL
+	first, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	conf.Chain = "polygon"
+	// the primary endpoint is down, the fallback answers.
+	conf.RPCURL = "http://127.0.0.1:1, " + polygon.URL
+	second, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "polygon", second.Chain())
+
+	assert.Equal(t, int64(100), first.LastProcessedBlock(ctx))
+	assert.Equal(t, int64(5000), second.LastProcessedBlock(ctx))
+	assert.Nil(t, first.Subscribe(ctx, addrA))
+	assert.Equal(t, []string{addrA}, first.ListSubscriptions(ctx))
+	assert.Equal(t, 0, len(second.ListSubscriptions(ctx)))
+
+	conf.RPCURL = " "
+	_, err = NewETHServiceFromConfig(conf)
+	assert.NotNil(t, err)
+}
+
+func TestNewETHServiceFromConfig_Storage(t *testing.T) {
+	ctx := context.Background()
+	node := newChainServer(t, 100)
+	defer node.Close()
+
+	conf := testConfig()
+	conf.RPCURL = node.URL
+	conf.Storage = StorageSQLite
+	conf.SQLitePath = filepath.Join(t.TempDir(), "gateway.db")
+	instance, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	opts := model.SubscriptionOptions{Direction: model.DirectionInbound, MinValue: big.NewInt(1000),
+		Label: "hot wallet", WebhookURL: "https://example.com/hook"}
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrB, opts))
+	instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Number:       "0x32",
+		Transactions: []*model.ETHTransaction{{Hash: "0x1", From: addrA, To: addrB}},
+	})
+	assert.Nil(t, instance.Close())
+
+	// restarted on the same database, subscriptions, their options and transactions are loaded back.
+	restarted, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{addrA, addrB}, restarted.ListSubscriptions(ctx))
+	restoredOpts, ok := restarted.SubscriptionOptions(ctx, addrB)
+	assert.True(t, ok)
+	assert.Equal(t, opts, restoredOpts)
+	list, err := restarted.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1"}, hashesOf(list))
+
+	conf.SQLitePath = ""
+	_, err = NewETHServiceFromConfig(conf)
+	assert.NotNil(t, err)
+	conf.Storage = "mongo"
+	_, err = NewETHServiceFromConfig(conf)
+	assert.NotNil(t, err)
+}
+
+func TestNewETHServiceFromConfig_Redis(t *testing.T) {
+	ctx := context.Background()
+	node := newChainServer(t, 100)
+	defer node.Close()
+	server := miniredis.RunT(t)
+
+	conf := testConfig()
+	conf.RPCURL = node.URL
+	conf.Storage = StorageRedis
+	conf.RedisAddr = server.Addr()
+	first, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Nil(t, first.Subscribe(ctx, addrA))
+	assert.True(t, server.Exists("{gateway}:subscriptions"))
+
+	// a replica on the same Redis.
+	second, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{addrA}, second.ListSubscriptions(ctx))
+
+	// the keys of another chain don't collide.
+	conf.Chain = "polygon"
+	polygon, err := NewETHServiceFromConfig(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(polygon.ListSubscriptions(ctx)))
+
+	conf.RedisAddr = ""
+	_, err = NewETHServiceFromConfig(conf)
+	assert.NotNil(t, err)
+}
+
+// recordingLogger logging.Logger keeping the messages of Error.
+type recordingLogger struct {
+	mutex  sync.Mutex
+	errors []string
+}
+
+func (l *recordingLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {}
+func (l *recordingLogger) Info(ctx context.Context, msg string, keyvals ...interface{})  {}
+func (l *recordingLogger) Warn(ctx context.Context, msg string, keyvals ...interface{})  {}
+func (l *recordingLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.errors = append(l.errors, msg)
+}
+
+func TestNewETHServiceFromConfig_BadNode(t *testing.T) {
+	// the node answers without a block number.
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":83,"result":""}`)
+	}))
+	defer node.Close()
+
+	conf := testConfig()
+	conf.RPCURL = node.URL
+	logger := &recordingLogger{}
+	_, err := NewETHServiceFromConfig(conf, WithLogger(logger))
+	assert.NotNil(t, err)
+	// the client logs with the logger of the service.
+	assert.Contains(t, logger.errors, "[ETHBlockDecimalNumber]: Error EthBlockNumber request, hexStr length is 0")
+	assert.Contains(t, logger.errors, "[NewETHService]: Error ETHBlockDecimalNumber")
+
+	_, err = startETHService(context.Background(), conf)
+	assert.NotNil(t, err)
+}
+
+func TestStartETHService_Timeout(t *testing.T) {
+	// the node never answers.
+	release := make(chan struct{})
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer node.Close()
+	defer close(release)
+
+	conf := testConfig()
+	conf.RPCURL = node.URL
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := startETHService(ctx, conf)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(started) < conf.RPCCallTimeout)
+}
+
+func TestStartWithRetry(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	err := startWithRetry(ctx, 4, time.Millisecond, func() error {
+		if calls++; calls < 3 {
+			return errors.New("node unreachable")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = startWithRetry(ctx, 2, time.Millisecond, func() error {
+		calls++
+		return errors.New("node unreachable")
+	})
+	assert.EqualError(t, err, "node unreachable")
+	assert.Equal(t, 2, calls)
+	// on the wrong chain, retrying won't help.
+	calls = 0
+	err = startWithRetry(ctx, 4, time.Millisecond, func() error {
+		calls++
+		return ErrChainIDMismatch
+	})
+	assert.Equal(t, ErrChainIDMismatch, err)
+	assert.Equal(t, 1, calls)
+}
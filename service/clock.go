@@ -0,0 +1,29 @@
+package service
+
+import "time"
+
+// clock time source of the poll loop, tests replace it to drive the loop without sleeping.
+type clock interface {
+	NewTimer(d time.Duration) timer
+}
+
+// timer the part of time.Timer used by the poll loop.
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// realClock clock backed by package time.
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sugarshop/env"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/webhook"
+)
+
+// Config ETHService settings.
+type Config struct {
+	// Chain name of the chain, such as polygon, empty for the default one.
+	Chain string
+	// ChainID EIP-155 id of the chain, such as 1 for mainnet. NewETHService fails unless the node and the
+	// storage are on it, 0 doesn't check.
+	ChainID int64
+	// RPCURL JSON-RPC endpoint NewETHServiceFromConfig polls, fallback endpoints may follow the primary one,
+	// separated by comma.
+	RPCURL string
+	// WSURL websocket endpoint NewETHServiceFromConfig subscribes new heads from, empty to poll only.
+	WSURL string
+	// BlockCacheSize blocks NewETHServiceFromConfig keeps in memory, 0 disables the cache.
+	BlockCacheSize int
+	// RPCRateLimit JSON-RPC calls NewETHServiceFromConfig sends per second at most, 0 doesn't limit them.
+	RPCRateLimit float64
+	// RPCBurst calls sent at once after a quiet while, under RPCRateLimit.
+	RPCBurst int
+	// RPCWeights how many calls a request of each method counts for under RPCRateLimit, 1 if not listed.
+	RPCWeights map[string]float64
+	// RPCTimeout timeout of a JSON-RPC attempt, 0 doesn't time out attempts.
+	RPCTimeout time.Duration
+	// RPCCallTimeout timeout of a JSON-RPC call, its retries included, so a hung node fails the call rather
+	// than stalling parsing. 0 doesn't time out calls.
+	RPCCallTimeout time.Duration
+	// RPCMaxIdleConnsPerHost idle connections kept per JSON-RPC endpoint, 0 for the Go default.
+	RPCMaxIdleConnsPerHost int
+	// RPCHeaders headers sent with every JSON-RPC request, such as the Authorization header of a managed node.
+	RPCHeaders map[string]string
+	// ReorgDepth how many recent block hashes are kept to detect chain reorganizations.
+	ReorgDepth int
+	// MaxTransactionsPerAddress how many transactions are retained per address, the oldest are dropped first.
+	MaxTransactionsPerAddress int
+	// MaxTransactionAge transactions of blocks mined longer ago are dropped, 0 keeps them whatever their age.
+	MaxTransactionAge time.Duration
+	// TrackTokenTransfers decode ERC-20, ERC-721 and ERC-1155 transfers from the receipts of every block, rather than only the blocks
+	// with matched transactions. the node should support eth_getBlockReceipts either way.
+	TrackTokenTransfers bool
+	// TokenTransfersFromLogs match the token transfers of subscribed addresses with eth_getLogs filtering
+	// their topics, rather than decoding the receipts of every transaction of the block. transactions are
+	// still matched by scanning the block, ether transfers emit no log. a node without eth_getLogs falls
+	// back to the receipts.
+	TokenTransfersFromLogs bool
+	// PollInterval how often the node is asked for new blocks while polling.
+	PollInterval time.Duration
+	// Confirmations blocks mined on top of a transaction's block before the transaction is reported, 0 reports it right away.
+	Confirmations int
+	// MaxPollInterval how far the poll interval backs off while no new block shows up.
+	MaxPollInterval time.Duration
+	// MinValue transactions transferring less wei are not stored, nil stores every transaction.
+	// token transfers are stored whatever the value of their transaction.
+	MinValue *big.Int
+	// Storage where subscriptions and transactions are kept, StorageMemory, StorageSQLite or StorageRedis.
+	// empty keeps them in memory, they are lost on restart.
+	Storage string
+	// SQLitePath database file of StorageSQLite, created if missing.
+	SQLitePath string
+	// RedisAddr host:port of the Redis server of StorageRedis, or of its cluster nodes, comma separated.
+	RedisAddr string
+	// CheckpointFile file keeping the last processed block across restarts, empty to keep it in storage.
+	CheckpointFile string
+	// MaxResumeBlocks how many blocks missed while stopped are caught up at most on restart, the older ones
+	// are skipped. 0 catches up every block.
+	MaxResumeBlocks int
+	// WebhookURL webhook receiving the transactions of every subscription without a webhook of its own,
+	// empty for none.
+	WebhookURL string
+	// Webhook how webhooks are delivered.
+	Webhook webhook.Config
+	// StreamBuffer transactions buffered per SubscribeChan channel, more are dropped while the consumer lags.
+	StreamBuffer int
+	// ObserverWorkers goroutines calling the observers of RegisterObserver.
+	ObserverWorkers int
+	// ObserverQueueSize transactions waiting per observer worker, more are dropped while the observers lag.
+	ObserverQueueSize int
+	// TrackPendingTransactions keep the transactions of subscribed addresses seen in the mempool until they
+	// are mined, reported by GetTransactions as pending. the node needs a websocket endpoint.
+	TrackPendingTransactions bool
+	// PendingPollInterval how often the node's pending block is fetched for pending transactions, 0 subscribes
+	// the mempool from the websocket endpoint instead, polling every PollInterval if there is none.
+	PendingPollInterval time.Duration
+	// PendingHorizon how long a transaction stays pending before it is reported dropped, it is forgotten
+	// after as long again.
+	PendingHorizon time.Duration
+	// ReceiptConcurrency receipts fetched at a time from a node without eth_getBlockReceipts, which
+	// is asked for each transaction's receipt instead.
+	ReceiptConcurrency int
+	// BlockBatchSize blocks fetched in one JSON-RPC batch while catching up or backfilling, 1 fetches them
+	// one by one.
+	BlockBatchSize int
+	// MaxHealthyLag blocks the last processed block may be behind chain head before Health reports lagging.
+	MaxHealthyLag int
+	// TrackInternalTransfers store the ether moved by contract calls to or from subscribed addresses, found
+	// in the call traces of every block. the node needs debug_traceBlockByNumber, the feature turns itself
+	// off otherwise.
+	TrackInternalTransfers bool
+	// VerifySubscriptions ask the node for the nonce of an address before subscribing it, Subscribe fails
+	// while the node is unreachable. false subscribes without asking, whatever the node's state.
+	VerifySubscriptions bool
+	// ENSTTL how often the ENS names subscribed by name are resolved again, a re-pointed name moves to its
+	// new address. 0 resolves them once, when subscribed.
+	ENSTTL time.Duration
+	// LogRepeatWindow how long a line logged by the service or its client isn't logged again if the same,
+	// such as the error of a node down on every poll, see logging.Dedup. 0 logs every line.
+	LogRepeatWindow time.Duration
+}
+
+// DefaultConfig ETHService default settings.
+func DefaultConfig() Config {
+	return Config{
+		BlockCacheSize:            128,
+		RPCBurst:                  10,
+		RPCCallTimeout:            remote.DefaultCallTimeout,
+		ReorgDepth:                64,
+		MaxTransactionsPerAddress: 10000,
+		TrackTokenTransfers:       true,
+		PollInterval:              1 * time.Second,
+		MaxPollInterval:           4 * time.Second,
+		Confirmations:             6,
+		MaxResumeBlocks:           1000,
+		Webhook:                   webhook.DefaultConfig(),
+		StreamBuffer:              256,
+		ObserverWorkers:           4,
+		ObserverQueueSize:         1024,
+		ReceiptConcurrency:        8,
+		BlockBatchSize:            20,
+		MaxHealthyLag:             10,
+		PendingHorizon:            10 * time.Minute,
+		LogRepeatWindow:           time.Minute,
+	}
+}
+
+// loadConfig load settings from global env, unset or invalid values fall back to the defaults.
+func loadConfig() Config {
+	conf := DefaultConfig()
+	conf.RPCURL = envString("ETHJSONRPCURL", conf.RPCURL)
+	conf.WSURL = envString("ETHWSURL", conf.WSURL)
+	conf.ChainID = int64(envCount("CHAIN_ID", int(conf.ChainID)))
+	conf.BlockCacheSize = envCount("BLOCK_CACHE_SIZE", conf.BlockCacheSize)
+	conf.RPCRateLimit = envFloat("RPC_RATE_LIMIT", conf.RPCRateLimit)
+	conf.RPCBurst = envInt("RPC_BURST", conf.RPCBurst)
+	conf.RPCWeights = envWeights("RPC_WEIGHTS", conf.RPCWeights)
+	conf.RPCTimeout = envDuration("RPC_TIMEOUT", conf.RPCTimeout)
+	conf.RPCCallTimeout = envDuration("RPC_CALL_TIMEOUT", conf.RPCCallTimeout)
+	conf.RPCMaxIdleConnsPerHost = envCount("RPC_MAX_IDLE_CONNS_PER_HOST", conf.RPCMaxIdleConnsPerHost)
+	conf.RPCHeaders = envHeaders("RPC_HEADERS", conf.RPCHeaders)
+	conf.ReorgDepth = envInt("REORG_DEPTH", conf.ReorgDepth)
+	conf.MaxTransactionsPerAddress = envInt("MAX_TRANSACTIONS_PER_ADDRESS", conf.MaxTransactionsPerAddress)
+	conf.MaxTransactionAge = envDuration("MAX_TRANSACTION_AGE", conf.MaxTransactionAge)
+	conf.TrackTokenTransfers = envBool("TRACK_TOKEN_TRANSFERS", conf.TrackTokenTransfers)
+	conf.TokenTransfersFromLogs = envBool("TOKEN_TRANSFERS_FROM_LOGS", conf.TokenTransfersFromLogs)
+	conf.PollInterval = envDuration("POLL_INTERVAL", conf.PollInterval)
+	conf.MaxPollInterval = envDuration("MAX_POLL_INTERVAL", conf.MaxPollInterval)
+	conf.Confirmations = envCount("CONFIRMATIONS", conf.Confirmations)
+	conf.MinValue = envBigInt("MIN_VALUE", conf.MinValue)
+	conf.Storage = envString("STORAGE", conf.Storage)
+	conf.SQLitePath = envString("SQLITE_PATH", conf.SQLitePath)
+	conf.RedisAddr = envString("REDIS_ADDR", conf.RedisAddr)
+	conf.CheckpointFile = envString("CHECKPOINT_FILE", conf.CheckpointFile)
+	conf.MaxResumeBlocks = envCount("MAX_RESUME_BLOCKS", conf.MaxResumeBlocks)
+	conf.WebhookURL = envString("WEBHOOK_URL", conf.WebhookURL)
+	conf.Webhook.Workers = envInt("WEBHOOK_WORKERS", conf.Webhook.Workers)
+	conf.Webhook.MaxAttempts = envInt("WEBHOOK_MAX_ATTEMPTS", conf.Webhook.MaxAttempts)
+	conf.Webhook.Timeout = envDuration("WEBHOOK_TIMEOUT", conf.Webhook.Timeout)
+	conf.Webhook.Secret = envString("WEBHOOK_SECRET", conf.Webhook.Secret)
+	conf.StreamBuffer = envInt("STREAM_BUFFER", conf.StreamBuffer)
+	conf.ObserverWorkers = envInt("OBSERVER_WORKERS", conf.ObserverWorkers)
+	conf.ObserverQueueSize = envInt("OBSERVER_QUEUE_SIZE", conf.ObserverQueueSize)
+	conf.TrackPendingTransactions = envBool("TRACK_PENDING_TRANSACTIONS", conf.TrackPendingTransactions)
+	conf.PendingPollInterval = envDuration("PENDING_POLL_INTERVAL", conf.PendingPollInterval)
+	conf.PendingHorizon = envDuration("PENDING_HORIZON", conf.PendingHorizon)
+	conf.ReceiptConcurrency = envInt("RECEIPT_CONCURRENCY", conf.ReceiptConcurrency)
+	conf.BlockBatchSize = envInt("BLOCK_BATCH_SIZE", conf.BlockBatchSize)
+	conf.MaxHealthyLag = envCount("MAX_HEALTHY_LAG", conf.MaxHealthyLag)
+	conf.TrackInternalTransfers = envBool("TRACK_INTERNAL_TRANSFERS", conf.TrackInternalTransfers)
+	conf.VerifySubscriptions = envBool("VERIFY_SUBSCRIPTIONS", conf.VerifySubscriptions)
+	conf.ENSTTL = envDuration("ENS_TTL", conf.ENSTTL)
+	conf.LogRepeatWindow = envDuration("LOG_REPEAT_WINDOW", conf.LogRepeatWindow)
+	return conf
+}
+
+// loadChainConfig settings of chain, the keys prefixed by the uppercase chain name, such as
+// POLYGON_ETHJSONRPCURL, override the shared ones. endpoints, chain id, SQLite database and checkpoint file
+// aren't shared.
+func loadChainConfig(chain string) Config {
+	conf := loadConfig()
+	prefix := strings.ToUpper(chain) + "_"
+	conf.Chain = chain
+	conf.RPCURL = envString(prefix+"ETHJSONRPCURL", "")
+	conf.WSURL = envString(prefix+"ETHWSURL", "")
+	conf.ChainID = int64(envCount(prefix+"CHAIN_ID", 0))
+	// the provider of a chain may have a limit of its own.
+	conf.RPCRateLimit = envFloat(prefix+"RPC_RATE_LIMIT", conf.RPCRateLimit)
+	conf.RPCBurst = envInt(prefix+"RPC_BURST", conf.RPCBurst)
+	conf.RPCWeights = envWeights(prefix+"RPC_WEIGHTS", conf.RPCWeights)
+	conf.RPCTimeout = envDuration(prefix+"RPC_TIMEOUT", conf.RPCTimeout)
+	conf.RPCCallTimeout = envDuration(prefix+"RPC_CALL_TIMEOUT", conf.RPCCallTimeout)
+	conf.RPCHeaders = envHeaders(prefix+"RPC_HEADERS", conf.RPCHeaders)
+	conf.SQLitePath = envString(prefix+"SQLITE_PATH", "")
+	conf.CheckpointFile = envString(prefix+"CHECKPOINT_FILE", "")
+	conf.Confirmations = envCount(prefix+"CONFIRMATIONS", conf.Confirmations)
+	conf.ReorgDepth = envInt(prefix+"REORG_DEPTH", conf.ReorgDepth)
+	conf.PollInterval = envDuration(prefix+"POLL_INTERVAL", conf.PollInterval)
+	conf.MaxPollInterval = envDuration(prefix+"MAX_POLL_INTERVAL", conf.MaxPollInterval)
+	conf.MinValue = envBigInt(prefix+"MIN_VALUE", conf.MinValue)
+	conf.WebhookURL = envString(prefix+"WEBHOOK_URL", conf.WebhookURL)
+	return conf
+}
+
+// warnInvalid log that the setting of key is invalid and its default is used, keyvals tell why.
+func warnInvalid(key string, keyvals ...interface{}) {
+	logging.Std().Warn(context.Background(), "[loadConfig]: invalid setting, use default", append([]interface{}{"key", key}, keyvals...)...)
+}
+
+func envString(key string, def string) string {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	return v
+}
+
+func envInt(key string, def int) int {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envCount like envInt, 0 is valid.
+func envCount(key string, def int) int {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+func envBool(key string, def bool) bool {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return b
+}
+
+// envBigInt a decimal integer, such as an amount of wei.
+func envBigInt(key string, def *big.Int) *big.Int {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	n, ok := new(big.Int).SetString(v, 10)
+	if !ok || n.Sign() < 0 {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envFloat a decimal number, 0 is valid.
+func envFloat(key string, def float64) float64 {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return f
+}
+
+// envWeights weights of methods, such as eth_getBlockReceipts:10,eth_call:2.
+func envWeights(key string, def map[string]float64) map[string]float64 {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	weights := map[string]float64{}
+	for _, pair := range strings.Split(v, ",") {
+		method, weight, found := strings.Cut(strings.TrimSpace(pair), ":")
+		w, err := strconv.ParseFloat(weight, 64)
+		if !found || len(method) == 0 || err != nil || w < 0 {
+			warnInvalid(key, "value", v, "default", def)
+			return def
+		}
+		weights[method] = w
+	}
+	return weights
+}
+
+// envHeaders headers, such as Authorization:Bearer token,X-Api-Key:key. a value can't hold a comma.
+func envHeaders(key string, def map[string]string) map[string]string {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	headers := map[string]string{}
+	for _, pair := range strings.Split(v, ",") {
+		name, value, found := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !found || len(name) == 0 {
+			warnInvalid(key)
+			return def
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v, ok := env.GlobalEnv().Get(key)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		warnInvalid(key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// backfill a history scan started by Backfill or SubscribeFrom, status is guarded by backfillMutex.
+type backfill struct {
+	status model.BackfillStatus
+	cancel context.CancelFunc
+}
+
+// SubscribeFrom subscribe address and Backfill its history from fromBlock in background.
+// progress is reported by BackfillStatus.
+func (s *ETHService) SubscribeFrom(ctx context.Context, address string, fromBlock int64) error {
+	if fromBlock < 0 {
+		return errors.New("negative from block")
+	}
+	return s.SubscribeFromWithWebhook(ctx, address, fromBlock, "")
+}
+
+// SubscribeFromWithWebhook subscribe address in a single step, posting its transactions to callbackURL like
+// SubscribeWithWebhook and backfilling its history from fromBlock like SubscribeFrom. an empty callbackURL
+// keeps the webhook of the subscription, a negative fromBlock backfills nothing.
+func (s *ETHService) SubscribeFromWithWebhook(ctx context.Context, address string, fromBlock int64, callbackURL string) error {
+	var configure func(conf *SubscriptionConfig)
+	if len(callbackURL) > 0 {
+		if err := validateCallbackURL(callbackURL); err != nil {
+			return err
+		}
+		configure = func(conf *SubscriptionConfig) {
+			conf.WebhookURL = callbackURL
+		}
+	}
+	address, _, err := s.subscribeWith(ctx, address, configure)
+	if err != nil {
+		return err
+	}
+	if fromBlock >= 0 {
+		// not derived from ctx, the backfill outlives the request asking for it.
+		bctx, b := s.startBackfill(context.Background(), address, fromBlock)
+		go s.backfill(bctx, address, b)
+	}
+	return nil
+}
+
+// Backfill scan blocks from fromBlock up to the last parsed one for transactions of the subscribed
+// address, and its ERC-20 transfers when Config.TrackTokenTransfers is set, and merge them with the
+// ones already captured. later blocks are left to the live loop,
+// and a transaction already stored is skipped, so nothing is duplicated. it returns once every block
+// is scanned, or ctx is done. progress is reported by BackfillStatus, a previous backfill of the
+// address is cancelled.
+func (s *ETHService) Backfill(ctx context.Context, address string, fromBlock int64) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if fromBlock < 0 {
+		return errors.New("negative from block")
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+	s.addrRWMutex.RLock()
+	_, subscribed := s.subAddrs[address]
+	s.addrRWMutex.RUnlock()
+	if !subscribed {
+		return ErrNotSubscribed
+	}
+	bctx, b := s.startBackfill(ctx, address, fromBlock)
+	return s.backfill(bctx, address, b)
+}
+
+// startBackfill register a backfill of address from fromBlock, cancelling the previous one.
+func (s *ETHService) startBackfill(ctx context.Context, address string, fromBlock int64) (context.Context, *backfill) {
+	bctx, cancel := context.WithCancel(ctx)
+	b := &backfill{
+		status: model.BackfillStatus{
+			FromBlock:    fromBlock,
+			ToBlock:      s.LastProcessedBlock(ctx),
+			ScannedBlock: fromBlock - 1,
+		},
+		cancel: cancel,
+	}
+	s.backfillMutex.Lock()
+	if prev, ok := s.backfills[address]; ok {
+		prev.cancel()
+	}
+	s.backfills[address] = b
+	if s.isClosed() {
+		// Close already cancelled the running backfills.
+		cancel()
+	}
+	s.backfillMutex.Unlock()
+	return bctx, b
+}
+
+// BackfillStatus progress of the latest backfill of address, false if it never had one.
+func (s *ETHService) BackfillStatus(ctx context.Context, address string) (model.BackfillStatus, bool) {
+	s.backfillMutex.Lock()
+	defer s.backfillMutex.Unlock()
+	b, ok := s.backfills[strings.ToLower(address)]
+	if !ok {
+		return model.BackfillStatus{}, false
+	}
+	return b.status, true
+}
+
+// backfill scan blocks from b's FromBlock to ToBlock, storing address's transactions which meet the
+// options of its subscription when the backfill starts.
+func (s *ETHService) backfill(ctx context.Context, address string, b *backfill) error {
+	defer b.cancel()
+	s.addrRWMutex.RLock()
+	conf, ok := s.subAddrs[address]
+	s.addrRWMutex.RUnlock()
+	if !ok {
+		// unsubscribed before the backfill started, nothing would be stored.
+		conf = &SubscriptionConfig{}
+	}
+	subAddrs := map[string]*SubscriptionConfig{address: conf}
+	blocks := s.newBlockPrefetcher(b.status.ToBlock)
+	for next := b.status.FromBlock; next <= b.status.ToBlock; next++ {
+		matches, receipts, err := s.fetchBackfillBlock(ctx, blocks, next, subAddrs)
+		// skipped when unsubscribed meanwhile, ctx is cancelled as well.
+		if err == nil {
+			err = s.storeMatches(ctx, matches)
+		}
+		if err == nil && s.conf.TrackTokenTransfers {
+			err = s.storeTokenTransfers(ctx, subAddrs, receiptLogs(receipts))
+		}
+		if err != nil {
+			s.logger.Error(ctx, "[backfill]: stop backfilling", "address", address, "block", next, "err", err)
+			s.backfillMutex.Lock()
+			b.status.Error = err.Error()
+			s.backfillMutex.Unlock()
+			return err
+		}
+		s.backfillMutex.Lock()
+		b.status.ScannedBlock = next
+		s.backfillMutex.Unlock()
+	}
+	s.backfillMutex.Lock()
+	b.status.Done = true
+	s.backfillMutex.Unlock()
+	return nil
+}
+
+// fetchBackfillBlock transactions of block number sent from or to subAddrs, along with the block
+// receipts, trying maxBlockRetries times before giving up. like storeBlock, receipts are fetched
+// for every block with transactions when Config.TrackTokenTransfers is set.
+func (s *ETHService) fetchBackfillBlock(ctx context.Context, blocks *blockPrefetcher, number int64, subAddrs map[string]*SubscriptionConfig) ([]txMatch, []*model.ETHTransactionReceipt, error) {
+	var err error
+	for attempt := 0; attempt < maxBlockRetries; attempt++ {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		var blockInfo *model.ETHBlockInfo
+		if blockInfo, err = blocks.fetch(ctx, number); err != nil {
+			continue
+		}
+		matches := s.matchTransactions(subAddrs, blockInfo)
+		var internal []txMatch
+		if internal, err = s.matchInternal(ctx, subAddrs, blockInfo); err != nil {
+			continue
+		}
+		matches = append(matches, internal...)
+		if len(matches) == 0 && (!s.conf.TrackTokenTransfers || len(blockInfo.Transactions) == 0) {
+			return nil, nil, nil
+		}
+		var receipts []*model.ETHTransactionReceipt
+		if receipts, err = s.fetchReceipts(ctx, blockInfo, matches, s.conf.TrackTokenTransfers); err != nil {
+			continue
+		}
+		withReceipts(matches, receipts)
+		return matches, receipts, nil
+	}
+	return nil, nil, err
+}
+
+// cancelBackfill cancel the backfill of address and forget its status.
+func (s *ETHService) cancelBackfill(address string) {
+	s.backfillMutex.Lock()
+	defer s.backfillMutex.Unlock()
+	if b, ok := s.backfills[address]; ok {
+		b.cancel()
+		delete(s.backfills, address)
+	}
+}
+
+// cancelBackfills cancel every running backfill, their status is kept.
+func (s *ETHService) cancelBackfills() {
+	s.backfillMutex.Lock()
+	defer s.backfillMutex.Unlock()
+	for _, b := range s.backfills {
+		b.cancel()
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_SubscribeFrom(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(5)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: upperAddrA, To: addrB})
+	client.setBlock(4, "0xh4", "0xh3", &model.ETHTransaction{Hash: "0x4", From: addrC, To: addrA})
+	client.setBlock(6, "0xh6", "0xh5", &model.ETHTransaction{Hash: "0x6", From: addrA, To: addrD})
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+
+	assert.NotNil(t, instance.SubscribeFrom(ctx, addrA, -1))
+	_, ok := instance.BackfillStatus(ctx, addrA)
+	assert.False(t, ok)
+
+	assert.Nil(t, instance.SubscribeFrom(ctx, upperAddrA, 1))
+	// live parsing goes on while backfilling.
+	client.setHead(6)
+	assert.Nil(t, instance.load(ctx))
+	waitFor(t, 3*time.Second, func() bool {
+		status, _ := instance.BackfillStatus(ctx, addrA)
+		return status.Done
+	})
+	status, ok := instance.BackfillStatus(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, model.BackfillStatus{FromBlock: 1, ToBlock: 5, ScannedBlock: 5, Done: true}, status)
+
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	// history is inserted before the live transactions.
+	assert.Equal(t, []string{"0x2", "0x4", "0x6"}, hashesOf(list))
+	assert.Equal(t, model.DirectionInbound, list[1].Direction)
+
+	// parsing a backfilled block again doesn't duplicate its transactions.
+	assert.Nil(t, instance.ParseTransactions(ctx, 4))
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2", "0x4", "0x6"}, hashesOf(list))
+}
+
+func TestETHService_SubscribeFromError(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(5)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	client.fails[3] = maxBlockRetries
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+
+	assert.Nil(t, instance.SubscribeFrom(ctx, addrA, 1))
+	waitFor(t, 3*time.Second, func() bool {
+		status, _ := instance.BackfillStatus(ctx, addrA)
+		return len(status.Error) > 0
+	})
+	status, _ := instance.BackfillStatus(ctx, addrA)
+	assert.False(t, status.Done)
+	assert.Equal(t, int64(2), status.ScannedBlock)
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2"}, hashesOf(list))
+
+	// unsubscribing forgets the backfill.
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, true))
+	_, ok := instance.BackfillStatus(ctx, addrA)
+	assert.False(t, ok)
+}
+
+func TestETHService_SubscribeFromWithWebhook(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(5)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+
+	// an invalid callback subscribes nothing.
+	assert.NotNil(t, instance.SubscribeFromWithWebhook(ctx, addrA, 1, "ftp://example.com"))
+	assert.False(t, instance.IsSubscribed(ctx, addrA))
+
+	assert.Nil(t, instance.SubscribeFromWithWebhook(ctx, addrA, 1, "https://example.com/hook"))
+	waitFor(t, 3*time.Second, func() bool {
+		status, _ := instance.BackfillStatus(ctx, addrA)
+		return status.Done
+	})
+	opts, ok := instance.SubscriptionOptions(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/hook", opts.WebhookURL)
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2"}, hashesOf(list))
+
+	// no callback keeps the webhook, a negative block backfills nothing.
+	assert.Nil(t, instance.SubscribeFromWithWebhook(ctx, addrB, -1, ""))
+	_, ok = instance.BackfillStatus(ctx, addrB)
+	assert.False(t, ok)
+	assert.Nil(t, instance.SubscribeFromWithWebhook(ctx, addrA, -1, ""))
+	opts, _ = instance.SubscriptionOptions(ctx, addrA)
+	assert.Equal(t, "https://example.com/hook", opts.WebhookURL)
+}
+
+func TestETHService_Backfill(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(4)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB})
+	client.setBlock(4, "0xh4", "0xh3", &model.ETHTransaction{Hash: "0x4", From: addrB, To: addrA})
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrNotSubscribed, instance.Backfill(ctx, addrA, 1))
+	instance.Subscribe(ctx, addrA)
+	// block 4 is already captured live.
+	assert.Nil(t, instance.ParseTransactions(ctx, 4))
+
+	assert.Nil(t, instance.Backfill(ctx, upperAddrA, 1))
+	status, ok := instance.BackfillStatus(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, model.BackfillStatus{FromBlock: 1, ToBlock: 4, ScannedBlock: 4, Done: true}, status)
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x1", "0x4"}, hashesOf(list))
+	assert.Equal(t, testBlockTime(1), *list[0].Timestamp)
+
+	// cancelled by ctx.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, instance.Backfill(cctx, addrA, 1))
+	status, _ = instance.BackfillStatus(ctx, addrA)
+	assert.Equal(t, int64(0), status.ScannedBlock)
+	assert.False(t, status.Done)
+}
+
+func TestETHService_BackfillTokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(3)
+	// addrA only shows up in the Transfer log, the transaction goes to the token contract.
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0xt1", From: addrC, To: tokenContract})
+	client.receipts[2] = []*model.ETHTransactionReceipt{{
+		TransactionHash: "0xt1",
+		Status:          "0x1",
+		Logs:            []*model.ETHLog{transferLog(addrC, addrA, "0x64")},
+	}}
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	client.setHead(3)
+	assert.Nil(t, instance.load(ctx))
+
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrC))
+	assert.Nil(t, instance.Backfill(ctx, addrA, 1))
+	transfers, err := instance.GetTokenTransfers(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(transfers))
+	assert.Equal(t, "100", transfers[0].Amount)
+	assert.Equal(t, addrC, transfers[0].From)
+	assert.Equal(t, model.DirectionInbound, transfers[0].Direction)
+	// only the backfilled address is stored.
+	transfers, _ = instance.GetTokenTransfers(ctx, addrC)
+	assert.Equal(t, 0, len(transfers))
+
+	// without tracking, blocks without a matched transaction aren't looked into.
+	conf := testConfig()
+	conf.TrackTokenTransfers = false
+	instance, err = NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.load(ctx))
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Backfill(ctx, addrA, 1))
+	transfers, _ = instance.GetTokenTransfers(ctx, addrA)
+	assert.Equal(t, 0, len(transfers))
+}
+
+func TestETHService_BackfillBatchSize(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeBatchClient{fakeETHClient: newFakeETHClient(8)}
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	client.setBlock(7, "0xh7", "0xh6", &model.ETHTransaction{Hash: "0x7", From: addrB, To: addrA})
+	conf := testConfig()
+	conf.BlockBatchSize = 3
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	assert.Nil(t, instance.Backfill(ctx, addrA, 1))
+	assert.Equal(t, [][]int64{blockRange(1, 3), blockRange(4, 6), blockRange(7, 8)}, client.batches)
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2", "0x7"}, hashesOf(list))
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+)
+
+// blockPrefetcher fetch the blocks of a range in ascending order, a batch of Config.BlockBatchSize at a
+// time when the client is a remote.BlockBatcher, one by one otherwise.
+type blockPrefetcher struct {
+	s       *ETHService
+	last    int64 // last block of the range.
+	batched int64 // last block requested in a batch so far.
+	blocks  map[int64]*model.ETHBlockInfo
+}
+
+func (s *ETHService) newBlockPrefetcher(last int64) *blockPrefetcher {
+	return &blockPrefetcher{s: s, last: last, blocks: map[int64]*model.ETHBlockInfo{}}
+}
+
+// fetch block number, from the current batch if it's there. a block missing from its batch is fetched
+// on its own.
+func (p *blockPrefetcher) fetch(ctx context.Context, number int64) (*model.ETHBlockInfo, error) {
+	if blockInfo, ok := p.take(number); ok {
+		return blockInfo, nil
+	}
+	batcher, ok := p.s.client.(remote.BlockBatcher)
+	size := int64(p.s.conf.BlockBatchSize)
+	if !ok || size <= 1 || number >= p.last || number <= p.batched {
+		return p.s.fetchBlock(ctx, number)
+	}
+	end := number + size - 1
+	if end > p.last {
+		end = p.last
+	}
+	numbers := make([]string, 0, end-number+1)
+	for n := number; n <= end; n++ {
+		numbers = append(numbers, fmt.Sprintf("0x%x", n))
+	}
+	p.batched = end
+	blocks, err := batcher.EthGetBlocksByNumber(ctx, numbers)
+	if err != nil {
+		p.s.logger.Error(ctx, "[blockPrefetcher]: Error EthGetBlocksByNumber", "err", err)
+	}
+	for i, blockInfo := range blocks {
+		if blockInfo != nil && i < len(numbers) {
+			p.blocks[number+int64(i)] = blockInfo
+		}
+	}
+	if blockInfo, ok := p.take(number); ok {
+		return blockInfo, nil
+	}
+	return p.s.fetchBlock(ctx, number)
+}
+
+func (p *blockPrefetcher) take(number int64) (*model.ETHBlockInfo, bool) {
+	blockInfo, ok := p.blocks[number]
+	delete(p.blocks, number)
+	return blockInfo, ok
+}
+
+// reset drop the blocks fetched ahead, after a reorg they may be orphaned.
+func (p *blockPrefetcher) reset() {
+	p.blocks = map[int64]*model.ETHBlockInfo{}
+	p.batched = 0
+}
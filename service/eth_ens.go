@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/webhook"
+)
+
+// ErrENSNotResolved the ENS name points to no address.
+var ErrENSNotResolved = remote.ErrENSNotResolved
+
+// ensName an ENS name subscribed by name.
+type ensName struct {
+	address string // the address the name pointed to when last resolved.
+	owned   bool   // whether address was subscribed by the name, rather than already subscribed.
+}
+
+// resolveENS the lowercase address of the ENS name.
+func (s *ETHService) resolveENS(ctx context.Context, name string) (string, error) {
+	resolver, ok := s.client.(remote.ENSResolver)
+	if !ok {
+		return "", errors.New("client can't resolve ENS names")
+	}
+	address, err := resolver.ResolveENS(ctx, name)
+	if err != nil {
+		s.logger.Error(ctx, "[resolveENS]: Error ResolveENS", "name", name, "err", err)
+		return "", err
+	}
+	return address, nil
+}
+
+// setENSName attach name to its subscribed address, owned if subscribing the name added the address.
+// the caller holds addrRWMutex.
+func (s *ETHService) setENSName(name, address string, owned bool) {
+	if previous, ok := s.ensNames[name]; ok && previous.address == address {
+		owned = owned || previous.owned
+	}
+	s.ensNames[name] = &ensName{address: address, owned: owned}
+}
+
+// dropENSNames forget the names attached to address. the caller holds addrRWMutex.
+func (s *ETHService) dropENSNames(address string) {
+	for name, n := range s.ensNames {
+		if n.address == address {
+			delete(s.ensNames, name)
+		}
+	}
+}
+
+// ensAddress the address the subscribed ENS name points to, name itself if it isn't subscribed.
+func (s *ETHService) ensAddress(name string) string {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	if n, ok := s.ensNames[name]; ok {
+		return n.address
+	}
+	return name
+}
+
+// ENSNames ENS names subscribed by name and the address each one points to, in lowercase. names live in
+// memory, they should be subscribed again after a restart.
+func (s *ETHService) ENSNames(ctx context.Context) map[string]string {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	names := make(map[string]string, len(s.ensNames))
+	for name, n := range s.ensNames {
+		names[name] = n.address
+	}
+	return names
+}
+
+// watchENS resolve the subscribed names again every Config.ENSTTL until ctx is done.
+func (s *ETHService) watchENS(ctx context.Context) {
+	for s.sleep(ctx, s.conf.ENSTTL) {
+		s.refreshENS(ctx)
+	}
+}
+
+// refreshENS resolve every subscribed name, a re-pointed name moves to its new address: the new address
+// is subscribed, taking over the webhook of the previous one, which is unsubscribed without purge
+// unless it was subscribed on its own. the webhook of the name is posted an EventENSChanged.
+func (s *ETHService) refreshENS(ctx context.Context) {
+	for name, previous := range s.ENSNames(ctx) {
+		address, err := s.resolveENS(ctx, name)
+		if err != nil || address == previous {
+			continue
+		}
+		s.logger.Info(ctx, "[refreshENS]: ENS name re-pointed", "name", name, "from", previous, "to", address)
+		added, err := s.SubscribeIfNew(ctx, address)
+		if err != nil {
+			s.logger.Error(ctx, "[refreshENS]: Error SubscribeIfNew", "name", name, "err", err)
+			continue
+		}
+		s.addrRWMutex.Lock()
+		n, ok := s.ensNames[name]
+		if !ok || n.address != previous {
+			// unsubscribed or re-pointed meanwhile.
+			s.addrRWMutex.Unlock()
+			continue
+		}
+		if prev, ok := s.subAddrs[previous]; ok && len(prev.WebhookURL) > 0 {
+			if conf, ok := s.subAddrs[address]; ok && len(conf.WebhookURL) == 0 {
+				s.setWebhook(ctx, address, prev.WebhookURL)
+			}
+		}
+		s.setENSName(name, address, added)
+		release := n.owned && !s.hasENSName(previous)
+		url := s.webhookOf(address)
+		s.addrRWMutex.Unlock()
+
+		if release {
+			if err := s.Unsubscribe(ctx, previous, false); err != nil {
+				s.logger.Error(ctx, "[refreshENS]: Error Unsubscribe", "address", previous, "err", err)
+			}
+		}
+		if len(url) > 0 {
+			err := s.dispatcher.Send(address, url, &webhook.Payload{
+				Address:  address,
+				Event:    webhook.EventENSChanged,
+				Name:     name,
+				Previous: previous,
+			})
+			if err != nil {
+				s.logger.Error(ctx, "[refreshENS]: Error Send", "err", err)
+			}
+		}
+	}
+}
+
+// hasENSName whether a name is attached to address. the caller holds addrRWMutex.
+func (s *ETHService) hasENSName(address string) bool {
+	for _, n := range s.ensNames {
+		if n.address == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/webhook"
+	"github.com/tj/assert"
+)
+
+// ensClient fakeETHClient resolving the names of targets.
+type ensClient struct {
+	*fakeETHClient
+	mu      sync.Mutex
+	targets map[string]string
+}
+
+func (c *ensClient) ResolveENS(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	address, ok := c.targets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no resolver", remote.ErrENSNotResolved, name)
+	}
+	return address, nil
+}
+
+func (c *ensClient) point(name, address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets[name] = address
+}
+
+func TestETHService_SubscribeENS(t *testing.T) {
+	ctx := context.Background()
+	client := &ensClient{fakeETHClient: newFakeETHClient(0), targets: map[string]string{"alice.eth": addrA}}
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+
+	assert.Nil(t, instance.Subscribe(ctx, "Alice.eth"))
+	assert.Equal(t, []string{addrA}, instance.ListSubscriptions(ctx))
+	assert.Equal(t, map[string]string{"alice.eth": addrA}, instance.ENSNames(ctx))
+	added, err := instance.SubscribeIfNew(ctx, "alice.eth")
+	assert.Nil(t, err)
+	assert.False(t, added)
+
+	// the literal name is never subscribed.
+	_, err = instance.SubscribeIfNew(ctx, "bob.eth")
+	assert.True(t, errors.Is(err, ErrENSNotResolved))
+	assert.Equal(t, []string{addrA}, instance.ListSubscriptions(ctx))
+
+	assert.Nil(t, instance.Unsubscribe(ctx, "alice.eth", false))
+	assert.Equal(t, []string{}, instance.ListSubscriptions(ctx))
+	assert.Equal(t, map[string]string{}, instance.ENSNames(ctx))
+
+	// a client without ENS support can't subscribe names.
+	instance = newTestETHService()
+	assert.NotNil(t, instance.Subscribe(ctx, "alice.eth"))
+	assert.Equal(t, []string{}, instance.ListSubscriptions(ctx))
+}
+
+func TestETHService_RefreshENS(t *testing.T) {
+	ctx := context.Background()
+	payloads := make(chan *webhook.Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := &webhook.Payload{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(payload))
+		payloads <- payload
+	}))
+	defer server.Close()
+	client := &ensClient{fakeETHClient: newFakeETHClient(0), targets: map[string]string{"alice.eth": addrA, "carol.eth": addrC}}
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.SubscribeWithWebhook(ctx, "alice.eth", server.URL))
+	// carol was subscribed on her own before her name.
+	assert.Nil(t, instance.Subscribe(ctx, addrC))
+	assert.Nil(t, instance.Subscribe(ctx, "carol.eth"))
+
+	instance.refreshENS(ctx)
+	assert.Equal(t, []string{addrA, addrC}, instance.ListSubscriptions(ctx))
+
+	client.point("alice.eth", addrB)
+	client.point("carol.eth", addrD)
+	instance.refreshENS(ctx)
+	assert.Equal(t, []string{addrB, addrC, addrD}, instance.ListSubscriptions(ctx))
+	assert.Equal(t, map[string]string{"alice.eth": addrB, "carol.eth": addrD}, instance.ENSNames(ctx))
+	payload := <-payloads
+	assert.Equal(t, webhook.EventENSChanged, payload.Event)
+	assert.Equal(t, "alice.eth", payload.Name)
+	assert.Equal(t, addrB, payload.Address)
+	assert.Equal(t, addrA, payload.Previous)
+	_, ok := instance.WebhookStats(ctx, addrB)
+	assert.True(t, ok)
+	assert.Nil(t, instance.Stop(ctx))
+	assert.Equal(t, 0, len(payloads))
+}
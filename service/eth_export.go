@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// formats of ExportTransactions.
+const (
+	ExportJSON = "json" // an array of model.ETHTransaction.
+	ExportCSV  = "csv"  // a header, then a row per transaction, see csvHeader.
+)
+
+// ErrUnsupportedFormat ExportTransactions doesn't know the format.
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// csvHeader columns of ExportCSV, value in wei and block in decimal.
+var csvHeader = []string{"hash", "from", "to", "value", "block", "direction", "status"}
+
+// ExportTransactions write the transactions of address GetTransactions returns to w in format, ExportJSON
+// or ExportCSV, a transaction at a time. an unsupported format fails with ErrUnsupportedFormat before
+// anything is written, an unsubscribed address with ErrNotSubscribed.
+func (s *ETHService) ExportTransactions(ctx context.Context, address string, format string, w io.Writer) error {
+	format = strings.ToLower(format)
+	if format != ExportJSON && format != ExportCSV {
+		return fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+	transactions, err := s.GetTransactions(ctx, address)
+	if err != nil {
+		return err
+	}
+	if format == ExportCSV {
+		err = exportCSV(w, transactions)
+	} else {
+		err = exportJSON(w, transactions)
+	}
+	if err != nil {
+		s.logger.Error(ctx, "[ExportTransactions]: Error writing export", "address", address, "format", format, "err", err)
+	}
+	return err
+}
+
+// exportJSON write transactions as a JSON array, encoding one at a time.
+func exportJSON(w io.Writer, transactions []*model.ETHTransaction) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, tx := range transactions {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// exportCSV write transactions as rows of csvHeader.
+func exportCSV(w io.Writer, transactions []*model.ETHTransaction) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, tx := range transactions {
+		block := ""
+		if number, err := util.HexToInt64(tx.BlockNumber); err == nil {
+			block = strconv.FormatInt(number, 10)
+		}
+		row := []string{tx.Hash, tx.From, tx.To, tx.ValueWei().String(), block, string(tx.Direction), string(tx.Status)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
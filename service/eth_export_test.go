@@ -0,0 +1,44 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_ExportTransactions(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	var buf bytes.Buffer
+	assert.Equal(t, ErrNotSubscribed, instance.ExportTransactions(ctx, addrA, ExportJSON, &buf))
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.ExportTransactions(ctx, addrA, ExportJSON, &buf))
+	assert.Equal(t, "[]\n", buf.String())
+
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x10", TransactionIndex: "0x0", From: addrA, To: addrB, Value: "0xde0b6b3a7640000"},
+		{Hash: "0x2", BlockNumber: "0x10", TransactionIndex: "0x1", From: addrC, To: addrA, Value: "0x1"},
+	}}))
+	buf.Reset()
+	assert.Nil(t, instance.ExportTransactions(ctx, upperAddrA, "JSON", &buf))
+	var exported []*model.ETHTransaction
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &exported))
+	assert.Equal(t, []string{"0x1", "0x2"}, hashesOf(exported))
+	assert.Equal(t, model.DirectionInbound, exported[1].Direction)
+
+	buf.Reset()
+	assert.Nil(t, instance.ExportTransactions(ctx, addrA, ExportCSV, &buf))
+	assert.Equal(t, "hash,from,to,value,block,direction,status\n"+
+		"0x1,"+addrA+","+addrB+",1000000000000000000,16,out,\n"+
+		"0x2,"+addrC+","+addrA+",1,16,in,\n", buf.String())
+
+	buf.Reset()
+	err := instance.ExportTransactions(ctx, addrA, "xml", &buf)
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+	assert.Equal(t, 0, buf.Len())
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// valueCallTypes call frames able to move ether, a DELEGATECALL reports the value of its parent and a
+// STATICCALL moves none.
+var valueCallTypes = map[string]bool{
+	"CALL":         true,
+	"CREATE":       true,
+	"CREATE2":      true,
+	"SELFDESTRUCT": true,
+}
+
+// matchInternal internal transfers of blockInfo sent from or to subAddrs, nil unless
+// Config.TrackInternalTransfers is set. a node rejecting the trace turns the feature off for good,
+// other errors fail the block, which is parsed again.
+func (s *ETHService) matchInternal(ctx context.Context, subAddrs map[string]*SubscriptionConfig, blockInfo *model.ETHBlockInfo) ([]txMatch, error) {
+	if !s.conf.TrackInternalTransfers || len(blockInfo.Transactions) == 0 || atomic.LoadInt32(&s.internalTransfersDisabled) == 1 {
+		return nil, nil
+	}
+	tracer, ok := s.client.(remote.BlockTracer)
+	if !ok {
+		s.disableInternalTransfers(ctx, errors.New("client can't trace blocks"))
+		return nil, nil
+	}
+	traces, err := tracer.TraceBlockByNumber(ctx, blockInfo.Number)
+	var rpcErr *model.JSONRPCError
+	if errors.As(err, &rpcErr) {
+		s.disableInternalTransfers(ctx, err)
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Error(ctx, "[matchInternal]: Error TraceBlockByNumber", "err", err)
+		return nil, err
+	}
+	byHash := make(map[string]*model.ETHTransaction, len(blockInfo.Transactions))
+	for _, tx := range blockInfo.Transactions {
+		byHash[strings.ToLower(tx.Hash)] = tx
+	}
+	var matches []txMatch
+	for i, trace := range traces {
+		if trace.Result == nil || len(trace.Result.Error) > 0 {
+			// a reverted transaction moves no value.
+			continue
+		}
+		// older nodes leave txHash out, traces are in block order.
+		tx := byHash[strings.ToLower(trace.TxHash)]
+		if tx == nil && len(trace.TxHash) == 0 && i < len(blockInfo.Transactions) {
+			tx = blockInfo.Transactions[i]
+		}
+		if tx == nil {
+			continue
+		}
+		// the root frame is the transaction itself, matched by matchTransactions.
+		for j, call := range trace.Result.Calls {
+			matches = s.matchCallFrame(matches, subAddrs, tx, call, fmt.Sprint(j))
+		}
+	}
+	withBlockTime(matches, blockInfo)
+	return matches, nil
+}
+
+// matchCallFrame append to matches the value moved by call and its subcalls from or to subAddrs, path
+// locating call in the call tree of tx.
+func (s *ETHService) matchCallFrame(matches []txMatch, subAddrs map[string]*SubscriptionConfig, tx *model.ETHTransaction, call *model.ETHCallFrame, path string) []txMatch {
+	if len(call.Error) > 0 {
+		// reverted along with its subcalls.
+		return matches
+	}
+	if valueCallTypes[strings.ToUpper(call.Type)] && hasValue(call.Value) {
+		internal := &model.ETHTransaction{
+			Hash:             tx.Hash,
+			BlockHash:        tx.BlockHash,
+			BlockNumber:      tx.BlockNumber,
+			TransactionIndex: tx.TransactionIndex,
+			From:             call.From,
+			To:               call.To,
+			Value:            call.Value,
+			Input:            call.Input,
+			Internal:         true,
+			TraceID:          tx.Hash + ":" + path,
+		}
+		for _, p := range transferParties(subAddrs, strings.ToLower(call.From), strings.ToLower(call.To)) {
+			if s.belowMinValue(internal, subAddrs[p.address]) {
+				continue
+			}
+			if stored := storedCopy(internal, p.direction); s.passesFilter(subAddrs[p.address], stored) {
+				matches = append(matches, txMatch{p.address, stored})
+			}
+		}
+	}
+	for j, sub := range call.Calls {
+		matches = s.matchCallFrame(matches, subAddrs, tx, sub, path+"."+fmt.Sprint(j))
+	}
+	return matches
+}
+
+// hasValue whether the hex value is above 0.
+func hasValue(value string) bool {
+	v, err := util.HexToBigInt(value)
+	return err == nil && v.Sign() > 0
+}
+
+// disableInternalTransfers stop tracing blocks, logging why once.
+func (s *ETHService) disableInternalTransfers(ctx context.Context, err error) {
+	if atomic.CompareAndSwapInt32(&s.internalTransfersDisabled, 0, 1) {
+		s.logger.Warn(ctx, "[matchInternal]: node can't trace blocks, internal transfers disabled", "err", err)
+	}
+}
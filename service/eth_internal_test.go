@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// fakeTracerClient fakeETHClient tracing its blocks with the call trees of traces, failing with err if set.
+type fakeTracerClient struct {
+	*fakeETHClient
+	traces map[string][]*model.ETHTransactionTrace // by block number.
+	err    error
+	calls  int
+}
+
+func (f *fakeTracerClient) TraceBlockByNumber(ctx context.Context, number string) ([]*model.ETHTransactionTrace, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.traces[number], nil
+}
+
+func newInternalTestService(t *testing.T, client *fakeTracerClient) *ETHService {
+	conf := testConfig()
+	conf.TrackTokenTransfers = false
+	instance, err := NewETHService(client, WithConfig(conf), WithInternalTransfers(true))
+	assert.Nil(t, err)
+	return instance
+}
+
+func TestETHService_InternalTransfers(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeTracerClient{fakeETHClient: newFakeETHClient(0), traces: map[string][]*model.ETHTransactionTrace{}}
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", TransactionIndex: "0x0", From: addrC, To: addrD, Value: "0x0"},
+		&model.ETHTransaction{Hash: "0x2", TransactionIndex: "0x1", From: addrA, To: addrD, Value: "0x5"},
+	)
+	client.traces["0x1"] = []*model.ETHTransactionTrace{
+		{TxHash: "0x1", Result: &model.ETHCallFrame{Type: "CALL", From: addrC, To: addrD, Value: "0x0", Calls: []*model.ETHCallFrame{
+			{Type: "STATICCALL", From: addrD, To: addrB},
+			{Type: "CALL", From: addrD, To: strings.ToUpper(addrA), Value: "0x0", Calls: []*model.ETHCallFrame{
+				{Type: "CALL", From: addrD, To: addrA, Value: "0x10"},
+				{Type: "CALL", From: addrD, To: addrA, Value: "0x20", Error: "execution reverted"},
+			}},
+			{Type: "DELEGATECALL", From: addrD, To: addrA, Value: "0x30"},
+		}}},
+		// the value of the transaction itself isn't reported twice.
+		{TxHash: "0x2", Result: &model.ETHCallFrame{Type: "CALL", From: addrA, To: addrD, Value: "0x5", Calls: []*model.ETHCallFrame{
+			{Type: "CALL", From: addrD, To: addrA, Value: "0x1"},
+		}}},
+	}
+	instance := newInternalTestService(t, client)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	// parsed again, nothing duplicated.
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, "0x1:1.0", list[0].TraceID)
+	assert.True(t, list[0].Internal)
+	assert.Equal(t, model.DirectionInbound, list[0].Direction)
+	assert.Equal(t, "0x10", list[0].Value)
+	assert.Equal(t, "0x0", list[0].TransactionIndex)
+	assert.False(t, list[1].Internal)
+	assert.Equal(t, model.DirectionOutbound, list[1].Direction)
+	assert.Equal(t, "0x2:0", list[2].TraceID)
+}
+
+func TestETHService_InternalTransfersUnsupported(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeTracerClient{fakeETHClient: newFakeETHClient(0)}
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrD})
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrD})
+	instance := newInternalTestService(t, client)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	// a transport error fails the block.
+	client.err = errors.New("connection reset")
+	assert.NotNil(t, instance.ParseTransactions(ctx, 1))
+	// a node without the debug namespace turns the feature off, blocks are still parsed.
+	client.err = &model.JSONRPCError{Code: methodNotFound, Message: "the method debug_traceBlockByNumber does not exist"}
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	assert.Nil(t, instance.ParseTransactions(ctx, 2))
+	assert.Equal(t, 2, client.calls)
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// maxLogTopics subscribed addresses per topic position of an eth_getLogs call, more are split across calls.
+const maxLogTopics = 100
+
+// matchTokenLogs transfer logs of blockInfo from or to subAddrs, asked with eth_getLogs when
+// Config.TokenTransfersFromLogs is set, false if they are to be found in the receipts instead. a node
+// rejecting eth_getLogs turns the feature off for good, other errors fail the block, which is parsed again.
+func (s *ETHService) matchTokenLogs(ctx context.Context, subAddrs map[string]*SubscriptionConfig, blockInfo *model.ETHBlockInfo) ([]*model.ETHLog, bool, error) {
+	if !s.conf.TrackTokenTransfers || !s.conf.TokenTransfersFromLogs || atomic.LoadInt32(&s.tokenLogsDisabled) == 1 {
+		return nil, false, nil
+	}
+	getter, ok := s.client.(remote.LogGetter)
+	if !ok {
+		s.disableTokenLogs(ctx, errors.New("client can't filter logs"))
+		return nil, false, nil
+	}
+	logs, err := s.fetchTokenLogs(ctx, getter, subAddrs, blockInfo.Hash)
+	var rpcErr *model.JSONRPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == methodNotFound {
+		s.disableTokenLogs(ctx, err)
+		return nil, false, nil
+	}
+	if err != nil {
+		s.logger.Error(ctx, "[matchTokenLogs]: Error fetchTokenLogs", "err", err)
+		return nil, false, err
+	}
+	return logs, true, nil
+}
+
+// fetchTokenLogs ERC-20, ERC-721 and ERC-1155 transfer logs of the block from or to subAddrs, in block
+// order. the block is asked by hash, a log of a competing block at the same height never shows up.
+func (s *ETHService) fetchTokenLogs(ctx context.Context, getter remote.LogGetter, subAddrs map[string]*SubscriptionConfig, blockHash string) ([]*model.ETHLog, error) {
+	topics := make([]string, 0, len(subAddrs))
+	for address := range subAddrs {
+		topics = append(topics, addressTopic(address))
+	}
+	sort.Strings(topics)
+	transfer := []string{model.TransferEventTopic}
+	multiTransfer := []string{model.TransferSingleEventTopic, model.TransferBatchEventTopic}
+	var logs []*model.ETHLog
+	seen := map[string]bool{}
+	for start := 0; start < len(topics); start += maxLogTopics {
+		end := start + maxLogTopics
+		if end > len(topics) {
+			end = len(topics)
+		}
+		chunk := topics[start:end]
+		for _, filter := range [][][]string{
+			{transfer, chunk},                // from, ERC-20 and ERC-721.
+			{transfer, nil, chunk},           // to.
+			{multiTransfer, nil, chunk},      // from, ERC-1155, after the operator.
+			{multiTransfer, nil, nil, chunk}, // to.
+		} {
+			found, err := getter.EthGetLogs(ctx, remote.LogFilter{BlockHash: blockHash, Topics: filter})
+			if err != nil {
+				return nil, err
+			}
+			// a transfer between two subscribed addresses matches twice.
+			for _, l := range found {
+				key := strings.ToLower(l.TransactionHash) + "/" + l.LogIndex
+				if !seen[key] {
+					seen[key] = true
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		a, _ := util.HexToInt64(logs[i].LogIndex)
+		b, _ := util.HexToInt64(logs[j].LogIndex)
+		return a < b
+	})
+	return logs, nil
+}
+
+// addressTopic address as a 32 bytes indexed topic, left padded with zeros.
+func addressTopic(address string) string {
+	return "0x000000000000000000000000" + strings.TrimPrefix(strings.ToLower(address), "0x")
+}
+
+// disableTokenLogs read the token transfers from receipts from now on, err tells why.
+func (s *ETHService) disableTokenLogs(ctx context.Context, err error) {
+	if atomic.CompareAndSwapInt32(&s.tokenLogsDisabled, 0, 1) {
+		s.logger.Warn(ctx, "[disableTokenLogs]: read token transfers from receipts", "err", err)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/tj/assert"
+)
+
+// logClient fakeETHClient filtering the logs of its receipts, or failing with err.
+type logClient struct {
+	*fakeETHClient
+	mu      sync.Mutex
+	filters []remote.LogFilter
+	err     error
+}
+
+func (c *logClient) EthGetLogs(ctx context.Context, filter remote.LogFilter) ([]*model.ETHLog, error) {
+	c.mu.Lock()
+	c.filters = append(c.filters, filter)
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	c.fakeETHClient.mu.Lock()
+	defer c.fakeETHClient.mu.Unlock()
+	var logs []*model.ETHLog
+	for number, block := range c.blocks {
+		if block.Hash != filter.BlockHash {
+			continue
+		}
+		for _, receipt := range c.receipts[number] {
+			for _, l := range receipt.Logs {
+				if logMatches(l, filter.Topics) {
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+	return logs, nil
+}
+
+// logMatches whether l matches topics the way a node does.
+func logMatches(l *model.ETHLog, topics [][]string) bool {
+	if len(l.Topics) < len(topics) {
+		return false
+	}
+	for i, position := range topics {
+		if position == nil {
+			continue
+		}
+		matched := false
+		for _, topic := range position {
+			matched = matched || strings.EqualFold(topic, l.Topics[i])
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func newTokenLogsBlock() *fakeETHClient {
+	operator := "0x00000000000000000000000000000000000000cc"
+	client := newFakeETHClient(1)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0xt1", From: holderA, To: tokenContract},
+		&model.ETHTransaction{Hash: "0xt2", From: operator, To: nftContract})
+	client.receipts[1] = []*model.ETHTransactionReceipt{{
+		TransactionHash: "0xt1",
+		Logs: []*model.ETHLog{
+			transferLogAt("0x0", holderA, holderB, "0x0a"),
+			transferLogAt("0x1", operator, holderB, "0x01"),
+			transferLogAt("0x2", operator, operator, "0x02"),
+		},
+	}, {
+		TransactionHash: "0xt2",
+		Logs: []*model.ETHLog{
+			erc721Log("0x3", holderB, holderA, 7),
+			nftLog("0x4", model.TransferSingleEventTopic,
+				[]string{addressTopic(operator), addressTopic(operator), addressTopic(holderA)}, "0x"+word(3)+word(5)),
+			nftLog("0x5", model.TransferSingleEventTopic,
+				[]string{addressTopic(holderA), addressTopic(operator), addressTopic(operator)}, "0x"+word(3)+word(5)),
+		},
+	}}
+	return client
+}
+
+func TestETHService_TokenTransfersFromLogs(t *testing.T) {
+	ctx := context.Background()
+	conf := testConfig()
+	conf.TokenTransfersFromLogs = true
+	client := &logClient{fakeETHClient: newTokenLogsBlock()}
+	fromLogs, err := NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	fromReceipts, err := NewETHService(newTokenLogsBlock(), WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	for _, instance := range []*ETHService{fromLogs, fromReceipts} {
+		assert.Nil(t, instance.Subscribe(ctx, holderA))
+		assert.Nil(t, instance.Subscribe(ctx, holderB))
+		assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	}
+	// asked by block hash, twice for each standard.
+	assert.Equal(t, 4, len(client.filters))
+	assert.Equal(t, "0xh1", client.filters[0].BlockHash)
+
+	// the same transfers either way.
+	for _, holder := range []string{holderA, holderB} {
+		want, err := fromReceipts.GetTokenTransfers(ctx, holder)
+		assert.Nil(t, err)
+		got, err := fromLogs.GetTokenTransfers(ctx, holder)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+		wantNFTs, err := fromReceipts.GetNFTTransfers(ctx, holder)
+		assert.Nil(t, err)
+		gotNFTs, err := fromLogs.GetNFTTransfers(ctx, holder)
+		assert.Nil(t, err)
+		assert.Equal(t, wantNFTs, gotNFTs)
+		wantTxs, _ := fromReceipts.GetTransactions(ctx, holder)
+		gotTxs, _ := fromLogs.GetTransactions(ctx, holder)
+		assert.Equal(t, len(wantTxs), len(gotTxs))
+	}
+	transfers, _ := fromLogs.GetTokenTransfers(ctx, holderB)
+	assert.Equal(t, 2, len(transfers))
+	// holderA operating a transfer isn't a party of it.
+	nfts, _ := fromLogs.GetNFTTransfers(ctx, holderA)
+	assert.Equal(t, 2, len(nfts))
+}
+
+func TestETHService_TokenTransfersFromLogsUnsupported(t *testing.T) {
+	ctx := context.Background()
+	conf := testConfig()
+	conf.TokenTransfersFromLogs = true
+	client := &logClient{fakeETHClient: newTokenLogsBlock(), err: &model.JSONRPCError{Code: methodNotFound, Message: "method not found"}}
+	instance, err := NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, holderB))
+	// the receipts take over.
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	transfers, _ := instance.GetTokenTransfers(ctx, holderB)
+	assert.Equal(t, 2, len(transfers))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	assert.Equal(t, 1, len(client.filters))
+
+	// other errors fail the block.
+	client = &logClient{fakeETHClient: newTokenLogsBlock(), err: remote.ErrRPCUnavailable}
+	instance, err = NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, holderB))
+	assert.NotNil(t, instance.ParseTransactions(ctx, 1))
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// zeroAddress sender of minted tokens, recipient of burnt ones.
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+// decodeNFTLog decode an ERC-721 Transfer(address indexed from, address indexed to, uint256 indexed tokenId),
+// an ERC-1155 TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id,
+// uint256 value) or TransferBatch(address indexed operator, address indexed from, address indexed to,
+// uint256[] ids, uint256[] values) log.
+func decodeNFTLog(l *model.ETHLog) (*model.NFTTransfer, bool) {
+	if len(l.Topics) != 4 || l.Removed {
+		return nil, false
+	}
+	transfer := &model.NFTTransfer{
+		Contract:         strings.ToLower(l.Address),
+		TransactionHash:  l.TransactionHash,
+		TransactionIndex: l.TransactionIndex,
+		LogIndex:         l.LogIndex,
+		BlockNumber:      l.BlockNumber,
+		BlockHash:        l.BlockHash,
+	}
+	first := 1 // topic of from.
+	switch strings.ToLower(l.Topics[0]) {
+	case model.TransferEventTopic:
+		tokenID, ok := new(big.Int).SetString(strings.TrimPrefix(l.Topics[3], "0x"), 16)
+		if !ok {
+			return nil, false
+		}
+		transfer.Standard = model.NFTStandardERC721
+		transfer.TokenIDs = []string{tokenID.String()}
+		transfer.Amounts = []string{"1"}
+	case model.TransferSingleEventTopic:
+		words, ok := dataWords(l.Data)
+		if !ok || len(words) != 2 {
+			return nil, false
+		}
+		transfer.Standard = model.NFTStandardERC1155
+		transfer.TokenIDs = []string{words[0].String()}
+		transfer.Amounts = []string{words[1].String()}
+		first = 2
+	case model.TransferBatchEventTopic:
+		words, ok := dataWords(l.Data)
+		if !ok {
+			return nil, false
+		}
+		ids, ok := uintArray(words, 0)
+		if !ok {
+			return nil, false
+		}
+		values, ok := uintArray(words, 1)
+		if !ok || len(values) != len(ids) {
+			return nil, false
+		}
+		transfer.Standard = model.NFTStandardERC1155
+		transfer.TokenIDs, transfer.Amounts = ids, values
+		first = 2
+	default:
+		return nil, false
+	}
+	if first == 2 {
+		operator, ok := topicAddress(l.Topics[1])
+		if !ok {
+			return nil, false
+		}
+		transfer.Operator = operator
+	}
+	from, ok := topicAddress(l.Topics[first])
+	if !ok {
+		return nil, false
+	}
+	to, ok := topicAddress(l.Topics[first+1])
+	if !ok {
+		return nil, false
+	}
+	transfer.From, transfer.To = from, to
+	transfer.Mint, transfer.Burn = from == zeroAddress, to == zeroAddress
+	return transfer, true
+}
+
+// dataWords the 32 bytes words of ABI encoded log data, false if it isn't made of whole words.
+func dataWords(data string) ([]*big.Int, bool) {
+	data = strings.TrimPrefix(data, "0x")
+	if len(data)%64 != 0 {
+		return nil, false
+	}
+	words := make([]*big.Int, 0, len(data)/64)
+	for i := 0; i < len(data); i += 64 {
+		word, ok := new(big.Int).SetString(data[i:i+64], 16)
+		if !ok {
+			return nil, false
+		}
+		words = append(words, word)
+	}
+	return words, true
+}
+
+// uintArray decimal elements of the uint256[] whose offset is the head word of words.
+func uintArray(words []*big.Int, head int) ([]string, bool) {
+	if head >= len(words) || !words[head].IsInt64() || words[head].Int64()%32 != 0 {
+		return nil, false
+	}
+	at := words[head].Int64() / 32
+	if at >= int64(len(words)) {
+		return nil, false
+	}
+	n := words[at]
+	if !n.IsInt64() || n.Int64() > int64(len(words))-at-1 {
+		return nil, false
+	}
+	elements := make([]string, 0, n.Int64())
+	for _, word := range words[at+1 : at+1+n.Int64()] {
+		elements = append(elements, word.String())
+	}
+	return elements, true
+}
+
+func withNFTDirection(transfer *model.NFTTransfer, direction model.Direction) *model.NFTTransfer {
+	stored := *transfer
+	stored.Direction = direction
+	return &stored
+}
+
+// GetNFTTransfers get address's inbound/outbound ERC-721 and ERC-1155 transfers having Config.Confirmations
+// confirmations, in chain order.
+func (s *ETHService) GetNFTTransfers(ctx context.Context, address string) ([]*model.NFTTransfer, error) {
+	transfers, _, err := s.nftPage(ctx, address, store.Query{MaxBlock: store.NoMaxBlock})
+	return transfers, err
+}
+
+// GetNFTTransfersPage get at most limit of address's NFT transfers after cursor, paged like GetTransactionsPage.
+func (s *ETHService) GetNFTTransfersPage(ctx context.Context, address string, cursor string, limit int) ([]*model.NFTTransfer, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New("limit should be positive")
+	}
+	return s.nftPage(ctx, address, store.Query{Cursor: cursor, Limit: limit, MaxBlock: store.NoMaxBlock})
+}
+
+// nftPage q.MaxBlock is capped at the confirmed block.
+func (s *ETHService) nftPage(ctx context.Context, address string, q store.Query) ([]*model.NFTTransfer, string, error) {
+	if confirmed := s.confirmedBlock(); q.MaxBlock > confirmed {
+		q.MaxBlock = confirmed
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, "", err
+	}
+	transfers, next, err := s.storage.GetNFTTransfers(ctx, address, q)
+	if err != nil {
+		s.logger.Error(ctx, "[nftPage]: Error GetNFTTransfers", "err", err)
+		return nil, "", err
+	}
+	return append(make([]*model.NFTTransfer, 0, len(transfers)), transfers...), next, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+const nftContract = "0x06012c8cf97BEaD5deAe237070F9587f8E7A266d"
+
+// word 32 bytes ABI word of n, in hex without prefix.
+func word(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+func nftLog(logIndex, topic0 string, topics []string, data string) *model.ETHLog {
+	return &model.ETHLog{
+		Address:         nftContract,
+		Topics:          append([]string{topic0}, topics...),
+		Data:            data,
+		BlockNumber:     "0x1",
+		TransactionHash: "0xt1",
+		LogIndex:        logIndex,
+	}
+}
+
+func erc721Log(logIndex, from, to string, tokenID int) *model.ETHLog {
+	return nftLog(logIndex, model.TransferEventTopic, []string{addressTopic(from), addressTopic(to), "0x" + word(tokenID)}, "0x")
+}
+
+func TestDecodeNFTLog(t *testing.T) {
+	transfer, ok := decodeNFTLog(erc721Log("0x0", holderA, holderB, 7))
+	assert.True(t, ok)
+	assert.Equal(t, model.NFTStandardERC721, transfer.Standard)
+	assert.Equal(t, "0x06012c8cf97bead5deae237070f9587f8e7a266d", transfer.Contract)
+	assert.Equal(t, holderA, transfer.From)
+	assert.Equal(t, holderB, transfer.To)
+	assert.Equal(t, []string{"7"}, transfer.TokenIDs)
+	assert.Equal(t, []string{"1"}, transfer.Amounts)
+	assert.False(t, transfer.Mint || transfer.Burn)
+
+	operator := "0x00000000000000000000000000000000000000cc"
+	transfer, ok = decodeNFTLog(nftLog("0x1", model.TransferSingleEventTopic,
+		[]string{addressTopic(operator), addressTopic(zeroAddress), addressTopic(holderB)}, "0x"+word(3)+word(50)))
+	assert.True(t, ok)
+	assert.Equal(t, model.NFTStandardERC1155, transfer.Standard)
+	assert.Equal(t, operator, transfer.Operator)
+	assert.Equal(t, zeroAddress, transfer.From)
+	assert.Equal(t, []string{"3"}, transfer.TokenIDs)
+	assert.Equal(t, []string{"50"}, transfer.Amounts)
+	assert.True(t, transfer.Mint)
+	assert.False(t, transfer.Burn)
+
+	// ids at offset 0x40, values at offset 0xa0, two of each.
+	batch := "0x" + word(0x40) + word(0xa0) + word(2) + word(1) + word(2) + word(2) + word(10) + word(20)
+	transfer, ok = decodeNFTLog(nftLog("0x2", model.TransferBatchEventTopic,
+		[]string{addressTopic(operator), addressTopic(holderA), addressTopic(zeroAddress)}, batch))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"1", "2"}, transfer.TokenIDs)
+	assert.Equal(t, []string{"10", "20"}, transfer.Amounts)
+	assert.True(t, transfer.Burn)
+
+	malformed := []*model.ETHLog{
+		// ERC-20 Transfer, tokenId isn't indexed.
+		transferLog(holderA, holderB, "0x"+word(1)),
+		// values longer than the data.
+		nftLog("0x3", model.TransferBatchEventTopic, []string{addressTopic(operator), addressTopic(holderA), addressTopic(holderB)},
+			"0x"+word(0x40)+word(0xa0)+word(2)+word(1)+word(2)+word(3)+word(10)+word(20)),
+		// offset out of the data.
+		nftLog("0x4", model.TransferBatchEventTopic, []string{addressTopic(operator), addressTopic(holderA), addressTopic(holderB)},
+			"0x"+word(0x400)+word(0x40)+word(0)),
+		nftLog("0x5", model.TransferSingleEventTopic, []string{addressTopic(operator), addressTopic(holderA), addressTopic(holderB)}, "0x"+word(1)),
+	}
+	for _, l := range malformed {
+		_, ok = decodeNFTLog(l)
+		assert.False(t, ok)
+	}
+}
+
+func TestETHService_NFTTransfers(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(1)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0xt1", From: holderA, To: nftContract})
+	client.receipts[1] = []*model.ETHTransactionReceipt{{
+		TransactionHash: "0xt1",
+		Logs: []*model.ETHLog{
+			erc721Log("0x0", holderA, holderB, 7),
+			transferLogAt("0x1", holderA, holderB, "0x0a"),
+			nftLog("0x2", model.TransferSingleEventTopic,
+				[]string{addressTopic(holderB), addressTopic(holderB), addressTopic(holderA)}, "0x"+word(3)+word(5)),
+			erc721Log("0x3", zeroAddress, holderA, 8),
+		},
+	}}
+	instance := newTestETHService()
+	instance.client = client
+	assert.Nil(t, instance.Subscribe(ctx, holderA))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+
+	transfers, err := instance.GetNFTTransfers(ctx, holderA)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(transfers))
+	assert.Equal(t, model.DirectionOutbound, transfers[0].Direction)
+	assert.Equal(t, model.DirectionInbound, transfers[1].Direction)
+	assert.Equal(t, model.NFTStandardERC1155, transfers[1].Standard)
+	assert.True(t, transfers[2].Mint)
+	tokens, _ := instance.GetTokenTransfers(ctx, holderA)
+	assert.Equal(t, 1, len(tokens))
+
+	page, next, err := instance.GetNFTTransfersPage(ctx, holderA, "", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, transfers[:2], page)
+	page, next, err = instance.GetNFTTransfersPage(ctx, holderA, next, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, transfers[2:], page)
+	assert.Equal(t, "", next)
+	_, _, err = instance.GetNFTTransfersPage(ctx, holderA, "", 0)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, instance.Unsubscribe(ctx, holderA, true))
+	transfers, _ = instance.GetNFTTransfers(ctx, holderA)
+	assert.Equal(t, 0, len(transfers))
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// observer function registered by RegisterObserver.
+type observer struct {
+	fn func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction)
+}
+
+// observation transaction stored for address, waiting for the observers.
+type observation struct {
+	ctx     context.Context
+	address string
+	tx      *model.ETHTransaction
+}
+
+// observerPool workers calling the observers, the observations of an address all go through the same
+// worker so they are observed in order.
+type observerPool struct {
+	queues []chan observation
+	done   chan struct{} // closed once every worker exited.
+}
+
+// RegisterObserver call fn for each transaction stored for a subscribed address, with the address and the
+// direction relative to it, until unregister is called. fn is called on a pool of Config.ObserverWorkers
+// goroutines rather than by the parsing loop, in the order the transactions of an address are stored. a
+// transaction which doesn't fit the queue of Config.ObserverQueueSize is dropped and counted in
+// DroppedObservations rather than holding back parsing. each call gets its own copy of tx, a panic of fn
+// is logged and recovered.
+func (s *ETHService) RegisterObserver(fn func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction)) (unregister func()) {
+	o := &observer{fn: fn}
+	s.observerMutex.Lock()
+	s.observers = append(s.observers, o)
+	s.observerMutex.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.observerMutex.Lock()
+			defer s.observerMutex.Unlock()
+			for i, registered := range s.observers {
+				if registered == o {
+					s.observers = append(s.observers[:i:i], s.observers[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// DroppedObservations transactions dropped so far because the queue of the observers was full.
+func (s *ETHService) DroppedObservations(ctx context.Context) int64 {
+	return atomic.LoadInt64(&s.droppedObservations)
+}
+
+// observe queue the transactions just stored for address for the observers, without waiting for them.
+// the pool is started on the first observation after RegisterObserver, or after Stop.
+func (s *ETHService) observe(ctx context.Context, address string, transactions []*model.ETHTransaction) {
+	s.observerMutex.Lock()
+	defer s.observerMutex.Unlock()
+	if len(s.observers) == 0 {
+		return
+	}
+	if s.observerPool == nil {
+		s.observerPool = s.startObserverPool()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(address))
+	queue := s.observerPool.queues[h.Sum32()%uint32(len(s.observerPool.queues))]
+	for _, tx := range transactions {
+		select {
+		case queue <- observation{ctx: ctx, address: address, tx: tx}:
+		default:
+			atomic.AddInt64(&s.droppedObservations, 1)
+		}
+	}
+}
+
+// startObserverPool start the workers of Config.ObserverWorkers. the caller holds observerMutex.
+func (s *ETHService) startObserverPool() *observerPool {
+	workers, size := s.conf.ObserverWorkers, s.conf.ObserverQueueSize
+	if workers <= 0 {
+		workers = DefaultConfig().ObserverWorkers
+	}
+	if size <= 0 {
+		size = DefaultConfig().ObserverQueueSize
+	}
+	pool := &observerPool{done: make(chan struct{})}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		queue := make(chan observation, size)
+		pool.queues = append(pool.queues, queue)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range queue {
+				s.notifyObservers(o)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(pool.done)
+	}()
+	return pool
+}
+
+// notifyObservers call the observers registered now with o.
+func (s *ETHService) notifyObservers(o observation) {
+	s.observerMutex.Lock()
+	observers := s.observers
+	s.observerMutex.Unlock()
+	for _, registered := range observers {
+		s.callObserver(registered, o)
+	}
+}
+
+// callObserver call one observer with a copy of the transaction of o, recovering its panic.
+func (s *ETHService) callObserver(registered *observer, o observation) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(o.ctx, "[callObserver]: Error observer panicked", "address", o.address, "hash", o.tx.Hash, "panic", r)
+		}
+	}()
+	registered.fn(o.ctx, o.address, o.tx.Direction, o.tx.Clone())
+}
+
+// stopObservers wait for the queued observations to be observed, or until ctx is done. the pool is
+// started again by the next observation.
+func (s *ETHService) stopObservers(ctx context.Context) error {
+	s.observerMutex.Lock()
+	pool := s.observerPool
+	s.observerPool = nil
+	if pool != nil {
+		// observe sends under observerMutex, nothing is sent on the closed queues.
+		for _, queue := range pool.queues {
+			close(queue)
+		}
+	}
+	s.observerMutex.Unlock()
+	if pool == nil {
+		return nil
+	}
+	select {
+	case <-pool.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
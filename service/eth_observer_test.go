@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_RegisterObserver(t *testing.T) {
+	ctx := context.Background()
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+
+	observed := make(chan string, 100)
+	unregister := instance.RegisterObserver(func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction) {
+		observed <- address + " " + string(direction) + " " + tx.Hash
+		tx.Hash = "modified"
+	})
+	// a panicking observer doesn't stop the others.
+	instance.RegisterObserver(func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction) {
+		panic("observer bug")
+	})
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", TransactionIndex: "0x0", From: addrA, To: addrC},
+		{Hash: "0x2", BlockNumber: "0x1", TransactionIndex: "0x1", From: addrC, To: addrD},
+		{Hash: "0x3", BlockNumber: "0x1", TransactionIndex: "0x2", From: addrB, To: addrA},
+		{Hash: "0x4", BlockNumber: "0x1", TransactionIndex: "0x3", From: addrC, To: addrA},
+	}}))
+	// Stop waits for the queued observations.
+	assert.Nil(t, instance.Stop(ctx))
+	close(observed)
+	var byAddress = map[string][]string{}
+	for o := range observed {
+		byAddress[o[:len(addrA)]] = append(byAddress[o[:len(addrA)]], o[len(addrA)+1:])
+	}
+	// in the order they are stored per address, 0x3 once for each of its addresses.
+	assert.Equal(t, []string{"out 0x1", "in 0x3", "in 0x4"}, byAddress[addrA])
+	assert.Equal(t, []string{"out 0x3"}, byAddress[addrB])
+	// observers get copies.
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1", "0x3", "0x4"}, hashesOf(list))
+
+	// once unregistered, an observer isn't called anymore, the pool starts again after Stop.
+	unregister()
+	unregister()
+	observed = make(chan string, 100)
+	called := make(chan struct{}, 1)
+	instance.RegisterObserver(func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction) {
+		called <- struct{}{}
+	})
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x5", BlockNumber: "0x2", TransactionIndex: "0x0", From: addrB, To: addrC},
+	}}))
+	select {
+	case <-called:
+	case <-time.After(3 * time.Second):
+		t.Fatal("observer not called after Stop")
+	}
+	assert.Nil(t, instance.Stop(ctx))
+	assert.Equal(t, 0, len(observed))
+	assert.Equal(t, int64(0), instance.DroppedObservations(ctx))
+}
+
+func TestETHService_RegisterObserverSlow(t *testing.T) {
+	ctx := context.Background()
+	conf := testConfig()
+	conf.ObserverWorkers = 1
+	conf.ObserverQueueSize = 1
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	entered, release := make(chan string, 10), make(chan struct{})
+	instance.RegisterObserver(func(ctx context.Context, address string, direction model.Direction, tx *model.ETHTransaction) {
+		entered <- tx.Hash
+		<-release
+	})
+	parse := func(i int64) {
+		assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+			{Hash: fmt.Sprintf("0x%x", i), BlockNumber: fmt.Sprintf("0x%x", i), TransactionIndex: "0x0", From: addrA},
+		}}))
+	}
+	parse(1)
+	assert.Equal(t, "0x1", <-entered)
+
+	// the observer is stuck, parsing goes on regardless: one transaction is queued, the others dropped.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := int64(2); i <= 4; i++ {
+			parse(i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parsing blocked by a slow observer")
+	}
+	assert.Equal(t, int64(2), instance.DroppedObservations(ctx))
+	close(release)
+	assert.Equal(t, "0x2", <-entered)
+	assert.Nil(t, instance.Stop(ctx))
+}
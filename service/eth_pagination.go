@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// GetTransactionsPage get at most limit address's inbound/outbound transactions after cursor,
+// an empty cursor starts from the oldest transaction. the returned cursor fetches the next page,
+// it is empty when there is nothing left.
+func (s *ETHService) GetTransactionsPage(ctx context.Context, address string, cursor string, limit int) ([]*model.ETHTransaction, string, error) {
+	return s.FilterTransactionsPage(ctx, address, model.TxFilter{}, cursor, limit)
+}
+
+// FilterTransactionsPage GetTransactionsPage over the transactions which meet the filter.
+func (s *ETHService) FilterTransactionsPage(ctx context.Context, address string, filter model.TxFilter, cursor string, limit int) ([]*model.ETHTransaction, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New("limit should be positive")
+	}
+	return s.page(ctx, address, store.Query{Cursor: cursor, Limit: limit, Filter: filter, MaxBlock: store.NoMaxBlock})
+}
+
+// GetTransactionsInRange get address's transactions from block fromBlock to toBlock, both included.
+// toBlock shouldn't be beyond the most recent parsed block.
+func (s *ETHService) GetTransactionsInRange(ctx context.Context, address string, fromBlock, toBlock int64) ([]*model.ETHTransaction, error) {
+	if fromBlock < 0 || fromBlock > toBlock {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", fromBlock, toBlock)
+	}
+	if recent := s.LastProcessedBlock(ctx); toBlock > recent {
+		return nil, fmt.Errorf("toBlock %d is beyond the most recent parsed block %d", toBlock, recent)
+	}
+	// the cursor of a position before any transaction of fromBlock.
+	cursor := store.EncodeCursor(store.Position{Block: fromBlock, Index: -1})
+	transactions, _, err := s.page(ctx, address, store.Query{Cursor: cursor, MaxBlock: toBlock})
+	return transactions, err
+}
+
+// GetStoredTransactionsPage GetTransactionsPage over every stored transaction, the ones within the
+// confirmation window included, which SubscribeChan streams as soon as they are stored. a client of the
+// stream replays what it missed with it.
+func (s *ETHService) GetStoredTransactionsPage(ctx context.Context, address string, cursor string, limit int) ([]*model.ETHTransaction, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New("limit should be positive")
+	}
+	return s.query(ctx, address, store.Query{Cursor: cursor, Limit: limit, MaxBlock: store.NoMaxBlock})
+}
+
+// page q.MaxBlock is capped at the confirmed block, a q.Limit <= 0 returns every transaction after q.Cursor.
+func (s *ETHService) page(ctx context.Context, address string, q store.Query) ([]*model.ETHTransaction, string, error) {
+	if len(q.Filter.State) > 0 && q.Filter.State != model.TxStateConfirmed {
+		// storage keeps mined transactions only.
+		return []*model.ETHTransaction{}, "", nil
+	}
+	if confirmed := s.confirmedBlock(); q.MaxBlock > confirmed {
+		q.MaxBlock = confirmed
+	}
+	return s.query(ctx, address, q)
+}
+
+// query page without capping q.MaxBlock.
+func (s *ETHService) query(ctx context.Context, address string, q store.Query) ([]*model.ETHTransaction, string, error) {
+	if q.Limit < 0 {
+		q.Limit = 0
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, "", err
+	}
+	list, next, err := s.storage.GetTransactions(ctx, address, q)
+	if err != nil {
+		s.logger.Error(ctx, "[query]: Error GetTransactions", "err", err)
+		return nil, "", err
+	}
+	recent := s.LastProcessedBlock(ctx)
+	transactions := make([]*model.ETHTransaction, 0, len(list))
+	for _, tx := range list {
+		transactions = append(transactions, withConfirmations(tx, recent))
+	}
+	return transactions, next, nil
+}
+
+// GetTransactionsPaged get a page of address's transactions ordered by block number descending,
+// most recent first, along with the total number of transactions. an offset out of range
+// yields an empty page.
+func (s *ETHService) GetTransactionsPaged(ctx context.Context, address string, offset, limit int) ([]*model.ETHTransaction, int, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, 0, errors.New("offset should not be negative and limit should be positive")
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, _, err := s.storage.GetTransactions(ctx, address, store.Query{MaxBlock: s.confirmedBlock()})
+	if err != nil {
+		s.logger.Error(ctx, "[GetTransactionsPaged]: Error GetTransactions", "err", err)
+		return nil, 0, err
+	}
+	total := len(list)
+	recent := s.LastProcessedBlock(ctx)
+	transactions := make([]*model.ETHTransaction, 0, limit)
+	// transactions are stored in chain order, walk backwards from the newest one.
+	for i := total - 1 - offset; i >= 0 && len(transactions) < limit; i-- {
+		transactions = append(transactions, withConfirmations(list[i], recent))
+	}
+	return transactions, total, nil
+}
+
+// withConfirmations clone of a stored tx with its confirmations at block recent, stored transactions
+// are shared with parsing and never modified once stored.
+func withConfirmations(tx *model.ETHTransaction, recent int64) *model.ETHTransaction {
+	queried := tx.Clone()
+	if confirmations := recent - store.PositionOf(tx).Block; confirmations > 0 {
+		queried.Confirmations = confirmations
+	}
+	// stored before transactions had a state, a stored transaction is mined.
+	if len(queried.State) == 0 {
+		queried.State = model.TxStateConfirmed
+	}
+	return queried
+}
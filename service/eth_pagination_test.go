@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func pageTestBlock(number int64, address string, txNum int) *model.ETHBlockInfo {
+	block := &model.ETHBlockInfo{Number: fmt.Sprintf("0x%x", number)}
+	for i := 0; i < txNum; i++ {
+		block.Transactions = append(block.Transactions, &model.ETHTransaction{
+			Hash:             fmt.Sprintf("0x%x%04x", number, i),
+			BlockNumber:      fmt.Sprintf("0x%x", number),
+			TransactionIndex: fmt.Sprintf("0x%x", i),
+			From:             address,
+			To:               addrB,
+		})
+	}
+	return block
+}
+
+func TestETHService_GetTransactionsPage(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	instance := newTestETHService()
+	instance.Subscribe(ctx, address)
+	instance.parseBlock(ctx, pageTestBlock(1, address, 3))
+	instance.parseBlock(ctx, pageTestBlock(2, address, 2))
+
+	page, cursor, err := instance.GetTransactionsPage(ctx, address, "", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x10000", "0x10001"}, hashesOf(page))
+	assert.NotEqual(t, "", cursor)
+
+	// appending more transactions keeps the cursor valid.
+	instance.parseBlock(ctx, pageTestBlock(3, address, 1))
+	page, cursor, err = instance.GetTransactionsPage(ctx, address, cursor, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x10002", "0x20000"}, hashesOf(page))
+
+	page, cursor, err = instance.GetTransactionsPage(ctx, address, cursor, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x20001", "0x30000"}, hashesOf(page))
+	assert.Equal(t, "", cursor)
+
+	all, err := instance.GetTransactions(ctx, address)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(all))
+}
+
+func TestETHService_GetTransactionsPageInvalid(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	_, _, err := instance.GetTransactionsPage(ctx, addrA, "", 0)
+	assert.NotNil(t, err)
+	_, _, err = instance.GetTransactionsPage(ctx, addrA, "not a cursor", 10)
+	assert.NotNil(t, err)
+	page, cursor, err := instance.GetTransactionsPage(ctx, addrA, "", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(page))
+	assert.Equal(t, "", cursor)
+}
+
+func hashesOf(transactions []*model.ETHTransaction) []string {
+	hashes := make([]string, 0, len(transactions))
+	for _, tx := range transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	return hashes
+}
+
+func TestETHService_GetTransactionsPaged(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	instance := newTestETHService()
+	instance.Subscribe(ctx, address)
+	instance.parseBlock(ctx, pageTestBlock(1, address, 2))
+	instance.parseBlock(ctx, pageTestBlock(2, address, 2))
+
+	page, total, err := instance.GetTransactionsPaged(ctx, address, 0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"0x20001", "0x20000", "0x10001"}, hashesOf(page))
+
+	page, total, err = instance.GetTransactionsPaged(ctx, address, 3, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"0x10000"}, hashesOf(page))
+
+	page, total, err = instance.GetTransactionsPaged(ctx, address, 10, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, 0, len(page))
+
+	_, _, err = instance.GetTransactionsPaged(ctx, address, -1, 3)
+	assert.NotNil(t, err)
+	_, _, err = instance.GetTransactionsPaged(ctx, address, 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestETHService_GetTransactionsInRange(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	instance := newTestETHService()
+	instance.Subscribe(ctx, address)
+	for number := int64(1); number <= 4; number++ {
+		instance.parseBlock(ctx, pageTestBlock(number, address, 2))
+	}
+	instance.recentBlockNumer = 4
+
+	list, err := instance.GetTransactionsInRange(ctx, address, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x20000", "0x20001", "0x30000", "0x30001"}, hashesOf(list))
+	list, err = instance.GetTransactionsInRange(ctx, address, 4, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x40000", "0x40001"}, hashesOf(list))
+	list, err = instance.GetTransactionsInRange(ctx, address, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x10000", "0x10001"}, hashesOf(list))
+
+	_, err = instance.GetTransactionsInRange(ctx, address, 3, 2)
+	assert.NotNil(t, err)
+	_, err = instance.GetTransactionsInRange(ctx, address, -1, 2)
+	assert.NotNil(t, err)
+	// beyond the most recent parsed block.
+	_, err = instance.GetTransactionsInRange(ctx, address, 1, 5)
+	assert.NotNil(t, err)
+}
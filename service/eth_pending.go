@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+)
+
+// maxPendingPerAddress pending transactions kept per address at most, more are ignored until some are mined.
+const maxPendingPerAddress = 1000
+
+// pendingTx a transaction of a subscribed address seen in the mempool, not mined yet.
+type pendingTx struct {
+	tx   *model.ETHTransaction
+	seen time.Time
+}
+
+// watchPending keep the pending transactions of subscribed addresses until ctx is done, from the node's
+// pending block every Config.PendingPollInterval if set, otherwise from the node's mempool subscription,
+// resubscribing with backoff while it keeps failing. the pending block is polled every Config.PollInterval
+// if the client can't subscribe the mempool. pending transactions expire meanwhile, see expirePending.
+func (s *ETHService) watchPending(ctx context.Context) {
+	expired := make(chan struct{})
+	go func() {
+		defer close(expired)
+		s.expirePendingEvery(ctx)
+	}()
+	defer func() { <-expired }()
+	if s.conf.PendingPollInterval > 0 {
+		s.pollPending(ctx, s.conf.PendingPollInterval)
+		return
+	}
+	sub, ok := s.client.(remote.PendingSubscriber)
+	if !ok {
+		s.logger.Info(ctx, "[watchPending]: client can't subscribe pending transactions, poll the pending block")
+		s.pollPending(ctx, s.conf.PollInterval)
+		return
+	}
+	backoff := minResubscribeBackoff
+	for ctx.Err() == nil {
+		txs, err := sub.SubscribePendingTransactions(ctx)
+		if errors.Is(err, remote.ErrWebSocketUnsupported) {
+			s.logger.Info(ctx, "[watchPending]: no websocket endpoint, poll the pending block")
+			s.pollPending(ctx, s.conf.PollInterval)
+			return
+		}
+		if err == nil {
+			received := 0
+			for tx := range txs {
+				received++
+				s.addPending(tx)
+			}
+			// a subscription dropping before any transaction doesn't count as recovered.
+			if received > 0 {
+				backoff = minResubscribeBackoff
+			}
+		} else {
+			s.logger.Error(ctx, "[watchPending]: Error SubscribePendingTransactions", "err", err)
+		}
+		if !s.sleep(ctx, backoff) {
+			return
+		}
+		backoff *= 2
+		if backoff > maxResubscribeBackoff {
+			backoff = maxResubscribeBackoff
+		}
+	}
+}
+
+// pollPending keep the transactions of the node's pending block every interval until ctx is done.
+func (s *ETHService) pollPending(ctx context.Context, interval time.Duration) {
+	for {
+		block, err := s.client.EthGetBlockByNumber(ctx, "pending")
+		if err != nil {
+			s.logger.Error(ctx, "[pollPending]: Error EthGetBlockByNumber pending", "err", err)
+		} else if block != nil {
+			for _, tx := range block.Transactions {
+				s.addPending(tx)
+			}
+		}
+		if !s.sleep(ctx, interval) {
+			return
+		}
+	}
+}
+
+// expirePendingEvery expire the pending transactions every quarter of Config.PendingHorizon until ctx is done.
+func (s *ETHService) expirePendingEvery(ctx context.Context) {
+	if s.conf.PendingHorizon <= 0 {
+		return
+	}
+	for s.sleep(ctx, s.conf.PendingHorizon/4) {
+		s.expirePending(time.Now())
+	}
+}
+
+// sleep wait for d on s.clock, false if ctx is done first.
+func (s *ETHService) sleep(ctx context.Context, d time.Duration) bool {
+	t := s.clock.NewTimer(d)
+	select {
+	case <-ctx.Done():
+		t.Stop()
+		return false
+	case <-t.C():
+		return true
+	}
+}
+
+// addPending keep tx for the subscribed addresses it is sent from or to, the same filters as mined
+// transactions apply.
+func (s *ETHService) addPending(tx *model.ETHTransaction) {
+	if len(tx.Hash) == 0 {
+		return
+	}
+	hash := strings.ToLower(tx.Hash)
+	now := time.Now()
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	parties := transferParties(s.subAddrs, strings.ToLower(tx.From), strings.ToLower(tx.To))
+	if len(parties) == 0 {
+		return
+	}
+	s.pendingMutex.Lock()
+	defer s.pendingMutex.Unlock()
+	for _, p := range parties {
+		if s.belowMinValue(tx, s.subAddrs[p.address]) {
+			continue
+		}
+		txs, ok := s.pending[p.address]
+		if !ok {
+			txs = map[string]*pendingTx{}
+			s.pending[p.address] = txs
+		}
+		if known, ok := txs[hash]; ok {
+			// back in the mempool, such as after a node restart.
+			if known.tx.State == model.TxStateDropped {
+				known.tx.State = model.TxStatePending
+				known.seen = now
+			}
+			continue
+		}
+		if len(txs) >= maxPendingPerAddress {
+			continue
+		}
+		pending := storedCopy(tx, p.direction)
+		if !s.passesFilter(s.subAddrs[p.address], pending) {
+			continue
+		}
+		pending.State = model.TxStatePending
+		// the pending block numbers its transactions, they may be mined in another block though.
+		pending.BlockHash, pending.BlockNumber, pending.TransactionIndex = "", "", ""
+		txs[hash] = &pendingTx{tx: pending, seen: now}
+	}
+}
+
+// expirePending report dropped the transactions pending for Config.PendingHorizon at now, and forget the
+// ones dropped for as long again.
+func (s *ETHService) expirePending(now time.Time) {
+	if s.conf.PendingHorizon <= 0 {
+		return
+	}
+	s.pendingMutex.Lock()
+	defer s.pendingMutex.Unlock()
+	for address, txs := range s.pending {
+		for hash, p := range txs {
+			switch age := now.Sub(p.seen); {
+			case age >= 2*s.conf.PendingHorizon:
+				delete(txs, hash)
+			case age >= s.conf.PendingHorizon:
+				p.tx.State = model.TxStateDropped
+			}
+		}
+		if len(txs) == 0 {
+			delete(s.pending, address)
+		}
+	}
+}
+
+// confirmPending drop the pending or dropped entries of address's transactions just mined, the mined ones
+// replace them.
+func (s *ETHService) confirmPending(address string, mined []*model.ETHTransaction) {
+	s.pendingMutex.Lock()
+	defer s.pendingMutex.Unlock()
+	txs, ok := s.pending[address]
+	if !ok {
+		return
+	}
+	for _, tx := range mined {
+		delete(txs, strings.ToLower(tx.Hash))
+	}
+	if len(txs) == 0 {
+		delete(s.pending, address)
+	}
+}
+
+// dropPending drop the pending transactions of address.
+func (s *ETHService) dropPending(address string) {
+	s.pendingMutex.Lock()
+	delete(s.pending, address)
+	s.pendingMutex.Unlock()
+}
+
+// pendingOf copies of address's pending and dropped transactions meeting filter, in the order they were seen.
+func (s *ETHService) pendingOf(address string, filter model.TxFilter) []*model.ETHTransaction {
+	// copied under the lock, expirePending and addPending update the state and seen time.
+	s.pendingMutex.Lock()
+	list := make([]pendingTx, 0, len(s.pending[address]))
+	for _, p := range s.pending[address] {
+		if filter.Match(p.tx) {
+			list = append(list, pendingTx{tx: p.tx.Clone(), seen: p.seen})
+		}
+	}
+	s.pendingMutex.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].seen.Equal(list[j].seen) {
+			return list[i].seen.Before(list[j].seen)
+		}
+		return list[i].tx.Hash < list[j].tx.Hash
+	})
+	transactions := make([]*model.ETHTransaction, 0, len(list))
+	for _, p := range list {
+		transactions = append(transactions, p.tx)
+	}
+	return transactions
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// fakePendingClient fakeETHClient pushing the mempool transactions sent to txs, until ctx is done.
+type fakePendingClient struct {
+	*fakeETHClient
+	txs chan *model.ETHTransaction
+}
+
+func (f *fakePendingClient) SubscribePendingTransactions(ctx context.Context) (<-chan *model.ETHTransaction, error) {
+	txs := make(chan *model.ETHTransaction)
+	go func() {
+		defer close(txs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case tx := <-f.txs:
+				select {
+				case txs <- tx:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return txs, nil
+}
+
+func statesOf(list []*model.ETHTransaction) []string {
+	states := make([]string, 0, len(list))
+	for _, tx := range list {
+		states = append(states, tx.Hash+" "+string(tx.Direction)+" "+string(tx.State))
+	}
+	return states
+}
+
+func TestETHService_Pending(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+
+	instance.addPending(&model.ETHTransaction{Hash: "0x1", From: upperAddrA, To: addrC})
+	instance.addPending(&model.ETHTransaction{Hash: "0x2", From: addrC, To: addrD})
+	instance.addPending(&model.ETHTransaction{Hash: "0x3", From: addrB, To: addrA})
+	// seen again, kept once.
+	instance.addPending(&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrC})
+
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1 out pending", "0x3 in pending"}, statesOf(list))
+	list, err = instance.FilterTransactions(ctx, addrA, model.TxFilter{Direction: model.DirectionInbound})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3 in pending"}, statesOf(list))
+
+	// mined, confirmed rather than duplicated.
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", TransactionIndex: "0x0", From: addrA, To: addrC},
+	}}))
+	list, err = instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1 out confirmed", "0x3 in pending"}, statesOf(list))
+
+	assert.Nil(t, instance.Unsubscribe(ctx, addrB, false))
+	_, err = instance.GetTransactions(ctx, addrB)
+	assert.Equal(t, ErrNotSubscribed, err)
+	// still pending for the other side.
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 2, len(list))
+}
+
+func TestETHService_PendingDropped(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	instance.addPending(&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrC})
+	instance.addPending(&model.ETHTransaction{Hash: "0x2", From: addrC, To: addrA})
+	horizon := instance.conf.PendingHorizon
+
+	instance.expirePending(time.Now().Add(horizon / 2))
+	list, _ := instance.FilterTransactions(ctx, addrA, model.TxFilter{State: model.TxStatePending})
+	assert.Equal(t, 2, len(list))
+	instance.expirePending(time.Now().Add(horizon))
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1 out dropped", "0x2 in dropped"}, statesOf(list))
+
+	// mined after all.
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", TransactionIndex: "0x0", From: addrA, To: addrC},
+	}}))
+	list, _ = instance.FilterTransactions(ctx, addrA, model.TxFilter{State: model.TxStateConfirmed})
+	assert.Equal(t, []string{"0x1 out confirmed"}, statesOf(list))
+	list, _ = instance.FilterTransactions(ctx, addrA, model.TxFilter{State: model.TxStateDropped})
+	assert.Equal(t, []string{"0x2 in dropped"}, statesOf(list))
+
+	instance.expirePending(time.Now().Add(2 * horizon))
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x1 out confirmed"}, statesOf(list))
+}
+
+// fakePendingBlockClient fakeETHClient serving block as the pending one.
+type fakePendingBlockClient struct {
+	*fakeETHClient
+	block *model.ETHBlockInfo
+}
+
+func (f *fakePendingBlockClient) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	if number == "pending" {
+		return f.block, nil
+	}
+	return f.fakeETHClient.EthGetBlockByNumber(ctx, number)
+}
+
+func TestETHService_PollPending(t *testing.T) {
+	ctx := context.Background()
+	client := &fakePendingBlockClient{fakeETHClient: newFakeETHClient(0), block: &model.ETHBlockInfo{Number: "0x2",
+		Transactions: []*model.ETHTransaction{{Hash: "0x1", BlockNumber: "0x2", TransactionIndex: "0x0", From: addrC, To: addrA}}}}
+	conf := testConfig()
+	conf.TrackPendingTransactions = true
+	conf.PendingPollInterval = 10 * time.Millisecond
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Start(ctx))
+	defer instance.Stop(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list, err := instance.FilterTransactions(ctx, addrA, model.TxFilter{State: model.TxStatePending})
+		assert.Nil(t, err)
+		if len(list) == 1 {
+			assert.Equal(t, "", list[0].BlockNumber)
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pending block not polled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestETHService_WatchPending(t *testing.T) {
+	ctx := context.Background()
+	client := &fakePendingClient{fakeETHClient: newFakeETHClient(0), txs: make(chan *model.ETHTransaction, 1)}
+	conf := testConfig()
+	conf.TrackPendingTransactions = true
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Start(ctx))
+
+	client.txs <- &model.ETHTransaction{Hash: "0x1", From: addrC, To: addrA}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list, err := instance.GetTransactions(ctx, addrA)
+		assert.Nil(t, err)
+		if len(list) == 1 {
+			assert.Equal(t, []string{"0x1 in pending"}, statesOf(list))
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pending transaction not tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, instance.Stop(ctx))
+}
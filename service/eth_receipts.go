@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+)
+
+// methodNotFound JSON-RPC error code of a method the node doesn't implement.
+const methodNotFound = -32601
+
+// fetchReceipts receipts of blockInfo's transactions parsing needs, every one when all is set, for the
+// token transfers, the matched ones otherwise. a single eth_getBlockReceipts when the node has it, otherwise an
+// eth_getTransactionReceipt per transaction, Config.ReceiptConcurrency at a time. a receipt missing fails
+// the whole block, which is parsed again.
+func (s *ETHService) fetchReceipts(ctx context.Context, blockInfo *model.ETHBlockInfo, matches []txMatch, all bool) ([]*model.ETHTransactionReceipt, error) {
+	getter, ok := s.client.(remote.ReceiptGetter)
+	if !ok || atomic.LoadInt32(&s.blockReceiptsUnsupported) == 0 {
+		receipts, err := s.client.EthGetBlockReceipts(ctx, blockInfo.Number)
+		var rpcErr *model.JSONRPCError
+		if !ok || !errors.As(err, &rpcErr) || rpcErr.Code != methodNotFound {
+			return receipts, err
+		}
+		if atomic.CompareAndSwapInt32(&s.blockReceiptsUnsupported, 0, 1) {
+			s.logger.Warn(ctx, "[fetchReceipts]: node without eth_getBlockReceipts, fetch receipts one by one", "err", err)
+		}
+	}
+	var hashes []string
+	if all {
+		for _, tx := range blockInfo.Transactions {
+			hashes = append(hashes, tx.Hash)
+		}
+	} else {
+		// a transaction between two subscribed addresses is matched twice.
+		seen := map[string]bool{}
+		for _, m := range matches {
+			if hash := strings.ToLower(m.tx.Hash); !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, m.tx.Hash)
+			}
+		}
+	}
+	return s.fetchTransactionReceipts(ctx, getter, hashes)
+}
+
+// fetchTransactionReceipts receipts of hashes in order, fetched Config.ReceiptConcurrency at a time.
+// the first failure cancels the fetches still running.
+func (s *ETHService) fetchTransactionReceipts(ctx context.Context, getter remote.ReceiptGetter, hashes []string) ([]*model.ETHTransactionReceipt, error) {
+	concurrency := s.conf.ReceiptConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConfig().ReceiptConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	receipts := make([]*model.ETHTransactionReceipt, len(hashes))
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, concurrency)
+	for i, hash := range hashes {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, hash string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			receipt, err := getter.EthGetTransactionReceipt(ctx, hash)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				cancel()
+				return
+			}
+			receipts[i] = receipt
+		}(i, hash)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		s.logger.Error(ctx, "[fetchTransactionReceipts]: Error EthGetTransactionReceipt", "err", firstErr)
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
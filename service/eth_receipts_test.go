@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+// fakeReceiptClient fakeETHClient without eth_getBlockReceipts, serving the receipts of its blocks one by one.
+type fakeReceiptClient struct {
+	*fakeETHClient
+	receiptMutex  sync.Mutex
+	blockReceipts int             // eth_getBlockReceipts calls.
+	fetched       []string        // hashes passed to eth_getTransactionReceipt.
+	missing       map[string]bool // receipts not available yet, once.
+	inFlight      int
+	maxInFlight   int
+}
+
+func newFakeReceiptClient() *fakeReceiptClient {
+	return &fakeReceiptClient{fakeETHClient: newFakeETHClient(0), missing: map[string]bool{}}
+}
+
+func (f *fakeReceiptClient) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	f.receiptMutex.Lock()
+	defer f.receiptMutex.Unlock()
+	f.blockReceipts++
+	return nil, &model.JSONRPCError{Code: methodNotFound, Message: "the method eth_getBlockReceipts does not exist"}
+}
+
+func (f *fakeReceiptClient) EthGetTransactionReceipt(ctx context.Context, hash string) (*model.ETHTransactionReceipt, error) {
+	f.receiptMutex.Lock()
+	f.fetched = append(f.fetched, hash)
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	missing := f.missing[hash]
+	delete(f.missing, hash)
+	f.receiptMutex.Unlock()
+	time.Sleep(time.Millisecond)
+	f.receiptMutex.Lock()
+	f.inFlight--
+	f.receiptMutex.Unlock()
+	if missing {
+		return nil, errors.New("empty receipt")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, receipts := range f.receipts {
+		for _, receipt := range receipts {
+			if receipt.TransactionHash == hash {
+				return receipt, nil
+			}
+		}
+	}
+	return &model.ETHTransactionReceipt{TransactionHash: hash, Status: "0x1"}, nil
+}
+
+func TestETHService_ReceiptsOneByOne(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeReceiptClient()
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrC},
+		&model.ETHTransaction{Hash: "0x2", From: addrC, To: addrD},
+		&model.ETHTransaction{Hash: "0x3", From: addrA, To: addrB},
+		&model.ETHTransaction{Hash: "0x4", From: addrA},
+	)
+	client.receipts[1] = []*model.ETHTransactionReceipt{
+		{TransactionHash: "0x1", Status: "0x0", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00"},
+		{TransactionHash: "0x3", Status: "0x1"},
+		{TransactionHash: "0x4", Status: "0x1", ContractAddress: "0x00000000000000000000000000000000000000CC"},
+	}
+	client.missing["0x4"] = true
+	conf := testConfig()
+	conf.TrackTokenTransfers = false
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+
+	// a receipt missing fails the block, nothing is stored.
+	assert.NotNil(t, instance.ParseTransactions(ctx, 1))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 0, len(list))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	// eth_getBlockReceipts isn't asked again, 0x2 matches nobody, 0x3 is fetched once.
+	assert.Equal(t, 1, client.blockReceipts)
+	assert.Equal(t, 6, len(client.fetched))
+	assert.NotContains(t, client.fetched, "0x2")
+
+	list, err = instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, model.TxStatusFailed, list[0].Status)
+	assert.Equal(t, "0x5208", list[0].GasUsed)
+	assert.Equal(t, "0x3b9aca00", list[0].EffectiveGasPrice)
+	assert.Equal(t, "0x00000000000000000000000000000000000000cc", list[2].ContractAddress)
+
+	list, err = instance.FilterTransactions(ctx, addrA, model.TxFilter{ExcludeFailed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3", "0x4"}, []string{list[0].Hash, list[1].Hash})
+}
+
+func TestETHService_ReceiptConcurrency(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeReceiptClient()
+	var txs []*model.ETHTransaction
+	for i := 0; i < 10; i++ {
+		txs = append(txs, &model.ETHTransaction{Hash: fmt.Sprintf("0x%x", i), From: addrC, To: addrD})
+	}
+	txs[9].To = strings.ToUpper(addrA)
+	client.setBlock(1, "0xh1", "0xh0", txs...)
+	conf := testConfig()
+	conf.ReceiptConcurrency = 2
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	// token transfers need every receipt of the block.
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	assert.Equal(t, 10, len(client.fetched))
+	assert.True(t, client.maxInFlight <= 2)
+}
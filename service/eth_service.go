@@ -2,153 +2,1489 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sugarshop/token-gateway/logging"
 	"github.com/sugarshop/token-gateway/model"
 	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+	"github.com/sugarshop/token-gateway/webhook"
 )
 
+const (
+	// maxBlockRetries how many ticks a failing block is retried before it is skipped.
+	maxBlockRetries = 5
+	// maxCatchUpBlocks how many blocks load parses at most per tick, the rest is left to next ticks.
+	maxCatchUpBlocks = 100
+	// followTicks how many ticks an instance which lost the checkpoint to another one sharing it only follows
+	// it, rather than parsing, before trying to take over again.
+	followTicks = 5
+	// retentionSweepInterval how often the transactions past Config.MaxTransactionAge are dropped.
+	retentionSweepInterval = 1 * time.Minute
+	// minResubscribeBackoff, maxResubscribeBackoff bounds of the wait before resubscribing new heads.
+	minResubscribeBackoff = 1 * time.Second
+	maxResubscribeBackoff = 1 * time.Minute
+)
+
+// errChainReorg block's parent isn't the block parsed at previous height.
+var errChainReorg = errors.New("chain reorganization")
+
+// ErrClosed returned by the methods changing a closed ETHService, see Close.
+var ErrClosed = errors.New("service closed")
+
+var (
+	// ErrNotSubscribed the address isn't subscribed.
+	ErrNotSubscribed = errors.New("address is not subscribed")
+	// ErrInvalidAddress the address is malformed, or its EIP-55 checksum doesn't match.
+	ErrInvalidAddress = util.ErrInvalidAddress
+	// ErrRPCUnavailable the node couldn't be reached, even after retrying.
+	ErrRPCUnavailable = remote.ErrRPCUnavailable
+	// ErrBlockNotFound the node doesn't have the block.
+	ErrBlockNotFound = remote.ErrBlockNotFound
+)
+
+// storageError storage failed to store a block, the block itself isn't at fault.
+type storageError struct {
+	err error
+}
+
+func (e *storageError) Error() string {
+	return "storage: " + e.err.Error()
+}
+
+func (e *storageError) Unwrap() error {
+	return e.err
+}
+
 // ETHService ETH Transactions data parser service.
 type ETHService struct {
-	recentBlockNumer int64 // the most recent block number I have ever oberve.
+	// 64-bit atomically accessed fields first to keep them aligned on 32-bit platforms.
+	recentBlockNumer int64 // the most recent block number I have ever parsed, written by load.
+	skippedBlocks int64 // blocks given up after maxBlockRetries.
+	pollInterval int64 // time.Duration between polls, changed by SetPollInterval.
+	droppedEvents int64 // transactions dropped by full streams.
+	droppedObservations int64 // transactions dropped by the full queue of the observers.
+	lastLoad int64 // unix nanoseconds of the last successful load, or of creation before the first one.
+	lastHead int64 // unix nanoseconds of the last head pushed by the newHeads subscription, 0 if none.
+	resubscribes int64 // newHeads subscriptions made after the first one.
+	headSource int32 // HeadSource of new blocks, see HeadsStatus.
+	blockReceiptsUnsupported int32 // 1 once the node rejected eth_getBlockReceipts.
+	internalTransfersDisabled int32 // 1 once the node rejected debug_traceBlockByNumber.
+	tokenLogsDisabled int32 // 1 once the node rejected eth_getLogs.
+	closed int32 // 1 once Close is called.
+
+	conf Config
+	client remote.ETHClient
+	runMutex sync.Mutex // guards cancel and done.
+	cancel context.CancelFunc // stop the background loop, set once Start is called.
+	done chan struct{} // closed when the background loop exits.
+	pollIntervalChanged chan struct{} // wake up poll to reset its timer.
+	clock clock
+	blockHashes map[int64]string // hash of the last conf.ReorgDepth parsed blocks, owned by load.
+	blockRetries map[int64]int // failed attempts of the blocks not parsed yet, owned by load.
+	following int // ticks left only following the checkpoint of another instance, owned by load.
 	addrRWMutex sync.RWMutex
-	subAddrs map[string]bool
-	txRWMutex sync.RWMutex
-	transactions map[string][]*model.ETHTransaction
+	subAddrs map[string]*SubscriptionConfig // subscriptions of storage, cached for parsing, with their options.
+	subsVersion int64 // bumped whenever an address is subscribed, unsubscribed or its options set, guarded by addrRWMutex.
+	backfillMutex sync.Mutex
+	backfills map[string]*backfill // the latest SubscribeFrom backfill of each address.
+	storage      store.Storage
+	checkpointer store.Checkpointer // storage unless WithCheckpointer.
+	storageCloser io.Closer // closes the storage NewETHServiceFromConfig opened, nil otherwise. Close leaves it open for reads.
+	ensNames     map[string]*ensName // ENS names subscribed by name, guarded by addrRWMutex.
+	dispatcher   *webhook.Dispatcher
+	streamMutex  sync.Mutex
+	streams      map[*stream]struct{} // open SubscribeChan channels.
+	tokens       *TokenMetadataService // nil unless client is a remote.ContractCaller.
+	pendingMutex sync.Mutex
+	pending      map[string]map[string]*pendingTx // pending transactions by address then lowercase hash.
+	logger       logging.Logger
+	onBlockProcessed func(ctx context.Context, number int64, matched int) // nil unless WithOnBlockProcessed.
+	observerMutex sync.Mutex
+	observers     []*observer   // registered by RegisterObserver.
+	observerPool  *observerPool // nil until there is something to observe.
+	gasStats      gasStatsCache // of the recently parsed blocks, see GetGasStats.
+	metrics       Metrics
 }
 
 var (
 	eTHServiceInstance *ETHService
-	eTHServiceOnce sync.Once
+	eTHServiceMutex sync.Mutex
 )
 
-// ETHServiceInstance ETHService singleton
-func ETHServiceInstance() *ETHService {
-	eTHServiceOnce.Do(func() {
-		eTHServiceInstance = &ETHService{
-			subAddrs:   map[string]bool{},
-			transactions:  map[string][]*model.ETHTransaction{},
+// ETHServiceInstance ETHService of the default chain, polling the node configured by global env. see
+// NewETHServiceFromConfig for a service per chain. an error, such as the node being unreachable, is
+// returned rather than cached, the next call tries again.
+func ETHServiceInstance() (*ETHService, error) {
+	eTHServiceMutex.Lock()
+	defer eTHServiceMutex.Unlock()
+	if eTHServiceInstance != nil {
+		return eTHServiceInstance, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	instance, err := startETHService(ctx, loadConfig())
+	if err != nil {
+		return nil, err
+	}
+	eTHServiceInstance = instance
+	return eTHServiceInstance, nil
+}
+
+// startETHService create the ETHService of conf, asking the node and storage with ctx, and start it. the
+// background loop doesn't derive from ctx, it outlives the startup. the service is closed, and the storage
+// it opened, if it fails to start.
+func startETHService(ctx context.Context, conf Config) (*ETHService, error) {
+	instance, err := newETHServiceFromConfig(ctx, conf)
+	if err != nil {
+		logging.Std().Error(ctx, "[startETHService]: Error NewETHServiceFromConfig", "chain", conf.Chain, "err", err)
+		return nil, err
+	}
+	if err := instance.Start(context.Background()); err != nil {
+		instance.logger.Error(ctx, "[startETHService]: Error Start", "chain", conf.Chain, "err", err)
+		instance.Close()
+		if instance.storageCloser != nil {
+			instance.storageCloser.Close()
 		}
-		ctx := context.Background()
-		dec, err := remote.ETHRPCServiceInstance().ETHBlockDecimalNumber(ctx)
-		if err != nil {
-			log.Panicln(ctx, "[ETHServiceInstance]: Panic, Error ETHBlockDecimalNumber, err: ", err)
+		return nil, err
+	}
+	return instance, nil
+}
+
+// Option ETHService option.
+type Option func(s *ETHService)
+
+// WithConfig replace the whole Config, DefaultConfig is used if not set.
+func WithConfig(conf Config) Option {
+	return func(s *ETHService) {
+		s.conf = conf
+	}
+}
+
+// WithPollInterval poll new blocks every d while the chain moves, overriding Config.PollInterval.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *ETHService) {
+		s.conf.PollInterval = d
+	}
+}
+
+// WithMinConfirmations report a transaction once n blocks are parsed on top of its block,
+// overriding Config.Confirmations. transactions are still stored as soon as they are parsed.
+func WithMinConfirmations(n int) Option {
+	return func(s *ETHService) {
+		s.conf.Confirmations = n
+	}
+}
+
+// WithMinValue store only the transactions transferring at least wei, see Config.MinValue.
+func WithMinValue(wei *big.Int) Option {
+	return func(s *ETHService) {
+		s.conf.MinValue = wei
+	}
+}
+
+// WithRetention retain the most recent maxPerAddress transactions per address, and the ones of blocks mined
+// less than maxAge ago, overriding Config.MaxTransactionsPerAddress and Config.MaxTransactionAge. 0 lifts
+// either bound. see Evictions for what is dropped.
+func WithRetention(maxPerAddress int, maxAge time.Duration) Option {
+	return func(s *ETHService) {
+		s.conf.MaxTransactionsPerAddress = maxPerAddress
+		s.conf.MaxTransactionAge = maxAge
+	}
+}
+
+// WithStorage keep subscriptions and transactions in storage, rather than in memory.
+// parsing resumes from the checkpoint of storage if it has one. Config.MaxTransactionsPerAddress and
+// Config.MaxTransactionAge bound the default in-memory storage only, storage applies its own retention. a storage owned by
+// this instance alone, such as SQLite, may be wrapped in store.NewCache to serve reads from memory.
+func WithStorage(storage store.Storage) Option {
+	return func(s *ETHService) {
+		s.storage = storage
+	}
+}
+
+// WithCheckpointer keep the last processed block in c rather than in storage.
+func WithCheckpointer(c store.Checkpointer) Option {
+	return func(s *ETHService) {
+		s.checkpointer = c
+	}
+}
+
+// WithInternalTransfers store the internal transfers of subscribed addresses found in call traces, see
+// Config.TrackInternalTransfers.
+func WithInternalTransfers(enabled bool) Option {
+	return func(s *ETHService) {
+		s.conf.TrackInternalTransfers = enabled
+	}
+}
+
+// WithVerifySubscriptions make Subscribe ask the node for the address first, see Config.VerifySubscriptions.
+func WithVerifySubscriptions(enabled bool) Option {
+	return func(s *ETHService) {
+		s.conf.VerifySubscriptions = enabled
+	}
+}
+
+// WithOnBlockProcessed call fn once a block is parsed and its matches stored, with the block number and how
+// many transactions of subscribed addresses it matched, 0 included. fn is called from the parsing loop, or
+// from the caller of ParseTransactions, parsing waits for it to return.
+func WithOnBlockProcessed(fn func(ctx context.Context, number int64, matched int)) Option {
+	return func(s *ETHService) {
+		s.onBlockProcessed = fn
+	}
+}
+
+// WithLogger log with logger instead of logging.Std.
+func WithLogger(logger logging.Logger) Option {
+	return func(s *ETHService) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics record metrics in m rather than in metrics.Default, such as NewPrometheusMetrics of a registry
+// of its own, or NopMetrics. NewETHServiceFromConfig records the JSON-RPC calls of its client in m as well.
+func WithMetrics(m Metrics) Option {
+	return func(s *ETHService) {
+		s.metrics = m
+	}
+}
+
+// withClock drive the poll loop by c instead of package time.
+func withClock(c clock) Option {
+	return func(s *ETHService) {
+		s.clock = c
+	}
+}
+
+// NewETHService return an ETHService parsing blocks from the chain head reported by client.
+// it doesn't poll new blocks until Start is called.
+func NewETHService(client remote.ETHClient, opts ...Option) (*ETHService, error) {
+	return newETHService(context.Background(), client, opts...)
+}
+
+// newETHService NewETHService asking client and storage with ctx.
+func newETHService(ctx context.Context, client remote.ETHClient, opts ...Option) (*ETHService, error) {
+	if client == nil {
+		return nil, errors.New("nil client")
+	}
+	s := &ETHService{
+		conf:                DefaultConfig(),
+		client:              client,
+		blockHashes:         map[int64]string{},
+		blockRetries:        map[int64]int{},
+		subAddrs:            map[string]*SubscriptionConfig{},
+		backfills:           map[string]*backfill{},
+		ensNames:            map[string]*ensName{},
+		streams:             map[*stream]struct{}{},
+		pending:             map[string]map[string]*pendingTx{},
+		pollIntervalChanged: make(chan struct{}, 1),
+		clock:               realClock{},
+		logger:              logging.Std(),
+		metrics:             defaultMetrics,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.conf.ChainID != 0 {
+		s.logger = logging.With(s.logger, "chain_id", s.conf.ChainID)
+	}
+	if s.conf.LogRepeatWindow > 0 {
+		// a node down fails every poll the same way.
+		s.logger = logging.Dedup(s.logger, s.conf.LogRepeatWindow)
+	}
+	if s.conf.PollInterval <= 0 {
+		s.conf.PollInterval = DefaultConfig().PollInterval
+	}
+	s.pollInterval = int64(s.conf.PollInterval)
+	atomic.StoreInt64(&s.lastLoad, time.Now().UnixNano())
+	if s.storage == nil {
+		s.storage = store.NewMemoryWithRetention(store.Retention{
+			MaxPerAddress: s.conf.MaxTransactionsPerAddress,
+			MaxAge:        s.conf.MaxTransactionAge,
+		})
+	}
+	if s.checkpointer == nil {
+		s.checkpointer = s.storage
+	}
+	webhookConf := s.conf.Webhook
+	if webhookConf.Logger == nil {
+		webhookConf.Logger = s.logger
+	}
+	s.dispatcher = webhook.NewDispatcher(webhookConf, nil)
+	if caller, ok := client.(remote.ContractCaller); ok {
+		// nil unless storage keeps metadata.
+		metadataStore, _ := s.storage.(store.TokenMetadataStore)
+		s.tokens = NewTokenMetadataService(caller, metadataStore)
+		s.tokens.logger = s.logger
+	}
+	if err := s.checkChainID(ctx); err != nil {
+		s.logger.Error(ctx, "[NewETHService]: Error checkChainID", "err", err)
+		return nil, err
+	}
+	dec, err := client.ETHBlockDecimalNumber(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[NewETHService]: Error ETHBlockDecimalNumber", "err", err)
+		return nil, err
+	}
+	options, _, err := s.listSubscriptions(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[NewETHService]: Error listSubscriptions", "err", err)
+		return nil, err
+	}
+	for addr, opts := range options {
+		s.subAddrs[addr] = &SubscriptionConfig{SubscriptionOptions: opts}
+	}
+	s.observeSubscriptions()
+	checkpoint, ok, err := s.checkpointer.GetCheckpoint(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[NewETHService]: Error GetCheckpoint", "err", err)
+		return nil, err
+	}
+	if ok && checkpoint < dec {
+		// catch up the blocks missed while stopped, Start does before following new blocks.
+		if limit := int64(s.conf.MaxResumeBlocks); limit > 0 && dec-checkpoint > limit {
+			s.logger.Warn(ctx, "[NewETHService]: skip blocks missed while stopped", "from", checkpoint+1, "to", dec-limit)
+			checkpoint = dec - limit
 		}
-		eTHServiceInstance.recentBlockNumer = dec
+		dec = checkpoint
+	}
+	atomic.StoreInt64(&s.recentBlockNumer, dec)
+	return s, nil
+}
+
+// Start start following new blocks in background until ctx is done or Stop is called.
+func (s *ETHService) Start(ctx context.Context) error {
+	s.runMutex.Lock()
+	defer s.runMutex.Unlock()
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if s.cancel != nil {
+		return errors.New("service already started")
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.run(ctx)
+	}()
+	return nil
+}
 
+// Stop stop the background loop and wait for it to exit, then for the queued webhooks to be
+// delivered and the queued observations to be observed, or until ctx is done. running backfills are cancelled without waiting.
+// blocks are stored all at once after everything is fetched, so an interrupted load
+// never leaves a block half stored.
+func (s *ETHService) Stop(ctx context.Context) error {
+	s.cancelBackfills()
+	s.runMutex.Lock()
+	cancel, done := s.cancel, s.done
+	s.runMutex.Unlock()
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			s.logger.Error(ctx, "[Stop]: background loop still running", "err", ctx.Err())
+			return ctx.Err()
+		}
+	}
+	if err := s.dispatcher.Close(ctx); err != nil {
+		s.logger.Error(ctx, "[Stop]: webhooks still being delivered", "err", err)
+		return err
+	}
+	if err := s.stopObservers(ctx); err != nil {
+		s.logger.Error(ctx, "[Stop]: observers still running", "err", err)
+		return err
+	}
+	return nil
+}
+
+// Close stop s for good, waiting for the background loop, an in-flight load included, and the queued
+// webhooks as Stop does. afterwards Start, Subscribe, Unsubscribe, ParseTransactions and Backfill fail
+// with ErrClosed, reads keep answering from storage. closing twice is a no-op.
+func (s *ETHService) Close() error {
+	s.runMutex.Lock()
+	closing := atomic.CompareAndSwapInt32(&s.closed, 0, 1)
+	s.runMutex.Unlock()
+	if !closing {
+		return nil
+	}
+	return s.Stop(context.Background())
+}
+
+func (s *ETHService) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) == 1
+}
+
+// run follow new blocks pushed by the node's newHeads subscription when the client supports it,
+// polling is the fallback if the endpoint is HTTP only, and while the socket is reconnecting. either way
+// new blocks go through loadTo, HeadsStatus tells which one is in use.
+func (s *ETHService) run(ctx context.Context) {
+	defer s.setHeadSource(HeadSourceStopped)
+	if s.conf.TrackPendingTransactions {
+		pendingDone := make(chan struct{})
 		go func() {
-			// query eth block number per second.
-			// if new block number appear, getBlockByNumber.
-			// parse tx into inbount/outbound.
-			for range time.Tick(1 * time.Second) {
-				if err := eTHServiceInstance.load(ctx); err != nil {
-					log.Println(ctx, "[ETHServiceInstance]: eTHServiceInstance load err: ", err)
-				}
-			}
+			defer close(pendingDone)
+			s.watchPending(ctx)
+		}()
+		defer func() { <-pendingDone }()
+	}
+	if evicter, ok := s.storage.(store.Evicter); ok && s.conf.MaxTransactionAge > 0 {
+		sweepDone := make(chan struct{})
+		go func() {
+			defer close(sweepDone)
+			s.sweepExpired(ctx, evicter)
 		}()
-	})
+		defer func() { <-sweepDone }()
+	}
+	if s.conf.ENSTTL > 0 {
+		ensDone := make(chan struct{})
+		go func() {
+			defer close(ensDone)
+			s.watchENS(ctx)
+		}()
+		defer func() { <-ensDone }()
+	}
+	s.catchUp(ctx)
+	sub, ok := s.client.(remote.HeadSubscriber)
+	backoff := minResubscribeBackoff
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		if !ok {
+			s.setHeadSource(HeadSourcePolling)
+			s.poll(ctx, 0)
+			return
+		}
+		if attempt > 0 {
+			atomic.AddInt64(&s.resubscribes, 1)
+		}
+		heads, err := sub.SubscribeNewHeads(ctx)
+		if errors.Is(err, remote.ErrWebSocketUnsupported) {
+			ok = false
+			continue
+		}
+		if err == nil {
+			s.logger.Info(ctx, "[run]: following newHeads subscription")
+			s.setHeadSource(HeadSourceWebSocket)
+			// a subscription dropping before any head doesn't count as recovered.
+			if s.consumeHeads(ctx, heads) > 0 {
+				backoff = minResubscribeBackoff
+			}
+		} else {
+			s.logger.Error(ctx, "[run]: Error SubscribeNewHeads", "err", err)
+		}
+		// poll until resubscribing, reconnect less and less often while the socket keeps failing.
+		s.setHeadSource(HeadSourceReconnecting)
+		s.poll(ctx, backoff)
+		backoff *= 2
+		if backoff > maxResubscribeBackoff {
+			backoff = maxResubscribeBackoff
+		}
+	}
+}
+
+// catchUp load blocks until the chain head, which load does maxCatchUpBlocks at a time.
+// it stops at the first error, polling retries.
+func (s *ETHService) catchUp(ctx context.Context) {
+	for ctx.Err() == nil {
+		before := s.LastProcessedBlock(ctx)
+		if err := s.load(ctx); err != nil {
+			s.logger.Error(ctx, "[catchUp]: Error load", "err", err)
+			return
+		}
+		if s.LastProcessedBlock(ctx)-before < maxCatchUpBlocks {
+			return
+		}
+	}
+}
+
+// consumeHeads load every new head until the subscription ends, return how many heads were received.
+func (s *ETHService) consumeHeads(ctx context.Context, heads <-chan *model.ETHBlockHeader) int {
+	received := 0
+	for head := range heads {
+		received++
+		atomic.StoreInt64(&s.lastHead, time.Now().UnixNano())
+		num, err := util.HexToInt64(head.Number)
+		if err != nil {
+			s.logger.Error(ctx, "[consumeHeads]: invalid head number", "number", head.Number)
+			continue
+		}
+		if err := s.loadTo(ctx, num); err != nil {
+			s.logger.Error(ctx, "[consumeHeads]: Error load", "err", err)
+		}
+	}
+	return received
+}
+
+// poll load new blocks every poll interval for d, or until ctx is done if d is 0.
+// the interval doubles up to Config.MaxPollInterval while no new block shows up, and is back to
+// PollInterval as soon as one does. the next wait starts once load returns, so a slow load
+// delays the next one instead of stacking RPC calls.
+func (s *ETHService) poll(ctx context.Context, d time.Duration) {
+	var timeout <-chan time.Time
+	if d > 0 {
+		t := s.clock.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C()
+	}
+	// query eth block number every poll interval.
+	// if new block number appear, getBlockByNumber.
+	// parse tx into inbount/outbound.
+	var interval time.Duration
+	for {
+		// the first load catches up right away, blocks may have been missed while the subscription was down.
+		before := s.LastProcessedBlock(ctx)
+		if err := s.load(ctx); err != nil {
+			s.logger.Error(ctx, "[poll]: Error load", "err", err)
+		}
+		interval = s.nextPollInterval(interval, s.LastProcessedBlock(ctx) != before)
+		t := s.clock.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-timeout:
+			t.Stop()
+			return
+		case <-s.pollIntervalChanged:
+			t.Stop()
+			interval = 0
+		case <-t.C():
+		}
+	}
+}
 
-	return eTHServiceInstance
+// nextPollInterval the wait before next load, given the last wait and whether the last load found new blocks.
+func (s *ETHService) nextPollInterval(last time.Duration, progressed bool) time.Duration {
+	base := s.PollInterval()
+	if progressed || last < base {
+		return base
+	}
+	next := last * 2
+	if next > s.conf.MaxPollInterval {
+		next = s.conf.MaxPollInterval
+	}
+	if next < base {
+		return base
+	}
+	return next
+}
+
+// PollInterval time between polls for new blocks.
+func (s *ETHService) PollInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.pollInterval))
+}
+
+// SetPollInterval change the base time between polls, a running poll loop loads right away and then
+// waits the new interval.
+func (s *ETHService) SetPollInterval(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("poll interval should be positive")
+	}
+	atomic.StoreInt64(&s.pollInterval, int64(d))
+	select {
+	case s.pollIntervalChanged <- struct{}{}:
+	default:
+		// a wake up is already pending, it reads the latest interval.
+	}
+	return nil
 }
 
 // GetCurrentBlock get current block.
 func (s *ETHService) GetCurrentBlock(ctx context.Context) (*model.ETHBlockInfo, error) {
-	num, err := remote.ETHRPCServiceInstance().EthBlockNumber(ctx)
+	num, err := s.client.EthBlockNumber(ctx)
 	if err != nil {
-		log.Println(ctx, "[GetCurrentBlock]: Error EthBlockNumber, err: ", err)
+		s.logger.Error(ctx, "[GetCurrentBlock]: Error EthBlockNumber", "err", err)
 		return nil, err
 	}
-	blockInfo, err := remote.ETHRPCServiceInstance().EthGetBlockByNumber(ctx, num)
+	blockInfo, err := s.client.EthGetBlockByNumber(ctx, num)
 	if err != nil {
-		log.Println(ctx, "[GetCurrentBlock]: Error EthGetBlockByNumber, err: ", err)
+		s.logger.Error(ctx, "[GetCurrentBlock]: Error EthGetBlockByNumber", "err", err)
 		return nil, err
 	}
 	return blockInfo, nil
 }
 
-// Subscribe subscribe an address's inbound/outbound transaction.
+// GetBlockByNumber get block number, with its transactions. number should be between 0 and chain head.
+func (s *ETHService) GetBlockByNumber(ctx context.Context, number int64) (*model.ETHBlockInfo, error) {
+	if number < 0 {
+		return nil, fmt.Errorf("invalid block number %d", number)
+	}
+	head, err := s.client.ETHBlockDecimalNumber(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[GetBlockByNumber]: Error ETHBlockDecimalNumber", "err", err)
+		return nil, err
+	}
+	if number > head {
+		return nil, fmt.Errorf("%w: %d beyond chain head %d", ErrBlockNotFound, number, head)
+	}
+	blockInfo, err := s.fetchBlock(ctx, number)
+	if err != nil {
+		s.logger.Error(ctx, "[GetBlockByNumber]: Error EthGetBlockByNumber", "block", number, "err", err)
+		return nil, err
+	}
+	return blockInfo, nil
+}
+
+// blockTags block tags GetBlockByTag accepts.
+var blockTags = map[string]bool{"latest": true, "earliest": true, "pending": true, "safe": true, "finalized": true}
+
+// GetBlockByTag get the block of tag, latest, earliest, pending, safe or finalized. the pending block
+// has neither hash nor number, and a node before the merge doesn't know safe and finalized.
+func (s *ETHService) GetBlockByTag(ctx context.Context, tag string) (*model.ETHBlockInfo, error) {
+	tag = strings.ToLower(tag)
+	if !blockTags[tag] {
+		return nil, fmt.Errorf("invalid block tag %q", tag)
+	}
+	blockInfo, err := s.client.EthGetBlockByNumber(ctx, tag)
+	if err != nil {
+		s.logger.Error(ctx, "[GetBlockByTag]: Error EthGetBlockByNumber", "tag", tag, "err", err)
+		return nil, err
+	}
+	return blockInfo, nil
+}
+
+// Subscribe subscribe an address's inbound/outbound transaction. address should be 0x followed by
+// 40 hex digits, with a valid EIP-55 checksum if mixed-case, errors wrap util.ErrInvalidAddress.
+// with Config.VerifySubscriptions the node is asked for the address first, the subscription fails with
+// ErrRPCUnavailable while it can't be reached. a closed service fails with ErrClosed.
+// an ENS name such as vitalik.eth subscribes the address it resolves to, see ENSNames.
 func (s *ETHService) Subscribe(ctx context.Context, address string) error {
-	address = strings.ToLower(address)
+	_, _, err := s.subscribeWith(ctx, address, nil)
+	return err
+}
+
+// SubscribeIfNew like Subscribe, reporting whether address was added rather than already subscribed.
+// of concurrent calls for the same address, a single one reports it added.
+func (s *ETHService) SubscribeIfNew(ctx context.Context, address string) (bool, error) {
+	_, added, err := s.subscribeWith(ctx, address, nil)
+	return added, err
+}
+
+// subscribeWith subscribe address, or the address the ENS name address resolves to, return it in lowercase
+// and whether it was added. configure changes a copy of the config of the subscription, replacing it under
+// the same lock, nil keeps it.
+func (s *ETHService) subscribeWith(ctx context.Context, address string, configure func(conf *SubscriptionConfig)) (string, bool, error) {
+	if s.isClosed() {
+		return "", false, ErrClosed
+	}
+	name := ""
+	if util.IsENSName(address) {
+		name = strings.ToLower(address)
+		resolved, err := s.resolveENS(ctx, name)
+		if err != nil {
+			return "", false, err
+		}
+		address = resolved
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		s.logger.Error(ctx, "[Subscribe]: Error NormalizeAddress", "err", err)
+		return "", false, err
+	}
+	if s.conf.VerifySubscriptions {
+		if err := s.verifyAddress(ctx, address); err != nil {
+			s.logger.Error(ctx, "[Subscribe]: Error verifyAddress", "address", address, "err", err)
+			return "", false, err
+		}
+	}
+	s.addrRWMutex.Lock()
+	defer s.addrRWMutex.Unlock()
+	conf, ok := s.subAddrs[address]
+	added := !ok
+	if added {
+		conf = &SubscriptionConfig{SubscribedAt: time.Now().UTC()}
+	}
+	if configure != nil {
+		updated := *conf
+		configure(&updated)
+		conf = &updated
+	}
+	if added || configure != nil {
+		if err := s.saveSubscription(ctx, address, conf.SubscriptionOptions, configure != nil); err != nil {
+			s.logger.Error(ctx, "[Subscribe]: Error saveSubscription", "err", err)
+			return "", false, err
+		}
+		s.subsVersion++
+	}
+	s.subAddrs[address] = conf
+	s.observeSubscriptions()
+	if len(name) > 0 {
+		s.setENSName(name, address, added)
+	}
+	return address, added, nil
+}
+
+// verifyAddress ask the node for the nonce of address, or for chain head if the client can't tell nonces.
+// a fresh address has nonce 0 and passes, only a node failing to answer fails it.
+func (s *ETHService) verifyAddress(ctx context.Context, address string) error {
+	if getter, ok := s.client.(remote.NonceGetter); ok {
+		_, err := getter.EthGetTransactionCount(ctx, address, "latest")
+		return err
+	}
+	_, err := s.client.ETHBlockDecimalNumber(ctx)
+	return err
+}
+
+// Unsubscribe stop watching an address's inbound/outbound transaction, or the address of a subscribed ENS name.
+// purge drops the transactions already collected for the address as well, a running backfill is cancelled.
+// Unsubscribing an address or ENS name which was never subscribed is a no-op, a malformed address fails with
+// ErrInvalidAddress.
+func (s *ETHService) Unsubscribe(ctx context.Context, address string, purge bool) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if util.IsENSName(address) {
+		name := strings.ToLower(address)
+		if address = s.ensAddress(name); address == name {
+			// not subscribed by name.
+			return nil
+		}
+	}
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		s.logger.Error(ctx, "[Unsubscribe]: Error NormalizeAddress", "err", err)
+		return err
+	}
+	s.cancelBackfill(address)
+	// hold the addr lock until purge finishes, parsing stores under the addr read lock,
+	// so a parsing block never sees a half-removed subscription.
 	s.addrRWMutex.Lock()
-	s.subAddrs[address] = true
-	s.addrRWMutex.Unlock()
+	defer s.addrRWMutex.Unlock()
+	// purge first, the subscription is dropped last, so a failed purge leaves the address subscribed
+	// and the unsubscribe can be retried.
+	if purge {
+		if err := s.storage.DeleteHistory(ctx, address); err != nil {
+			s.logger.Error(ctx, "[Unsubscribe]: Error DeleteHistory", "err", err)
+			return err
+		}
+	}
+	if err := s.storage.DeleteSubscription(ctx, address); err != nil {
+		s.logger.Error(ctx, "[Unsubscribe]: Error DeleteSubscription", "err", err)
+		return err
+	}
+	delete(s.subAddrs, address)
+	s.subsVersion++
+	s.observeSubscriptions()
+	s.dropENSNames(address)
+	s.dropPending(address)
 	return nil
 }
 
-// GetTransactions get address's inbound/outbound transactions
-func (s *ETHService) GetTransactions(ctx context.Context, address string) ([]*model.ETHTransaction, error) {
-	address = strings.ToLower(address)
-	s.txRWMutex.RLock()
-	transactions, ok := s.transactions[address]
+// ListSubscriptions list subscribed addresses in sorted order.
+// the returned slice is a copy, callers are free to modify it.
+func (s *ETHService) ListSubscriptions(ctx context.Context) []string {
+	s.addrRWMutex.RLock()
+	addrs := make([]string, 0, len(s.subAddrs))
+	for addr := range s.subAddrs {
+		addrs = append(addrs, addr)
+	}
+	s.addrRWMutex.RUnlock()
+	// sort outside the lock, don't hold back ParseTransactions.
+	sort.Strings(addrs)
+	return addrs
+}
+
+// ListSubscriptionsWithPrefix subscriptions of the addresses starting with prefix, in any case, in sorted
+// order of address, along with what is attached to them, see ListSubscriptions for the addresses only. an
+// empty prefix lists every subscription. the subscriptions are copied under the addr read lock, their
+// transactions counted after releasing it, storage may be a network round trip away.
+func (s *ETHService) ListSubscriptionsWithPrefix(ctx context.Context, prefix string) ([]model.Subscription, error) {
+	prefix = strings.ToLower(prefix)
+	subscriptions := s.copySubscriptions(prefix)
+	for i := range subscriptions {
+		n, lastBlock, err := s.countTransactions(ctx, subscriptions[i].Address)
+		if err != nil {
+			s.logger.Error(ctx, "[ListSubscriptionsWithPrefix]: Error countTransactions", "address", subscriptions[i].Address, "err", err)
+			return nil, err
+		}
+		subscriptions[i].TransactionCount = n
+		subscriptions[i].LastMatchBlock = lastBlock
+	}
+	return subscriptions, nil
+}
+
+// copySubscriptions subscriptions of the addresses starting with prefix, sorted, without their transactions.
+func (s *ETHService) copySubscriptions(prefix string) []model.Subscription {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	addrs := make([]string, 0, len(s.subAddrs))
+	for addr := range s.subAddrs {
+		if strings.HasPrefix(addr, prefix) {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	names := map[string][]string{}
+	for name, n := range s.ensNames {
+		names[n.address] = append(names[n.address], name)
+	}
+	subscriptions := make([]model.Subscription, 0, len(addrs))
+	for _, addr := range addrs {
+		conf := s.subAddrs[addr]
+		sub := model.Subscription{Address: addr, Label: conf.Label, Webhook: s.webhookOf(addr), ENSNames: names[addr]}
+		sort.Strings(sub.ENSNames)
+		if !conf.SubscribedAt.IsZero() {
+			at := conf.SubscribedAt
+			sub.SubscribedAt = &at
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions
+}
+
+// countTransactions number of transactions stored for address and the block of the last one, read whole
+// when storage can't count them.
+func (s *ETHService) countTransactions(ctx context.Context, address string) (int, int64, error) {
+	if counter, ok := s.storage.(store.TransactionCounter); ok {
+		return counter.CountTransactions(ctx, address)
+	}
+	transactions, _, err := s.storage.GetTransactions(ctx, address, store.Query{MaxBlock: store.NoMaxBlock})
+	if err != nil || len(transactions) == 0 {
+		return 0, 0, err
+	}
+	return len(transactions), store.PositionOf(transactions[len(transactions)-1]).Block, nil
+}
+
+// IsSubscribed whether address is subscribed, in any case, false if it is malformed.
+func (s *ETHService) IsSubscribed(ctx context.Context, address string) bool {
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return false
+	}
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	_, ok := s.subAddrs[address]
+	return ok
+}
+
+// sweepExpired drop the transactions past Config.MaxTransactionAge every retentionSweepInterval until ctx is
+// done, the addresses appended to drop theirs meanwhile already.
+func (s *ETHService) sweepExpired(ctx context.Context, evicter store.Evicter) {
+	for s.sleep(ctx, retentionSweepInterval) {
+		if err := evicter.EvictExpired(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "[sweepExpired]: Error EvictExpired", "err", err)
+		}
+	}
+}
+
+// Evictions transactions, token and NFT transfers dropped per address past the retention, so far. the
+// addresses without any are left out, and so is everything when storage doesn't tell.
+func (s *ETHService) Evictions(ctx context.Context) (map[string]int64, error) {
+	evicter, ok := s.storage.(store.Evicter)
 	if !ok {
-		transactions = make([]*model.ETHTransaction, 0)
+		return map[string]int64{}, nil
+	}
+	evictions, err := evicter.Evictions(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[Evictions]: Error Evictions", "err", err)
+		return nil, err
+	}
+	return evictions, nil
+}
+
+// GetTransactions get address's inbound/outbound transactions in chain order, by block number then
+// transaction index whatever the order their blocks were parsed in, such as by a backfill.
+// only the most recent Config.MaxTransactionsPerAddress transactions are retained, the ones past
+// Config.MaxTransactionAge neither, and a transaction is reported once it has Config.Confirmations
+// confirmations. the transactions of an unsubscribed address are kept until purged, ErrNotSubscribed is
+// returned once there is none.
+func (s *ETHService) GetTransactions(ctx context.Context, address string) ([]*model.ETHTransaction, error) {
+	return s.FilterTransactions(ctx, address, model.TxFilter{})
+}
+
+// GetTransactionsInOrder get address's transactions like GetTransactions, most recent first for
+// model.OrderDescending. the pending transactions are the most recent ones.
+func (s *ETHService) GetTransactionsInOrder(ctx context.Context, address string, order model.Order) ([]*model.ETHTransaction, error) {
+	if order != model.OrderAscending && order != model.OrderDescending {
+		return nil, fmt.Errorf("invalid order %q", order)
+	}
+	transactions, err := s.GetTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if order == model.OrderDescending {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
+	return transactions, nil
+}
+
+// GetTransactionsWithCounterparty get address's transactions like GetTransactions, only the ones whose other
+// side is counterparty: the sender of an inbound transaction, the recipient of an outbound one. a self
+// transfer has address as counterparty. empty if there is none.
+func (s *ETHService) GetTransactionsWithCounterparty(ctx context.Context, address, counterparty string) ([]*model.ETHTransaction, error) {
+	counterparty, err := util.NormalizeAddress(counterparty)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.GetTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*model.ETHTransaction, 0)
+	for _, tx := range transactions {
+		if counterpartyOf(tx) == counterparty {
+			matched = append(matched, tx)
+		}
+	}
+	return matched, nil
+}
+
+// counterpartyOf the other side of a stored tx, in lowercase like stored addresses.
+func counterpartyOf(tx *model.ETHTransaction) string {
+	if tx.Direction == model.DirectionInbound {
+		return tx.From
+	}
+	// outbound, or a self transfer whose recipient is the address itself.
+	return tx.To
+}
+
+// FilterTransactions get address's transactions which meet the filter, such as inbound only.
+// with Config.TrackPendingTransactions the transactions still in the mempool follow the mined ones,
+// in State model.TxStatePending, or model.TxStateDropped past Config.PendingHorizon.
+func (s *ETHService) FilterTransactions(ctx context.Context, address string, filter model.TxFilter) ([]*model.ETHTransaction, error) {
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	transactions, _, err := s.page(ctx, address, store.Query{Filter: filter, MaxBlock: store.NoMaxBlock})
+	if err != nil {
+		return nil, err
+	}
+	transactions = append(transactions, s.pendingOf(address, filter)...)
+	if len(transactions) == 0 && !s.IsSubscribed(ctx, address) {
+		return nil, ErrNotSubscribed
 	}
-	s.txRWMutex.RUnlock()
 	return transactions, nil
 }
 
 // load load transactions via address.
 func (s *ETHService) load(ctx context.Context) error {
 	// 1. query new block number.
-	num, err := remote.ETHRPCServiceInstance().ETHBlockDecimalNumber(ctx)
+	num, err := s.client.ETHBlockDecimalNumber(ctx)
 	if err != nil {
-		log.Println(ctx, "[load]: Error EthBlockNumber request:", err)
+		s.logger.Error(ctx, "[load]: Error EthBlockNumber", "err", err)
 		return err
 	}
-	// 2. compare, if no new block, return
-	if s.recentBlockNumer >= num {
-		// no new block, return.
+	if recent := s.LastProcessedBlock(ctx); num < recent {
+		// a fallback endpoint lagging the one the last block was parsed from, no new block yet.
+		s.logger.Debug(ctx, "[load]: chain head behind the last processed block", "head", num, "block", recent)
+	}
+	s.refreshSubscriptions(ctx)
+	if s.following > 0 {
+		s.following--
+		s.follow(ctx)
 		return nil
 	}
-	// 3. update block number.
-	s.recentBlockNumer = num
-	log.Println(ctx, "[ETHService]: Block Number:", num)
-	// 4. parse block transactions.
-	err = s.ParseTransactions(ctx, num)
+	return s.loadTo(ctx, num)
+}
+
+// saveSubscription save address to storage, with opts if withOptions and storage keeps them.
+func (s *ETHService) saveSubscription(ctx context.Context, address string, opts model.SubscriptionOptions, withOptions bool) error {
+	if optionsStore, ok := s.storage.(store.SubscriptionOptionsStore); ok {
+		if withOptions {
+			return optionsStore.SaveSubscriptionOptions(ctx, address, opts)
+		}
+	}
+	return s.storage.SaveSubscription(ctx, address)
+}
+
+// listSubscriptions the subscribed addresses in storage with their options, and whether storage keeps
+// options, if not they are all the zero value.
+func (s *ETHService) listSubscriptions(ctx context.Context) (map[string]model.SubscriptionOptions, bool, error) {
+	if optionsStore, ok := s.storage.(store.SubscriptionOptionsStore); ok {
+		options, err := optionsStore.ListSubscriptionOptions(ctx)
+		return options, true, err
+	}
+	addrs, err := s.storage.ListSubscriptions(ctx)
 	if err != nil {
-		log.Println(ctx, "[load]: Error ParseTransactions request:", err)
-		return err
+		return nil, false, err
+	}
+	options := make(map[string]model.SubscriptionOptions, len(addrs))
+	for _, addr := range addrs {
+		options[addr] = model.SubscriptionOptions{}
+	}
+	return options, false, nil
+}
+
+// refreshSubscriptions reload the subscribed addresses from storage, picking up the ones made through
+// other instances sharing it along with their options when storage keeps them, and forgetting the ENS
+// names and pending transactions of the ones unsubscribed there. storage is listed outside the addr lock, it may be a network round trip
+// away. on failure, or if an address was subscribed or unsubscribed meanwhile, the addresses known so far
+// are kept until the next refresh.
+func (s *ETHService) refreshSubscriptions(ctx context.Context) {
+	s.addrRWMutex.RLock()
+	version := s.subsVersion
+	s.addrRWMutex.RUnlock()
+	options, persisted, err := s.listSubscriptions(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[refreshSubscriptions]: Error listSubscriptions", "err", err)
+		return
+	}
+	s.addrRWMutex.Lock()
+	defer s.addrRWMutex.Unlock()
+	if s.subsVersion != version {
+		// options may miss an address subscribed or configured meanwhile.
+		return
+	}
+	subAddrs := make(map[string]*SubscriptionConfig, len(options))
+	for addr, opts := range options {
+		conf, ok := s.subAddrs[addr]
+		switch {
+		case !ok:
+			conf = &SubscriptionConfig{SubscriptionOptions: opts}
+		case persisted && !reflect.DeepEqual(conf.SubscriptionOptions, opts):
+			// set through another instance, the filter and SubscribedAt of this one are kept.
+			updated := *conf
+			updated.SubscriptionOptions = opts
+			conf = &updated
+		}
+		subAddrs[addr] = conf
+	}
+	for addr := range s.subAddrs {
+		if _, ok := subAddrs[addr]; !ok {
+			s.dropENSNames(addr)
+			s.dropPending(addr)
+		}
+	}
+	s.subAddrs = subAddrs
+	s.observeSubscriptions()
+}
+
+// loadTo load transactions of blocks up to chain head num.
+func (s *ETHService) loadTo(ctx context.Context, num int64) error {
+	// 2. parse every block after the last parsed one in order, if no new block, nothing to do.
+	// a huge gap is caught up over several ticks so a single load never blocks for too long.
+	defer s.observeHead(num)
+	recent := s.LastProcessedBlock(ctx)
+	if num-recent > maxCatchUpBlocks {
+		num = recent + maxCatchUpBlocks
+	}
+	blocks := s.newBlockPrefetcher(num)
+	for next := recent + 1; next <= num; next++ {
+		if ctx.Err() != nil {
+			// stopping, it isn't the block's fault.
+			return ctx.Err()
+		}
+		start := time.Now()
+		err := s.parseCanonicalBlock(ctx, blocks, next, start)
+		s.observeParse(start)
+		if errors.Is(err, errChainReorg) {
+			blocks.reset()
+			// the cached blocks of every tracked height may be orphaned, rollback must see the canonical ones.
+			if cache, ok := s.client.(remote.BlockCache); ok {
+				cache.InvalidateBlocksFrom(next - int64(s.conf.ReorgDepth))
+			}
+			ancestor, err := s.rollback(ctx, next)
+			if err != nil {
+				s.logger.Error(ctx, "[loadTo]: Error rollback", "err", err)
+				return err
+			}
+			s.logger.Info(ctx, "[loadTo]: chain reorg detected", "block", next, "reparse_from", ancestor+1)
+			atomic.StoreInt64(&s.recentBlockNumer, ancestor)
+			if !s.checkpoint(ctx, ancestor) {
+				return nil
+			}
+			// loop continues from ancestor + 1.
+			next = ancestor
+			continue
+		}
+		var storageErr *storageError
+		if errors.As(err, &storageErr) {
+			// pause until storage is back rather than skipping blocks it can't store.
+			s.logger.Error(ctx, "[loadTo]: Error storing block, pause parsing", "block", next, "err", err)
+			return err
+		}
+		if err != nil {
+			s.blockRetries[next]++
+			if s.blockRetries[next] < maxBlockRetries {
+				// the block is retried on next tick.
+				s.logger.Warn(ctx, "[loadTo]: Error ParseTransactions, retry", "block", next,
+					"attempt", s.blockRetries[next], "err", err)
+				return err
+			}
+			// retry budget exhausted, give up the block rather than stalling forever.
+			s.logger.Error(ctx, "[loadTo]: Error ParseTransactions, skip block", "block", next, "attempts", maxBlockRetries, "err", err)
+			atomic.AddInt64(&s.skippedBlocks, 1)
+		}
+		delete(s.blockRetries, next)
+		// 3. update block number only after the block is parsed or skipped.
+		atomic.StoreInt64(&s.recentBlockNumer, next)
+		ok := s.checkpoint(ctx, next)
+		s.metrics.BlockProcessed(s.metricsChain(), err != nil)
+		if !ok {
+			return nil
+		}
 	}
+	atomic.StoreInt64(&s.lastLoad, time.Now().UnixNano())
 	return nil
 }
 
+// checkpoint save the last processed block, false if another instance sharing the checkpoint moved it
+// meanwhile, this one then follows it for followTicks ticks rather than parsing the same blocks. other
+// failures only cost parsing a few blocks again after restart.
+func (s *ETHService) checkpoint(ctx context.Context, number int64) bool {
+	err := s.checkpointer.SetCheckpoint(ctx, number)
+	if errors.Is(err, store.ErrCheckpointConflict) {
+		s.logger.Info(ctx, "[checkpoint]: checkpoint moved by another instance, follow it", "block", number)
+		s.following = followTicks
+		s.follow(ctx)
+		return false
+	}
+	if err != nil {
+		s.logger.Error(ctx, "[checkpoint]: Error SetCheckpoint", "err", err)
+	}
+	return true
+}
+
+// follow take the checkpoint set by the instance parsing blocks as the last processed block, it may be
+// behind after a reorg. once followTicks ticks go by, this instance parses the blocks after it again,
+// taking over if the other one stopped, or following it again if it still moves the checkpoint.
+func (s *ETHService) follow(ctx context.Context) {
+	block, ok, err := s.checkpointer.GetCheckpoint(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[follow]: Error GetCheckpoint", "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	atomic.StoreInt64(&s.recentBlockNumer, block)
+	// the hashes of the blocks parsed by the other instance are unknown, it detects their reorgs.
+	s.blockHashes = map[int64]string{}
+	atomic.StoreInt64(&s.lastLoad, time.Now().UnixNano())
+}
+
+// LastProcessedBlock number of the most recent block parsed, compare it with chain head to
+// see how far behind the service is.
+func (s *ETHService) LastProcessedBlock(ctx context.Context) int64 {
+	return atomic.LoadInt64(&s.recentBlockNumer)
+}
+
+// SkippedBlocks number of blocks permanently skipped because they kept failing to parse.
+func (s *ETHService) SkippedBlocks() int64 {
+	return atomic.LoadInt64(&s.skippedBlocks)
+}
+
 // ParseTransactions parse block transactions.
 func (s *ETHService) ParseTransactions(ctx context.Context, number int64) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	start := time.Now()
+	defer s.observeParse(start)
+	blockInfo, err := s.fetchBlock(ctx, number)
+	if err != nil {
+		s.logger.Error(ctx, "[ParseTransactions]: Error EthGetBlockByNumber", "block", number, "err", err)
+		return err
+	}
+	return s.storeBlock(ctx, blockInfo, start)
+}
+
+func (s *ETHService) fetchBlock(ctx context.Context, number int64) (*model.ETHBlockInfo, error) {
 	hexStr := fmt.Sprintf("0x%x", number)
-	blockInfo, err := remote.ETHRPCServiceInstance().EthGetBlockByNumber(ctx, hexStr)
+	return s.client.EthGetBlockByNumber(ctx, hexStr)
+}
+
+// storeBlock store transactions and token transfers of subscribed addresses in the block, cache its gas
+// stats, then report it to the OnBlockProcessed hook and log it along with the time since start, when
+// fetching it began. everything is fetched before storing, so a failed block is retried without partial state.
+func (s *ETHService) storeBlock(ctx context.Context, blockInfo *model.ETHBlockInfo, start time.Time) error {
+	matched, err := s.storeBlockMatches(ctx, blockInfo)
 	if err != nil {
-		log.Println(ctx, "[ParseTransactions]: Error EthGetBlockByNumber request:", err)
 		return err
 	}
-	transactions := blockInfo.Transactions
-	for _, tx := range transactions {
-		// if a key exists in map, store it.
-		s.addrRWMutex.RLock()
-		s.txRWMutex.Lock()
-		if _, ok := s.subAddrs[tx.From]; ok {
-			// outboundTx: From -> To
-			if txList, okk := s.transactions[tx.From]; okk {
-				txList = append(txList, tx)
-				s.transactions[tx.From] = txList
-			} else {
-				s.transactions[tx.From] = []*model.ETHTransaction{tx}
+	s.gasStats.add(gasStatsOf(blockInfo))
+	number, _ := util.HexToInt64(blockInfo.Number)
+	if s.onBlockProcessed != nil {
+		s.onBlockProcessed(ctx, number, matched)
+	}
+	s.logger.Info(ctx, "[storeBlock]: block processed", "block", number, "txs", len(blockInfo.Transactions),
+		"matched", matched, "duration", time.Since(start))
+	return nil
+}
+
+// storeBlockMatches store the block like storeBlock, returning how many transactions of subscribed addresses,
+// internal transfers included, it matched.
+func (s *ETHService) storeBlockMatches(ctx context.Context, blockInfo *model.ETHBlockInfo) (int, error) {
+	subAddrs := s.subscriptionSnapshot()
+	if len(subAddrs) == 0 {
+		return 0, nil
+	}
+	matches := s.matchTransactions(subAddrs, blockInfo)
+	internal, err := s.matchInternal(ctx, subAddrs, blockInfo)
+	if err != nil {
+		return 0, err
+	}
+	matches = append(matches, internal...)
+	tokenLogs, fromLogs, err := s.matchTokenLogs(ctx, subAddrs, blockInfo)
+	if err != nil {
+		return 0, err
+	}
+	// a single eth_getBlockReceipts per block, rather than a receipt per matched transaction, when the node has it.
+	// every receipt is needed for the token transfers, unless they are matched by eth_getLogs.
+	allReceipts := s.conf.TrackTokenTransfers && !fromLogs
+	var receipts []*model.ETHTransactionReceipt
+	if len(matches) > 0 || allReceipts && len(blockInfo.Transactions) > 0 {
+		receipts, err = s.fetchReceipts(ctx, blockInfo, matches, allReceipts)
+		if err != nil {
+			s.logger.Error(ctx, "[storeBlockMatches]: Error fetchReceipts", "err", err)
+			return 0, err
+		}
+	}
+	withReceipts(matches, receipts)
+	if err := s.storeMatches(ctx, matches); err != nil {
+		return 0, err
+	}
+	if fromLogs {
+		return len(matches), s.storeTokenTransfers(ctx, subAddrs, tokenLogs)
+	}
+	if s.conf.TrackTokenTransfers {
+		// a block failing here is parsed again, the transactions already stored are skipped then.
+		return len(matches), s.parseTokenTransfers(ctx, receipts)
+	}
+	return len(matches), nil
+}
+
+// parseCanonicalBlock parse the block of chain head and remember its hash,
+// errChainReorg is returned if it doesn't extend the block parsed at previous height. start when parsing began.
+func (s *ETHService) parseCanonicalBlock(ctx context.Context, blocks *blockPrefetcher, number int64, start time.Time) error {
+	blockInfo, err := blocks.fetch(ctx, number)
+	if err != nil {
+		s.logger.Error(ctx, "[parseCanonicalBlock]: Error EthGetBlockByNumber", "block", number, "err", err)
+		return err
+	}
+	if parent, ok := s.blockHashes[number-1]; ok && parent != blockInfo.ParentHash {
+		return errChainReorg
+	}
+	if err := s.storeBlock(ctx, blockInfo, start); err != nil {
+		return err
+	}
+	s.blockHashes[number] = blockInfo.Hash
+	delete(s.blockHashes, number-int64(s.conf.ReorgDepth))
+	return nil
+}
+
+// rollback walk back from number to the most recent parsed block which is still canonical,
+// drop the transactions of orphaned blocks above it and return its height.
+// a reorg deeper than conf.ReorgDepth rolls back every tracked block.
+func (s *ETHService) rollback(ctx context.Context, number int64) (int64, error) {
+	ancestor := number - 1 // known orphaned, its hash doesn't match the parent of number.
+	for {
+		ancestor--
+		hash, ok := s.blockHashes[ancestor]
+		if !ok {
+			break
+		}
+		blockInfo, err := s.fetchBlock(ctx, ancestor)
+		if err != nil {
+			s.logger.Error(ctx, "[rollback]: Error EthGetBlockByNumber", "err", err)
+			return 0, err
+		}
+		if blockInfo.Hash == hash {
+			break
+		}
+	}
+	if err := s.storage.Rollback(ctx, ancestor); err != nil {
+		s.logger.Error(ctx, "[rollback]: Error Rollback", "err", err)
+		return 0, err
+	}
+	for h := ancestor + 1; h < number; h++ {
+		delete(s.blockHashes, h)
+	}
+	s.gasStats.invalidateFrom(ancestor + 1)
+	return ancestor, nil
+}
+
+// parseBlock store block transactions of subscribed addresses, without receipts.
+func (s *ETHService) parseBlock(ctx context.Context, blockInfo *model.ETHBlockInfo) error {
+	// 1. snapshot subscribed addresses once per block.
+	subAddrs := s.subscriptionSnapshot()
+	if len(subAddrs) == 0 {
+		return nil
+	}
+
+	// 2. match without holding any lock.
+	return s.storeMatches(ctx, s.matchTransactions(subAddrs, blockInfo))
+}
+
+// storeMatches 3. store a batch per address. the addr read lock is held so an address unsubscribed
+// since the snapshot is skipped rather than left with stale entries, Unsubscribe holds the write lock.
+func (s *ETHService) storeMatches(ctx context.Context, matches []txMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	var addrs []string
+	batches := map[string][]*model.ETHTransaction{}
+	for _, m := range matches {
+		if _, ok := batches[m.address]; !ok {
+			addrs = append(addrs, m.address)
+		}
+		batches[m.address] = append(batches[m.address], m.tx)
+	}
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	for _, addr := range addrs {
+		if _, ok := s.subAddrs[addr]; !ok {
+			continue
+		}
+		if err := s.storage.AppendTransactions(ctx, addr, batches[addr]); err != nil {
+			s.logger.Error(ctx, "[storeMatches]: Error AppendTransactions", "err", err)
+			return &storageError{err}
+		}
+		s.metrics.TransactionsMatched(s.metricsChain(), addr, len(batches[addr]))
+		s.confirmPending(addr, batches[addr])
+		if url := s.webhookOf(addr); len(url) > 0 {
+			s.notifyWebhook(ctx, addr, url, batches[addr])
+		}
+		s.publish(addr, batches[addr])
+		s.observe(ctx, addr, batches[addr])
+	}
+	return nil
+}
+
+// txMatch a transaction stored for a subscribed address.
+type txMatch struct {
+	address string
+	tx      *model.ETHTransaction
+}
+
+// matchTransactions copies of block's transactions sent from or to subAddrs, meeting the options of the
+// subscription they are stored for.
+func (s *ETHService) matchTransactions(subAddrs map[string]*SubscriptionConfig, blockInfo *model.ETHBlockInfo) []txMatch {
+	var matches []txMatch
+	for _, tx := range blockInfo.Transactions {
+		// some nodes return EIP-55 mixed-case addresses, subscriptions are keyed in lowercase.
+		// a tx between two subscribed addresses is stored for both, outbound first.
+		for _, p := range transferParties(subAddrs, strings.ToLower(tx.From), strings.ToLower(tx.To)) {
+			if s.belowMinValue(tx, subAddrs[p.address]) {
+				continue
+			}
+			stored := storedCopy(tx, p.direction)
+			if !s.passesFilter(subAddrs[p.address], stored) {
+				continue
 			}
+			matches = append(matches, txMatch{p.address, stored})
+		}
+	}
+	withBlockTime(matches, blockInfo)
+	return matches
+}
+
+// withBlockTime set the timestamp of blockInfo on matched transactions, left nil if the block has none.
+func withBlockTime(matches []txMatch, blockInfo *model.ETHBlockInfo) {
+	sec, err := util.HexToInt64(blockInfo.Timestamp)
+	if err != nil {
+		return
+	}
+	timestamp := time.Unix(sec, 0).UTC()
+	for _, m := range matches {
+		m.tx.Timestamp = &timestamp
+	}
+}
+
+// belowMinValue whether tx transfers less than the MinValue of the subscription of conf, or Config.MinValue
+// if it has none. values are 256-bit, a malformed one counts as 0.
+func (s *ETHService) belowMinValue(tx *model.ETHTransaction, conf *SubscriptionConfig) bool {
+	min := s.minValueOf(conf)
+	if min == nil || min.Sign() <= 0 {
+		return false
+	}
+	value, err := util.HexToBigInt(tx.Value)
+	if err != nil {
+		return true
+	}
+	return value.Cmp(min) < 0
+}
+
+// subscriptionSnapshot copy of the subscribed addresses and their configs.
+func (s *ETHService) subscriptionSnapshot() map[string]*SubscriptionConfig {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	subAddrs := make(map[string]*SubscriptionConfig, len(s.subAddrs))
+	for addr, conf := range s.subAddrs {
+		subAddrs[addr] = conf
+	}
+	return subAddrs
+}
+
+func (s *ETHService) hasSubscription() bool {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	return len(s.subAddrs) > 0
+}
+
+// confirmedBlock the most recent block having conf.Confirmations confirmations, every block if
+// confirmations aren't required. the transactions of later blocks stay stored until the chain moves on.
+func (s *ETHService) confirmedBlock() int64 {
+	if s.conf.Confirmations <= 0 {
+		return store.NoMaxBlock
+	}
+	return atomic.LoadInt64(&s.recentBlockNumer) - int64(s.conf.Confirmations)
+}
+
+// withReceipts fill the status, gas used, effective gas price and created contract of matched transactions
+// from their receipts. an internal transfer gets the status of its transaction only.
+func withReceipts(matches []txMatch, receipts []*model.ETHTransactionReceipt) {
+	if len(matches) == 0 || len(receipts) == 0 {
+		return
+	}
+	byHash := make(map[string]*model.ETHTransactionReceipt, len(receipts))
+	for _, receipt := range receipts {
+		byHash[strings.ToLower(receipt.TransactionHash)] = receipt
+	}
+	for _, m := range matches {
+		receipt, ok := byHash[strings.ToLower(m.tx.Hash)]
+		if !ok {
+			continue
 		}
-		if _, ok := s.subAddrs[tx.To]; ok {
-			// inboundTx: From -> To
-			if txList, okk := s.transactions[tx.To]; okk {
-				txList = append(txList, tx)
-				s.transactions[tx.To] = txList
-			} else {
-				s.transactions[tx.To] = []*model.ETHTransaction{tx}
+		if !m.tx.Internal {
+			m.tx.GasUsed = receipt.GasUsed
+			m.tx.EffectiveGasPrice = receipt.EffectiveGasPrice
+			if m.tx.Quantities != nil {
+				m.tx.Quantities.GasUsed = model.ParseDecimal(receipt.GasUsed)
+				m.tx.Quantities.EffectiveGasPrice = model.ParseDecimal(receipt.EffectiveGasPrice)
 			}
+			if receipt.ContractAddress != "" {
+				m.tx.ContractAddress = strings.ToLower(receipt.ContractAddress)
+			}
+		}
+		// pre-Byzantium receipts carry a state root instead of a status.
+		switch receipt.Status {
+		case "0x1":
+			m.tx.Status = model.TxStatusSuccess
+		case "0x0":
+			m.tx.Status = model.TxStatusFailed
 		}
-		s.addrRWMutex.RUnlock()
-		s.txRWMutex.Unlock()
 	}
-	return nil
-}
\ No newline at end of file
+}
+
+// storedCopy copy of tx with lowercase addresses, tagged with the direction and the called method. a tx between two
+// subscribed addresses is stored for both of them in different directions.
+func storedCopy(tx *model.ETHTransaction, direction model.Direction) *model.ETHTransaction {
+	stored := *tx
+	stored.From = strings.ToLower(tx.From)
+	stored.To = strings.ToLower(tx.To)
+	stored.Direction = direction
+	stored.State = model.TxStateConfirmed
+	stored.MethodID = model.MethodSelector(tx.Input)
+	stored.MethodName = model.MethodName(stored.MethodID)
+	// empty while pending, which leaves 0.
+	stored.BlockNum, _ = util.HexToInt64(tx.BlockNumber)
+	stored.TxIndex, _ = util.HexToInt64(tx.TransactionIndex)
+	stored.Quantities = model.ParseQuantities(tx)
+	stored.TypeName = tx.TxType().String()
+	return &stored
+}
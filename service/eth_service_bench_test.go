@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// parseBlockPerTxLocking block parsing as it used to be, locking and storing every transaction on its own.
+// kept as the baseline of BenchmarkParseBlock.
+func (s *ETHService) parseBlockPerTxLocking(ctx context.Context, blockInfo *model.ETHBlockInfo) error {
+	for _, tx := range blockInfo.Transactions {
+		from, to := strings.ToLower(tx.From), strings.ToLower(tx.To)
+		s.addrRWMutex.RLock()
+		if _, ok := s.subAddrs[from]; ok {
+			s.storage.AppendTransactions(ctx, from, []*model.ETHTransaction{storedCopy(tx, model.DirectionOutbound)})
+		}
+		if _, ok := s.subAddrs[to]; ok {
+			s.storage.AppendTransactions(ctx, to, []*model.ETHTransaction{storedCopy(tx, model.DirectionInbound)})
+		}
+		s.addrRWMutex.RUnlock()
+	}
+	return nil
+}
+
+func syntheticBlock(txNum int) *model.ETHBlockInfo {
+	block := &model.ETHBlockInfo{}
+	for i := 0; i < txNum; i++ {
+		block.Transactions = append(block.Transactions, &model.ETHTransaction{
+			Hash: fmt.Sprintf("0x%x", i),
+			From: fmt.Sprintf("0x%040x", i),
+			To:   fmt.Sprintf("0x%040x", i+txNum),
+		})
+	}
+	return block
+}
+
+func BenchmarkParseBlock(b *testing.B) {
+	ctx := context.Background()
+	block := syntheticBlock(500)
+	parsers := map[string]func(*ETHService, context.Context, *model.ETHBlockInfo) error{
+		"PerTxLocking": (*ETHService).parseBlockPerTxLocking,
+		"PerBlock":     (*ETHService).parseBlock,
+	}
+	for name, parse := range parsers {
+		parse := parse
+		newInstance := func() *ETHService {
+			instance := newTestETHService()
+			// a tenth of the transactions are subscribed.
+			for i := 0; i < 500; i += 10 {
+				instance.Subscribe(ctx, block.Transactions[i].From)
+			}
+			return instance
+		}
+		b.Run(name, func(b *testing.B) {
+			instance := newInstance()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				parse(instance, ctx, block)
+			}
+		})
+		// parsing while readers keep querying transactions.
+		b.Run(name+"Contended", func(b *testing.B) {
+			instance := newInstance()
+			done := make(chan struct{})
+			defer close(done)
+			for i := 0; i < 4; i++ {
+				go func() {
+					for {
+						select {
+						case <-done:
+							return
+						default:
+							instance.GetTransactions(ctx, addrA)
+						}
+					}
+				}()
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				parse(instance, ctx, block)
+			}
+		})
+	}
+}
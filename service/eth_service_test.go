@@ -2,33 +2,58 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/tj/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/redis"
+	"github.com/sugarshop/token-gateway/util"
+	"github.com/sugarshop/token-gateway/webhook"
+	"github.com/tj/assert"
 )
 
 func TestETHService_GetCurrentBlock(t *testing.T) {
 	ctx := context.Background()
-	instance := ETHServiceInstance()
+	instance, err := ETHServiceInstance()
+	assert.Nil(t, err)
 	blockInfo, err := instance.GetCurrentBlock(ctx)
 	assert.Nil(t, err)
 	assert.NotNil(t, blockInfo)
-	assert.Equal(t, fmt.Sprintf("0x%x", instance.recentBlockNumer), blockInfo.Number)
+	assert.Equal(t, fmt.Sprintf("0x%x", instance.LastProcessedBlock(ctx)), blockInfo.Number)
 }
 
 func TestETHService_Subscribe(t *testing.T) {
 	ctx := context.Background()
 	address := "0x76759058b7a242A86a0367729FAe98803d86891B"
-	ETHServiceInstance().Subscribe(ctx, address)
-	_, ok := ETHServiceInstance().subAddrs[strings.ToLower(address)]
+	instance, err := ETHServiceInstance()
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, address)
+	_, ok := instance.subAddrs[strings.ToLower(address)]
 	assert.Equal(t, ok, true)
 }
 
 func TestETHService_GetTransactions(t *testing.T) {
 	ctx := context.Background()
 	blockNumber := int64(19862630)
-	instance := ETHServiceInstance()
+	instance, err := ETHServiceInstance()
+	assert.Nil(t, err)
 	txCaseList := []struct{
 		Addr string
 		txNum int
@@ -43,7 +68,7 @@ func TestETHService_GetTransactions(t *testing.T) {
 	for _, txCase := range txCaseList {
 		instance.Subscribe(ctx, txCase.Addr)
 	}
-	err := instance.ParseTransactions(ctx, blockNumber)
+	err = instance.ParseTransactions(ctx, blockNumber)
 	assert.Nil(t, err)
 
 	for _, txCase := range txCaseList {
@@ -56,4 +81,1988 @@ func TestETHService_GetTransactions(t *testing.T) {
 			})
 		}
 	}
-}
\ No newline at end of file
+}
+
+// 20 bytes test addresses, named after their last byte. the upper ones are the same addresses in uppercase.
+const (
+	addrA      = "0x00000000000000000000000000000000000000aa"
+	addrB      = "0x00000000000000000000000000000000000000bb"
+	addrC      = "0x00000000000000000000000000000000000000cc"
+	addrD      = "0x00000000000000000000000000000000000000dd"
+	upperAddrA = "0x00000000000000000000000000000000000000AA"
+	upperAddrB = "0x00000000000000000000000000000000000000BB"
+	upperAddrC = "0x00000000000000000000000000000000000000CC"
+)
+
+// testConfig DefaultConfig reporting transactions as soon as they are parsed.
+func testConfig() Config {
+	conf := DefaultConfig()
+	conf.Confirmations = 0
+	return conf
+}
+
+// newTestETHService return an ETHService on a fake chain at block 0, not polling.
+func newTestETHService() *ETHService {
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(testConfig()), WithLogger(logging.Nop()))
+	if err != nil {
+		panic(err)
+	}
+	return instance
+}
+
+func TestNewETHService(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewETHService(nil)
+	assert.NotNil(t, err)
+
+	client := newFakeETHClient(42)
+	client.headErr = errors.New("node unreachable")
+	_, err = NewETHService(client)
+	assert.NotNil(t, err)
+
+	client.headErr = nil
+	clock := newFakeClock()
+	instance, err := NewETHService(client, WithConfig(Config{ReorgDepth: 3}), withClock(clock))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, 3, instance.conf.ReorgDepth)
+
+	// nothing is polled before Start, which loads right away, then arms the poll timer.
+	client.setHead(45)
+	calls := client.callCount()
+	assert.Equal(t, 0, len(clock.timers))
+	assert.Equal(t, int64(42), instance.LastProcessedBlock(ctx))
+	assert.Nil(t, instance.Start(ctx))
+	defer instance.Stop(ctx)
+	clock.next(t)
+	assert.True(t, client.callCount() > calls)
+	assert.Equal(t, int64(45), instance.LastProcessedBlock(ctx))
+}
+
+func TestNewETHService_SubscribeAndParse(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(7)
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, upperAddrA))
+	client.setBlock(8, "0xh8", "0xh7", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB})
+	client.setHead(8)
+
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, []int64{8}, client.fetched)
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1"}, hashesOf(list))
+}
+
+func TestETHService_Unsubscribe(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	address := "0x76759058b7a242A86a0367729FAe98803d86891B"
+	lower := strings.ToLower(address)
+
+	// never subscribed, no-op.
+	assert.Nil(t, instance.Unsubscribe(ctx, address, true))
+
+	instance.Subscribe(ctx, address)
+	instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{{Hash: "0x1", From: lower, To: "0x2"}},
+	})
+	assert.Nil(t, instance.Unsubscribe(ctx, lower, false))
+	_, ok := instance.subAddrs[lower]
+	assert.False(t, ok)
+	list, _ := instance.GetTransactions(ctx, address)
+	assert.Equal(t, 1, len(list))
+
+	instance.Subscribe(ctx, address)
+	assert.Nil(t, instance.Unsubscribe(ctx, address, true))
+	list, _ = instance.GetTransactions(ctx, address)
+	assert.Equal(t, 0, len(list))
+	assert.Nil(t, instance.Unsubscribe(ctx, address, true))
+}
+
+func TestETHService_UnsubscribePurgeFails(t *testing.T) {
+	ctx := context.Background()
+	storage := &failingStorage{Storage: store.NewMemory(0)}
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{{Hash: "0x1", From: addrA, To: addrB}},
+	})
+
+	// the purge failed, still subscribed, so it can be retried.
+	storage.setFail(true)
+	assert.NotNil(t, instance.Unsubscribe(ctx, addrA, true))
+	assert.Equal(t, []string{addrA}, instance.ListSubscriptions(ctx))
+
+	storage.setFail(false)
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, true))
+	assert.Equal(t, []string{}, instance.ListSubscriptions(ctx))
+	list, _, err := storage.GetTransactions(ctx, addrA, store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(list))
+}
+
+func TestETHService_UnsubscribeRace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	addrs := []string{addrA, addrB, addrC}
+	block := &model.ETHBlockInfo{}
+	for i, addr := range addrs {
+		block.Transactions = append(block.Transactions, &model.ETHTransaction{
+			Hash: fmt.Sprintf("0x%x", i),
+			From: addr,
+			To:   addrs[(i+1)%len(addrs)],
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(2)
+		go func(addr string) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				instance.Subscribe(ctx, addr)
+			}
+		}(addr)
+		go func(addr string) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				assert.Nil(t, instance.Unsubscribe(ctx, addr, i%2 == 0))
+			}
+		}(addr)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			instance.parseBlock(ctx, block)
+		}
+	}()
+	wg.Wait()
+
+	for _, addr := range addrs {
+		assert.Nil(t, instance.Unsubscribe(ctx, addr, true))
+		_, err := instance.GetTransactions(ctx, addr)
+		assert.Equal(t, ErrNotSubscribed, err)
+	}
+}
+
+func TestETHService_SubscribeInvalidAddress(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	for _, address := range []string{"", "0xaa", "5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0xzzeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		// one letter flipped from the checksum.
+		"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"} {
+		assert.True(t, errors.Is(instance.Subscribe(ctx, address), util.ErrInvalidAddress), address)
+		_, err := instance.GetTransactions(ctx, address)
+		assert.True(t, errors.Is(err, util.ErrInvalidAddress), address)
+	}
+	assert.Equal(t, []string{}, instance.ListSubscriptions(ctx))
+
+	assert.Nil(t, instance.Subscribe(ctx, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"))
+	assert.Nil(t, instance.Subscribe(ctx, "0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359"))
+	assert.Equal(t, []string{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"}, instance.ListSubscriptions(ctx))
+	_, err := instance.GetTransactions(ctx, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assert.Nil(t, err)
+}
+
+func TestETHService_SubscribeIfNew(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	var wg sync.WaitGroup
+	var added int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := instance.SubscribeIfNew(ctx, addrA)
+			assert.Nil(t, err)
+			if ok {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), added)
+
+	// in any case.
+	ok, err := instance.SubscribeIfNew(ctx, "0x"+strings.ToUpper(addrA[2:]))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, false))
+	ok, err = instance.SubscribeIfNew(ctx, addrA)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestETHService_UnsubscribeNeverSubscribed(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	assert.Nil(t, instance.Unsubscribe(ctx, "0x"+strings.ToUpper(addrA[2:]), true))
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, false))
+	assert.Nil(t, instance.Unsubscribe(ctx, "nobody.eth", false))
+	assert.Equal(t, 0, len(instance.ListSubscriptions(ctx)))
+
+	// malformed, or a mixed-case address with a wrong checksum.
+	for _, address := range []string{"0xAbC", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD"} {
+		assert.True(t, errors.Is(instance.Unsubscribe(ctx, address, false), ErrInvalidAddress), address)
+		assert.False(t, instance.IsSubscribed(ctx, address), address)
+		_, err := instance.FilterTransactions(ctx, address, model.TxFilter{State: model.TxStatePending})
+		assert.True(t, errors.Is(err, ErrInvalidAddress), address)
+	}
+}
+
+func TestETHService_ListSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	assert.Equal(t, []string{}, instance.ListSubscriptions(ctx))
+
+	instance.Subscribe(ctx, upperAddrC)
+	instance.Subscribe(ctx, addrA)
+	instance.Subscribe(ctx, upperAddrB)
+	addrs := instance.ListSubscriptions(ctx)
+	assert.Equal(t, []string{addrA, addrB, addrC}, addrs)
+
+	// mutate the snapshot, internal state stays untouched.
+	addrs[0] = addrD
+	assert.Equal(t, []string{addrA, addrB, addrC}, instance.ListSubscriptions(ctx))
+}
+
+func TestETHService_ListSubscriptionsWithPrefix(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	subscriptions, err := instance.ListSubscriptionsWithPrefix(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Subscription{}, subscriptions)
+
+	assert.Nil(t, instance.SubscribeWithWebhook(ctx, addrB, "https://example.com/hook"))
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrC))
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: addrA, To: addrB, BlockNumber: "0x7"},
+			{Hash: "0x2", From: addrB, To: addrD, BlockNumber: "0x7", TransactionIndex: "0x1"},
+		},
+	}))
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{{Hash: "0x3", From: addrD, To: addrA, BlockNumber: "0x9"}},
+	}))
+
+	subscriptions, err = instance.ListSubscriptionsWithPrefix(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(subscriptions))
+	assert.Equal(t, addrA, subscriptions[0].Address)
+	assert.Equal(t, 2, subscriptions[0].TransactionCount)
+	assert.Equal(t, int64(9), subscriptions[0].LastMatchBlock)
+	assert.NotNil(t, subscriptions[0].SubscribedAt)
+	assert.Equal(t, "", subscriptions[0].Webhook)
+	assert.Equal(t, addrB, subscriptions[1].Address)
+	assert.Equal(t, 2, subscriptions[1].TransactionCount)
+	assert.Equal(t, int64(7), subscriptions[1].LastMatchBlock)
+	assert.Equal(t, "https://example.com/hook", subscriptions[1].Webhook)
+	assert.Equal(t, addrC, subscriptions[2].Address)
+	assert.Equal(t, 0, subscriptions[2].TransactionCount)
+	assert.Equal(t, int64(0), subscriptions[2].LastMatchBlock)
+
+	// the prefix matches in any case.
+	subscriptions, err = instance.ListSubscriptionsWithPrefix(ctx, "0x00000000000000000000000000000000000000B")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(subscriptions))
+	assert.Equal(t, addrB, subscriptions[0].Address)
+
+	assert.Nil(t, instance.Unsubscribe(ctx, addrB, false))
+	subscriptions, err = instance.ListSubscriptionsWithPrefix(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subscriptions))
+	assert.False(t, instance.IsSubscribed(ctx, addrB))
+}
+
+// fakeETHClient in-memory chain serving ethClient calls.
+type fakeETHClient struct {
+	mu      sync.Mutex
+	head    int64
+	blocks  map[int64]*model.ETHBlockInfo
+	fails   map[int64]int // remaining failures of EthGetBlockByNumber per block.
+	fetched []int64
+
+	receipts     map[int64][]*model.ETHTransactionReceipt
+	receiptFails map[int64]int // remaining failures of EthGetBlockReceipts per block.
+	headErr      error
+	calls        int // RPC calls of any method.
+}
+
+func (f *fakeETHClient) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func newFakeETHClient(head int64) *fakeETHClient {
+	return &fakeETHClient{
+		head:   head,
+		blocks: map[int64]*model.ETHBlockInfo{},
+		fails:  map[int64]int{},
+
+		receipts:     map[int64][]*model.ETHTransactionReceipt{},
+		receiptFails: map[int64]int{},
+	}
+}
+
+func (f *fakeETHClient) setHead(head int64) {
+	f.mu.Lock()
+	f.head = head
+	f.mu.Unlock()
+}
+
+func (f *fakeETHClient) ETHBlockDecimalNumber(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.head, f.headErr
+}
+
+func (f *fakeETHClient) EthBlockNumber(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return fmt.Sprintf("0x%x", f.head), f.headErr
+}
+
+func (f *fakeETHClient) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	num, err := strconv.ParseInt(strings.TrimPrefix(number, "0x"), 16, 64)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.fetched = append(f.fetched, num)
+	if f.fails[num] > 0 {
+		f.fails[num]--
+		return nil, errors.New("fake rpc error")
+	}
+	if block, ok := f.blocks[num]; ok {
+		return block, nil
+	}
+	return &model.ETHBlockInfo{
+		Number:     number,
+		Hash:       fmt.Sprintf("0xh%d", num),
+		ParentHash: fmt.Sprintf("0xh%d", num-1),
+	}, nil
+}
+
+func (f *fakeETHClient) EthGetBlockReceipts(ctx context.Context, number string) ([]*model.ETHTransactionReceipt, error) {
+	num, err := strconv.ParseInt(strings.TrimPrefix(number, "0x"), 16, 64)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.receiptFails[num] > 0 {
+		f.receiptFails[num]--
+		return nil, errors.New("fake rpc error")
+	}
+	if receipts, ok := f.receipts[num]; ok {
+		return receipts, nil
+	}
+	return []*model.ETHTransactionReceipt{}, nil
+}
+
+// testBlockTime time block num of fakeETHClient is mined at, 12s apart.
+func testBlockTime(num int64) time.Time {
+	return time.Unix(1700000000+12*num, 0).UTC()
+}
+
+func (f *fakeETHClient) setBlock(num int64, hash string, parentHash string, transactions ...*model.ETHTransaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tx := range transactions {
+		tx.BlockHash = hash
+		tx.BlockNumber = fmt.Sprintf("0x%x", num)
+	}
+	f.blocks[num] = &model.ETHBlockInfo{
+		Number:       fmt.Sprintf("0x%x", num),
+		Timestamp:    fmt.Sprintf("0x%x", testBlockTime(num).Unix()),
+		Hash:         hash,
+		ParentHash:   parentHash,
+		Transactions: transactions,
+	}
+}
+
+func TestETHService_LoadCatchUp(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(100)
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 100
+
+	client.setHead(105)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, []int64{101, 102, 103, 104, 105}, client.fetched)
+	assert.Equal(t, int64(105), instance.LastProcessedBlock(ctx))
+
+	// no new block, no fetch.
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 5, len(client.fetched))
+
+	// failed over to a node one block behind, it doesn't go backwards.
+	client.setHead(104)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 5, len(client.fetched))
+	assert.Equal(t, int64(105), instance.LastProcessedBlock(ctx))
+}
+
+func TestETHService_LoadFailedBlockNotSkipped(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(10)
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 10
+
+	client.setHead(13)
+	client.fails[12] = 1
+	assert.NotNil(t, instance.load(ctx))
+	assert.Equal(t, int64(11), instance.LastProcessedBlock(ctx))
+
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, []int64{11, 12, 12, 13}, client.fetched)
+	assert.Equal(t, int64(13), instance.LastProcessedBlock(ctx))
+}
+
+func TestETHService_LoadRetryTransientError(t *testing.T) {
+	ctx := context.Background()
+	address := "0x107fe4e8248ae91651668666e82752890d700eec"
+	client := newFakeETHClient(20)
+	client.blocks[21] = &model.ETHBlockInfo{
+		Number:       "0x15",
+		Transactions: []*model.ETHTransaction{{Hash: "0x1", From: address, To: "0x2"}},
+	}
+	client.fails[21] = maxBlockRetries - 1
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 20
+	instance.Subscribe(ctx, address)
+
+	client.setHead(21)
+	for i := 0; i < maxBlockRetries-1; i++ {
+		assert.NotNil(t, instance.load(ctx))
+		assert.Equal(t, int64(20), instance.LastProcessedBlock(ctx))
+	}
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(21), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, int64(0), instance.SkippedBlocks())
+	list, _ := instance.GetTransactions(ctx, address)
+	assert.Equal(t, 1, len(list))
+}
+
+func TestETHService_LoadSkipAfterRetryBudget(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(30)
+	client.fails[31] = maxBlockRetries
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 30
+
+	client.setHead(32)
+	for i := 0; i < maxBlockRetries-1; i++ {
+		assert.NotNil(t, instance.load(ctx))
+	}
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(32), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, int64(1), instance.SkippedBlocks())
+	assert.Equal(t, 0, len(instance.blockRetries))
+}
+
+func TestETHService_LoadCatchUpCap(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	instance := newTestETHService()
+	instance.client = client
+
+	client.setHead(maxCatchUpBlocks*2 + 10)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(maxCatchUpBlocks), instance.LastProcessedBlock(ctx))
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(maxCatchUpBlocks*2), instance.LastProcessedBlock(ctx))
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(maxCatchUpBlocks*2+10), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, maxCatchUpBlocks*2+10, len(client.fetched))
+}
+
+// fakeBatchClient fake chain answering batches of blocks, a block failing in a batch is left nil.
+type fakeBatchClient struct {
+	*fakeETHClient
+	batches [][]int64
+}
+
+func (f *fakeBatchClient) EthGetBlocksByNumber(ctx context.Context, numbers []string) ([]*model.ETHBlockInfo, error) {
+	blocks := make([]*model.ETHBlockInfo, len(numbers))
+	batch := make([]int64, 0, len(numbers))
+	var lastErr error
+	for i, number := range numbers {
+		num, _ := strconv.ParseInt(strings.TrimPrefix(number, "0x"), 16, 64)
+		batch = append(batch, num)
+		if blocks[i], lastErr = f.EthGetBlockByNumber(ctx, number); lastErr != nil {
+			blocks[i] = nil
+		}
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, batch)
+	f.mu.Unlock()
+	return blocks, lastErr
+}
+
+func blockRange(from, to int64) []int64 {
+	numbers := make([]int64, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+func TestETHService_LoadCatchUpBatch(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeBatchClient{fakeETHClient: newFakeETHClient(10)}
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 10
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	client.setBlock(33, "0xh33", "0xh32", &model.ETHTransaction{Hash: "0x33", From: addrA})
+	client.fails[22] = 1
+	client.setHead(45)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, [][]int64{blockRange(11, 30), blockRange(31, 45)}, client.batches)
+	// 22 failed in its batch, it alone is fetched again.
+	assert.Equal(t, append(append(blockRange(11, 30), 22), blockRange(31, 45)...), client.fetched)
+	assert.Equal(t, int64(45), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, int64(0), instance.SkippedBlocks())
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x33"}, hashesOf(list))
+
+	// 45 is replaced, the blocks after the ancestor are fetched again in a batch.
+	client.setBlock(45, "0xh45b", "0xh44", &model.ETHTransaction{Hash: "0x45b", To: addrA})
+	client.setBlock(46, "0xh46", "0xh45b")
+	client.setHead(46)
+	client.batches, client.fetched = nil, nil
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, []int64{46, 44, 45, 46}, client.fetched)
+	assert.Equal(t, [][]int64{{45, 46}}, client.batches)
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x33", "0x45b"}, hashesOf(list))
+}
+
+func TestETHService_ParseUnsubscribeRace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	address := addrA
+	block := &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: address, To: addrB},
+			{Hash: "0x2", From: addrB, To: address},
+		},
+	}
+
+	for i := 0; i < 500; i++ {
+		instance.Subscribe(ctx, address)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			instance.parseBlock(ctx, block)
+		}()
+		go func() {
+			defer wg.Done()
+			instance.Subscribe(ctx, addrB)
+			instance.Unsubscribe(ctx, addrB, true)
+		}()
+		assert.Nil(t, instance.Unsubscribe(ctx, address, true))
+		// a block parsed after Unsubscribe returned must not store anything for the address.
+		instance.parseBlock(ctx, block)
+		wg.Wait()
+		list, _, err := instance.storage.GetTransactions(ctx, address, store.Query{MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(list))
+	}
+}
+
+func TestETHService_LoadReorg(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	client := newFakeETHClient(10)
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 10
+	instance.Subscribe(ctx, address)
+
+	client.setBlock(11, "0xh11", "0xh10", &model.ETHTransaction{Hash: "0xkept", From: address})
+	client.setBlock(12, "0xh12", "0xh11", &model.ETHTransaction{Hash: "0xorphan", To: address})
+	client.setHead(12)
+	assert.Nil(t, instance.load(ctx))
+	list, _ := instance.GetTransactions(ctx, address)
+	assert.Equal(t, []string{"0xkept", "0xorphan"}, hashesOf(list))
+
+	// block 12 is replaced, 13 builds on the new 12.
+	client.setBlock(12, "0xh12b", "0xh11", &model.ETHTransaction{Hash: "0xreplaced", To: address})
+	client.setBlock(13, "0xh13", "0xh12b", &model.ETHTransaction{Hash: "0xnew", From: address})
+	client.setHead(13)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(13), instance.LastProcessedBlock(ctx))
+	list, _ = instance.GetTransactions(ctx, address)
+	assert.Equal(t, []string{"0xkept", "0xreplaced", "0xnew"}, hashesOf(list))
+	assert.Equal(t, "0xh12b", instance.blockHashes[12])
+}
+
+// fakeCachingClient fake chain recording the cache invalidations.
+type fakeCachingClient struct {
+	*fakeETHClient
+	invalidated []int64
+}
+
+func (f *fakeCachingClient) InvalidateBlocksFrom(number int64) {
+	f.invalidated = append(f.invalidated, number)
+}
+
+func TestETHService_LoadReorgInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeCachingClient{fakeETHClient: newFakeETHClient(10)}
+	conf := testConfig()
+	conf.ReorgDepth = 4
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	instance.recentBlockNumer = 10
+
+	client.setHead(12)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 0, len(client.invalidated))
+
+	client.setBlock(12, "0xh12b", "0xh11")
+	client.setBlock(13, "0xh13", "0xh12b")
+	client.setHead(13)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, []int64{9}, client.invalidated)
+	assert.Equal(t, "0xh12b", instance.blockHashes[12])
+}
+
+func TestETHService_LoadTwoBlockReorg(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	client := newFakeETHClient(10)
+	instance := newTestETHService()
+	instance.client = client
+	instance.recentBlockNumer = 10
+	instance.Subscribe(ctx, address)
+
+	client.setBlock(11, "0xh11", "0xh10", &model.ETHTransaction{Hash: "0x11", From: address})
+	client.setBlock(12, "0xh12", "0xh11", &model.ETHTransaction{Hash: "0x12", To: address})
+	client.setBlock(13, "0xh13", "0xh12", &model.ETHTransaction{Hash: "0x13", From: address})
+	client.setHead(13)
+	assert.Nil(t, instance.load(ctx))
+
+	// 12 and 13 are orphaned, the canonical chain goes on from 11 up to 14.
+	client.setBlock(12, "0xh12b", "0xh11", &model.ETHTransaction{Hash: "0x12b", To: address})
+	client.setBlock(13, "0xh13b", "0xh12b")
+	client.setBlock(14, "0xh14b", "0xh13b", &model.ETHTransaction{Hash: "0x14b", From: address})
+	client.setHead(14)
+	client.fetched = nil
+	assert.Nil(t, instance.load(ctx))
+
+	// 14 doesn't extend the stored 13, walk back until the ancestor 11 and parse the canonical 12 to 14.
+	assert.Equal(t, []int64{14, 12, 11, 12, 13, 14}, client.fetched)
+	assert.Equal(t, int64(14), instance.LastProcessedBlock(ctx))
+	list, _ := instance.GetTransactions(ctx, address)
+	assert.Equal(t, []string{"0x11", "0x12b", "0x14b"}, hashesOf(list))
+	for _, tx := range list {
+		assert.Equal(t, instance.blockHashes[store.PositionOf(tx).Block], tx.BlockHash)
+	}
+}
+
+func TestETHService_LoadDeepReorg(t *testing.T) {
+	ctx := context.Background()
+	address := addrA
+	client := newFakeETHClient(0)
+	instance := newTestETHService()
+	instance.conf.ReorgDepth = 3
+	instance.client = client
+	instance.Subscribe(ctx, address)
+
+	for i := int64(1); i <= 5; i++ {
+		client.setBlock(i, fmt.Sprintf("0xh%d", i), fmt.Sprintf("0xh%d", i-1),
+			&model.ETHTransaction{Hash: fmt.Sprintf("0xa%d", i), From: address})
+	}
+	client.setHead(5)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 3, len(instance.blockHashes))
+
+	// every tracked block is replaced, rollback stops at the edge of the tracked window.
+	for i := int64(3); i <= 6; i++ {
+		client.setBlock(i, fmt.Sprintf("0xb%d", i), fmt.Sprintf("0xb%d", i-1),
+			&model.ETHTransaction{Hash: fmt.Sprintf("0xb%d", i), From: address})
+	}
+	client.blocks[3].ParentHash = "0xh2"
+	client.setHead(6)
+	assert.Nil(t, instance.load(ctx))
+	list, _ := instance.GetTransactions(ctx, address)
+	assert.Equal(t, []string{"0xa1", "0xa2", "0xb3", "0xb4", "0xb5", "0xb6"}, hashesOf(list))
+}
+
+func TestETHService_FilterTransactionsDirection(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+	instance.Subscribe(ctx, addrB)
+	instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: addrA, To: addrC},
+			{Hash: "0x2", From: addrB, To: addrA},
+			{Hash: "0x3", From: addrA, To: addrA},
+		},
+	})
+
+	cases := []struct {
+		addr      string
+		direction model.Direction
+		hashes    []string
+	}{
+		{addrA, "", []string{"0x1", "0x2", "0x3"}},
+		{addrA, model.DirectionOutbound, []string{"0x1", "0x3"}},
+		{addrA, model.DirectionInbound, []string{"0x2", "0x3"}},
+		{addrB, model.DirectionOutbound, []string{"0x2"}},
+		{addrB, model.DirectionInbound, []string{}},
+	}
+	for _, c := range cases {
+		list, err := instance.FilterTransactions(ctx, c.addr, model.TxFilter{Direction: c.direction})
+		assert.Nil(t, err)
+		assert.Equal(t, c.hashes, hashesOf(list), c.addr+" "+string(c.direction))
+	}
+
+	// the same tx is stored for both addresses with its own direction.
+	aa, _ := instance.GetTransactions(ctx, addrA)
+	bb, _ := instance.GetTransactions(ctx, addrB)
+	assert.Equal(t, model.DirectionInbound, aa[1].Direction)
+	assert.Equal(t, model.DirectionOutbound, bb[0].Direction)
+	assert.Equal(t, model.DirectionSelf, aa[2].Direction)
+}
+
+func TestETHService_FilterTransactionsMethod(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+	instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: addrA, To: addrC, Input: "0x"},
+			{Hash: "0x2", From: addrA, To: addrC, Input: "0xA9059CBB000000000000000000000000000000000000000000000000000000000000beef"},
+			{Hash: "0x3", From: addrA, To: addrC, Input: "0x12345678"},
+			{Hash: "0x4", From: addrA, To: addrC, Input: "0x1234"},
+		},
+	})
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, "", list[0].MethodID)
+	assert.Equal(t, "0xa9059cbb", list[1].MethodID)
+	assert.Equal(t, "transfer", list[1].MethodName)
+	assert.Equal(t, "0x12345678", list[2].MethodID)
+	assert.Equal(t, "", list[2].MethodName)
+	assert.Equal(t, "", list[3].MethodID)
+
+	for _, method := range []string{"transfer", "0xa9059cbb", "0xA9059CBB"} {
+		list, err = instance.FilterTransactions(ctx, addrA, model.TxFilter{Method: method})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0x2"}, hashesOf(list), method)
+	}
+	list, _ = instance.FilterTransactions(ctx, addrA, model.TxFilter{Method: "approve"})
+	assert.Equal(t, []string{}, hashesOf(list))
+}
+
+func TestETHService_ParseMixedCaseAddresses(t *testing.T) {
+	ctx := context.Background()
+	lower := "0x76759058b7a242a86a0367729fae98803d86891b"
+	checksum := "0x76759058b7a242A86a0367729FAe98803d86891B"
+	upper := "0x76759058B7A242A86A0367729FAE98803D86891B"
+	cases := []struct {
+		name      string
+		subscribe string
+		from      string
+		to        string
+		query     string
+		direction model.Direction
+	}{
+		{"checksum from", lower, checksum, addrB, lower, model.DirectionOutbound},
+		{"upper to", checksum, addrB, upper, upper, model.DirectionInbound},
+		{"lower to", upper, upperAddrB, lower, checksum, model.DirectionInbound},
+		{"mixed self", lower, checksum, upper, lower, model.DirectionSelf},
+	}
+	for _, c := range cases {
+		instance := newTestETHService()
+		instance.Subscribe(ctx, c.subscribe)
+		instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Transactions: []*model.ETHTransaction{{Hash: "0x1", From: c.from, To: c.to}},
+		})
+		list, err := instance.GetTransactions(ctx, c.query)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(list), c.name)
+		assert.Equal(t, c.direction, list[0].Direction, c.name)
+		assert.Equal(t, strings.ToLower(c.from), list[0].From, c.name)
+		assert.Equal(t, strings.ToLower(c.to), list[0].To, c.name)
+	}
+}
+
+func TestETHService_MaxTransactionsPerAddress(t *testing.T) {
+	ctx := context.Background()
+	conf := testConfig()
+	conf.MaxTransactionsPerAddress = 3
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(conf))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+	for i := 0; i < 5; i++ {
+		instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Transactions: []*model.ETHTransaction{{Hash: fmt.Sprintf("0x%d", i), From: addrA, To: addrB}},
+		})
+	}
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x2", "0x3", "0x4"}, hashesOf(list))
+}
+
+func TestETHService_WithRetention(t *testing.T) {
+	ctx := context.Background()
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(testConfig()), WithRetention(2, time.Hour),
+		WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+	instance.Subscribe(ctx, addrB)
+	mined := func(ago time.Duration) string {
+		return fmt.Sprintf("0x%x", time.Now().Add(-ago).Unix())
+	}
+	for i, ago := range []time.Duration{2 * time.Hour, time.Minute, time.Minute, time.Minute} {
+		assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Timestamp:    mined(ago),
+			Transactions: []*model.ETHTransaction{{Hash: fmt.Sprintf("0x%d", i), From: addrA, To: addrC, BlockNumber: fmt.Sprintf("0x%x", i)}},
+		}))
+	}
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x2", "0x3"}, hashesOf(list))
+	// the expired one, then the oldest past the count.
+	evictions, err := instance.Evictions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{addrA: 2}, evictions)
+}
+
+func TestETHService_GetTransactionsInOrder(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+	tx := func(block, index int) *model.ETHTransaction {
+		return &model.ETHTransaction{Hash: fmt.Sprintf("0x%d%d", block, index), From: addrA, To: addrB,
+			BlockNumber: fmt.Sprintf("0x%x", block), TransactionIndex: fmt.Sprintf("0x%x", index)}
+	}
+	// blocks parsed out of order, such as by a backfill, and transactions out of order in a block.
+	for _, block := range []int{12, 1, 3} {
+		assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Number:       fmt.Sprintf("0x%x", block),
+			Transactions: []*model.ETHTransaction{tx(block, 10), tx(block, 2)},
+		}))
+	}
+
+	list, err := instance.GetTransactionsInOrder(ctx, addrA, model.OrderAscending)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x12", "0x110", "0x32", "0x310", "0x122", "0x1210"}, hashesOf(list))
+	assert.Equal(t, int64(12), list[5].BlockNum)
+	assert.Equal(t, int64(10), list[5].TxIndex)
+	list, err = instance.GetTransactionsInOrder(ctx, addrA, model.OrderDescending)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1210", "0x122", "0x310", "0x32", "0x110", "0x12"}, hashesOf(list))
+	_, err = instance.GetTransactionsInOrder(ctx, addrA, "up")
+	assert.NotNil(t, err)
+}
+
+func TestETHService_GetTransactionsWithCounterparty(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: upperAddrB, To: addrA},
+			{Hash: "0x2", From: addrA, To: upperAddrB},
+			{Hash: "0x3", From: addrC, To: addrA},
+			{Hash: "0x4", From: addrA, To: addrA},
+			{Hash: "0x5", From: addrB, To: addrC},
+		},
+	}))
+
+	// both directions, whatever the case of either address.
+	list, err := instance.GetTransactionsWithCounterparty(ctx, upperAddrA, upperAddrB)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1", "0x2"}, hashesOf(list))
+	list, err = instance.GetTransactionsWithCounterparty(ctx, addrA, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x4"}, hashesOf(list))
+	list, err = instance.GetTransactionsWithCounterparty(ctx, addrA, addrD)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(list))
+	assert.NotNil(t, list)
+
+	_, err = instance.GetTransactionsWithCounterparty(ctx, addrA, "0xzz")
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+	_, err = instance.GetTransactionsWithCounterparty(ctx, addrB, addrA)
+	assert.Equal(t, ErrNotSubscribed, err)
+}
+
+func TestETHService_ParseSubscribeRace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	block := syntheticBlock(500)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for _, tx := range block.Transactions {
+			instance.Subscribe(ctx, tx.From)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			instance.parseBlock(ctx, block)
+		}
+	}()
+	wg.Wait()
+
+	// every address is subscribed now, one more block stores one transaction each.
+	instance.parseBlock(ctx, block)
+	for _, tx := range block.Transactions {
+		list, _ := instance.GetTransactions(ctx, tx.From)
+		assert.Condition(t, func() bool { return len(list) >= 1 && len(list) <= 21 })
+	}
+}
+
+func TestETHService_GetTransactionsParseRace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// a snapshot never shrinks nor holds a half appended transaction.
+				list, err := instance.GetTransactions(ctx, addrA)
+				assert.Nil(t, err)
+				assert.True(t, len(list) >= seen)
+				for _, tx := range list {
+					assert.Equal(t, addrA, tx.From)
+				}
+				seen = len(list)
+			}
+		}()
+	}
+	for i := 1; i <= 200; i++ {
+		assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Transactions: []*model.ETHTransaction{{Hash: fmt.Sprintf("0x%x", i), From: addrA, To: addrB, BlockNumber: fmt.Sprintf("0x%x", i)}},
+		}))
+	}
+	close(done)
+	wg.Wait()
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 200, len(list))
+}
+
+func TestETHService_GetTransactionsModifyRace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	instance.Subscribe(ctx, addrA)
+	mined := fmt.Sprintf("0x%x", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// until every transaction was modified once.
+		for {
+			// callers own what they get, the stored transactions stay untouched.
+			list, _ := instance.GetTransactions(ctx, addrA)
+			for _, tx := range list {
+				tx.Value = "0x0"
+				*tx.Timestamp = time.Time{}
+				tx.AccessList = append(tx.AccessList[:0], "modified")
+			}
+			if len(list) == 100 {
+				return
+			}
+		}
+	}()
+	for i := 1; i <= 100; i++ {
+		assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+			Timestamp: mined,
+			Transactions: []*model.ETHTransaction{{Hash: fmt.Sprintf("0x%x", i), From: addrA, To: addrB, Value: "0x1",
+				BlockNumber: fmt.Sprintf("0x%x", i), AccessList: []interface{}{"0x1"}}},
+		}))
+	}
+	wg.Wait()
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 100, len(list))
+	for _, tx := range list {
+		assert.Equal(t, "0x1", tx.Value)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *tx.Timestamp)
+		assert.Equal(t, []interface{}{"0x1"}, tx.AccessList)
+	}
+}
+
+func TestETHService_LastProcessedBlockRace(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	instance := newTestETHService()
+	instance.client = client
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// never goes backwards without a reorg.
+				n := instance.LastProcessedBlock(ctx)
+				assert.True(t, n >= last)
+				last = n
+			}
+		}()
+	}
+	for head := int64(1); head <= 50; head++ {
+		client.setHead(head)
+		assert.Nil(t, instance.load(ctx))
+	}
+	close(done)
+	wg.Wait()
+	assert.Equal(t, int64(50), instance.LastProcessedBlock(ctx))
+}
+
+// fakeHeadClient fake chain pushing heads through a channel.
+type fakeHeadClient struct {
+	*fakeETHClient
+	heads chan *model.ETHBlockHeader
+}
+
+func (f *fakeHeadClient) SubscribeNewHeads(ctx context.Context) (<-chan *model.ETHBlockHeader, error) {
+	return f.heads, nil
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestETHService_StartFollowsNewHeads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &fakeHeadClient{fakeETHClient: newFakeETHClient(0), heads: make(chan *model.ETHBlockHeader)}
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Start(ctx))
+	assert.NotNil(t, instance.Start(ctx))
+
+	client.heads <- &model.ETHBlockHeader{Number: "0x3"}
+	waitFor(t, time.Second, func() bool { return instance.LastProcessedBlock(ctx) == 3 })
+	status := instance.HeadsStatus()
+	assert.Equal(t, HeadSourceWebSocket, status.Source)
+	assert.False(t, status.LastHead.IsZero())
+
+	// socket drops, blocks are polled until resubscribing.
+	close(client.heads)
+	client.setHead(5)
+	waitFor(t, 3*time.Second, func() bool { return instance.LastProcessedBlock(ctx) == 5 })
+	waitFor(t, time.Second, func() bool { return instance.HeadsStatus().Source == HeadSourceReconnecting })
+
+	assert.Nil(t, instance.Stop(ctx))
+	assert.Equal(t, HeadSourceStopped, instance.HeadsStatus().Source)
+}
+
+func TestETHService_Stop(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	clock := newFakeClock()
+	instance, err := NewETHService(client, WithConfig(testConfig()), withClock(clock))
+	assert.Nil(t, err)
+	// stopping a service never started is fine.
+	assert.Nil(t, instance.Stop(ctx))
+
+	client.setHead(2)
+	assert.Nil(t, instance.Start(ctx))
+	timer := clock.next(t)
+	assert.Equal(t, int64(2), instance.LastProcessedBlock(ctx))
+
+	// the loop has exited once Stop returns, a timer firing afterwards loads nothing.
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.Nil(t, instance.Stop(stopCtx))
+	calls := client.callCount()
+	client.setHead(10)
+	timer.fire()
+	assert.Equal(t, 0, len(clock.timers))
+	assert.Equal(t, calls, client.callCount())
+	assert.Equal(t, int64(2), instance.LastProcessedBlock(ctx))
+	assert.Nil(t, instance.Stop(ctx))
+}
+
+// blockingClient fakeETHClient whose block fetches wait for release, whatever their ctx.
+type blockingClient struct {
+	*fakeETHClient
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingClient) EthGetBlockByNumber(ctx context.Context, number string) (*model.ETHBlockInfo, error) {
+	select {
+	case b.entered <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return b.fakeETHClient.EthGetBlockByNumber(ctx, number)
+}
+
+func TestETHService_Close(t *testing.T) {
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+	client := &blockingClient{fakeETHClient: newFakeETHClient(0), entered: make(chan struct{}, 1),
+		release: make(chan struct{})}
+	conf := testConfig()
+	conf.PollInterval = 10 * time.Millisecond
+	instance, err := NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Start(ctx))
+	client.setHead(1)
+	<-client.entered
+
+	// the in-flight load finishes before Close returns.
+	closed := make(chan error)
+	go func() { closed <- instance.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned during a load")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(client.release)
+	assert.Nil(t, <-closed)
+	waitFor(t, time.Second, func() bool { return runtime.NumGoroutine() <= goroutines })
+
+	assert.Equal(t, ErrClosed, instance.Start(ctx))
+	assert.Equal(t, ErrClosed, instance.Subscribe(ctx, addrB))
+	assert.Equal(t, ErrClosed, instance.Unsubscribe(ctx, addrA, true))
+	assert.Equal(t, ErrClosed, instance.ParseTransactions(ctx, 1))
+	assert.Equal(t, ErrClosed, instance.Backfill(ctx, addrA, 0))
+	assert.Equal(t, ErrClosed, instance.SubscribeFrom(ctx, addrB, 0))
+	assert.Equal(t, []string{addrA}, instance.ListSubscriptions(ctx))
+	_, err = instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Close())
+}
+
+func TestETHService_SetPollInterval(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	conf := DefaultConfig()
+	conf.PollInterval = time.Hour
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, instance.PollInterval())
+	assert.NotNil(t, instance.SetPollInterval(0))
+
+	assert.Nil(t, instance.Start(ctx))
+	defer instance.Stop(ctx)
+	waitFor(t, time.Second, func() bool { return client.callCount() > 0 })
+	client.setHead(3)
+	// the hourly ticker is reset by the new interval.
+	assert.Nil(t, instance.SetPollInterval(20*time.Millisecond))
+	waitFor(t, time.Second, func() bool { return instance.LastProcessedBlock(ctx) == 3 })
+}
+
+// fakeClock clock whose timers only fire when the test fires them.
+type fakeClock struct {
+	timers chan *fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{timers: make(chan *fakeTimer, 16)}
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) timer {
+	t := &fakeTimer{d: d, c: make(chan time.Time, 1)}
+	c.timers <- t
+	return t
+}
+
+// next wait for the poll loop to arm its next timer.
+func (c *fakeClock) next(t *testing.T) *fakeTimer {
+	select {
+	case timer := <-c.timers:
+		return timer
+	case <-time.After(3 * time.Second):
+		t.Fatal("no timer armed")
+		return nil
+	}
+}
+
+type fakeTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+func (t *fakeTimer) Stop() bool          { return true }
+func (t *fakeTimer) fire()               { t.c <- time.Now() }
+
+func TestETHService_PollAdaptiveInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := newFakeETHClient(0)
+	clock := newFakeClock()
+	conf := DefaultConfig()
+	conf.MaxPollInterval = 5 * time.Second
+	instance, err := NewETHService(client, WithConfig(conf), WithPollInterval(time.Second), withClock(clock))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Start(ctx))
+	defer instance.Stop(ctx)
+
+	// no new block, back off up to the max.
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		timer := clock.next(t)
+		assert.Equal(t, want, timer.d)
+		timer.fire()
+	}
+	// a new block resets the interval.
+	client.setHead(2)
+	timer := clock.next(t)
+	assert.Equal(t, time.Second, timer.d)
+	assert.Equal(t, int64(2), instance.LastProcessedBlock(ctx))
+	timer.fire()
+	assert.Equal(t, 2*time.Second, clock.next(t).d)
+}
+
+func TestETHService_PollNoOverlappingLoads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := newFakeETHClient(0)
+	clock := newFakeClock()
+	instance, err := NewETHService(client, withClock(clock))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Start(ctx))
+	defer instance.Stop(ctx)
+
+	// the next timer is only armed once the previous load returned, one load per fired timer.
+	for i := 0; i < 3; i++ {
+		timer := clock.next(t)
+		calls := client.callCount()
+		select {
+		case extra := <-clock.timers:
+			t.Fatalf("timer armed while waiting: %v", extra.d)
+		case <-time.After(20 * time.Millisecond):
+		}
+		assert.Equal(t, calls, client.callCount())
+		timer.fire()
+	}
+}
+
+func TestETHService_ParseBlockTwice(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB, TransactionIndex: "0x0"},
+		&model.ETHTransaction{Hash: "0x2", From: addrA, To: addrA, TransactionIndex: "0x1"})
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x3", From: addrB, To: addrA, TransactionIndex: "0x0"})
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+	instance.Subscribe(ctx, addrB)
+
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	assert.Nil(t, instance.ParseTransactions(ctx, 2))
+	// reprocessed after a restart, both the latest and an older block.
+	assert.Nil(t, instance.ParseTransactions(ctx, 2))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x1", "0x2", "0x3"}, hashesOf(list))
+	list, _ = instance.GetTransactions(ctx, addrB)
+	assert.Equal(t, []string{"0x1", "0x3"}, hashesOf(list))
+}
+
+func TestETHService_ParseBlockConcurrently(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	var transactions []*model.ETHTransaction
+	for i := 0; i < 50; i++ {
+		transactions = append(transactions, &model.ETHTransaction{
+			Hash: fmt.Sprintf("0x%d", i), From: addrA, To: addrB, TransactionIndex: fmt.Sprintf("0x%x", i),
+		})
+	}
+	client.setBlock(1, "0xh1", "0xh0", transactions...)
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	// a retry racing with the live loop over the same block.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, instance.ParseTransactions(ctx, 1))
+		}()
+	}
+	wg.Wait()
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 50, len(list))
+	assert.Equal(t, hashesOf(transactions), hashesOf(list))
+}
+
+func TestETHService_WithMinValue(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB, Value: "0x0", TransactionIndex: "0x0"},
+		&model.ETHTransaction{Hash: "0x2", From: addrB, To: addrA, Value: "0xde0b6b3a763ffff", TransactionIndex: "0x1"},
+		&model.ETHTransaction{Hash: "0x3", From: addrA, To: addrB, Value: "0xde0b6b3a7640000", TransactionIndex: "0x2"},
+		// 2^256-1 wei, far beyond int64.
+		&model.ETHTransaction{Hash: "0x4", From: addrB, To: addrA, Value: "0x" + strings.Repeat("f", 64), TransactionIndex: "0x3"},
+		&model.ETHTransaction{Hash: "0x5", From: addrA, To: addrB, Value: "not hex", TransactionIndex: "0x4"})
+	// 1 ether.
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithMinValue(big.NewInt(1000000000000000000)))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x3", "0x4"}, hashesOf(list))
+}
+
+func TestETHService_Confirmations(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA})
+	client.setBlock(3, "0xh3", "0xh2", &model.ETHTransaction{Hash: "0x3", To: addrA})
+	conf := DefaultConfig()
+	conf.Confirmations = 3
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	client.setHead(3)
+	assert.Nil(t, instance.load(ctx))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 0, len(list))
+
+	// block 1 has 3 confirmations at block 4, block 3 waits until block 6.
+	client.setHead(4)
+	assert.Nil(t, instance.load(ctx))
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x1"}, hashesOf(list))
+	_, total, _ := instance.GetTransactionsPaged(ctx, addrA, 0, 10)
+	assert.Equal(t, 1, total)
+
+	client.setHead(6)
+	assert.Nil(t, instance.load(ctx))
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x1", "0x3"}, hashesOf(list))
+}
+
+func TestETHService_ReceiptStatus(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0xok", From: addrA, To: addrB, TransactionIndex: "0x0"},
+		&model.ETHTransaction{Hash: "0xreverted", From: addrA, To: addrB, TransactionIndex: "0x1"},
+		&model.ETHTransaction{Hash: "0xother", From: addrC, To: addrD, TransactionIndex: "0x2"})
+	client.receipts[1] = []*model.ETHTransactionReceipt{
+		{TransactionHash: "0xOK", Status: "0x1", GasUsed: "0x5208"},
+		{TransactionHash: "0xreverted", Status: "0x0", GasUsed: "0x6000"},
+		{TransactionHash: "0xother", Status: "0x1", GasUsed: "0x5208"},
+	}
+	client.receiptFails[1] = 1
+	conf := testConfig()
+	conf.TrackTokenTransfers = false
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	// receipts are fetched for matched transactions even without token tracking.
+	assert.NotNil(t, instance.ParseTransactions(ctx, 1))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, model.TxStatusSuccess, list[0].Status)
+	assert.Equal(t, "0x5208", list[0].GasUsed)
+	assert.Equal(t, model.TxStatusFailed, list[1].Status)
+
+	assert.Equal(t, testBlockTime(1), *list[0].Timestamp)
+
+	list, _ = instance.FilterTransactions(ctx, addrA, model.TxFilter{Status: model.TxStatusSuccess})
+	assert.Equal(t, []string{"0xok"}, hashesOf(list))
+	list, _ = instance.FilterTransactions(ctx, addrA, model.TxFilter{Status: model.TxStatusFailed})
+	assert.Equal(t, []string{"0xreverted"}, hashesOf(list))
+}
+
+func TestETHService_WithMinConfirmations(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA})
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithMinConfirmations(2))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	client.setHead(2)
+	assert.Nil(t, instance.load(ctx))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 0, len(list))
+
+	client.setHead(4)
+	assert.Nil(t, instance.load(ctx))
+	list, _ = instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, int64(3), list[0].Confirmations)
+	// the stored transaction isn't modified by queries.
+	stored, _, _ := instance.storage.GetTransactions(ctx, addrA, store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, int64(0), stored[0].Confirmations)
+	page, _, _ := instance.GetTransactionsPaged(ctx, addrA, 0, 1)
+	assert.Equal(t, int64(3), page[0].Confirmations)
+}
+
+func TestETHService_WithOnBlockProcessed(t *testing.T) {
+	ctx := context.Background()
+	type processed struct {
+		number  int64
+		matched int
+	}
+	var got []processed
+	client := newFakeETHClient(2)
+	client.setBlock(3, "0xh3", "0xh2", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB})
+	client.setBlock(5, "0xh5", "0xh4", &model.ETHTransaction{Hash: "0x2", From: addrB, To: addrA},
+		&model.ETHTransaction{Hash: "0x3", From: addrA, To: addrC})
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()),
+		WithOnBlockProcessed(func(ctx context.Context, number int64, matched int) {
+			got = append(got, processed{number, matched})
+		}))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	client.setHead(5)
+	assert.Nil(t, instance.load(ctx))
+	// every block is reported once stored, the ones matching nothing included.
+	assert.Equal(t, []processed{{3, 1}, {4, 0}, {5, 2}}, got)
+
+	// nil-safe.
+	instance, err = NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()), WithOnBlockProcessed(nil))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.ParseTransactions(ctx, 5))
+}
+
+func TestETHService_WithStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemory(0)
+	client := newFakeETHClient(2)
+	client.setBlock(3, "0xh3", "0xh2", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB})
+	client.setBlock(5, "0xh5", "0xh4", &model.ETHTransaction{Hash: "0x2", From: addrB, To: addrA})
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, upperAddrA))
+	client.setHead(3)
+	assert.Nil(t, instance.load(ctx))
+
+	// restarted while the chain moved on, it resumes from the checkpoint with the same subscriptions.
+	client.setHead(6)
+	restarted, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), restarted.LastProcessedBlock(ctx))
+	assert.Equal(t, []string{addrA}, restarted.ListSubscriptions(ctx))
+	assert.Nil(t, restarted.load(ctx))
+	assert.Equal(t, int64(6), restarted.LastProcessedBlock(ctx))
+	list, err := restarted.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1", "0x2"}, hashesOf(list))
+}
+
+// failingStorage store.Storage failing to append or delete history while fail is set, as an unreachable database would.
+type failingStorage struct {
+	store.Storage
+	mu   sync.Mutex
+	fail bool
+}
+
+func (f *failingStorage) setFail(fail bool) {
+	f.mu.Lock()
+	f.fail = fail
+	f.mu.Unlock()
+}
+
+func (f *failingStorage) AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error {
+	f.mu.Lock()
+	fail := f.fail
+	f.mu.Unlock()
+	if fail {
+		return errors.New("storage unreachable")
+	}
+	return f.Storage.AppendTransactions(ctx, address, transactions)
+}
+
+func (f *failingStorage) DeleteHistory(ctx context.Context, address string) error {
+	f.mu.Lock()
+	fail := f.fail
+	f.mu.Unlock()
+	if fail {
+		return errors.New("storage unreachable")
+	}
+	return f.Storage.DeleteHistory(ctx, address)
+}
+
+func TestETHService_StorageUnreachable(t *testing.T) {
+	ctx := context.Background()
+	storage := &failingStorage{Storage: store.NewMemory(0)}
+	client := newFakeETHClient(1)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+	instance.Subscribe(ctx, addrA)
+
+	// parsing pauses at block 2 however long storage is down, rather than skipping it.
+	storage.setFail(true)
+	client.setHead(3)
+	for i := 0; i < 2*maxBlockRetries; i++ {
+		assert.NotNil(t, instance.load(ctx))
+	}
+	assert.Equal(t, int64(1), instance.LastProcessedBlock(ctx))
+	assert.Equal(t, int64(0), instance.SkippedBlocks())
+
+	storage.setFail(false)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, int64(3), instance.LastProcessedBlock(ctx))
+	list, _ := instance.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2"}, hashesOf(list))
+}
+
+func TestETHService_SharedStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemory(0)
+	client := newFakeETHClient(1)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	a, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+	b, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+
+	// subscribed through a, parsed by b.
+	assert.Nil(t, a.Subscribe(ctx, addrA))
+	client.setHead(2)
+	assert.Nil(t, b.load(ctx))
+	list, _ := a.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2"}, hashesOf(list))
+	assert.Equal(t, []string{addrA}, b.ListSubscriptions(ctx))
+}
+
+func TestETHService_SharedSubscriptionOptions(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemory(0)
+	client := newFakeETHClient(1)
+	client.setBlock(2, "0xh2", "0xh1",
+		&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB},
+		&model.ETHTransaction{Hash: "0x2", From: addrB, To: addrA})
+	a, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	b, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+
+	// set through a, b picks them up on refresh and parses with them, keeping its own filter.
+	assert.Nil(t, b.SubscribeWithFilter(ctx, addrA, func(tx *model.ETHTransaction) bool { return true }))
+	opts := model.SubscriptionOptions{Direction: model.DirectionInbound, Label: "hot wallet"}
+	assert.Nil(t, a.SubscribeWithOptions(ctx, addrA, opts))
+	client.setHead(2)
+	assert.Nil(t, b.load(ctx))
+	got, ok := b.SubscriptionOptions(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, opts, got)
+	b.addrRWMutex.RLock()
+	assert.NotNil(t, b.subAddrs[addrA].Filter)
+	b.addrRWMutex.RUnlock()
+	list, _ := a.GetTransactions(ctx, addrA)
+	assert.Equal(t, []string{"0x2"}, hashesOf(list))
+
+	// a restart on the same storage restores them.
+	restarted, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	got, ok = restarted.SubscriptionOptions(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, opts, got)
+}
+
+// countingCheckpointer store.Checkpointer counting the checkpoints it set.
+type countingCheckpointer struct {
+	store.Checkpointer
+	set int
+}
+
+func (c *countingCheckpointer) SetCheckpoint(ctx context.Context, block int64) error {
+	err := c.Checkpointer.SetCheckpoint(ctx, block)
+	if err == nil {
+		c.set++
+	}
+	return err
+}
+
+func TestETHService_CheckpointConflict(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	defer client.Close()
+	node := newFakeETHClient(1)
+	for n := int64(2); n <= 7; n++ {
+		node.setBlock(n, fmt.Sprintf("0xh%d", n), fmt.Sprintf("0xh%d", n-1))
+	}
+	newReplica := func() (*ETHService, *countingCheckpointer) {
+		storage := redis.New(redis.NewGoRedis(client), "{gateway}:")
+		checkpointer := &countingCheckpointer{Checkpointer: storage}
+		instance, err := NewETHService(node, WithConfig(testConfig()), WithStorage(storage),
+			WithCheckpointer(checkpointer), WithLogger(logging.Nop()))
+		assert.Nil(t, err)
+		return instance, checkpointer
+	}
+	a, aCheckpointer := newReplica()
+	b, bCheckpointer := newReplica()
+
+	// both parse block 2, b loses the checkpoint to a and follows it.
+	node.setHead(3)
+	assert.Nil(t, a.load(ctx))
+	assert.Nil(t, b.load(ctx))
+	assert.Equal(t, 2, aCheckpointer.set)
+	assert.Equal(t, 0, bCheckpointer.set)
+	assert.Equal(t, int64(3), b.LastProcessedBlock(ctx))
+
+	// only a advances the checkpoint while b follows.
+	node.setHead(5)
+	for i := 0; i < followTicks; i++ {
+		assert.Nil(t, a.load(ctx))
+		assert.Nil(t, b.load(ctx))
+	}
+	assert.Equal(t, 4, aCheckpointer.set)
+	assert.Equal(t, 0, bCheckpointer.set)
+	assert.Equal(t, int64(5), b.LastProcessedBlock(ctx))
+
+	// a stopped, b takes over once done following, and a follows b if it comes back.
+	node.setHead(7)
+	assert.Nil(t, b.load(ctx))
+	assert.Equal(t, 2, bCheckpointer.set)
+	assert.Nil(t, a.load(ctx))
+	assert.Equal(t, 4, aCheckpointer.set)
+	assert.Equal(t, int64(7), a.LastProcessedBlock(ctx))
+	block, ok, err := bCheckpointer.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), block)
+}
+
+// blockingListStorage storage whose ListSubscriptions waits for release once listed is read.
+type blockingListStorage struct {
+	store.Storage
+	listed  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingListStorage) ListSubscriptions(ctx context.Context) ([]string, error) {
+	addrs, err := b.Storage.ListSubscriptions(ctx)
+	b.listed <- struct{}{}
+	<-b.release
+	return addrs, err
+}
+
+func TestETHService_RefreshSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemory(0)
+	client := &ensClient{fakeETHClient: newFakeETHClient(0), targets: map[string]string{"alice.eth": addrA}}
+	a, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	b, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+
+	// unsubscribed through b, a forgets the name along with the address.
+	assert.Nil(t, a.Subscribe(ctx, "alice.eth"))
+	assert.Nil(t, b.Unsubscribe(ctx, addrA, false))
+	a.refreshSubscriptions(ctx)
+	assert.Equal(t, []string{}, a.ListSubscriptions(ctx))
+	assert.Equal(t, map[string]string{}, a.ENSNames(ctx))
+
+	// storage is listed without holding back the readers, nor Subscribe, which the stale list doesn't undo.
+	blocking := &blockingListStorage{Storage: storage, listed: make(chan struct{}), release: make(chan struct{})}
+	a.storage = blocking
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.refreshSubscriptions(ctx)
+	}()
+	<-blocking.listed
+	assert.False(t, a.IsSubscribed(ctx, addrB))
+	assert.Nil(t, a.Subscribe(ctx, addrB))
+	close(blocking.release)
+	<-done
+	assert.Equal(t, []string{addrB}, a.ListSubscriptions(ctx))
+}
+
+func TestETHService_ResumeFromCheckpointFile(t *testing.T) {
+	ctx := context.Background()
+	checkpointer := store.NewFileCheckpointer(filepath.Join(t.TempDir(), "checkpoint"))
+	client := newFakeETHClient(2)
+	for n := int64(6); n <= 15; n++ {
+		client.setBlock(n, fmt.Sprintf("0xh%d", n), fmt.Sprintf("0xh%d", n-1),
+			&model.ETHTransaction{Hash: fmt.Sprintf("0x%d", n), From: addrA, To: addrB})
+	}
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithCheckpointer(checkpointer))
+	assert.Nil(t, err)
+	client.setHead(5)
+	assert.Nil(t, instance.load(ctx))
+	block, ok, err := checkpointer.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), block)
+
+	// restarted 10 blocks later, with the checkpoint only, every missed block is caught up on Start.
+	client.setHead(15)
+	conf := testConfig()
+	conf.PollInterval = time.Hour
+	restarted, err := NewETHService(client, WithConfig(conf), WithCheckpointer(checkpointer))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), restarted.LastProcessedBlock(ctx))
+	restarted.Subscribe(ctx, addrA)
+	assert.Nil(t, restarted.Start(ctx))
+	defer restarted.Stop(ctx)
+	waitFor(t, 3*time.Second, func() bool { return restarted.LastProcessedBlock(ctx) == 15 })
+	list, _ := restarted.GetTransactions(ctx, addrA)
+	assert.Equal(t, 10, len(list))
+	block, _, err = checkpointer.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), block)
+}
+
+func TestETHService_MaxResumeBlocks(t *testing.T) {
+	ctx := context.Background()
+	checkpointer := store.NewFileCheckpointer(filepath.Join(t.TempDir(), "checkpoint"))
+	assert.Nil(t, checkpointer.SetCheckpoint(ctx, 5))
+	conf := testConfig()
+	conf.MaxResumeBlocks = 4
+	instance, err := NewETHService(newFakeETHClient(15), WithConfig(conf), WithCheckpointer(checkpointer))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), instance.LastProcessedBlock(ctx))
+
+	// a checkpoint ahead of the node, such as one written against another node, starts from the head.
+	assert.Nil(t, checkpointer.SetCheckpoint(ctx, 20))
+	instance, err = NewETHService(newFakeETHClient(15), WithConfig(conf), WithCheckpointer(checkpointer))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), instance.LastProcessedBlock(ctx))
+}
+
+func TestETHService_SubscribeWithWebhook(t *testing.T) {
+	ctx := context.Background()
+	payloads := make(chan *webhook.Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := &webhook.Payload{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(payload))
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	instance := newTestETHService()
+	assert.NotNil(t, instance.SubscribeWithWebhook(ctx, addrA, "ftp://example.com"))
+	assert.NotNil(t, instance.SubscribeWithWebhook(ctx, "0xaa", server.URL))
+	_, ok := instance.WebhookStats(ctx, addrA)
+	assert.False(t, ok)
+
+	assert.Nil(t, instance.SubscribeWithWebhook(ctx, upperAddrA, server.URL))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Number: "0x5", Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x5", From: addrB, To: upperAddrA},
+	}}))
+	payload := <-payloads
+	assert.Equal(t, addrA, payload.Address)
+	assert.Equal(t, model.DirectionInbound, payload.Direction)
+	assert.Equal(t, int64(5), payload.BlockNumber)
+	assert.Equal(t, "0x1", payload.Transaction.Hash)
+	// addrB has no webhook.
+	assert.Nil(t, instance.Stop(ctx))
+	assert.Equal(t, 0, len(payloads))
+	stats, ok := instance.WebhookStats(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), stats.Delivered)
+
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, false))
+	_, ok = instance.WebhookStats(ctx, addrA)
+	assert.False(t, ok)
+}
+
+func TestETHService_GlobalWebhook(t *testing.T) {
+	ctx := context.Background()
+	global, own := make(chan *webhook.Payload, 10), make(chan *webhook.Payload, 10)
+	serve := func(payloads chan *webhook.Payload) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload := &webhook.Payload{}
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(payload))
+			payloads <- payload
+		}))
+	}
+	globalServer, ownServer := serve(global), serve(own)
+	defer globalServer.Close()
+	defer ownServer.Close()
+
+	conf := testConfig()
+	conf.WebhookURL = globalServer.URL
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.SubscribeWithWebhook(ctx, addrB, ownServer.URL))
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Number: "0x1", Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", From: addrA, To: addrB},
+	}}))
+	assert.Nil(t, instance.Stop(ctx))
+
+	assert.Equal(t, 1, len(global))
+	payload := <-global
+	assert.Equal(t, addrA, payload.Address)
+	assert.Equal(t, model.DirectionOutbound, payload.Direction)
+	assert.Equal(t, 1, len(own))
+	payload = <-own
+	assert.Equal(t, addrB, payload.Address)
+	assert.Equal(t, model.DirectionInbound, payload.Direction)
+	_, ok := instance.WebhookStats(ctx, addrC)
+	assert.False(t, ok)
+}
+
+// nonceClient fakeETHClient telling nonces, failing with err.
+type nonceClient struct {
+	*fakeETHClient
+	asked []string
+	err   error
+}
+
+func (c *nonceClient) EthGetTransactionCount(ctx context.Context, address, block string) (int64, error) {
+	c.asked = append(c.asked, address)
+	return 0, c.err
+}
+
+func TestETHService_SubscribeVerify(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	unreachable := &remote.HTTPStatusError{StatusCode: 503}
+	client.headErr = unreachable
+	// lenient by default, the node isn't asked.
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	WithVerifySubscriptions(true)(instance)
+	err = instance.Subscribe(ctx, addrB)
+	assert.True(t, errors.Is(err, ErrRPCUnavailable))
+	assert.Equal(t, []string{addrA}, instance.ListSubscriptions(ctx))
+	client.headErr = nil
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+
+	// a client telling nonces is asked for the address, nonce 0 passes.
+	nonces := &nonceClient{fakeETHClient: newFakeETHClient(0)}
+	conf := testConfig()
+	conf.VerifySubscriptions = true
+	instance, err = NewETHService(nonces, WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrC))
+	assert.Equal(t, []string{addrC}, nonces.asked)
+	nonces.err = unreachable
+	assert.True(t, errors.Is(instance.Subscribe(ctx, addrD), ErrRPCUnavailable))
+	assert.Equal(t, []string{addrC}, instance.ListSubscriptions(ctx))
+}
+
+// linesLogger logging.Logger keeping every line as its level, message and keyvals.
+type linesLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *linesLogger) add(level, msg string, keyvals []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(level, " ", msg, " ", keyvals))
+}
+
+// withPrefix the lines starting with prefix.
+func (l *linesLogger) withPrefix(prefix string) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var lines []string
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (l *linesLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.add("DEBUG", msg, keyvals)
+}
+
+func (l *linesLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.add("INFO", msg, keyvals)
+}
+
+func (l *linesLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.add("WARN", msg, keyvals)
+}
+
+func (l *linesLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.add("ERROR", msg, keyvals)
+}
+
+func TestETHService_Logging(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", From: addrA, To: addrC},
+		&model.ETHTransaction{Hash: "0x2", From: addrC, To: addrD},
+	)
+	logged := &linesLogger{}
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logged))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+
+	client.setHead(1)
+	assert.Nil(t, instance.load(ctx))
+	lines := logged.withPrefix("INFO [storeBlock]: block processed [block 1 txs 2 matched 1 duration ")
+	assert.Equal(t, 1, len(lines))
+
+	// a node down fails every poll the same way, the error is logged once per LogRepeatWindow.
+	client.mu.Lock()
+	client.headErr = errors.New("node down")
+	client.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		assert.NotNil(t, instance.load(ctx))
+	}
+	assert.Equal(t, []string{"ERROR [load]: Error EthBlockNumber [err node down]"},
+		logged.withPrefix("ERROR [load]: Error EthBlockNumber"))
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// stream channel of a SubscribeChan or Events caller.
+type stream struct {
+	address string // empty for every subscribed address.
+	ch      chan *model.ETHTransaction
+}
+
+// SubscribeChan stream the transactions of the subscribed address as they are stored, before they
+// have Config.Confirmations confirmations. each caller gets its own channel, buffering Config.StreamBuffer
+// transactions, a transaction which doesn't fit is dropped and counted in DroppedEvents rather than
+// holding back parsing. the channel is closed once ctx is done.
+func (s *ETHService) SubscribeChan(ctx context.Context, address string) (<-chan *model.ETHTransaction, error) {
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	s.addrRWMutex.RLock()
+	_, ok := s.subAddrs[address]
+	s.addrRWMutex.RUnlock()
+	if !ok {
+		return nil, ErrNotSubscribed
+	}
+	return s.openStream(ctx, address), nil
+}
+
+// Events stream the transactions of every subscribed address as they are stored, like SubscribeChan.
+// a transaction between two subscribed addresses is streamed once for each, with its direction
+// relative to that address.
+func (s *ETHService) Events(ctx context.Context) <-chan *model.ETHTransaction {
+	return s.openStream(ctx, "")
+}
+
+// DroppedEvents transactions dropped so far because a stream's buffer was full.
+func (s *ETHService) DroppedEvents(ctx context.Context) int64 {
+	return atomic.LoadInt64(&s.droppedEvents)
+}
+
+// openStream register a stream of address until ctx is done.
+func (s *ETHService) openStream(ctx context.Context, address string) <-chan *model.ETHTransaction {
+	size := s.conf.StreamBuffer
+	if size <= 0 {
+		size = DefaultConfig().StreamBuffer
+	}
+	st := &stream{address: address, ch: make(chan *model.ETHTransaction, size)}
+	s.streamMutex.Lock()
+	s.streams[st] = struct{}{}
+	s.streamMutex.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.streamMutex.Lock()
+		delete(s.streams, st)
+		// publish sends under streamMutex, nothing is sent on the closed channel.
+		close(st.ch)
+		s.streamMutex.Unlock()
+	}()
+	return st.ch
+}
+
+// publish hand the transactions just stored for address to its streams, without waiting for them.
+func (s *ETHService) publish(address string, transactions []*model.ETHTransaction) {
+	s.streamMutex.Lock()
+	defer s.streamMutex.Unlock()
+	for st := range s.streams {
+		if len(st.address) > 0 && st.address != address {
+			continue
+		}
+		for _, tx := range transactions {
+			// a copy per stream, consumers are free to modify it.
+			published := *tx
+			select {
+			case st.ch <- &published:
+			default:
+				atomic.AddInt64(&s.droppedEvents, 1)
+			}
+		}
+	}
+}
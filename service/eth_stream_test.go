@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func hashesOfChan(ch <-chan *model.ETHTransaction, n int) []string {
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		hashes = append(hashes, (<-ch).Hash)
+	}
+	return hashes
+}
+
+func TestETHService_SubscribeChan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := newFakeETHClient(0)
+	instance, err := NewETHService(client, WithConfig(testConfig()))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+
+	_, err = instance.SubscribeChan(ctx, "0xaa")
+	assert.NotNil(t, err)
+	_, err = instance.SubscribeChan(ctx, addrC)
+	assert.Equal(t, ErrNotSubscribed, err)
+	first, err := instance.SubscribeChan(ctx, upperAddrA)
+	assert.Nil(t, err)
+	second, err := instance.SubscribeChan(ctx, addrA)
+	assert.Nil(t, err)
+	other, err := instance.SubscribeChan(ctx, addrB)
+	assert.Nil(t, err)
+
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrC})
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrC, To: addrB})
+	client.setBlock(3, "0xh3", "0xh2", &model.ETHTransaction{Hash: "0x3", From: addrB, To: addrA})
+	client.setHead(3)
+	assert.Nil(t, instance.load(ctx))
+
+	// every stream of an address gets each of its transactions.
+	assert.Equal(t, []string{"0x1", "0x3"}, hashesOfChan(first, 2))
+	assert.Equal(t, []string{"0x1", "0x3"}, hashesOfChan(second, 2))
+	assert.Equal(t, []string{"0x2", "0x3"}, hashesOfChan(other, 2))
+	assert.Equal(t, int64(0), instance.DroppedEvents(ctx))
+
+	cancel()
+	for _, ch := range []<-chan *model.ETHTransaction{first, second, other} {
+		_, open := <-ch
+		assert.False(t, open)
+	}
+	instance.streamMutex.Lock()
+	assert.Equal(t, 0, len(instance.streams))
+	instance.streamMutex.Unlock()
+}
+
+func TestETHService_SubscribeChanSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conf := testConfig()
+	conf.StreamBuffer = 1
+	instance, err := NewETHService(newFakeETHClient(0), WithConfig(conf))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	ch, err := instance.SubscribeChan(ctx, addrA)
+	assert.Nil(t, err)
+
+	// nobody reads, parsing goes on regardless.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := int64(1); i <= 3; i++ {
+			assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+				{Hash: fmt.Sprintf("0x%x", i), BlockNumber: "0x1", TransactionIndex: fmt.Sprintf("0x%x", i), From: addrA},
+			}}))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parsing blocked by a slow consumer")
+	}
+	assert.Equal(t, []string{"0x1"}, hashesOfChan(ch, 1))
+	assert.Equal(t, int64(2), instance.DroppedEvents(ctx))
+}
+
+func TestETHService_Events(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance := newTestETHService()
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+	first, second := instance.Events(ctx), instance.Events(ctx)
+
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", TransactionIndex: "0x0", From: addrA, To: addrC},
+		{Hash: "0x2", BlockNumber: "0x1", TransactionIndex: "0x1", From: addrC, To: addrD},
+		{Hash: "0x3", BlockNumber: "0x1", TransactionIndex: "0x2", From: addrB, To: addrA},
+	}}))
+	for _, ch := range []<-chan *model.ETHTransaction{first, second} {
+		got := make([]string, 0, 3)
+		for i := 0; i < 3; i++ {
+			tx := <-ch
+			got = append(got, tx.Hash+" "+string(tx.Direction))
+		}
+		// 0x3 once for each of its addresses, 0x2 matches none.
+		assert.Equal(t, []string{"0x1 out", "0x3 in", "0x3 out"}, got)
+	}
+	cancel()
+	_, open := <-first
+	assert.False(t, open)
+}
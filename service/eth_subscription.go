@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// SubscriptionConfig how the transactions of a subscribed address are handled. a config in subAddrs is
+// replaced rather than modified, so a snapshot of the map is read without holding addrRWMutex.
+type SubscriptionConfig struct {
+	model.SubscriptionOptions
+	// SubscribedAt when the address was subscribed through this instance, zero if it was loaded from storage.
+	SubscribedAt time.Time
+	// Filter stores only the transactions it returns true for on top of the options, nil for every one. a
+	// func is not saved to storage, it lives in the memory of the instance setting it.
+	Filter func(tx *model.ETHTransaction) bool
+}
+
+// acceptsDirection whether a transfer in direction relative to the address is stored.
+func (c *SubscriptionConfig) acceptsDirection(direction model.Direction) bool {
+	return model.TxFilter{Direction: c.Direction}.MatchDirection(direction)
+}
+
+// SubscribeWithOptions subscribe address like Subscribe, handling its transactions as opts tells. subscribing
+// an address again replaces its options whole, the last call wins, while Subscribe and SubscribeFrom keep
+// them, the filter of SubscribeWithFilter is kept as well. a StartBlock backfills the history of the address,
+// cancelling its previous backfill.
+// options are saved with the subscription and restored on restart when storage keeps them, see
+// store.SubscriptionOptionsStore, every storage of this package does, other ones keep them in memory.
+func (s *ETHService) SubscribeWithOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error {
+	if err := validateOptions(opts); err != nil {
+		return err
+	}
+	address, _, err := s.subscribeWith(ctx, address, func(conf *SubscriptionConfig) {
+		conf.SubscriptionOptions = opts
+	})
+	if err != nil {
+		return err
+	}
+	if opts.StartBlock > 0 {
+		// not derived from ctx, the backfill outlives the request asking for it.
+		bctx, b := s.startBackfill(context.Background(), address, opts.StartBlock)
+		go s.backfill(bctx, address, b)
+	}
+	return nil
+}
+
+// SubscribeWithFilter subscribe address like Subscribe, storing only the transactions filter returns true
+// for, on top of the options of the subscription, which are kept. filter is called while blocks are parsed,
+// without holding the lock of the subscriptions, with a copy of each transaction matched for the address,
+// Direction set. a panic of filter is logged and skips the transaction. a nil filter stores every
+// transaction, like Subscribe. the filter lives in memory, unlike the options it is not saved to storage, and
+// is dropped by Unsubscribe.
+func (s *ETHService) SubscribeWithFilter(ctx context.Context, address string, filter func(*model.ETHTransaction) bool) error {
+	_, _, err := s.subscribeWith(ctx, address, func(conf *SubscriptionConfig) {
+		conf.Filter = filter
+	})
+	return err
+}
+
+// passesFilter whether tx, a copy matched for the subscription of conf, passes its Filter.
+func (s *ETHService) passesFilter(conf *SubscriptionConfig, tx *model.ETHTransaction) (passed bool) {
+	if conf == nil || conf.Filter == nil {
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(context.Background(), "[passesFilter]: Error filter panicked", "hash", tx.Hash, "panic", r)
+			passed = false
+		}
+	}()
+	// a copy of its own, the filter can't change what is stored.
+	return conf.Filter(tx.Clone())
+}
+
+// validateOptions whether opts are usable, the errors tell which option is not.
+func validateOptions(opts model.SubscriptionOptions) error {
+	if opts.Direction != "" && opts.Direction != model.DirectionInbound && opts.Direction != model.DirectionOutbound {
+		return fmt.Errorf("invalid direction %q", opts.Direction)
+	}
+	if opts.MinValue != nil && opts.MinValue.Sign() < 0 {
+		return errors.New("negative min value")
+	}
+	if opts.StartBlock < 0 {
+		return errors.New("negative start block")
+	}
+	if len(opts.WebhookURL) > 0 {
+		if err := validateCallbackURL(opts.WebhookURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCallbackURL whether callbackURL is an absolute http or https URL.
+func validateCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid callback url %q", callbackURL)
+	}
+	return nil
+}
+
+// SubscriptionOptions options of the subscribed address, false if it isn't subscribed.
+func (s *ETHService) SubscriptionOptions(ctx context.Context, address string) (model.SubscriptionOptions, bool) {
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	conf, ok := s.subAddrs[strings.ToLower(address)]
+	if !ok {
+		return model.SubscriptionOptions{}, false
+	}
+	return conf.SubscriptionOptions, true
+}
+
+// minValueOf the minimum wei of the transactions stored for a subscription of conf, nil for none.
+func (s *ETHService) minValueOf(conf *SubscriptionConfig) *big.Int {
+	if conf != nil && conf.MinValue != nil {
+		return conf.MinValue
+	}
+	return s.conf.MinValue
+}
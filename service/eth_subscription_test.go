@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_SubscribeWithOptionsInvalid(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	for _, opts := range []model.SubscriptionOptions{
+		{Direction: model.DirectionSelf},
+		{MinValue: big.NewInt(-1)},
+		{StartBlock: -1},
+		{WebhookURL: "ftp://example.com"},
+	} {
+		assert.NotNil(t, instance.SubscribeWithOptions(ctx, addrA, opts))
+	}
+	assert.False(t, instance.IsSubscribed(ctx, addrA))
+}
+
+func TestETHService_SubscribeWithOptionsFilters(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrA, model.SubscriptionOptions{Direction: model.DirectionInbound}))
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrB, model.SubscriptionOptions{MinValue: big.NewInt(100)}))
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{
+		Transactions: []*model.ETHTransaction{
+			{Hash: "0x1", From: addrA, To: addrC, Value: "0x1000"},
+			{Hash: "0x2", From: addrC, To: addrA, Value: "0x1"},
+			{Hash: "0x3", From: addrA, To: addrA, Value: "0x1"},
+			{Hash: "0x4", From: addrB, To: addrA, Value: "0x63"},
+			{Hash: "0x5", From: addrB, To: addrC, Value: "0x64"},
+		},
+	}))
+
+	// outbound transfers of addrA are ignored, a self transfer is inbound as well.
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x2", "0x3", "0x4"}, hashesOf(list))
+	// transfers of addrB below 100 wei are ignored, whatever the other party.
+	list, err = instance.GetTransactions(ctx, addrB)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x5"}, hashesOf(list))
+}
+
+func TestETHService_SubscribeWithOptionsReplace(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	opts := model.SubscriptionOptions{Direction: model.DirectionOutbound, Label: "treasury", WebhookURL: "https://example.com/a"}
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, upperAddrA, opts))
+	got, ok := instance.SubscriptionOptions(ctx, addrA)
+	assert.True(t, ok)
+	assert.Equal(t, opts, got)
+	subscriptions, err := instance.ListSubscriptionsWithPrefix(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "treasury", subscriptions[0].Label)
+	assert.Equal(t, "https://example.com/a", subscriptions[0].Webhook)
+	subscribedAt := *subscriptions[0].SubscribedAt
+
+	// Subscribe keeps the options, SubscribeWithWebhook replaces the webhook only.
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	got, _ = instance.SubscriptionOptions(ctx, addrA)
+	assert.Equal(t, opts, got)
+	assert.Nil(t, instance.SubscribeWithWebhook(ctx, addrA, "https://example.com/b"))
+	got, _ = instance.SubscriptionOptions(ctx, addrA)
+	assert.Equal(t, model.SubscriptionOptions{Direction: model.DirectionOutbound, Label: "treasury", WebhookURL: "https://example.com/b"}, got)
+
+	// SubscribeWithOptions replaces the options whole, the subscription time stays.
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrA, model.SubscriptionOptions{Label: "cold wallet"}))
+	got, _ = instance.SubscriptionOptions(ctx, addrA)
+	assert.Equal(t, model.SubscriptionOptions{Label: "cold wallet"}, got)
+	subscriptions, _ = instance.ListSubscriptionsWithPrefix(ctx, "")
+	assert.Equal(t, subscribedAt, *subscriptions[0].SubscribedAt)
+	assert.Equal(t, "", subscriptions[0].Webhook)
+
+	// options are dropped along with the subscription.
+	assert.Nil(t, instance.Unsubscribe(ctx, addrA, false))
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	got, _ = instance.SubscriptionOptions(ctx, addrA)
+	assert.Equal(t, model.SubscriptionOptions{}, got)
+	_, ok = instance.SubscriptionOptions(ctx, addrB)
+	assert.False(t, ok)
+}
+
+func TestETHService_SubscribeWithOptionsStartBlock(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(5)
+	client.setBlock(2, "0xh2", "0xh1", &model.ETHTransaction{Hash: "0x2", From: addrA, To: addrB})
+	client.setBlock(3, "0xh3", "0xh2", &model.ETHTransaction{Hash: "0x3", From: addrC, To: addrA})
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrA, model.SubscriptionOptions{StartBlock: 1, Direction: model.DirectionInbound}))
+	waitFor(t, 3*time.Second, func() bool {
+		status, _ := instance.BackfillStatus(ctx, addrA)
+		return status.Done
+	})
+	// the backfill applies the options as well.
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3"}, hashesOf(list))
+
+	// no start block, no backfill.
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrB, model.SubscriptionOptions{}))
+	_, ok := instance.BackfillStatus(ctx, addrB)
+	assert.False(t, ok)
+}
+
+func TestETHService_SubscribeWithFilter(t *testing.T) {
+	ctx := context.Background()
+	instance := newTestETHService()
+	var directions []model.Direction
+	assert.Nil(t, instance.SubscribeWithFilter(ctx, upperAddrA, func(tx *model.ETHTransaction) bool {
+		directions = append(directions, tx.Direction)
+		tx.Hash = "modified"
+		return tx.Input == "0xa9059cbb"
+	}))
+	// a panicking filter skips the transaction, a nil filter stores every one.
+	assert.Nil(t, instance.SubscribeWithFilter(ctx, addrB, func(tx *model.ETHTransaction) bool {
+		if tx.Value == "0x0" {
+			panic("filter bug")
+		}
+		return true
+	}))
+	assert.Nil(t, instance.SubscribeWithFilter(ctx, addrC, nil))
+	// the options are kept along with the filter.
+	assert.Nil(t, instance.SubscribeWithOptions(ctx, addrB, model.SubscriptionOptions{Label: "hot wallet"}))
+	opts, _ := instance.SubscriptionOptions(ctx, addrB)
+	assert.Equal(t, "hot wallet", opts.Label)
+
+	assert.Nil(t, instance.parseBlock(ctx, &model.ETHBlockInfo{Transactions: []*model.ETHTransaction{
+		{Hash: "0x1", From: addrA, To: addrD, Value: "0x1", Input: "0xa9059cbb"},
+		{Hash: "0x2", From: addrD, To: addrA, Value: "0x1", Input: "0x"},
+		{Hash: "0x3", From: addrB, To: addrC, Value: "0x0"},
+		{Hash: "0x4", From: addrC, To: addrB, Value: "0x1"},
+	}}))
+	assert.Equal(t, []model.Direction{model.DirectionOutbound, model.DirectionInbound}, directions)
+	list, err := instance.GetTransactions(ctx, addrA)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1"}, hashesOf(list))
+	list, err = instance.GetTransactions(ctx, addrB)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x4"}, hashesOf(list))
+	list, err = instance.GetTransactions(ctx, addrC)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3", "0x4"}, hashesOf(list))
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// decodeTransferLog decode an ERC-20 Transfer(address indexed from, address indexed to, uint256 value) log.
+// ERC-721 shares the event signature but indexes tokenId as a 4th topic, it isn't a token amount transfer.
+func decodeTransferLog(l *model.ETHLog) (*model.TokenTransfer, bool) {
+	if len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], model.TransferEventTopic) || l.Removed {
+		return nil, false
+	}
+	from, ok := topicAddress(l.Topics[1])
+	if !ok {
+		return nil, false
+	}
+	to, ok := topicAddress(l.Topics[2])
+	if !ok {
+		return nil, false
+	}
+	amount := new(big.Int)
+	if data := strings.TrimPrefix(l.Data, "0x"); len(data) > 0 {
+		if _, ok := amount.SetString(data, 16); !ok {
+			return nil, false
+		}
+	}
+	return &model.TokenTransfer{
+		Contract:         strings.ToLower(l.Address),
+		From:             from,
+		To:               to,
+		Amount:           amount.String(),
+		TransactionHash:  l.TransactionHash,
+		TransactionIndex: l.TransactionIndex,
+		LogIndex:         l.LogIndex,
+		BlockNumber:      l.BlockNumber,
+		BlockHash:        l.BlockHash,
+	}, true
+}
+
+// topicAddress address of a 32 bytes indexed topic, left padded with zeros.
+func topicAddress(topic string) (string, bool) {
+	topic = strings.TrimPrefix(strings.ToLower(topic), "0x")
+	if len(topic) != 64 {
+		return "", false
+	}
+	return "0x" + topic[24:], true
+}
+
+// parseTokenTransfers store ERC-20 and NFT transfers of subscribed addresses found in receipts.
+func (s *ETHService) parseTokenTransfers(ctx context.Context, receipts []*model.ETHTransactionReceipt) error {
+	if len(receipts) == 0 {
+		return nil
+	}
+	return s.storeTokenTransfers(ctx, s.subscriptionSnapshot(), receiptLogs(receipts))
+}
+
+// receiptLogs logs of receipts in order.
+func receiptLogs(receipts []*model.ETHTransactionReceipt) []*model.ETHLog {
+	var logs []*model.ETHLog
+	for _, receipt := range receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	return logs
+}
+
+// transferParty subscribed address on a side of a transfer, with the direction relative to it.
+type transferParty struct {
+	address   string
+	direction model.Direction
+}
+
+// transferParties subscribed addresses among from and to, the ones whose subscription ignores the direction
+// of the transfer left out. a transfer to itself has a single party.
+func transferParties(subAddrs map[string]*SubscriptionConfig, from, to string) []transferParty {
+	if from == to {
+		if conf, ok := subAddrs[from]; ok && conf.acceptsDirection(model.DirectionSelf) {
+			return []transferParty{{from, model.DirectionSelf}}
+		}
+		return nil
+	}
+	var parties []transferParty
+	if conf, ok := subAddrs[from]; ok && conf.acceptsDirection(model.DirectionOutbound) {
+		parties = append(parties, transferParty{from, model.DirectionOutbound})
+	}
+	if conf, ok := subAddrs[to]; ok && conf.acceptsDirection(model.DirectionInbound) {
+		parties = append(parties, transferParty{to, model.DirectionInbound})
+	}
+	return parties
+}
+
+// storeTokenTransfers store ERC-20 and NFT transfers of subAddrs found in logs, skipping the addresses
+// unsubscribed meanwhile, same locking as storeMatches.
+func (s *ETHService) storeTokenTransfers(ctx context.Context, subAddrs map[string]*SubscriptionConfig, logs []*model.ETHLog) error {
+	var addrs []string
+	contracts := map[string]bool{}
+	tokens := map[string][]*model.TokenTransfer{}
+	nfts := map[string][]*model.NFTTransfer{}
+	seen := func(address string) {
+		if _, ok := tokens[address]; ok {
+			return
+		}
+		if _, ok := nfts[address]; ok {
+			return
+		}
+		addrs = append(addrs, address)
+	}
+	for _, l := range logs {
+		if transfer, ok := decodeTransferLog(l); ok {
+			for _, p := range transferParties(subAddrs, transfer.From, transfer.To) {
+				seen(p.address)
+				contracts[transfer.Contract] = true
+				tokens[p.address] = append(tokens[p.address], withTransferDirection(transfer, p.direction))
+			}
+			continue
+		}
+		if transfer, ok := decodeNFTLog(l); ok {
+			for _, p := range transferParties(subAddrs, transfer.From, transfer.To) {
+				seen(p.address)
+				nfts[p.address] = append(nfts[p.address], withNFTDirection(transfer, p.direction))
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	// tokens seen for the first time are resolved now, outside the locks.
+	s.resolveTokens(ctx, contracts)
+
+	s.addrRWMutex.RLock()
+	defer s.addrRWMutex.RUnlock()
+	for _, addr := range addrs {
+		if _, ok := s.subAddrs[addr]; !ok {
+			continue
+		}
+		if transfers, ok := tokens[addr]; ok {
+			if err := s.storage.AppendTokenTransfers(ctx, addr, transfers); err != nil {
+				s.logger.Error(ctx, "[storeTokenTransfers]: Error AppendTokenTransfers", "err", err)
+				return &storageError{err}
+			}
+		}
+		if transfers, ok := nfts[addr]; ok {
+			if err := s.storage.AppendNFTTransfers(ctx, addr, transfers); err != nil {
+				s.logger.Error(ctx, "[storeTokenTransfers]: Error AppendNFTTransfers", "err", err)
+				return &storageError{err}
+			}
+		}
+	}
+	return nil
+}
+
+func withTransferDirection(transfer *model.TokenTransfer, direction model.Direction) *model.TokenTransfer {
+	stored := *transfer
+	stored.Direction = direction
+	return &stored
+}
+
+// GetTokenTransfers get address's inbound/outbound ERC-20 transfers having Config.Confirmations confirmations,
+// along with the symbol and decimals of their token when they are resolved.
+func (s *ETHService) GetTokenTransfers(ctx context.Context, address string) ([]*model.TokenTransfer, error) {
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	// same confirmations as transactions.
+	transfers, err := s.storage.GetTokenTransfers(ctx, address, s.confirmedBlock())
+	if err != nil {
+		s.logger.Error(ctx, "[GetTokenTransfers]: Error GetTokenTransfers", "err", err)
+		return nil, err
+	}
+	return s.withTokenMetadata(ctx, transfers), nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+const (
+	tokenContract = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	holderA       = "0x00000000000000000000000000000000000000aa"
+	holderB       = "0x00000000000000000000000000000000000000bb"
+)
+
+func transferLog(from, to, data string) *model.ETHLog {
+	return transferLogAt("0x0", from, to, data)
+}
+
+func transferLogAt(logIndex, from, to, data string) *model.ETHLog {
+	return &model.ETHLog{
+		Address:         tokenContract,
+		Topics:          []string{model.TransferEventTopic, addressTopic(from), addressTopic(to)},
+		Data:            data,
+		BlockNumber:     "0x1",
+		TransactionHash: "0xt1",
+		LogIndex:        logIndex,
+	}
+}
+
+func TestDecodeTransferLog(t *testing.T) {
+	transfer, ok := decodeTransferLog(transferLog(holderA, holderB, "0x00000000000000000000000000000000000000000000000000000000000f4240"))
+	assert.True(t, ok)
+	assert.Equal(t, "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", transfer.Contract)
+	assert.Equal(t, holderA, transfer.From)
+	assert.Equal(t, holderB, transfer.To)
+	assert.Equal(t, "1000000", transfer.Amount)
+
+	// uint256 max doesn't fit in int64.
+	transfer, ok = decodeTransferLog(transferLog(holderA, holderB, "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
+	assert.True(t, ok)
+	assert.Equal(t, "115792089237316195423570985008687907853269984665640564039457584007913129639935", transfer.Amount)
+
+	// ERC-721 Transfer indexes tokenId.
+	nft := transferLog(holderA, holderB, "0x")
+	nft.Topics = append(nft.Topics, "0x0000000000000000000000000000000000000000000000000000000000000001")
+	_, ok = decodeTransferLog(nft)
+	assert.False(t, ok)
+
+	// other events.
+	approval := transferLog(holderA, holderB, "0x01")
+	approval.Topics[0] = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	_, ok = decodeTransferLog(approval)
+	assert.False(t, ok)
+}
+
+func TestETHService_ParseTokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(1)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0xt1", From: holderA, To: tokenContract})
+	client.receipts[1] = []*model.ETHTransactionReceipt{{
+		TransactionHash: "0xt1",
+		Logs: []*model.ETHLog{
+			transferLog(holderA, holderB, "0x0a"),
+			transferLogAt("0x1", "0x00000000000000000000000000000000000000cc", holderB, "0x01"),
+		},
+	}}
+	client.receiptFails[1] = 1
+	instance := newTestETHService()
+	instance.client = client
+	instance.Subscribe(ctx, holderA)
+	instance.Subscribe(ctx, holderB)
+
+	// receipts failing leaves nothing behind, the block is parsed again.
+	assert.NotNil(t, instance.ParseTransactions(ctx, 1))
+	list, _ := instance.GetTransactions(ctx, holderA)
+	assert.Equal(t, 0, len(list))
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+
+	list, _ = instance.GetTransactions(ctx, holderA)
+	assert.Equal(t, 1, len(list))
+	transfers, err := instance.GetTokenTransfers(ctx, holderA)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(transfers))
+	assert.Equal(t, "10", transfers[0].Amount)
+	assert.Equal(t, model.DirectionOutbound, transfers[0].Direction)
+
+	transfers, err = instance.GetTokenTransfers(ctx, holderB)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(transfers))
+	assert.Equal(t, model.DirectionInbound, transfers[1].Direction)
+
+	// parsing the block again doesn't duplicate its transfers.
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	transfers, _ = instance.GetTokenTransfers(ctx, holderB)
+	assert.Equal(t, 2, len(transfers))
+
+	assert.Nil(t, instance.Unsubscribe(ctx, holderB, true))
+	transfers, _ = instance.GetTokenTransfers(ctx, holderB)
+	assert.Equal(t, 0, len(transfers))
+}
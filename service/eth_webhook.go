@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/util"
+	"github.com/sugarshop/token-gateway/webhook"
+)
+
+// SubscribeWithWebhook subscribe address, then POST a webhook.Payload to callbackURL rather than
+// Config.WebhookURL for each of its transactions once stored, signed with Config.Webhook.Secret.
+// delivery is at least once, a block parsed again after a failure may be posted again, receivers
+// should dedup on the transaction hash. the other options of the subscription are kept, see
+// SubscribeWithOptions.
+// the callback is saved with the subscription when storage keeps options, see store.SubscriptionOptionsStore,
+// and is dropped by Unsubscribe.
+func (s *ETHService) SubscribeWithWebhook(ctx context.Context, address string, callbackURL string) error {
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return err
+	}
+	_, _, err := s.subscribeWith(ctx, address, func(conf *SubscriptionConfig) {
+		conf.WebhookURL = callbackURL
+	})
+	return err
+}
+
+// setWebhook replace the webhook of a subscribed address, keeping its other options, and save them. the
+// caller holds addrRWMutex.
+func (s *ETHService) setWebhook(ctx context.Context, address, callbackURL string) {
+	conf, ok := s.subAddrs[address]
+	if !ok {
+		return
+	}
+	updated := *conf
+	updated.WebhookURL = callbackURL
+	if err := s.saveSubscription(ctx, address, updated.SubscriptionOptions, true); err != nil {
+		s.logger.Error(ctx, "[setWebhook]: Error saveSubscription", "address", address, "err", err)
+		return
+	}
+	s.subAddrs[address] = &updated
+	s.subsVersion++
+}
+
+// WebhookStats deliveries to the webhook of address, its own or Config.WebhookURL, false if it has none.
+func (s *ETHService) WebhookStats(ctx context.Context, address string) (webhook.Stats, bool) {
+	address, err := util.NormalizeAddress(address)
+	if err != nil {
+		return webhook.Stats{}, false
+	}
+	s.addrRWMutex.RLock()
+	url := s.webhookOf(address)
+	s.addrRWMutex.RUnlock()
+	if len(url) == 0 {
+		return webhook.Stats{}, false
+	}
+	stats, _ := s.dispatcher.Stats(address)
+	return stats, true
+}
+
+// webhookOf webhook of a subscribed address, empty if none. the caller holds addrRWMutex.
+func (s *ETHService) webhookOf(address string) string {
+	conf, ok := s.subAddrs[address]
+	if !ok {
+		return ""
+	}
+	if len(conf.WebhookURL) > 0 {
+		return conf.WebhookURL
+	}
+	return s.conf.WebhookURL
+}
+
+// notifyWebhook queue the transactions just stored for address, without waiting for their delivery.
+func (s *ETHService) notifyWebhook(ctx context.Context, address, callbackURL string, transactions []*model.ETHTransaction) {
+	for _, tx := range transactions {
+		err := s.dispatcher.Send(address, callbackURL, &webhook.Payload{
+			Address:     address,
+			Direction:   tx.Direction,
+			BlockNumber: store.PositionOf(tx).Block,
+			Transaction: tx,
+		})
+		if err != nil {
+			s.logger.Error(ctx, "[notifyWebhook]: Error Send", "err", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/remote/remotetest"
+	"github.com/tj/assert"
+)
+
+// the fixtures of testdata/fixtures are shaped like mainnet responses, block 0x1000000 holding an ether
+// transfer to fixtureAlice, a USDC transfer from her, an unrelated transfer and a contract she creates
+// in a legacy transaction, its parent 0xffffff holding a transaction of each type sent by fixtureAlice.
+// re-record them from a live node with ETH_FIXTURE_RECORD_URL.
+const (
+	fixtureBlock      = int64(0x1000000)
+	fixtureTypedBlock = fixtureBlock - 1
+	fixtureAlice      = "0xae2fc483527b8ef99eb5d9b44875f005ba1fae13"
+	fixtureBob        = "0x6b75d8af000000e20b7a7ddf000ba900b4009a80"
+	fixtureCarol      = "0x107fe4e8248ae91651668666e82752890d700eec"
+	fixtureUSDC       = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+)
+
+// newFixtureETHService return an ETHService on a node replaying testdata/fixtures, not polling.
+func newFixtureETHService(t *testing.T, conf Config) *ETHService {
+	server := remotetest.NewFixtureServer(t, "testdata/fixtures")
+	instance, err := NewETHService(remote.NewETHRPCService(server.URL), WithConfig(conf), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+	return instance
+}
+
+func TestFixture_GetCurrentBlock(t *testing.T) {
+	instance := newFixtureETHService(t, testConfig())
+	blockInfo, err := instance.GetCurrentBlock(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "0x1000000", blockInfo.Number)
+	assert.Equal(t, 4, len(blockInfo.Transactions))
+	assert.Equal(t, "0x2", blockInfo.Transactions[0].Type)
+}
+
+func TestFixture_ParseTransactions(t *testing.T) {
+	ctx := context.Background()
+	instance := newFixtureETHService(t, testConfig())
+	assert.Nil(t, instance.Subscribe(ctx, fixtureAlice))
+	assert.Nil(t, instance.Subscribe(ctx, fixtureCarol))
+	assert.Nil(t, instance.ParseTransactions(ctx, fixtureBlock))
+
+	list, err := instance.GetTransactions(ctx, fixtureAlice)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	byHash := map[string]*model.ETHTransaction{}
+	for _, tx := range list {
+		byHash[tx.Hash] = tx
+	}
+	received := byHash["0xa1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1"]
+	assert.NotNil(t, received)
+	assert.Equal(t, model.DirectionInbound, received.Direction)
+	assert.Equal(t, fixtureBob, received.From)
+	assert.Equal(t, "1.5", received.ValueEtherString())
+	sent := byHash["0xb2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2"]
+	assert.NotNil(t, sent)
+	assert.Equal(t, model.DirectionOutbound, sent.Direction)
+	assert.Equal(t, fixtureUSDC, sent.To)
+
+	// carol only shows up in the calldata and logs of the USDC transfer, not as a transaction party.
+	list, err = instance.GetTransactions(ctx, fixtureCarol)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(list))
+	_, err = instance.GetTransactions(ctx, fixtureBob)
+	assert.Equal(t, ErrNotSubscribed, err)
+}
+
+func TestFixture_ParsedFields(t *testing.T) {
+	ctx := context.Background()
+	instance := newFixtureETHService(t, testConfig())
+	assert.Nil(t, instance.Subscribe(ctx, fixtureAlice))
+	assert.Nil(t, instance.ParseTransactions(ctx, fixtureBlock))
+	list, err := instance.GetTransactions(ctx, fixtureAlice)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	mined := time.Unix(0x6638d2c0, 0).UTC()
+
+	// EIP-1559 transfer.
+	received := list[0]
+	assert.Equal(t, mined, *received.Timestamp)
+	assert.Equal(t, fixtureBlock, received.BlockNum)
+	assert.Equal(t, int64(0), received.TxIndex)
+	assert.Equal(t, "1500000000000000000", received.Quantities.Value.String())
+	assert.Equal(t, "21000", received.Quantities.Gas.String())
+	assert.Equal(t, "10000000000", received.Quantities.MaxFeePerGas.String())
+	assert.Equal(t, "1000000000", received.Quantities.MaxPriorityFeePerGas.String())
+	assert.Equal(t, "21000", received.Quantities.GasUsed.String())
+	assert.Equal(t, "7000000000", received.Quantities.EffectiveGasPrice.String())
+
+	// legacy contract creation, to is null.
+	created := list[2]
+	assert.Equal(t, "0xd4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4d4", created.Hash)
+	assert.Equal(t, model.DirectionOutbound, created.Direction)
+	assert.Equal(t, "", created.To)
+	assert.Equal(t, "0x5fbdb2315678afecb367f032d93f642f64180aa3", created.ContractAddress)
+	assert.Equal(t, int64(3), created.TxIndex)
+	assert.Equal(t, "0", created.Quantities.Value.String())
+	assert.Nil(t, created.Quantities.MaxFeePerGas)
+	assert.Equal(t, "62560", created.Quantities.GasUsed.String())
+
+	// quantities are decimal strings in JSON.
+	data, err := json.Marshal(received.Quantities)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"value":"1500000000000000000","gas":"21000","gasPrice":"7000000000","maxFeePerGas":"10000000000",`+
+		`"maxPriorityFeePerGas":"1000000000","gasUsed":"21000","effectiveGasPrice":"7000000000"}`, string(data))
+	var decoded model.Quantities
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, *received.Quantities, decoded)
+}
+
+func TestFixture_TypedTransactions(t *testing.T) {
+	ctx := context.Background()
+	instance := newFixtureETHService(t, testConfig())
+	assert.Nil(t, instance.Subscribe(ctx, fixtureAlice))
+	assert.Nil(t, instance.ParseTransactions(ctx, fixtureTypedBlock))
+	list, err := instance.GetTransactions(ctx, fixtureAlice)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(list))
+
+	legacy, accessList, dynamicFee, blob := list[0], list[1], list[2], list[3]
+	assert.Equal(t, model.TxTypeLegacy, legacy.TxType())
+	assert.Equal(t, "legacy", legacy.TypeName)
+	assert.Equal(t, "", legacy.MaxFeePerGas)
+	assert.Equal(t, "7000000000", legacy.Quantities.GasPrice.String())
+	assert.Equal(t, "147000000000000", legacy.MaxFee().String())
+
+	assert.Equal(t, model.TxTypeAccessList, accessList.TxType())
+	assert.Equal(t, "accessList", accessList.TypeName)
+	assert.Equal(t, 1, len(accessList.AccessList))
+	assert.Equal(t, "700000000000000", accessList.MaxFee().String())
+
+	assert.Equal(t, model.TxTypeDynamicFee, dynamicFee.TxType())
+	assert.Equal(t, "dynamicFee", dynamicFee.TypeName)
+	assert.Equal(t, "1000000000", dynamicFee.Quantities.MaxPriorityFeePerGas.String())
+	assert.Nil(t, dynamicFee.Quantities.MaxFeePerBlobGas)
+	assert.Equal(t, "210000000000000", dynamicFee.MaxFee().String())
+
+	// the blob fee is on top of the gas fee, for 2 blobs of 131072 blob gas.
+	assert.Equal(t, model.TxTypeBlob, blob.TxType())
+	assert.Equal(t, "blob", blob.TypeName)
+	assert.Equal(t, 2, len(blob.BlobVersionedHashes))
+	assert.Equal(t, "1000000000", blob.Quantities.MaxFeePerBlobGas.String())
+	assert.Equal(t, "472144000000000", blob.MaxFee().String())
+}
+
+func TestFixture_TokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	conf := testConfig()
+	conf.TrackTokenTransfers = true
+	instance := newFixtureETHService(t, conf)
+	assert.Nil(t, instance.Subscribe(ctx, fixtureCarol))
+	assert.Nil(t, instance.ParseTransactions(ctx, fixtureBlock))
+
+	transfers, err := instance.GetTokenTransfers(ctx, fixtureCarol)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(transfers))
+	assert.Equal(t, fixtureUSDC, transfers[0].Contract)
+	assert.Equal(t, fixtureAlice, transfers[0].From)
+	assert.Equal(t, model.DirectionInbound, transfers[0].Direction)
+	assert.Equal(t, "250000000", transfers[0].Amount)
+	assert.Equal(t, "USDC", transfers[0].Symbol)
+	assert.Equal(t, "250", transfers[0].FormattedAmount)
+}
+
+func TestFixture_GetBlockByNumber(t *testing.T) {
+	ctx := context.Background()
+	instance := newFixtureETHService(t, testConfig())
+	blockInfo, err := instance.GetBlockByNumber(ctx, fixtureBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x1000000", blockInfo.Number)
+	_, err = instance.GetBlockByNumber(ctx, fixtureBlock+1)
+	assert.EqualError(t, err, "block not found: 16777217 beyond chain head 16777216")
+	_, err = instance.GetBlockByNumber(ctx, -1)
+	assert.NotNil(t, err)
+
+	blockInfo, err = instance.GetBlockByTag(ctx, "LATEST")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x1000000", blockInfo.Number)
+	_, err = instance.GetBlockByTag(ctx, "0x10")
+	assert.EqualError(t, err, `invalid block tag "0x10"`)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// gasStatsCacheSize blocks whose GasStats are kept, the most recent ones.
+const gasStatsCacheSize = 256
+
+// GasStats gas prices paid by the transactions of a block, the effective ones for EIP-1559 transactions.
+// Min, Median and Max are nil for a block without transactions.
+type GasStats struct {
+	Number       int64    `json:"number"`
+	Hash         string   `json:"hash"`
+	Transactions int      `json:"transactions"` // transactions with a gas price.
+	Min          *big.Int `json:"min"`
+	Median       *big.Int `json:"median"` // the mean of the 2 middle prices for an even count.
+	Max          *big.Int `json:"max"`
+}
+
+// gasStatsCache GasStats of the recently parsed blocks by number, safe for concurrent use.
+type gasStatsCache struct {
+	mu    sync.Mutex
+	stats map[int64]*GasStats
+}
+
+// GetGasStats min, median and max gas price of the transactions of block number. the blocks parsed
+// recently are served from memory, older ones are fetched from the node, and cached as well. errors wrap
+// ErrBlockNotFound for a block beyond chain head.
+func (s *ETHService) GetGasStats(ctx context.Context, number int64) (*GasStats, error) {
+	if stats, ok := s.gasStats.get(number); ok {
+		return stats, nil
+	}
+	blockInfo, err := s.GetBlockByNumber(ctx, number)
+	if err != nil {
+		s.logger.Error(ctx, "[GetGasStats]: Error GetBlockByNumber", "block", number, "err", err)
+		return nil, err
+	}
+	stats := gasStatsOf(blockInfo)
+	s.gasStats.add(stats)
+	return stats.copy(), nil
+}
+
+// gasStatsOf compute the GasStats of blockInfo, transactions without a valid gasPrice are left out.
+func gasStatsOf(blockInfo *model.ETHBlockInfo) *GasStats {
+	number, _ := util.HexToInt64(blockInfo.Number)
+	stats := &GasStats{Number: number, Hash: blockInfo.Hash}
+	prices := make([]*big.Int, 0, len(blockInfo.Transactions))
+	for _, tx := range blockInfo.Transactions {
+		if price, err := util.HexToBigInt(tx.GasPrice); err == nil {
+			prices = append(prices, price)
+		}
+	}
+	stats.Transactions = len(prices)
+	if len(prices) == 0 {
+		return stats
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	stats.Min, stats.Max = prices[0], prices[len(prices)-1]
+	mid := len(prices) / 2
+	stats.Median = new(big.Int).Set(prices[mid])
+	if len(prices)%2 == 0 {
+		stats.Median.Add(stats.Median, prices[mid-1]).Rsh(stats.Median, 1)
+	}
+	return stats
+}
+
+// copy deep copy of st, the caller is free to modify it.
+func (st *GasStats) copy() *GasStats {
+	c := *st
+	for _, v := range []**big.Int{&c.Min, &c.Median, &c.Max} {
+		if *v != nil {
+			*v = new(big.Int).Set(*v)
+		}
+	}
+	return &c
+}
+
+func (c *gasStatsCache) get(number int64) (*GasStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.stats[number]
+	if !ok {
+		return nil, false
+	}
+	return stats.copy(), true
+}
+
+// add cache stats, replacing the ones of the same block number, and forget the lowest number once full.
+func (c *gasStatsCache) add(stats *GasStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats == nil {
+		c.stats = map[int64]*GasStats{}
+	}
+	c.stats[stats.Number] = stats
+	if len(c.stats) <= gasStatsCacheSize {
+		return
+	}
+	lowest := stats.Number
+	for number := range c.stats {
+		if number < lowest {
+			lowest = number
+		}
+	}
+	delete(c.stats, lowest)
+}
+
+// invalidateFrom forget the stats of number and above, their blocks may be orphaned.
+func (c *gasStatsCache) invalidateFrom(number int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n := range c.stats {
+		if n >= number {
+			delete(c.stats, n)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_GetGasStats(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(3)
+	client.setBlock(1, "0xh1", "0xh0",
+		&model.ETHTransaction{Hash: "0x1", GasPrice: "0x3"},
+		&model.ETHTransaction{Hash: "0x2", GasPrice: "0x1"},
+		&model.ETHTransaction{Hash: "0x3"},
+		&model.ETHTransaction{Hash: "0x4", GasPrice: "0x4"},
+		&model.ETHTransaction{Hash: "0x5", GasPrice: "0x2"},
+	)
+	client.setBlock(2, "0xh2", "0xh1",
+		&model.ETHTransaction{Hash: "0x6", GasPrice: "0x3b9aca00"},
+		&model.ETHTransaction{Hash: "0x7", GasPrice: "0x1"},
+		&model.ETHTransaction{Hash: "0x8", GasPrice: "0x2540be400"},
+	)
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithLogger(logging.Nop()))
+	assert.Nil(t, err)
+
+	// a parsed block is served from memory, the mean of the 2 middle prices is the median of an even count.
+	assert.Nil(t, instance.ParseTransactions(ctx, 1))
+	calls := client.callCount()
+	stats, err := instance.GetGasStats(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, calls, client.callCount())
+	assert.Equal(t, "0xh1", stats.Hash)
+	assert.Equal(t, 4, stats.Transactions)
+	assert.Equal(t, "1 2 4", stats.Min.String()+" "+stats.Median.String()+" "+stats.Max.String())
+	// callers get copies.
+	stats.Min.SetInt64(100)
+	stats, _ = instance.GetGasStats(ctx, 1)
+	assert.Equal(t, "1", stats.Min.String())
+
+	// a block not parsed is fetched once.
+	stats, err = instance.GetGasStats(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "1 1000000000 10000000000", stats.Min.String()+" "+stats.Median.String()+" "+stats.Max.String())
+	calls = client.callCount()
+	_, err = instance.GetGasStats(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, calls, client.callCount())
+
+	stats, err = instance.GetGasStats(ctx, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, &GasStats{Number: 3, Hash: "0xh3"}, stats)
+	_, err = instance.GetGasStats(ctx, 4)
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+}
+
+func TestGasStatsCache(t *testing.T) {
+	var c gasStatsCache
+	for n := int64(1); n <= gasStatsCacheSize+1; n++ {
+		c.add(&GasStats{Number: n})
+	}
+	// the lowest block is forgotten once full.
+	_, ok := c.get(1)
+	assert.False(t, ok)
+	_, ok = c.get(2)
+	assert.True(t, ok)
+
+	c.invalidateFrom(100)
+	_, ok = c.get(99)
+	assert.True(t, ok)
+	_, ok = c.get(100)
+	assert.False(t, ok)
+}
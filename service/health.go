@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sugarshop/token-gateway/remote"
+)
+
+// HealthStatus whether an ETHService keeps up with the chain, see Health.
+type HealthStatus struct {
+	// Healthy the node is reachable and parsing isn't lagging.
+	Healthy bool `json:"healthy"`
+	// LastProcessedBlock number of the most recent block parsed.
+	LastProcessedBlock int64 `json:"last_processed_block"`
+	// ChainHead number of the most recent block of the node, 0 if it is unreachable.
+	ChainHead int64 `json:"chain_head,omitempty"`
+	// Lag blocks between LastProcessedBlock and ChainHead.
+	Lag int64 `json:"lag"`
+	// Lagging Lag is above Config.MaxHealthyLag.
+	Lagging bool `json:"lagging"`
+	// LastLoad when blocks were last loaded successfully, even if there was no new one, the creation of
+	// the service before the first load.
+	LastLoad time.Time `json:"last_load"`
+	// SinceLastLoad time elapsed since LastLoad, nanoseconds in JSON.
+	SinceLastLoad time.Duration `json:"since_last_load"`
+	// Error why the node is unreachable.
+	Error string `json:"error,omitempty"`
+	// Heads how new blocks are learnt about.
+	Heads HeadsStatus `json:"heads"`
+	// Endpoints health of the JSON-RPC endpoints of the client, if it calls several.
+	Endpoints []remote.EndpointStatus `json:"endpoints,omitempty"`
+}
+
+// HeadSource how an ETHService learns about new blocks.
+type HeadSource string
+
+const (
+	HeadSourceStopped      HeadSource = "stopped"      // not started, or stopped.
+	HeadSourcePolling      HeadSource = "polling"      // the node has no websocket endpoint, eth_blockNumber is polled.
+	HeadSourceWebSocket    HeadSource = "websocket"    // new heads are pushed by the newHeads subscription.
+	HeadSourceReconnecting HeadSource = "reconnecting" // the subscription failed, polling until it is made again.
+)
+
+// headSources HeadSource of the headSource field, stopped at 0.
+var headSources = []HeadSource{HeadSourceStopped, HeadSourcePolling, HeadSourceWebSocket, HeadSourceReconnecting}
+
+// HeadsStatus state of the newHeads subscription, see HeadsStatus.
+type HeadsStatus struct {
+	Source HeadSource `json:"source"`
+	// LastHead when the subscription last pushed a head, zero if it never did.
+	LastHead time.Time `json:"last_head,omitempty"`
+	// Resubscribes subscriptions made after the first one, because the socket dropped or couldn't connect.
+	Resubscribes int64 `json:"resubscribes"`
+}
+
+// HeadsStatus how s learns about new blocks, for monitoring the websocket connection.
+func (s *ETHService) HeadsStatus() HeadsStatus {
+	status := HeadsStatus{
+		Source:       headSources[atomic.LoadInt32(&s.headSource)],
+		Resubscribes: atomic.LoadInt64(&s.resubscribes),
+	}
+	if last := atomic.LoadInt64(&s.lastHead); last > 0 {
+		status.LastHead = time.Unix(0, last)
+	}
+	return status
+}
+
+// setHeadSource record how new blocks are learnt about from now on.
+func (s *ETHService) setHeadSource(source HeadSource) {
+	for i, known := range headSources {
+		if known == source {
+			atomic.StoreInt32(&s.headSource, int32(i))
+		}
+	}
+}
+
+// Health report whether the node is reachable and parsing keeps up with its chain head. the status
+// is filled as far as possible when the node is unreachable, along with the error.
+func (s *ETHService) Health(ctx context.Context) (HealthStatus, error) {
+	lastLoad := time.Unix(0, atomic.LoadInt64(&s.lastLoad))
+	status := HealthStatus{
+		LastProcessedBlock: s.LastProcessedBlock(ctx),
+		LastLoad:           lastLoad,
+		SinceLastLoad:      time.Since(lastLoad),
+		Heads:              s.HeadsStatus(),
+	}
+	if reporter, ok := s.client.(remote.EndpointReporter); ok {
+		status.Endpoints = reporter.Endpoints()
+	}
+	head, err := s.client.ETHBlockDecimalNumber(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "[Health]: Error ETHBlockDecimalNumber", "err", err)
+		status.Error = err.Error()
+		return status, err
+	}
+	status.ChainHead = head
+	if status.Lag = head - status.LastProcessedBlock; status.Lag < 0 {
+		// the head of another node behind the block we parsed.
+		status.Lag = 0
+	}
+	status.Lagging = status.Lag > int64(s.conf.MaxHealthyLag)
+	status.Healthy = !status.Lagging
+	return status, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestETHService_Health(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(1)
+	conf := testConfig()
+	conf.MaxHealthyLag = 2
+	instance, err := NewETHService(client, WithConfig(conf))
+	assert.Nil(t, err)
+
+	status, err := instance.Health(ctx)
+	assert.Nil(t, err)
+	assert.True(t, status.Healthy)
+	assert.Equal(t, int64(1), status.LastProcessedBlock)
+	created := status.LastLoad
+
+	client.setHead(4)
+	status, err = instance.Health(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), status.Lag)
+	assert.True(t, status.Lagging)
+	assert.False(t, status.Healthy)
+
+	assert.Nil(t, instance.load(ctx))
+	status, err = instance.Health(ctx)
+	assert.Nil(t, err)
+	assert.True(t, status.Healthy)
+	assert.Equal(t, int64(4), status.LastProcessedBlock)
+	assert.True(t, status.LastLoad.After(created))
+
+	client.mu.Lock()
+	client.headErr = errors.New("connection refused")
+	client.mu.Unlock()
+	status, err = instance.Health(ctx)
+	assert.NotNil(t, err)
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "connection refused", status.Error)
+	assert.Equal(t, int64(4), status.LastProcessedBlock)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sugarshop/token-gateway/metrics"
+	"github.com/sugarshop/token-gateway/remote"
+)
+
+// Metrics sink of the metrics of block processing, labeled by chain, see metricsChain. PrometheusMetrics
+// exposes them in the Prometheus text format, NopMetrics drops them, see WithMetrics.
+type Metrics interface {
+	// RPCRequest a JSON-RPC call of the client NewETHServiceFromConfig makes.
+	remote.Metrics
+	// BlockParsed a block fetched and stored, or failing to, in d.
+	BlockParsed(chain string, d time.Duration)
+	// BlockProcessed a block parsed, or skipped after too many failures.
+	BlockProcessed(chain string, skipped bool)
+	// TransactionsMatched n transactions of a subscribed address stored.
+	TransactionsMatched(chain, address string, n int)
+	// Head chain head and the most recent block processed.
+	Head(chain string, head, processed int64)
+	// Subscriptions addresses subscribed.
+	Subscriptions(chain string, n int)
+}
+
+// PrometheusMetrics Metrics kept in a metrics.Registry.
+type PrometheusMetrics struct {
+	*remote.PrometheusMetrics
+	blocksProcessed     *metrics.Counter
+	blocksSkipped       *metrics.Counter
+	transactionsMatched *metrics.Counter
+	addressTransactions *metrics.Counter
+	chainHead           *metrics.Gauge
+	processedBlock      *metrics.Gauge
+	blockLag            *metrics.Gauge
+	subscriptions       *metrics.Gauge
+	blockParseSeconds   *metrics.Histogram
+}
+
+// NewPrometheusMetrics register the metrics of block processing and JSON-RPC calls in r, once per registry.
+// the services of several chains share it.
+func NewPrometheusMetrics(r *metrics.Registry) *PrometheusMetrics {
+	return newPrometheusMetrics(r, remote.NewPrometheusMetrics(r))
+}
+
+func newPrometheusMetrics(r *metrics.Registry, rpc *remote.PrometheusMetrics) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		PrometheusMetrics: rpc,
+		blocksProcessed: r.NewCounter("token_gateway_blocks_processed_total",
+			"Blocks parsed, or skipped after too many failures.", "chain"),
+		blocksSkipped: r.NewCounter("token_gateway_blocks_skipped_total",
+			"Blocks skipped after too many failures.", "chain"),
+		transactionsMatched: r.NewCounter("token_gateway_transactions_matched_total",
+			"Transactions of subscribed addresses stored, once per address.", "chain"),
+		addressTransactions: r.NewCounter("token_gateway_address_transactions_total",
+			"Transactions stored per subscribed address.", "chain", "address"),
+		chainHead: r.NewGauge("token_gateway_chain_head",
+			"Number of the most recent block of the node.", "chain"),
+		processedBlock: r.NewGauge("token_gateway_processed_block",
+			"Number of the most recent block processed.", "chain"),
+		blockLag: r.NewGauge("token_gateway_block_lag",
+			"Blocks between the most recent block processed and the chain head.", "chain"),
+		subscriptions: r.NewGauge("token_gateway_subscriptions",
+			"Addresses subscribed.", "chain"),
+		blockParseSeconds: r.NewHistogram("token_gateway_block_parse_seconds",
+			"Time to fetch and store a block.", nil, "chain"),
+	}
+}
+
+// defaultMetrics Metrics of metrics.Default, used unless WithMetrics.
+var defaultMetrics = newPrometheusMetrics(metrics.Default, remote.DefaultMetrics())
+
+// BlockParsed observe d in the parse time histogram.
+func (m *PrometheusMetrics) BlockParsed(chain string, d time.Duration) {
+	m.blockParseSeconds.Observe(d.Seconds(), chain)
+}
+
+// BlockProcessed count a processed block, and a skipped one.
+func (m *PrometheusMetrics) BlockProcessed(chain string, skipped bool) {
+	if skipped {
+		m.blocksSkipped.Inc(chain)
+	}
+	m.blocksProcessed.Inc(chain)
+}
+
+// TransactionsMatched count n transactions of the chain and of address.
+func (m *PrometheusMetrics) TransactionsMatched(chain, address string, n int) {
+	m.transactionsMatched.Add(float64(n), chain)
+	m.addressTransactions.Add(float64(n), chain, address)
+}
+
+// Head set the chain head, processed block and lag gauges.
+func (m *PrometheusMetrics) Head(chain string, head, processed int64) {
+	m.chainHead.Set(float64(head), chain)
+	m.processedBlock.Set(float64(processed), chain)
+	lag := head - processed
+	if lag < 0 {
+		// the head of another node behind the block we parsed.
+		lag = 0
+	}
+	m.blockLag.Set(float64(lag), chain)
+}
+
+// Subscriptions set the subscriptions gauge.
+func (m *PrometheusMetrics) Subscriptions(chain string, n int) {
+	m.subscriptions.Set(float64(n), chain)
+}
+
+// NopMetrics Metrics dropping everything.
+type NopMetrics struct {
+	remote.NopMetrics
+}
+
+func (NopMetrics) BlockParsed(chain string, d time.Duration)        {}
+func (NopMetrics) BlockProcessed(chain string, skipped bool)        {}
+func (NopMetrics) TransactionsMatched(chain, address string, n int) {}
+func (NopMetrics) Head(chain string, head, processed int64)         {}
+func (NopMetrics) Subscriptions(chain string, n int)                {}
+
+// metricsChain chain label of the metrics of s, default for the default chain.
+func (s *ETHService) metricsChain() string {
+	if len(s.conf.Chain) == 0 {
+		return "default"
+	}
+	return s.conf.Chain
+}
+
+// observeHead record chain head head and the lag of the last processed block behind it.
+func (s *ETHService) observeHead(head int64) {
+	s.metrics.Head(s.metricsChain(), head, atomic.LoadInt64(&s.recentBlockNumer))
+}
+
+// observeParse record a block parse started at start.
+func (s *ETHService) observeParse(start time.Time) {
+	s.metrics.BlockParsed(s.metricsChain(), time.Since(start))
+}
+
+// observeSubscriptions record how many addresses are subscribed. the caller holds addrRWMutex.
+func (s *ETHService) observeSubscriptions() {
+	s.metrics.Subscriptions(s.metricsChain(), len(s.subAddrs))
+}
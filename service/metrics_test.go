@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/metrics"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func TestETHService_Metrics(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(10)
+	client.setBlock(12, "0xh12", "0xh11", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB},
+		&model.ETHTransaction{Hash: "0x2", From: addrC, To: addrA})
+	conf := testConfig()
+	conf.Chain = "metricstest"
+	// a registry of its own keeps the series of other tests apart.
+	m := NewPrometheusMetrics(metrics.NewRegistry())
+	instance, err := NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()), WithMetrics(m))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	assert.Nil(t, instance.Subscribe(ctx, addrB))
+	assert.Nil(t, instance.Unsubscribe(ctx, addrB, false))
+	assert.Equal(t, 1.0, m.subscriptions.Value("metricstest"))
+
+	client.setHead(13)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 3.0, m.blocksProcessed.Value("metricstest"))
+	assert.Equal(t, 2.0, m.transactionsMatched.Value("metricstest"))
+	assert.Equal(t, 2.0, m.addressTransactions.Value("metricstest", addrA))
+	assert.Equal(t, uint64(3), m.blockParseSeconds.Count("metricstest"))
+	assert.Equal(t, 13.0, m.chainHead.Value("metricstest"))
+	assert.Equal(t, 13.0, m.processedBlock.Value("metricstest"))
+	assert.Equal(t, 0.0, m.blockLag.Value("metricstest"))
+
+	// block 14 keeps failing.
+	client.setHead(14)
+	client.fails[14] = maxBlockRetries + 1
+	assert.NotNil(t, instance.load(ctx))
+	assert.Equal(t, 14.0, m.chainHead.Value("metricstest"))
+	assert.Equal(t, 1.0, m.blockLag.Value("metricstest"))
+	assert.Equal(t, 3.0, m.blocksProcessed.Value("metricstest"))
+	assert.Equal(t, 0.0, m.blocksSkipped.Value("metricstest"))
+}
+
+func TestETHService_NopMetrics(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeETHClient(0)
+	client.setBlock(1, "0xh1", "0xh0", &model.ETHTransaction{Hash: "0x1", From: addrA, To: addrB})
+	conf := testConfig()
+	conf.Chain = "noptest"
+	instance, err := NewETHService(client, WithConfig(conf), WithLogger(logging.Nop()), WithMetrics(NopMetrics{}))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, addrA))
+	client.setHead(1)
+	assert.Nil(t, instance.load(ctx))
+	assert.Equal(t, 0.0, defaultMetrics.addressTransactions.Value("noptest", addrA))
+}
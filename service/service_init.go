@@ -1,5 +1,70 @@
 package service
 
-func Init()  {
-	ETHServiceInstance()
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// startupAttempts how many times Init tries to start a service before giving up.
+	startupAttempts = 4
+	// startupBackoff delay before the second attempt to start a service, doubled after each attempt.
+	startupBackoff = time.Second
+	// startupTimeout how long an attempt may wait for the node and storage, the lock of the services is held meanwhile.
+	startupTimeout = 30 * time.Second
+)
+
+// Init start the ETHService of the default chain and of every chain of Chains, retrying with backoff
+// so a node briefly unreachable at startup isn't fatal. the first error is returned once every one is
+// tried, the services failing are started again on their next use.
+func Init() error {
+	ctx := context.Background()
+	err := startWithRetry(ctx, startupAttempts, startupBackoff, func() error {
+		_, err := ETHServiceInstance()
+		return err
+	})
+	for _, chain := range Chains() {
+		chain := chain
+		chainErr := startWithRetry(ctx, startupAttempts, startupBackoff, func() error {
+			_, err := ChainService(chain)
+			return err
+		})
+		if chainErr != nil && err == nil {
+			err = chainErr
+		}
+	}
+	return err
+}
+
+// startWithRetry call start until it succeeds, at most attempts times, waiting backoff before the second
+// attempt then twice as long before each next one. the last error is returned if every attempt fails or
+// ctx is done. a chain id mismatch isn't retried, the next attempt would be on the same chain.
+func startWithRetry(ctx context.Context, attempts int, backoff time.Duration, start func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = start(); err == nil || attempt >= attempts || errors.Is(err, ErrChainIDMismatch) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Stop stop the background block loop of the started services, the first error is returned once every
+// one is stopped.
+func Stop(ctx context.Context) error {
+	var err error
+	for _, s := range startedServices() {
+		if stopErr := s.Stop(ctx); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	return err
 }
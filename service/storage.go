@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"strings"
+
+	// the driver of StorageSQLite.
+	_ "github.com/mattn/go-sqlite3"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/redis"
+	"github.com/sugarshop/token-gateway/store/sqlite"
+)
+
+const (
+	// StorageMemory keep subscriptions and transactions in memory, the default.
+	StorageMemory = "memory"
+	// StorageSQLite keep subscriptions and transactions in the SQLite database of Config.SQLitePath, they
+	// are loaded back on restart.
+	StorageSQLite = "sqlite"
+	// StorageRedis keep subscriptions and transactions in Redis at Config.RedisAddr, shared by the replicas
+	// of the gateway.
+	StorageRedis = "redis"
+)
+
+// openStorage open the storage conf.Storage selects and what closes it, nil for the default in-memory one.
+func openStorage(ctx context.Context, conf Config) (store.Storage, io.Closer, error) {
+	switch conf.Storage {
+	case "", StorageMemory:
+		return nil, nil, nil
+	case StorageSQLite:
+		if len(conf.SQLitePath) == 0 {
+			return nil, nil, errors.New("no SQLite path for chain " + conf.Chain)
+		}
+		db, err := sql.Open("sqlite3", conf.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		storage, err := sqlite.Open(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		// the database belongs to this instance, reads are served from memory.
+		return store.NewCache(storage), storage, nil
+	case StorageRedis:
+		var addrs []string
+		for _, addr := range strings.Split(conf.RedisAddr, ",") {
+			if addr = strings.TrimSpace(addr); len(addr) > 0 {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, nil, errors.New("no Redis address for chain " + conf.Chain)
+		}
+		client := goredis.NewUniversalClient(&goredis.UniversalOptions{Addrs: addrs})
+		return redis.New(redis.NewGoRedis(client), redisPrefix(conf.Chain)), client, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage %q", conf.Storage)
+}
+
+// redisPrefix prefix of the Redis keys of chain, hash tagged so that the keys of a chain share a hash
+// slot of a cluster.
+func redisPrefix(chain string) string {
+	if len(chain) == 0 {
+		return "{gateway}:"
+	}
+	return "{gateway:" + chain + "}:"
+}
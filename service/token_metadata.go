@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/remote"
+	"github.com/sugarshop/token-gateway/store"
+)
+
+// selectors of the ERC-20 metadata getters, the first 4 bytes of keccak256 of their signature.
+const (
+	nameSelector     = "0x06fdde03" // name()
+	symbolSelector   = "0x95d89b41" // symbol()
+	decimalsSelector = "0x313ce567" // decimals()
+)
+
+// maxTokenDecimals decimals are an uint8, a larger value isn't taken as decimals.
+const maxTokenDecimals = 255
+
+// TokenMetadataService resolve the name, symbol and decimals of token contracts with eth_call, once per
+// contract. resolved metadata is kept in memory, and in storage as well when it is given.
+type TokenMetadataService struct {
+	caller  remote.ContractCaller
+	storage store.TokenMetadataStore // nil to keep metadata in memory only.
+
+	mutex  sync.Mutex
+	tokens map[string]*model.TokenMetadata
+	logger logging.Logger // logging.Std unless set by the ETHService it belongs to.
+}
+
+// NewTokenMetadataService return a TokenMetadataService calling contracts with caller, storage may be nil.
+func NewTokenMetadataService(caller remote.ContractCaller, storage store.TokenMetadataStore) *TokenMetadataService {
+	return &TokenMetadataService{caller: caller, storage: storage, tokens: map[string]*model.TokenMetadata{},
+		logger: logging.Std()}
+}
+
+// Resolve metadata of contract, from memory, then storage, then calling the contract. a getter the
+// contract rejects, such as a revert or a missing function, leaves its field empty. Resolve fails without
+// remembering anything when a call fails for another reason, the next Resolve calls the contract again.
+// the returned metadata must not be modified.
+func (m *TokenMetadataService) Resolve(ctx context.Context, contract string) (*model.TokenMetadata, error) {
+	contract = strings.ToLower(contract)
+	m.mutex.Lock()
+	metadata, ok := m.tokens[contract]
+	m.mutex.Unlock()
+	if ok {
+		return metadata, nil
+	}
+	if m.storage != nil {
+		metadata, ok, err := m.storage.GetTokenMetadata(ctx, contract)
+		if err != nil {
+			m.logger.Error(ctx, "[Resolve]: Error GetTokenMetadata", "err", err)
+		} else if ok {
+			m.remember(metadata)
+			return metadata, nil
+		}
+	}
+	metadata, err := m.call(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+	if m.storage != nil {
+		if err := m.storage.SaveTokenMetadata(ctx, metadata); err != nil {
+			m.logger.Error(ctx, "[Resolve]: Error SaveTokenMetadata", "err", err)
+		}
+	}
+	m.remember(metadata)
+	return metadata, nil
+}
+
+func (m *TokenMetadataService) remember(metadata *model.TokenMetadata) {
+	m.mutex.Lock()
+	m.tokens[metadata.Contract] = metadata
+	m.mutex.Unlock()
+}
+
+// call read the metadata of contract from its getters.
+func (m *TokenMetadataService) call(ctx context.Context, contract string) (*model.TokenMetadata, error) {
+	metadata := &model.TokenMetadata{Contract: contract}
+	data, err := m.callGetter(ctx, contract, nameSelector)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Name, _ = decodeABIString(data)
+	if data, err = m.callGetter(ctx, contract, symbolSelector); err != nil {
+		return nil, err
+	}
+	metadata.Symbol, _ = decodeABIString(data)
+	if data, err = m.callGetter(ctx, contract, decimalsSelector); err != nil {
+		return nil, err
+	}
+	if decimals, ok := decodeABIUint(data); ok && decimals.IsInt64() && decimals.Int64() <= maxTokenDecimals {
+		d := int(decimals.Int64())
+		metadata.Decimals = &d
+	}
+	return metadata, nil
+}
+
+// callGetter return data of the getter selector of contract, nil if the contract rejects the call.
+func (m *TokenMetadataService) callGetter(ctx context.Context, contract, selector string) ([]byte, error) {
+	result, err := m.caller.EthCall(ctx, contract, selector)
+	var rpcErr *model.JSONRPCError
+	if errors.As(err, &rpcErr) {
+		return nil, nil
+	}
+	if err != nil {
+		m.logger.Error(ctx, "[callGetter]: Error EthCall", "contract", contract, "selector", selector, "err", err)
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// decodeABIString decode a string returned by a getter. some early tokens return a bytes32 instead,
+// right padded with zeros, it is decoded as well. invalid UTF-8 is dropped.
+func decodeABIString(data []byte) (string, bool) {
+	var raw []byte
+	switch {
+	case len(data) == 32:
+		raw = data
+	case len(data) >= 64:
+		offset, ok := abiWordInt(data, 0)
+		if !ok || offset > len(data)-32 {
+			return "", false
+		}
+		length, ok := abiWordInt(data, offset)
+		if !ok || length > len(data)-offset-32 {
+			return "", false
+		}
+		raw = data[offset+32 : offset+32+length]
+	default:
+		return "", false
+	}
+	str := strings.TrimRight(string(raw), "\x00")
+	return strings.TrimSpace(strings.ToValidUTF8(str, "")), true
+}
+
+// decodeABIUint decode an uint256 returned by a getter.
+func decodeABIUint(data []byte) (*big.Int, bool) {
+	if len(data) < 32 {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(data[:32]), true
+}
+
+// abiWordInt the 32 bytes word at offset of data as an int, false if it is out of data or too large.
+func abiWordInt(data []byte, offset int) (int, bool) {
+	if offset < 0 || offset+32 > len(data) {
+		return 0, false
+	}
+	v := new(big.Int).SetBytes(data[offset : offset+32])
+	if !v.IsInt64() || v.Int64() > int64(len(data)) {
+		return 0, false
+	}
+	return int(v.Int64()), true
+}
+
+// formatTokenAmount amount, a raw decimal integer, scaled by decimals without trailing zeros,
+// "1500000" with 6 decimals is "1.5". empty if amount isn't an integer.
+func formatTokenAmount(amount string, decimals int) string {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return ""
+	}
+	if decimals <= 0 {
+		return value.String()
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(value, unit, new(big.Int))
+	if frac.Sign() == 0 {
+		return whole.String()
+	}
+	fraction := frac.String()
+	fraction = strings.Repeat("0", decimals-len(fraction)) + fraction
+	return whole.String() + "." + strings.TrimRight(fraction, "0")
+}
+
+// resolveTokens resolve the metadata of contracts not resolved yet, a failure is logged and left to
+// the next GetTokenTransfers.
+func (s *ETHService) resolveTokens(ctx context.Context, contracts map[string]bool) {
+	if s.tokens == nil {
+		return
+	}
+	for contract := range contracts {
+		if _, err := s.tokens.Resolve(ctx, contract); err != nil {
+			s.logger.Error(ctx, "[resolveTokens]: Error Resolve", "contract", contract, "err", err)
+		}
+	}
+}
+
+// withTokenMetadata transfers along with the metadata of their token, copies when it is resolved.
+func (s *ETHService) withTokenMetadata(ctx context.Context, transfers []*model.TokenTransfer) []*model.TokenTransfer {
+	result := make([]*model.TokenTransfer, 0, len(transfers))
+	if s.tokens == nil {
+		return append(result, transfers...)
+	}
+	// nil for the tokens failing, tried once per call.
+	resolved := map[string]*model.TokenMetadata{}
+	for _, transfer := range transfers {
+		metadata, ok := resolved[transfer.Contract]
+		if !ok {
+			var err error
+			if metadata, err = s.tokens.Resolve(ctx, transfer.Contract); err != nil {
+				s.logger.Error(ctx, "[withTokenMetadata]: Error Resolve", "contract", transfer.Contract, "err", err)
+			}
+			resolved[transfer.Contract] = metadata
+		}
+		if metadata == nil {
+			result = append(result, transfer)
+			continue
+		}
+		enriched := *transfer
+		enriched.Symbol = metadata.Symbol
+		if metadata.Decimals != nil {
+			decimals := *metadata.Decimals
+			enriched.Decimals = &decimals
+			enriched.FormattedAmount = formatTokenAmount(transfer.Amount, decimals)
+		}
+		result = append(result, &enriched)
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/tj/assert"
+)
+
+// fakeCallerClient fakeETHClient answering eth_call from results, keyed by lowercase contract and
+// selector. a missing result reverts.
+type fakeCallerClient struct {
+	*fakeETHClient
+	callMutex sync.Mutex
+	results   map[string]string
+	callErr   error
+	ethCalls  int
+}
+
+func (f *fakeCallerClient) EthCall(ctx context.Context, to, data string) (string, error) {
+	f.callMutex.Lock()
+	defer f.callMutex.Unlock()
+	f.ethCalls++
+	if f.callErr != nil {
+		return "", f.callErr
+	}
+	if result, ok := f.results[strings.ToLower(to)+data]; ok {
+		return result, nil
+	}
+	return "", &model.JSONRPCError{Code: 3, Message: "execution reverted"}
+}
+
+// abiString ABI encoding of a string return value.
+func abiString(s string) string {
+	data := fmt.Sprintf("%064x%064x%x", 32, len(s), s)
+	if pad := len(data) % 64; pad > 0 {
+		data += strings.Repeat("0", 64-pad)
+	}
+	return "0x" + data
+}
+
+func TestDecodeABIString(t *testing.T) {
+	decode := func(result string) (string, bool) {
+		data, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+		assert.Nil(t, err)
+		return decodeABIString(data)
+	}
+	str, ok := decode(abiString("USD Coin"))
+	assert.True(t, ok)
+	assert.Equal(t, "USD Coin", str)
+
+	// MKR returns a bytes32.
+	str, ok = decode(fmt.Sprintf("0x%x", "MKR") + strings.Repeat("0", 58))
+	assert.True(t, ok)
+	assert.Equal(t, "MKR", str)
+
+	// offset out of the data.
+	_, ok = decode(fmt.Sprintf("0x%064x%064x", 4096, 3))
+	assert.False(t, ok)
+	// length out of the data.
+	_, ok = decode(fmt.Sprintf("0x%064x%064x", 32, 4096))
+	assert.False(t, ok)
+	_, ok = decode("0x0102")
+	assert.False(t, ok)
+}
+
+func TestFormatTokenAmount(t *testing.T) {
+	assert.Equal(t, "1.5", formatTokenAmount("1500000", 6))
+	assert.Equal(t, "0.000001", formatTokenAmount("1", 6))
+	assert.Equal(t, "2", formatTokenAmount("2000000000000000000", 18))
+	assert.Equal(t, "42", formatTokenAmount("42", 0))
+	assert.Equal(t, "", formatTokenAmount("0x2a", 6))
+}
+
+func TestETHService_TokenMetadata(t *testing.T) {
+	ctx := context.Background()
+	bytes32Token := "0x00000000000000000000000000000000000000dd"
+	revertingToken := "0x00000000000000000000000000000000000000ee"
+	client := &fakeCallerClient{fakeETHClient: newFakeETHClient(0), results: map[string]string{
+		strings.ToLower(tokenContract) + nameSelector:     abiString("USD Coin"),
+		strings.ToLower(tokenContract) + symbolSelector:   abiString("USDC"),
+		strings.ToLower(tokenContract) + decimalsSelector: fmt.Sprintf("0x%064x", 6),
+		bytes32Token + symbolSelector:                     fmt.Sprintf("0x%x", "MKR") + strings.Repeat("0", 58),
+		bytes32Token + decimalsSelector:                   fmt.Sprintf("0x%064x", 18),
+	}}
+	storage := store.NewCache(store.NewMemory(0))
+	instance, err := NewETHService(client, WithConfig(testConfig()), WithStorage(storage))
+	assert.Nil(t, err)
+	assert.Nil(t, instance.Subscribe(ctx, holderA))
+
+	other := transferLogAt("0x1", holderA, holderB, "0x0de0b6b3a7640000")
+	other.Address = bytes32Token
+	reverting := transferLogAt("0x2", holderB, holderA, "0x05")
+	reverting.Address = revertingToken
+	assert.Nil(t, instance.storeTokenTransfers(ctx, map[string]*SubscriptionConfig{holderA: {}},
+		[]*model.ETHLog{transferLog(holderA, holderB, "0x16e360"), other, reverting}))
+	// resolved once stored, 3 calls per token.
+	assert.Equal(t, 9, client.ethCalls)
+
+	transfers, err := instance.GetTokenTransfers(ctx, holderA)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(transfers))
+	assert.Equal(t, "USDC", transfers[0].Symbol)
+	assert.Equal(t, 6, *transfers[0].Decimals)
+	assert.Equal(t, "1.5", transfers[0].FormattedAmount)
+	assert.Equal(t, "MKR", transfers[1].Symbol)
+	assert.Equal(t, "1", transfers[1].FormattedAmount)
+	// reverting on every getter doesn't break anything.
+	assert.Equal(t, "", transfers[2].Symbol)
+	assert.Nil(t, transfers[2].Decimals)
+	assert.Equal(t, "", transfers[2].FormattedAmount)
+	assert.Equal(t, "5", transfers[2].Amount)
+	assert.Equal(t, 9, client.ethCalls)
+
+	// the stored transfers are left as they were.
+	stored, err := storage.GetTokenTransfers(ctx, holderA, store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, "", stored[0].Symbol)
+}
+
+func TestTokenMetadataService_Resolve(t *testing.T) {
+	ctx := context.Background()
+	contract := strings.ToLower(tokenContract)
+	client := &fakeCallerClient{results: map[string]string{
+		contract + symbolSelector:   abiString("USDC"),
+		contract + decimalsSelector: fmt.Sprintf("0x%064x", 6),
+	}}
+	metadataStore := &fakeTokenMetadataStore{tokens: map[string]*model.TokenMetadata{}}
+
+	// a node unreachable isn't remembered.
+	client.callErr = errors.New("connection refused")
+	tokens := NewTokenMetadataService(client, metadataStore)
+	_, err := tokens.Resolve(ctx, tokenContract)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(metadataStore.tokens))
+
+	client.callErr = nil
+	metadata, err := tokens.Resolve(ctx, tokenContract)
+	assert.Nil(t, err)
+	assert.Equal(t, "USDC", metadata.Symbol)
+	assert.Equal(t, "", metadata.Name)
+	assert.Equal(t, metadata, metadataStore.tokens[contract])
+
+	// read from storage by another instance.
+	calls := client.ethCalls
+	metadata, err = NewTokenMetadataService(client, metadataStore).Resolve(ctx, tokenContract)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, *metadata.Decimals)
+	assert.Equal(t, calls, client.ethCalls)
+}
+
+type fakeTokenMetadataStore struct {
+	tokens map[string]*model.TokenMetadata
+}
+
+func (f *fakeTokenMetadataStore) SaveTokenMetadata(ctx context.Context, metadata *model.TokenMetadata) error {
+	f.tokens[metadata.Contract] = metadata
+	return nil
+}
+
+func (f *fakeTokenMetadataStore) GetTokenMetadata(ctx context.Context, contract string) (*model.TokenMetadata, bool, error) {
+	metadata, ok := f.tokens[contract]
+	return metadata, ok, nil
+}
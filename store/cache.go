@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+var (
+	_ Storage                  = (*Cache)(nil)
+	_ TokenMetadataStore       = (*Cache)(nil)
+	_ ChainIDStore             = (*Cache)(nil)
+	_ TransactionCounter       = (*Cache)(nil)
+	_ SubscriptionOptionsStore = (*Cache)(nil)
+)
+
+// Cache Storage keeping the transactions of a backend, such as SQLite, in a Memory as well. writes go
+// through to the backend first, reads of an address are served from memory once its transactions were
+// loaded by a first read. the backend should belong to this instance alone, writes of other instances
+// never reach the memory.
+type Cache struct {
+	backend Storage
+	memory  *Memory
+
+	// mutex guards loaded and is held across the write to both the backend and memory, so a rollback
+	// or the load of an address never interleaves with them.
+	mutex  sync.Mutex
+	loaded map[string]bool
+}
+
+// NewCache return a Cache in front of backend, it retains every transaction of the addresses read.
+func NewCache(backend Storage) *Cache {
+	return &Cache{backend: backend, memory: NewMemory(0), loaded: map[string]bool{}}
+}
+
+func (c *Cache) SaveSubscription(ctx context.Context, address string) error {
+	return c.backend.SaveSubscription(ctx, address)
+}
+
+func (c *Cache) DeleteSubscription(ctx context.Context, address string) error {
+	return c.backend.DeleteSubscription(ctx, address)
+}
+
+func (c *Cache) ListSubscriptions(ctx context.Context) ([]string, error) {
+	return c.backend.ListSubscriptions(ctx)
+}
+
+// SaveSubscriptionOptions save opts in the backend, or only the address if it doesn't keep options.
+func (c *Cache) SaveSubscriptionOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error {
+	if optionsStore, ok := c.backend.(SubscriptionOptionsStore); ok {
+		return optionsStore.SaveSubscriptionOptions(ctx, address, opts)
+	}
+	return c.backend.SaveSubscription(ctx, address)
+}
+
+// ListSubscriptionOptions options of the backend, zero ones if it doesn't keep options.
+func (c *Cache) ListSubscriptionOptions(ctx context.Context) (map[string]model.SubscriptionOptions, error) {
+	if optionsStore, ok := c.backend.(SubscriptionOptionsStore); ok {
+		return optionsStore.ListSubscriptionOptions(ctx)
+	}
+	addrs, err := c.backend.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	options := make(map[string]model.SubscriptionOptions, len(addrs))
+	for _, addr := range addrs {
+		options[addr] = model.SubscriptionOptions{}
+	}
+	return options, nil
+}
+
+func (c *Cache) AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.backend.AppendTransactions(ctx, address, transactions); err != nil {
+		return err
+	}
+	if !c.loaded[address] {
+		// read from the backend along with the rest on first read.
+		return nil
+	}
+	return c.memory.AppendTransactions(ctx, address, transactions)
+}
+
+func (c *Cache) GetTransactions(ctx context.Context, address string, q Query) ([]*model.ETHTransaction, string, error) {
+	if err := c.load(ctx, address); err != nil {
+		return nil, "", err
+	}
+	return c.memory.GetTransactions(ctx, address, q)
+}
+
+// CountTransactions from memory once address was read, from the backend if it counts them, otherwise
+// its transactions are loaded.
+func (c *Cache) CountTransactions(ctx context.Context, address string) (int, int64, error) {
+	c.mutex.Lock()
+	loaded := c.loaded[address]
+	c.mutex.Unlock()
+	if counter, ok := c.backend.(TransactionCounter); ok && !loaded {
+		return counter.CountTransactions(ctx, address)
+	}
+	if err := c.load(ctx, address); err != nil {
+		return 0, 0, err
+	}
+	return c.memory.CountTransactions(ctx, address)
+}
+
+// load copy the transactions of address from the backend to memory, once.
+func (c *Cache) load(ctx context.Context, address string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.loaded[address] {
+		return nil
+	}
+	transactions, _, err := c.backend.GetTransactions(ctx, address, Query{MaxBlock: NoMaxBlock})
+	if err != nil {
+		return err
+	}
+	if err := c.memory.AppendTransactions(ctx, address, transactions); err != nil {
+		return err
+	}
+	c.loaded[address] = true
+	return nil
+}
+
+// AppendTokenTransfers token transfers are not cached.
+func (c *Cache) AppendTokenTransfers(ctx context.Context, address string, transfers []*model.TokenTransfer) error {
+	return c.backend.AppendTokenTransfers(ctx, address, transfers)
+}
+
+func (c *Cache) GetTokenTransfers(ctx context.Context, address string, maxBlock int64) ([]*model.TokenTransfer, error) {
+	return c.backend.GetTokenTransfers(ctx, address, maxBlock)
+}
+
+// AppendNFTTransfers NFT transfers are not cached.
+func (c *Cache) AppendNFTTransfers(ctx context.Context, address string, transfers []*model.NFTTransfer) error {
+	return c.backend.AppendNFTTransfers(ctx, address, transfers)
+}
+
+func (c *Cache) GetNFTTransfers(ctx context.Context, address string, q Query) ([]*model.NFTTransfer, string, error) {
+	return c.backend.GetNFTTransfers(ctx, address, q)
+}
+
+// SaveTokenMetadata saved in the backend if it is a TokenMetadataStore, dropped otherwise.
+func (c *Cache) SaveTokenMetadata(ctx context.Context, metadata *model.TokenMetadata) error {
+	if backend, ok := c.backend.(TokenMetadataStore); ok {
+		return backend.SaveTokenMetadata(ctx, metadata)
+	}
+	return nil
+}
+
+func (c *Cache) GetTokenMetadata(ctx context.Context, contract string) (*model.TokenMetadata, bool, error) {
+	if backend, ok := c.backend.(TokenMetadataStore); ok {
+		return backend.GetTokenMetadata(ctx, contract)
+	}
+	return nil, false, nil
+}
+
+// SaveChainID saved in the backend if it is a ChainIDStore, dropped otherwise.
+func (c *Cache) SaveChainID(ctx context.Context, chainID int64) error {
+	if backend, ok := c.backend.(ChainIDStore); ok {
+		return backend.SaveChainID(ctx, chainID)
+	}
+	return nil
+}
+
+func (c *Cache) GetChainID(ctx context.Context) (int64, bool, error) {
+	if backend, ok := c.backend.(ChainIDStore); ok {
+		return backend.GetChainID(ctx)
+	}
+	return 0, false, nil
+}
+
+func (c *Cache) DeleteHistory(ctx context.Context, address string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.backend.DeleteHistory(ctx, address); err != nil {
+		return err
+	}
+	return c.memory.DeleteHistory(ctx, address)
+}
+
+func (c *Cache) Rollback(ctx context.Context, block int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.backend.Rollback(ctx, block); err != nil {
+		return err
+	}
+	return c.memory.Rollback(ctx, block)
+}
+
+func (c *Cache) GetCheckpoint(ctx context.Context) (int64, bool, error) {
+	return c.backend.GetCheckpoint(ctx)
+}
+
+func (c *Cache) SetCheckpoint(ctx context.Context, block int64) error {
+	return c.backend.SetCheckpoint(ctx, block)
+}
@@ -0,0 +1,74 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/storetest"
+	"github.com/tj/assert"
+)
+
+// countingStorage Memory counting the GetTransactions reaching it.
+type countingStorage struct {
+	*store.Memory
+	reads int
+}
+
+func (c *countingStorage) GetTransactions(ctx context.Context, address string, q store.Query) ([]*model.ETHTransaction, string, error) {
+	c.reads++
+	return c.Memory.GetTransactions(ctx, address, q)
+}
+
+func TestCache(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return store.NewCache(store.NewMemory(0))
+	})
+}
+
+func TestCache_TransactionCounter(t *testing.T) {
+	storetest.RunTransactionCounter(t, func(t *testing.T) store.Storage {
+		return store.NewCache(store.NewMemory(0))
+	})
+	ctx := context.Background()
+	backend := &countingStorage{Memory: store.NewMemory(0)}
+	assert.Nil(t, backend.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+	n, _, err := store.NewCache(backend).CountTransactions(ctx, "0xaa")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	// counted by the backend, nothing loaded.
+	assert.Equal(t, 0, backend.reads)
+}
+
+func TestCache_SubscriptionOptions(t *testing.T) {
+	storetest.RunSubscriptionOptions(t, func(t *testing.T) store.Storage {
+		return store.NewCache(store.NewMemory(0))
+	})
+}
+
+func TestCache_ReadsFromMemory(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStorage{Memory: store.NewMemory(0)}
+	// stored before a restart.
+	assert.Nil(t, backend.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+
+	c := store.NewCache(backend)
+	assert.Nil(t, c.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(2, 0, model.DirectionInbound)}))
+	for i := 0; i < 3; i++ {
+		list, _, err := c.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0x1_0", "0x2_0"}, storetest.Hashes(list))
+	}
+	assert.Equal(t, 1, backend.reads)
+
+	assert.Nil(t, c.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(3, 0, model.DirectionInbound)}))
+	assert.Nil(t, c.Rollback(ctx, 1))
+	list, _, err := c.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0"}, storetest.Hashes(list))
+	list, _, err = backend.Memory.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0"}, storetest.Hashes(list))
+	assert.Equal(t, 1, backend.reads)
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Checkpointer where the last processed block is kept, so parsing resumes from it after a restart.
+type Checkpointer interface {
+	// GetCheckpoint the last processed block, false if none was set.
+	GetCheckpoint(ctx context.Context) (int64, bool, error)
+	// SetCheckpoint remember block as the last processed one. a storage shared by several instances
+	// may refuse with ErrCheckpointConflict, so only one of them advances it.
+	SetCheckpoint(ctx context.Context, block int64) error
+}
+
+var _ Checkpointer = (*FileCheckpointer)(nil)
+
+// FileCheckpointer Checkpointer keeping the block number in a file, alone.
+type FileCheckpointer struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileCheckpointer return a FileCheckpointer writing to path, its directory should exist.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+func (f *FileCheckpointer) GetCheckpoint(ctx context.Context) (int64, bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	block, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid checkpoint file %s: %v", f.path, err)
+	}
+	return block, true, nil
+}
+
+// SetCheckpoint write a temporary file renamed over the checkpoint, a crash never leaves it half written.
+func (f *FileCheckpointer) SetCheckpoint(ctx context.Context, block int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strconv.FormatInt(block, 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,36 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/tj/assert"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	c := store.NewFileCheckpointer(path)
+	_, ok, err := c.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, c.SetCheckpoint(ctx, 19862630))
+	assert.Nil(t, c.SetCheckpoint(ctx, 19862631))
+	// read back by another instance, as after a restart.
+	block, ok, err := store.NewFileCheckpointer(path).GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(19862631), block)
+	// no temporary file is left behind.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	assert.Nil(t, os.WriteFile(path, []byte("garbage"), 0o644))
+	_, _, err = c.GetCheckpoint(ctx)
+	assert.NotNil(t, err)
+}
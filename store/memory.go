@@ -0,0 +1,367 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+)
+
+var (
+	_ Storage                  = (*Memory)(nil)
+	_ Evicter                  = (*Memory)(nil)
+	_ TransactionCounter       = (*Memory)(nil)
+	_ SubscriptionOptionsStore = (*Memory)(nil)
+)
+
+// Retention how much of the history of each address Memory keeps, the zero value keeps everything.
+type Retention struct {
+	// MaxPerAddress the most recent transactions, token and NFT transfers retained per address, the oldest
+	// are dropped first. 0 retains any number.
+	MaxPerAddress int
+	// MaxAge transactions of blocks mined longer ago are dropped, the ones without block timestamp are kept.
+	// token and NFT transfers have no timestamp, MaxPerAddress bounds them only. 0 retains any age.
+	MaxAge time.Duration
+}
+
+// Memory Storage keeping everything in maps, lost on restart.
+type Memory struct {
+	retention     Retention
+	mu            sync.RWMutex // guards the fields below.
+	subscriptions map[string]model.SubscriptionOptions
+	transactions  map[string][]*model.ETHTransaction
+	transfers     map[string][]*model.TokenTransfer
+	nfts          map[string][]*model.NFTTransfer
+	evicted       map[string]int64 // entries dropped per address past the retention.
+	checkpoint    int64
+	hasCheckpoint bool
+}
+
+// NewMemory return a Memory retaining the most recent maxPerAddress transactions, token and NFT transfers
+// per address, the oldest are dropped first. 0 retains everything.
+func NewMemory(maxPerAddress int) *Memory {
+	return NewMemoryWithRetention(Retention{MaxPerAddress: maxPerAddress})
+}
+
+// NewMemoryWithRetention return a Memory retaining the history of each address following retention.
+// entries past it are dropped as the address is appended to, and by EvictExpired for the idle addresses.
+func NewMemoryWithRetention(retention Retention) *Memory {
+	return &Memory{
+		retention:     retention,
+		subscriptions: map[string]model.SubscriptionOptions{},
+		transactions:  map[string][]*model.ETHTransaction{},
+		transfers:     map[string][]*model.TokenTransfer{},
+		nfts:          map[string][]*model.NFTTransfer{},
+		evicted:       map[string]int64{},
+	}
+}
+
+func (m *Memory) SaveSubscription(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[address]; !ok {
+		m.subscriptions[address] = model.SubscriptionOptions{}
+	}
+	return nil
+}
+
+func (m *Memory) SaveSubscriptionOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscriptions[address] = opts
+	return nil
+}
+
+func (m *Memory) ListSubscriptionOptions(ctx context.Context) (map[string]model.SubscriptionOptions, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	options := make(map[string]model.SubscriptionOptions, len(m.subscriptions))
+	for addr, opts := range m.subscriptions {
+		options[addr] = opts
+	}
+	return options, nil
+}
+
+func (m *Memory) DeleteSubscription(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subscriptions, address)
+	return nil
+}
+
+func (m *Memory) ListSubscriptions(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	addrs := make([]string, 0, len(m.subscriptions))
+	for addr := range m.subscriptions {
+		addrs = append(addrs, addr)
+	}
+	m.mu.RUnlock()
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func (m *Memory) AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.transactions[address]
+	for _, tx := range transactions {
+		pos := PositionOf(tx)
+		// new blocks land at the end, older ones, such as backfilled blocks, are inserted before them.
+		i := sort.Search(len(list), func(i int) bool { return PositionOf(list[i]).After(pos) })
+		if containsTransaction(list[:i], pos, KeyOf(tx)) {
+			continue
+		}
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = tx
+	}
+	if limit := m.retention.MaxPerAddress; limit > 0 && len(list) > limit {
+		// drop the oldest, the dropped head is released once append reallocates the backing array.
+		m.evicted[address] += int64(len(list) - limit)
+		list = list[len(list)-limit:]
+	}
+	m.transactions[address] = m.dropExpired(address, list, time.Now())
+	return nil
+}
+
+// dropExpired list without the transactions older than the max age at now, counted as evicted for address.
+// list is in chain order, so the expired ones are at its head, the cost is that of the dropped ones.
+// the caller holds the write lock.
+func (m *Memory) dropExpired(address string, list []*model.ETHTransaction, now time.Time) []*model.ETHTransaction {
+	if m.retention.MaxAge <= 0 {
+		return list
+	}
+	cutoff := now.Add(-m.retention.MaxAge)
+	i := 0
+	for i < len(list) && list[i].Timestamp != nil && list[i].Timestamp.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		m.evicted[address] += int64(i)
+	}
+	return list[i:]
+}
+
+// EvictExpired drop the transactions past the max age of every address, the write lock is taken an
+// address at a time so reads are held back no longer than the eviction of a single address.
+func (m *Memory) EvictExpired(ctx context.Context) error {
+	if m.retention.MaxAge <= 0 {
+		return nil
+	}
+	m.mu.RLock()
+	addrs := make([]string, 0, len(m.transactions))
+	for addr := range m.transactions {
+		addrs = append(addrs, addr)
+	}
+	m.mu.RUnlock()
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		// purged meanwhile, not recreated.
+		if list, ok := m.transactions[addr]; ok {
+			m.transactions[addr] = m.dropExpired(addr, list, time.Now())
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Evictions entries dropped per address past the retention, the addresses without any are left out.
+func (m *Memory) Evictions(ctx context.Context) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	evictions := make(map[string]int64, len(m.evicted))
+	for addr, n := range m.evicted {
+		evictions[addr] = n
+	}
+	return evictions, nil
+}
+
+// containsTransaction whether the transaction of key, see KeyOf, is at the end of list, at position pos.
+// the list itself is the seen-set, bounded by the retention and forgetting rolled back blocks.
+func containsTransaction(list []*model.ETHTransaction, pos Position, key string) bool {
+	for j := len(list) - 1; j >= 0 && PositionOf(list[j]) == pos; j-- {
+		if KeyOf(list[j]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) GetTransactions(ctx context.Context, address string, q Query) ([]*model.ETHTransaction, string, error) {
+	var from Position
+	if len(q.Cursor) > 0 {
+		p, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		from = p
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.transactions[address]
+	end := sort.Search(len(list), func(i int) bool { return PositionOf(list[i]).Block > q.MaxBlock })
+	start := 0
+	if len(q.Cursor) > 0 {
+		start = sort.Search(end, func(i int) bool { return PositionOf(list[i]).After(from) })
+	}
+	transactions := make([]*model.ETHTransaction, 0)
+	next := ""
+	for _, tx := range list[start:end] {
+		if !q.Filter.Match(tx) {
+			continue
+		}
+		if q.Limit > 0 && len(transactions) == q.Limit {
+			// there is more.
+			next = EncodeCursor(PositionOf(transactions[q.Limit-1]))
+			break
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, next, nil
+}
+
+func (m *Memory) CountTransactions(ctx context.Context, address string) (int, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.transactions[address]
+	if len(list) == 0 {
+		return 0, 0, nil
+	}
+	return len(list), PositionOf(list[len(list)-1]).Block, nil
+}
+
+func (m *Memory) AppendTokenTransfers(ctx context.Context, address string, transfers []*model.TokenTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.transfers[address]
+	for _, transfer := range transfers {
+		pos := TransferPositionOf(transfer)
+		i := sort.Search(len(list), func(i int) bool { return TransferPositionOf(list[i]).After(pos) })
+		if i > 0 && TransferPositionOf(list[i-1]) == pos && list[i-1].TransactionHash == transfer.TransactionHash {
+			continue
+		}
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = transfer
+	}
+	if limit := m.retention.MaxPerAddress; limit > 0 && len(list) > limit {
+		m.evicted[address] += int64(len(list) - limit)
+		list = list[len(list)-limit:]
+	}
+	m.transfers[address] = list
+	return nil
+}
+
+func (m *Memory) GetTokenTransfers(ctx context.Context, address string, maxBlock int64) ([]*model.TokenTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.transfers[address]
+	i := sort.Search(len(list), func(i int) bool { return TransferPositionOf(list[i]).Block > maxBlock })
+	transfers := make([]*model.TokenTransfer, 0, i)
+	transfers = append(transfers, list[:i]...)
+	return transfers, nil
+}
+
+func (m *Memory) AppendNFTTransfers(ctx context.Context, address string, transfers []*model.NFTTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.nfts[address]
+	for _, transfer := range transfers {
+		pos := NFTPositionOf(transfer)
+		i := sort.Search(len(list), func(i int) bool { return NFTPositionOf(list[i]).After(pos) })
+		if i > 0 && NFTPositionOf(list[i-1]) == pos && list[i-1].TransactionHash == transfer.TransactionHash {
+			continue
+		}
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = transfer
+	}
+	if limit := m.retention.MaxPerAddress; limit > 0 && len(list) > limit {
+		m.evicted[address] += int64(len(list) - limit)
+		list = list[len(list)-limit:]
+	}
+	m.nfts[address] = list
+	return nil
+}
+
+func (m *Memory) GetNFTTransfers(ctx context.Context, address string, q Query) ([]*model.NFTTransfer, string, error) {
+	var from Position
+	if len(q.Cursor) > 0 {
+		p, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		from = p
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.nfts[address]
+	end := sort.Search(len(list), func(i int) bool { return NFTPositionOf(list[i]).Block > q.MaxBlock })
+	start := 0
+	if len(q.Cursor) > 0 {
+		start = sort.Search(end, func(i int) bool { return NFTPositionOf(list[i]).After(from) })
+	}
+	transfers := make([]*model.NFTTransfer, 0)
+	next := ""
+	for _, transfer := range list[start:end] {
+		if !q.Filter.MatchDirection(transfer.Direction) {
+			continue
+		}
+		if q.Limit > 0 && len(transfers) == q.Limit {
+			// there is more.
+			next = EncodeCursor(NFTPositionOf(transfers[q.Limit-1]))
+			break
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, next, nil
+}
+
+func (m *Memory) DeleteHistory(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.transactions, address)
+	delete(m.transfers, address)
+	delete(m.nfts, address)
+	delete(m.evicted, address)
+	return nil
+}
+
+func (m *Memory) Rollback(ctx context.Context, block int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// stored in chain order, cut at the first orphaned one.
+	for addr, list := range m.transactions {
+		i := sort.Search(len(list), func(i int) bool { return PositionOf(list[i]).Block > block })
+		m.transactions[addr] = list[:i]
+	}
+	for addr, list := range m.transfers {
+		i := sort.Search(len(list), func(i int) bool { return TransferPositionOf(list[i]).Block > block })
+		m.transfers[addr] = list[:i]
+	}
+	for addr, list := range m.nfts {
+		i := sort.Search(len(list), func(i int) bool { return NFTPositionOf(list[i]).Block > block })
+		m.nfts[addr] = list[:i]
+	}
+	return nil
+}
+
+func (m *Memory) GetCheckpoint(ctx context.Context) (int64, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkpoint, m.hasCheckpoint, nil
+}
+
+func (m *Memory) SetCheckpoint(ctx context.Context, block int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoint, m.hasCheckpoint = block, true
+	return nil
+}
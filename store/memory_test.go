@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/storetest"
+	"github.com/tj/assert"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return store.NewMemory(0)
+	})
+}
+
+func TestMemory_TransactionCounter(t *testing.T) {
+	storetest.RunTransactionCounter(t, func(t *testing.T) store.Storage {
+		return store.NewMemory(0)
+	})
+}
+
+func TestMemory_SubscriptionOptions(t *testing.T) {
+	storetest.RunSubscriptionOptions(t, func(t *testing.T) store.Storage {
+		return store.NewMemory(0)
+	})
+}
+
+func TestMemory_Retention(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory(3)
+	for block := int64(1); block <= 5; block++ {
+		assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(block, 0, model.DirectionInbound)}))
+		assert.Nil(t, s.AppendTokenTransfers(ctx, "0xaa", []*model.TokenTransfer{storetest.Transfer(block, 0)}))
+	}
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3_0", "0x4_0", "0x5_0"}, storetest.Hashes(list))
+	transfers, err := s.GetTokenTransfers(ctx, "0xaa", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(transfers))
+}
+
+func TestMemory_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryWithRetention(store.Retention{MaxPerAddress: 3, MaxAge: time.Hour})
+	now := time.Now()
+	tx := func(block int64, age time.Duration) *model.ETHTransaction {
+		tx := storetest.Tx(block, 0, model.DirectionInbound)
+		if age > 0 {
+			timestamp := now.Add(-age)
+			tx.Timestamp = &timestamp
+		}
+		return tx
+	}
+	// the expired ones are dropped on append, a transaction without timestamp is kept.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{tx(1, 3*time.Hour), tx(2, 2*time.Hour), tx(3, 0)}))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xbb", []*model.ETHTransaction{tx(3, 0), tx(4, time.Minute)}))
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3_0"}, storetest.Hashes(list))
+
+	// then the oldest past MaxPerAddress.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xbb", []*model.ETHTransaction{tx(5, time.Minute), tx(6, time.Minute)}))
+	list, _, _ = s.GetTransactions(ctx, "0xbb", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, []string{"0x4_0", "0x5_0", "0x6_0"}, storetest.Hashes(list))
+
+	evictions, err := s.Evictions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"0xaa": 2, "0xbb": 1}, evictions)
+
+	// purging forgets the evictions of the address.
+	assert.Nil(t, s.DeleteHistory(ctx, "0xaa"))
+	assert.Nil(t, s.EvictExpired(ctx))
+	evictions, _ = s.Evictions(ctx)
+	assert.Equal(t, map[string]int64{"0xbb": 1}, evictions)
+}
+
+func TestMemory_EvictExpired(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryWithRetention(store.Retention{MaxAge: 50 * time.Millisecond})
+	timestamp := time.Now()
+	tx := storetest.Tx(1, 0, model.DirectionInbound)
+	tx.Timestamp = &timestamp
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{tx}))
+	assert.Nil(t, s.EvictExpired(ctx))
+	list, _, _ := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, 1, len(list))
+
+	// an idle address is swept once expired.
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, s.EvictExpired(ctx))
+	list, _, _ = s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, 0, len(list))
+	evictions, _ := s.Evictions(ctx)
+	assert.Equal(t, map[string]int64{"0xaa": 1}, evictions)
+}
@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"context"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// goRedis Client on a github.com/redis/go-redis client.
+type goRedis struct {
+	client goredis.UniversalClient
+}
+
+// NewGoRedis return a Client running its commands on client, a single node or a cluster one.
+func NewGoRedis(client goredis.UniversalClient) Client {
+	return goRedis{client: client}
+}
+
+func (c goRedis) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	v, err := c.client.Do(ctx, args...).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	return v, err
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/storetest"
+	"github.com/tj/assert"
+)
+
+// newMiniredis client of a miniredis server, running the scripts on a real Lua interpreter.
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, Client) {
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return server, NewGoRedis(client)
+}
+
+func TestStorage_Miniredis(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		_, client := newMiniredis(t)
+		return New(client, "gateway:")
+	})
+}
+
+func TestStorage_MiniredisTransactionCounter(t *testing.T) {
+	storetest.RunTransactionCounter(t, func(t *testing.T) store.Storage {
+		_, client := newMiniredis(t)
+		return New(client, "gateway:")
+	})
+}
+
+func TestStorage_MiniredisSubscriptionOptions(t *testing.T) {
+	storetest.RunSubscriptionOptions(t, func(t *testing.T) store.Storage {
+		_, client := newMiniredis(t)
+		return New(client, "gateway:")
+	})
+}
+
+func TestStorage_MiniredisChainID(t *testing.T) {
+	storetest.RunChainID(t, func(t *testing.T) store.ChainIDStore {
+		_, client := newMiniredis(t)
+		return New(client, "gateway:")
+	})
+}
+
+func TestStorage_MiniredisCheckpointConflict(t *testing.T) {
+	ctx := context.Background()
+	_, client := newMiniredis(t)
+	a, b := New(client, "gateway:"), New(client, "gateway:")
+	assert.Nil(t, a.SetCheckpoint(ctx, 10))
+	assert.Equal(t, store.ErrCheckpointConflict, b.SetCheckpoint(ctx, 10))
+	assert.Nil(t, b.SetCheckpoint(ctx, 11))
+	block, _, err := a.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), block)
+}
+
+func TestStorage_MiniredisUnreachable(t *testing.T) {
+	ctx := context.Background()
+	server, client := newMiniredis(t)
+	s := New(client, "gateway:")
+	server.Close()
+	assert.NotNil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+
+	assert.Nil(t, server.Restart())
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0"}, storetest.Hashes(list))
+}
@@ -0,0 +1,573 @@
+// Package redis store.Storage in Redis, shared by the replicas of the gateway.
+//
+// layout, every key under a prefix:
+//
+//	subscriptions                  set of subscribed addresses.
+//	suboptions                     hash of subscribed address to the json of its options, if it has any.
+//	histories                      set of addresses having transactions or transfers of any kind.
+//	tx:<address>                   sorted set of transaction keys, scored by block*10000+txIndex.
+//	txdata:<address>               hash of transaction key to json.
+//	transfers:<address>            sorted set of transfer keys, scored by block*10000+logIndex.
+//	transfersdata:<address>        hash of transfer key to json.
+//	nfts:<address>                 sorted set of NFT transfer keys, scored by block*10000+logIndex.
+//	nftsdata:<address>             hash of NFT transfer key to json.
+//	tokens                         hash of token contract to its metadata json.
+//	checkpoint                     last processed block.
+//	chainid                        chain id the state belongs to.
+//
+// scripts are passed every key they touch, on a cluster the keys should share a hash slot, with a hash
+// tagged prefix such as "{gateway}:".
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+)
+
+// positionsPerBlock positions per block in scores, a block with more transactions or logs is stored out of order.
+const positionsPerBlock = 10000
+
+// scanChunk how many members GetTransactions reads per round trip.
+const scanChunk = 100
+
+// Client run a redis command, a nil reply is returned as a nil value and nil error. replies are
+// int64, string, nil or []interface{} of them. see NewGoRedis for github.com/redis/go-redis.
+type Client interface {
+	Do(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+var (
+	_ store.Storage                  = (*Storage)(nil)
+	_ store.TokenMetadataStore       = (*Storage)(nil)
+	_ store.ChainIDStore             = (*Storage)(nil)
+	_ store.TransactionCounter       = (*Storage)(nil)
+	_ store.SubscriptionOptionsStore = (*Storage)(nil)
+)
+
+// Storage store.Storage in Redis. while Redis is unreachable every method fails, ETHService then
+// pauses parsing at the block it couldn't store and resumes from it once Redis is back.
+type Storage struct {
+	client Client
+	prefix string
+
+	checkpointMutex sync.Mutex
+	checkpoint      string // the checkpoint as last read or written by this instance, empty if none.
+}
+
+// New return a Storage keeping its keys under prefix, such as "gateway:".
+func New(client Client, prefix string) *Storage {
+	return &Storage{client: client, prefix: prefix}
+}
+
+func (s *Storage) key(parts ...string) string {
+	k := s.prefix
+	for _, p := range parts {
+		k += p
+	}
+	return k
+}
+
+func (s *Storage) SaveSubscription(ctx context.Context, address string) error {
+	_, err := s.client.Do(ctx, "SADD", s.key("subscriptions"), address)
+	return err
+}
+
+func (s *Storage) DeleteSubscription(ctx context.Context, address string) error {
+	if _, err := s.client.Do(ctx, "SREM", s.key("subscriptions"), address); err != nil {
+		return err
+	}
+	_, err := s.client.Do(ctx, "HDEL", s.key("suboptions"), address)
+	return err
+}
+
+// SaveSubscriptionOptions set the options before adding the address, an instance listing it meanwhile
+// never sees it without them.
+func (s *Storage) SaveSubscriptionOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Do(ctx, "HSET", s.key("suboptions"), address, string(data)); err != nil {
+		return err
+	}
+	return s.SaveSubscription(ctx, address)
+}
+
+func (s *Storage) ListSubscriptionOptions(ctx context.Context) (map[string]model.SubscriptionOptions, error) {
+	addrs, err := s.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	options := make(map[string]model.SubscriptionOptions, len(addrs))
+	if len(addrs) == 0 {
+		return options, nil
+	}
+	args := []interface{}{"HMGET", s.key("suboptions")}
+	for _, addr := range addrs {
+		args = append(args, addr)
+	}
+	reply, err := s.client.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != len(addrs) {
+		return nil, fmt.Errorf("unexpected HMGET reply %T", reply)
+	}
+	for i, addr := range addrs {
+		opts := model.SubscriptionOptions{}
+		if data, ok := values[i].(string); ok {
+			if err := json.Unmarshal([]byte(data), &opts); err != nil {
+				return nil, fmt.Errorf("options of %s: %v", addr, err)
+			}
+		}
+		options[addr] = opts
+	}
+	return options, nil
+}
+
+func (s *Storage) ListSubscriptions(ctx context.Context) ([]string, error) {
+	reply, err := s.client.Do(ctx, "SMEMBERS", s.key("subscriptions"))
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := stringsOf(reply)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// appendScript add members along with their data, skipping members already stored.
+// KEYS: sorted set, data hash, histories. ARGV: address, then score, member, data of each.
+const appendScript = `
+redis.call('SADD', KEYS[3], ARGV[1])
+for i = 2, #ARGV, 3 do
+	if redis.call('HSETNX', KEYS[2], ARGV[i+1], ARGV[i+2]) == 1 then
+		redis.call('ZADD', KEYS[1], ARGV[i], ARGV[i+1])
+	end
+end
+return 0`
+
+func (s *Storage) AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	args := []interface{}{"EVAL", appendScript, 3, s.key("tx:", address), s.key("txdata:", address), s.key("histories"), address}
+	for _, tx := range transactions {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return err
+		}
+		pos := store.PositionOf(tx)
+		args = append(args, score(pos), member(pos, store.KeyOf(tx)), string(data))
+	}
+	_, err := s.client.Do(ctx, args...)
+	return err
+}
+
+func (s *Storage) CountTransactions(ctx context.Context, address string) (int, int64, error) {
+	reply, err := s.client.Do(ctx, "ZCARD", s.key("tx:", address))
+	if err != nil {
+		return 0, 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, 0, errors.New("unexpected reply, want an integer")
+	}
+	if n == 0 {
+		return 0, 0, nil
+	}
+	if reply, err = s.client.Do(ctx, "ZRANGE", s.key("tx:", address), -1, -1); err != nil {
+		return 0, 0, err
+	}
+	members, err := stringsOf(reply)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(members) == 0 {
+		// dropped meanwhile.
+		return 0, 0, nil
+	}
+	var lastBlock int64
+	if _, err := fmt.Sscanf(members[0], "%d:", &lastBlock); err != nil {
+		return 0, 0, fmt.Errorf("invalid member %q: %v", members[0], err)
+	}
+	return int(n), lastBlock, nil
+}
+
+func (s *Storage) GetTransactions(ctx context.Context, address string, q store.Query) ([]*model.ETHTransaction, string, error) {
+	min := "-inf"
+	if len(q.Cursor) > 0 {
+		from, err := store.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		// exclusive, the page starts after the cursor.
+		min = "(" + score(from)
+	}
+	transactions := make([]*model.ETHTransaction, 0)
+	next := ""
+	err := s.scan(ctx, s.key("tx:", address), s.key("txdata:", address), min, maxScore(q.MaxBlock), func(data string) (bool, error) {
+		tx := &model.ETHTransaction{}
+		if err := json.Unmarshal([]byte(data), tx); err != nil {
+			return false, err
+		}
+		if !q.Filter.Match(tx) {
+			return true, nil
+		}
+		if q.Limit > 0 && len(transactions) == q.Limit {
+			// there is more.
+			next = store.EncodeCursor(store.PositionOf(transactions[q.Limit-1]))
+			return false, nil
+		}
+		transactions = append(transactions, tx)
+		return true, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return transactions, next, nil
+}
+
+// scan visit the data of the members of zset scored in [min, max] in order, scanChunk members per
+// round trip, until visit returns false.
+func (s *Storage) scan(ctx context.Context, zset, hash, min, max string, visit func(data string) (bool, error)) error {
+	for offset := 0; ; offset += scanChunk {
+		reply, err := s.client.Do(ctx, "ZRANGEBYSCORE", zset, min, max, "LIMIT", offset, scanChunk)
+		if err != nil {
+			return err
+		}
+		members, err := stringsOf(reply)
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		args := []interface{}{"HMGET", hash}
+		for _, m := range members {
+			args = append(args, m)
+		}
+		if reply, err = s.client.Do(ctx, args...); err != nil {
+			return err
+		}
+		values, ok := reply.([]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected HMGET reply %T", reply)
+		}
+		for _, v := range values {
+			data, ok := v.(string)
+			if !ok {
+				// dropped by a rollback meanwhile.
+				continue
+			}
+			more, err := visit(data)
+			if err != nil || !more {
+				return err
+			}
+		}
+		if len(members) < scanChunk {
+			return nil
+		}
+	}
+}
+
+func (s *Storage) AppendTokenTransfers(ctx context.Context, address string, transfers []*model.TokenTransfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+	args := []interface{}{"EVAL", appendScript, 3, s.key("transfers:", address), s.key("transfersdata:", address), s.key("histories"), address}
+	for _, transfer := range transfers {
+		data, err := json.Marshal(transfer)
+		if err != nil {
+			return err
+		}
+		pos := store.TransferPositionOf(transfer)
+		args = append(args, score(pos), member(pos, transfer.TransactionHash), string(data))
+	}
+	_, err := s.client.Do(ctx, args...)
+	return err
+}
+
+func (s *Storage) GetTokenTransfers(ctx context.Context, address string, maxBlock int64) ([]*model.TokenTransfer, error) {
+	transfers := make([]*model.TokenTransfer, 0)
+	err := s.scan(ctx, s.key("transfers:", address), s.key("transfersdata:", address), "-inf", maxScore(maxBlock), func(data string) (bool, error) {
+		transfer := &model.TokenTransfer{}
+		if err := json.Unmarshal([]byte(data), transfer); err != nil {
+			return false, err
+		}
+		transfers = append(transfers, transfer)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (s *Storage) AppendNFTTransfers(ctx context.Context, address string, transfers []*model.NFTTransfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+	args := []interface{}{"EVAL", appendScript, 3, s.key("nfts:", address), s.key("nftsdata:", address), s.key("histories"), address}
+	for _, transfer := range transfers {
+		data, err := json.Marshal(transfer)
+		if err != nil {
+			return err
+		}
+		pos := store.NFTPositionOf(transfer)
+		args = append(args, score(pos), member(pos, transfer.TransactionHash), string(data))
+	}
+	_, err := s.client.Do(ctx, args...)
+	return err
+}
+
+func (s *Storage) GetNFTTransfers(ctx context.Context, address string, q store.Query) ([]*model.NFTTransfer, string, error) {
+	min := "-inf"
+	if len(q.Cursor) > 0 {
+		from, err := store.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		min = "(" + score(from)
+	}
+	transfers := make([]*model.NFTTransfer, 0)
+	next := ""
+	err := s.scan(ctx, s.key("nfts:", address), s.key("nftsdata:", address), min, maxScore(q.MaxBlock), func(data string) (bool, error) {
+		transfer := &model.NFTTransfer{}
+		if err := json.Unmarshal([]byte(data), transfer); err != nil {
+			return false, err
+		}
+		if !q.Filter.MatchDirection(transfer.Direction) {
+			return true, nil
+		}
+		if q.Limit > 0 && len(transfers) == q.Limit {
+			next = store.EncodeCursor(store.NFTPositionOf(transfers[q.Limit-1]))
+			return false, nil
+		}
+		transfers = append(transfers, transfer)
+		return true, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return transfers, next, nil
+}
+
+func (s *Storage) DeleteHistory(ctx context.Context, address string) error {
+	_, err := s.client.Do(ctx, "DEL", s.key("tx:", address), s.key("txdata:", address),
+		s.key("transfers:", address), s.key("transfersdata:", address),
+		s.key("nfts:", address), s.key("nftsdata:", address))
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "SREM", s.key("histories"), address)
+	return err
+}
+
+// rollbackScript drop members scored from ARGV[1] on, of the addresses in ARGV, unless histories
+// doesn't hold exactly them anymore, then 1 is returned.
+// KEYS: histories, then sorted set and data hash of each kind of each address. ARGV: min score, addresses.
+const rollbackScript = `
+if redis.call('SCARD', KEYS[1]) ~= #ARGV - 1 then
+	return 1
+end
+for i = 2, #ARGV do
+	if redis.call('SISMEMBER', KEYS[1], ARGV[i]) == 0 then
+		return 1
+	end
+end
+for i = 2, #KEYS, 2 do
+	for _, m in ipairs(redis.call('ZRANGEBYSCORE', KEYS[i], ARGV[1], '+inf')) do
+		redis.call('HDEL', KEYS[i+1], m)
+	end
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], ARGV[1], '+inf')
+end
+return 0`
+
+// Rollback list the addresses having a history, then drop their members in one script, listed again if
+// an address got or lost its history meanwhile.
+func (s *Storage) Rollback(ctx context.Context, block int64) error {
+	for {
+		reply, err := s.client.Do(ctx, "SMEMBERS", s.key("histories"))
+		if err != nil {
+			return err
+		}
+		addresses, err := stringsOf(reply)
+		if err != nil {
+			return err
+		}
+		keys := []interface{}{s.key("histories")}
+		for _, address := range addresses {
+			keys = append(keys, s.key("tx:", address), s.key("txdata:", address),
+				s.key("transfers:", address), s.key("transfersdata:", address),
+				s.key("nfts:", address), s.key("nftsdata:", address))
+		}
+		args := append([]interface{}{"EVAL", rollbackScript, len(keys)}, keys...)
+		args = append(args, score(store.Position{Block: block + 1}))
+		for _, address := range addresses {
+			args = append(args, address)
+		}
+		if reply, err = s.client.Do(ctx, args...); err != nil {
+			return err
+		}
+		if changed, _ := reply.(int64); changed == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *Storage) SaveTokenMetadata(ctx context.Context, metadata *model.TokenMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "HSET", s.key("tokens"), metadata.Contract, string(data))
+	return err
+}
+
+func (s *Storage) GetTokenMetadata(ctx context.Context, contract string) (*model.TokenMetadata, bool, error) {
+	reply, err := s.client.Do(ctx, "HGET", s.key("tokens"), contract)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected HGET reply %T", reply)
+	}
+	metadata := &model.TokenMetadata{}
+	if err := json.Unmarshal([]byte(data), metadata); err != nil {
+		return nil, false, err
+	}
+	return metadata, true, nil
+}
+
+func (s *Storage) SaveChainID(ctx context.Context, chainID int64) error {
+	_, err := s.client.Do(ctx, "SET", s.key("chainid"), strconv.FormatInt(chainID, 10))
+	return err
+}
+
+func (s *Storage) GetChainID(ctx context.Context) (int64, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", s.key("chainid"))
+	if err != nil {
+		return 0, false, err
+	}
+	if reply == nil {
+		return 0, false, nil
+	}
+	v, ok := reply.(string)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected GET reply %T", reply)
+	}
+	chainID, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return chainID, true, nil
+}
+
+func (s *Storage) GetCheckpoint(ctx context.Context) (int64, bool, error) {
+	s.checkpointMutex.Lock()
+	defer s.checkpointMutex.Unlock()
+	reply, err := s.client.Do(ctx, "GET", s.key("checkpoint"))
+	if err != nil {
+		return 0, false, err
+	}
+	if reply == nil {
+		s.checkpoint = ""
+		return 0, false, nil
+	}
+	v, ok := reply.(string)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected GET reply %T", reply)
+	}
+	block, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	s.checkpoint = v
+	return block, true, nil
+}
+
+// casScript set KEYS[1] to ARGV[2] if it is still ARGV[1], an empty ARGV[1] stands for unset.
+const casScript = `
+local current = redis.call('GET', KEYS[1])
+if (current == false and ARGV[1] == '') or current == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+
+// SetCheckpoint set the checkpoint only if no other instance moved it since this one last read or
+// wrote it, otherwise fail with store.ErrCheckpointConflict. the next attempt compares against the
+// checkpoint found then.
+func (s *Storage) SetCheckpoint(ctx context.Context, block int64) error {
+	s.checkpointMutex.Lock()
+	defer s.checkpointMutex.Unlock()
+	v := strconv.FormatInt(block, 10)
+	reply, err := s.client.Do(ctx, "EVAL", casScript, 1, s.key("checkpoint"), s.checkpoint, v)
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n == 1 {
+		s.checkpoint = v
+		return nil
+	}
+	current, err := s.client.Do(ctx, "GET", s.key("checkpoint"))
+	if err != nil {
+		logging.Std().Error(ctx, "[SetCheckpoint]: Error GET", "err", err)
+		return store.ErrCheckpointConflict
+	}
+	s.checkpoint, _ = current.(string)
+	return store.ErrCheckpointConflict
+}
+
+func score(p store.Position) string {
+	return strconv.FormatInt(p.Block*positionsPerBlock+p.Index, 10)
+}
+
+// maxScore bound of the scores of blocks up to maxBlock.
+func maxScore(maxBlock int64) string {
+	if maxBlock >= store.NoMaxBlock/positionsPerBlock-1 {
+		return "+inf"
+	}
+	return "(" + score(store.Position{Block: maxBlock + 1})
+}
+
+// member key of a transaction or transfer, the same one at the same position is stored once.
+func member(p store.Position, hash string) string {
+	return fmt.Sprintf("%d:%d:%s", p.Block, p.Index, hash)
+}
+
+func stringsOf(reply interface{}) ([]string, error) {
+	values, ok := reply.([]interface{})
+	if !ok {
+		if reply == nil {
+			return []string{}, nil
+		}
+		return nil, errors.New("unexpected reply, want an array")
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected array element %T", v)
+		}
+		result = append(result, str)
+	}
+	return result, nil
+}
@@ -0,0 +1,353 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/storetest"
+	"github.com/tj/assert"
+)
+
+// fakeClient in-memory redis serving the commands Storage sends, scripts run as their Go equivalent.
+type fakeClient struct {
+	mu     sync.Mutex
+	down   bool
+	sets   map[string]map[string]bool
+	zsets  map[string]map[string]float64
+	hashes map[string]map[string]string
+	values map[string]string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		sets:   map[string]map[string]bool{},
+		zsets:  map[string]map[string]float64{},
+		hashes: map[string]map[string]string{},
+		values: map[string]string{},
+	}
+}
+
+func (f *fakeClient) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeClient) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	a := make([]string, 0, len(args))
+	for _, arg := range args {
+		a = append(a, fmt.Sprint(arg))
+	}
+	switch a[0] {
+	case "SADD":
+		f.sadd(a[1], a[2:]...)
+		return int64(1), nil
+	case "SREM":
+		for _, m := range a[2:] {
+			delete(f.sets[a[1]], m)
+		}
+		return int64(1), nil
+	case "SMEMBERS":
+		members := make([]string, 0)
+		for m := range f.sets[a[1]] {
+			members = append(members, m)
+		}
+		return array(members), nil
+	case "ZRANGEBYSCORE":
+		members := f.zrange(a[1], a[2], a[3])
+		if len(a) == 7 {
+			offset, _ := strconv.Atoi(a[5])
+			count, _ := strconv.Atoi(a[6])
+			if offset > len(members) {
+				offset = len(members)
+			}
+			if offset+count < len(members) {
+				members = members[:offset+count]
+			}
+			members = members[offset:]
+		}
+		return array(members), nil
+	case "ZCARD":
+		return int64(len(f.zsets[a[1]])), nil
+	case "ZRANGE":
+		members := f.zrange(a[1], "-inf", "+inf")
+		start, _ := strconv.Atoi(a[2])
+		stop, _ := strconv.Atoi(a[3])
+		if start < 0 {
+			start += len(members)
+		}
+		if stop < 0 {
+			stop += len(members)
+		}
+		if start < 0 {
+			start = 0
+		}
+		if stop >= len(members) {
+			stop = len(members) - 1
+		}
+		if start > stop {
+			return array(nil), nil
+		}
+		return array(members[start : stop+1]), nil
+	case "HMGET":
+		values := make([]interface{}, 0)
+		for _, field := range a[2:] {
+			if v, ok := f.hashes[a[1]][field]; ok {
+				values = append(values, v)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values, nil
+	case "HSET":
+		if f.hashes[a[1]] == nil {
+			f.hashes[a[1]] = map[string]string{}
+		}
+		f.hashes[a[1]][a[2]] = a[3]
+		return int64(1), nil
+	case "HDEL":
+		for _, field := range a[2:] {
+			delete(f.hashes[a[1]], field)
+		}
+		return int64(1), nil
+	case "HGET":
+		if v, ok := f.hashes[a[1]][a[2]]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "DEL":
+		for _, k := range a[1:] {
+			delete(f.sets, k)
+			delete(f.zsets, k)
+			delete(f.hashes, k)
+			delete(f.values, k)
+		}
+		return int64(1), nil
+	case "SET":
+		f.values[a[1]] = a[2]
+		return "OK", nil
+	case "GET":
+		if v, ok := f.values[a[1]]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "EVAL":
+		numKeys, _ := strconv.Atoi(a[2])
+		return f.eval(args[1].(string), a[3:3+numKeys], a[3+numKeys:])
+	}
+	return nil, fmt.Errorf("unknown command %s", a[0])
+}
+
+func (f *fakeClient) eval(script string, keys, argv []string) (interface{}, error) {
+	switch script {
+	case appendScript:
+		f.sadd(keys[2], argv[0])
+		for i := 1; i+2 < len(argv); i += 3 {
+			if _, ok := f.hashes[keys[1]][argv[i+1]]; ok {
+				continue
+			}
+			if f.hashes[keys[1]] == nil {
+				f.hashes[keys[1]] = map[string]string{}
+			}
+			f.hashes[keys[1]][argv[i+1]] = argv[i+2]
+			if f.zsets[keys[0]] == nil {
+				f.zsets[keys[0]] = map[string]float64{}
+			}
+			sc, _ := strconv.ParseFloat(argv[i], 64)
+			f.zsets[keys[0]][argv[i+1]] = sc
+		}
+		return int64(0), nil
+	case rollbackScript:
+		if len(f.sets[keys[0]]) != len(argv)-1 {
+			return int64(1), nil
+		}
+		for _, address := range argv[1:] {
+			if !f.sets[keys[0]][address] {
+				return int64(1), nil
+			}
+		}
+		for i := 1; i+1 < len(keys); i += 2 {
+			for _, m := range f.zrange(keys[i], argv[0], "+inf") {
+				delete(f.hashes[keys[i+1]], m)
+				delete(f.zsets[keys[i]], m)
+			}
+		}
+		return int64(0), nil
+	case casScript:
+		current, ok := f.values[keys[0]]
+		if (!ok && argv[0] == "") || (ok && current == argv[0]) {
+			f.values[keys[0]] = argv[1]
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func (f *fakeClient) sadd(key string, members ...string) {
+	if f.sets[key] == nil {
+		f.sets[key] = map[string]bool{}
+	}
+	for _, m := range members {
+		f.sets[key][m] = true
+	}
+}
+
+func (f *fakeClient) zrange(key, min, max string) []string {
+	members := make([]string, 0)
+	for m, sc := range f.zsets[key] {
+		if above(sc, min) && below(sc, max) {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		si, sj := f.zsets[key][members[i]], f.zsets[key][members[j]]
+		return si < sj || (si == sj && members[i] < members[j])
+	})
+	return members
+}
+
+func above(sc float64, min string) bool {
+	if min == "-inf" {
+		return true
+	}
+	if strings.HasPrefix(min, "(") {
+		v, _ := strconv.ParseFloat(min[1:], 64)
+		return sc > v
+	}
+	v, _ := strconv.ParseFloat(min, 64)
+	return sc >= v
+}
+
+func below(sc float64, max string) bool {
+	if max == "+inf" {
+		return true
+	}
+	if strings.HasPrefix(max, "(") {
+		v, _ := strconv.ParseFloat(max[1:], 64)
+		return sc < v
+	}
+	v, _ := strconv.ParseFloat(max, 64)
+	return sc <= v
+}
+
+func array(members []string) []interface{} {
+	values := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		values = append(values, m)
+	}
+	return values
+}
+
+func TestStorage(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return New(newFakeClient(), "gateway:")
+	})
+}
+
+func TestStorage_TransactionCounter(t *testing.T) {
+	storetest.RunTransactionCounter(t, func(t *testing.T) store.Storage {
+		return New(newFakeClient(), "gateway:")
+	})
+}
+
+func TestStorage_SubscriptionOptions(t *testing.T) {
+	storetest.RunSubscriptionOptions(t, func(t *testing.T) store.Storage {
+		return New(newFakeClient(), "gateway:")
+	})
+}
+
+func TestStorage_TokenMetadata(t *testing.T) {
+	storetest.RunTokenMetadata(t, func(t *testing.T) store.TokenMetadataStore {
+		return New(newFakeClient(), "gateway:")
+	})
+}
+
+func TestStorage_ChainID(t *testing.T) {
+	storetest.RunChainID(t, func(t *testing.T) store.ChainIDStore {
+		return New(newFakeClient(), "gateway:")
+	})
+}
+
+func TestStorage_CheckpointConflict(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	a, b := New(client, "gateway:"), New(client, "gateway:")
+	assert.Nil(t, a.SetCheckpoint(ctx, 10))
+	// b never read the checkpoint a wrote.
+	assert.Equal(t, store.ErrCheckpointConflict, b.SetCheckpoint(ctx, 10))
+	assert.Nil(t, a.SetCheckpoint(ctx, 11))
+
+	block, ok, err := b.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(11), block)
+	assert.Nil(t, b.SetCheckpoint(ctx, 12))
+	// a lost the race now.
+	assert.Equal(t, store.ErrCheckpointConflict, a.SetCheckpoint(ctx, 12))
+	// and compares against the checkpoint found then.
+	assert.Nil(t, a.SetCheckpoint(ctx, 13))
+}
+
+func TestStorage_Unreachable(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	s := New(client, "gateway:")
+	client.setDown(true)
+	assert.NotNil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+	_, _, err := s.GetCheckpoint(ctx)
+	assert.NotNil(t, err)
+
+	client.setDown(false)
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(1, 0, model.DirectionInbound)}))
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0"}, storetest.Hashes(list))
+}
+
+// appendingClient Client appending the transactions of another address right before the first rollback
+// script runs, as another replica would.
+type appendingClient struct {
+	*fakeClient
+	other    *Storage
+	appended bool
+}
+
+func (c *appendingClient) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	if len(args) > 1 && args[0] == "EVAL" && args[1] == rollbackScript && !c.appended {
+		c.appended = true
+		if err := c.other.AppendTransactions(ctx, "0xbb", []*model.ETHTransaction{storetest.Tx(5, 0, model.DirectionInbound)}); err != nil {
+			return nil, err
+		}
+	}
+	return c.fakeClient.Do(ctx, args...)
+}
+
+func TestStorage_RollbackHistoriesChanged(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeClient()
+	client := &appendingClient{fakeClient: fake, other: New(fake, "gateway:")}
+	s := New(client, "gateway:")
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(5, 0, model.DirectionInbound)}))
+
+	// 0xbb got its history after the addresses were listed, they are listed again.
+	assert.Nil(t, s.Rollback(ctx, 4))
+	for _, address := range []string{"0xaa", "0xbb"} {
+		list, _, err := s.GetTransactions(ctx, address, store.Query{MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(list))
+	}
+}
@@ -0,0 +1,516 @@
+// Package sqlite store.Storage persisted in a SQLite database.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+)
+
+// lastProcessed name of the checkpoint of the last processed block.
+const lastProcessed = "last_processed"
+
+// chainIDSetting name of the setting of the chain id.
+const chainIDSetting = "chain_id"
+
+var (
+	_ store.Storage                  = (*Storage)(nil)
+	_ store.TokenMetadataStore       = (*Storage)(nil)
+	_ store.ChainIDStore             = (*Storage)(nil)
+	_ store.TransactionCounter       = (*Storage)(nil)
+	_ store.SubscriptionOptionsStore = (*Storage)(nil)
+)
+
+// Storage store.Storage in a SQLite database. transactions and transfers are kept as json next to
+// the columns they are queried by, so new fields don't need a migration.
+type Storage struct {
+	db *sql.DB
+}
+
+// Open return a Storage on db, opened with a SQLite driver such as github.com/mattn/go-sqlite3
+// or modernc.org/sqlite, creating or migrating its schema. db is limited to a single connection,
+// SQLite serializes writes anyway and an in-memory database is per connection.
+func Open(ctx context.Context, db *sql.DB) (*Storage, error) {
+	db.SetMaxOpenConns(1)
+	if err := migrate(ctx, db); err != nil {
+		logging.Std().Error(ctx, "[Open]: Error migrate", "err", err)
+		return nil, err
+	}
+	return &Storage{db: db}, nil
+}
+
+// Close close the underlying database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) SaveSubscription(ctx context.Context, address string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO subscriptions (address) VALUES (?)`, address)
+	return err
+}
+
+func (s *Storage) DeleteSubscription(ctx context.Context, address string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE address = ?`, address)
+	return err
+}
+
+func (s *Storage) ListSubscriptions(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT address FROM subscriptions ORDER BY address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	addrs := make([]string, 0)
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, rows.Err()
+}
+
+func (s *Storage) CountTransactions(ctx context.Context, address string) (int, int64, error) {
+	var n int
+	var lastBlock int64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(MAX(block), 0) FROM transactions WHERE address = ?`,
+		address).Scan(&n, &lastBlock)
+	return n, lastBlock, err
+}
+
+func (s *Storage) SaveSubscriptionOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO subscriptions (address, options) VALUES (?, ?)
+		ON CONFLICT (address) DO UPDATE SET options = excluded.options`, address, data)
+	return err
+}
+
+func (s *Storage) ListSubscriptionOptions(ctx context.Context) (map[string]model.SubscriptionOptions, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT address, options FROM subscriptions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	options := map[string]model.SubscriptionOptions{}
+	for rows.Next() {
+		var addr string
+		var data []byte
+		if err := rows.Scan(&addr, &data); err != nil {
+			return nil, err
+		}
+		opts := model.SubscriptionOptions{}
+		if err := json.Unmarshal(data, &opts); err != nil {
+			return nil, fmt.Errorf("options of %s: %v", addr, err)
+		}
+		options[addr] = opts
+	}
+	return options, rows.Err()
+}
+
+func (s *Storage) AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO transactions
+			(address, block, tx_index, hash, direction, status, method_id, method_name, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, t := range transactions {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			pos := store.PositionOf(t)
+			// the hash column keys the transaction, an internal transfer shares the hash of its transaction.
+			if _, err := stmt.ExecContext(ctx, address, pos.Block, pos.Index, store.KeyOf(t), string(t.Direction), string(t.Status),
+				t.MethodID, t.MethodName, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) GetTransactions(ctx context.Context, address string, q store.Query) ([]*model.ETHTransaction, string, error) {
+	query := `SELECT data FROM transactions WHERE address = ? AND block <= ?`
+	args := []interface{}{address, q.MaxBlock}
+	if len(q.Cursor) > 0 {
+		from, err := store.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (block > ? OR (block = ? AND tx_index > ?))`
+		args = append(args, from.Block, from.Block, from.Index)
+	}
+	if len(q.Filter.Direction) > 0 {
+		// same as TxFilter.Match, a self transfer is both inbound and outbound.
+		query += ` AND direction IN (?, ?)`
+		args = append(args, string(q.Filter.Direction), string(model.DirectionSelf))
+	}
+	if len(q.Filter.Status) > 0 {
+		query += ` AND status = ?`
+		args = append(args, string(q.Filter.Status))
+	}
+	if q.Filter.ExcludeFailed {
+		query += ` AND status != ?`
+		args = append(args, string(model.TxStatusFailed))
+	}
+	if len(q.Filter.Method) > 0 {
+		query += ` AND (method_id = ? OR method_name = ?)`
+		args = append(args, strings.ToLower(q.Filter.Method), q.Filter.Method)
+	}
+	// rowid keeps transactions at the same position in insertion order.
+	query += ` ORDER BY block, tx_index, rowid`
+	if q.Limit > 0 {
+		// one more tells whether there is a next page.
+		query += ` LIMIT ?`
+		args = append(args, q.Limit+1)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	transactions := make([]*model.ETHTransaction, 0)
+	next := ""
+	for rows.Next() {
+		if q.Limit > 0 && len(transactions) == q.Limit {
+			next = store.EncodeCursor(store.PositionOf(transactions[q.Limit-1]))
+			break
+		}
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", err
+		}
+		t := &model.ETHTransaction{}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, "", err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, next, rows.Err()
+}
+
+func (s *Storage) AppendTokenTransfers(ctx context.Context, address string, transfers []*model.TokenTransfer) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO token_transfers
+			(address, block, log_index, hash, data) VALUES (?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, transfer := range transfers {
+			data, err := json.Marshal(transfer)
+			if err != nil {
+				return err
+			}
+			pos := store.TransferPositionOf(transfer)
+			if _, err := stmt.ExecContext(ctx, address, pos.Block, pos.Index, transfer.TransactionHash, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) GetTokenTransfers(ctx context.Context, address string, maxBlock int64) ([]*model.TokenTransfer, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM token_transfers WHERE address = ? AND block <= ?
+		ORDER BY block, log_index, rowid`, address, maxBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transfers := make([]*model.TokenTransfer, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		transfer := &model.TokenTransfer{}
+		if err := json.Unmarshal(data, transfer); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+func (s *Storage) AppendNFTTransfers(ctx context.Context, address string, transfers []*model.NFTTransfer) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO nft_transfers
+			(address, block, log_index, hash, direction, data) VALUES (?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, transfer := range transfers {
+			data, err := json.Marshal(transfer)
+			if err != nil {
+				return err
+			}
+			pos := store.NFTPositionOf(transfer)
+			if _, err := stmt.ExecContext(ctx, address, pos.Block, pos.Index, transfer.TransactionHash, string(transfer.Direction), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) GetNFTTransfers(ctx context.Context, address string, q store.Query) ([]*model.NFTTransfer, string, error) {
+	query := `SELECT data FROM nft_transfers WHERE address = ? AND block <= ?`
+	args := []interface{}{address, q.MaxBlock}
+	if len(q.Cursor) > 0 {
+		from, err := store.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += ` AND (block > ? OR (block = ? AND log_index > ?))`
+		args = append(args, from.Block, from.Block, from.Index)
+	}
+	if len(q.Filter.Direction) > 0 {
+		query += ` AND direction IN (?, ?)`
+		args = append(args, string(q.Filter.Direction), string(model.DirectionSelf))
+	}
+	query += ` ORDER BY block, log_index, rowid`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit+1)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	transfers := make([]*model.NFTTransfer, 0)
+	next := ""
+	for rows.Next() {
+		if q.Limit > 0 && len(transfers) == q.Limit {
+			next = store.EncodeCursor(store.NFTPositionOf(transfers[q.Limit-1]))
+			break
+		}
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", err
+		}
+		transfer := &model.NFTTransfer{}
+		if err := json.Unmarshal(data, transfer); err != nil {
+			return nil, "", err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, next, rows.Err()
+}
+
+// historyTables tables of the transactions and transfers of the addresses.
+var historyTables = []string{"transactions", "token_transfers", "nft_transfers"}
+
+func (s *Storage) DeleteHistory(ctx context.Context, address string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range historyTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE address = ?`, address); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) Rollback(ctx context.Context, block int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range historyTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE block > ?`, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) SaveTokenMetadata(ctx context.Context, metadata *model.TokenMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT OR REPLACE INTO token_metadata (contract, data) VALUES (?, ?)`, metadata.Contract, data)
+	return err
+}
+
+func (s *Storage) GetTokenMetadata(ctx context.Context, contract string) (*model.TokenMetadata, bool, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM token_metadata WHERE contract = ?`, contract).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	metadata := &model.TokenMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, false, err
+	}
+	return metadata, true, nil
+}
+
+func (s *Storage) SaveChainID(ctx context.Context, chainID int64) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO settings (name, value) VALUES (?, ?)`, chainIDSetting,
+		strconv.FormatInt(chainID, 10))
+	return err
+}
+
+func (s *Storage) GetChainID(ctx context.Context) (int64, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE name = ?`, chainIDSetting).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	chainID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid chain id %q: %v", value, err)
+	}
+	return chainID, true, nil
+}
+
+func (s *Storage) GetCheckpoint(ctx context.Context) (int64, bool, error) {
+	var block int64
+	err := s.db.QueryRowContext(ctx, `SELECT block FROM checkpoints WHERE name = ?`, lastProcessed).Scan(&block)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return block, true, nil
+}
+
+func (s *Storage) SetCheckpoint(ctx context.Context, block int64) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO checkpoints (name, block) VALUES (?, ?)`, lastProcessed, block)
+	return err
+}
+
+// inTx run f in a transaction, committed if f succeeds.
+func (s *Storage) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrations schema changes in order, a database at version n, its user_version, has the first n applied.
+// append new changes, never edit the applied ones.
+var migrations = [][]string{
+	{
+		`CREATE TABLE subscriptions (
+			address TEXT PRIMARY KEY
+		)`,
+		// the primary key is the position, a transaction already stored at it is ignored.
+		`CREATE TABLE transactions (
+			address   TEXT    NOT NULL,
+			block     INTEGER NOT NULL,
+			tx_index  INTEGER NOT NULL,
+			hash      TEXT    NOT NULL,
+			direction TEXT    NOT NULL,
+			status    TEXT    NOT NULL,
+			data      BLOB    NOT NULL,
+			PRIMARY KEY (address, block, tx_index, hash)
+		)`,
+		`CREATE INDEX transactions_block ON transactions (block)`,
+		`CREATE TABLE token_transfers (
+			address   TEXT    NOT NULL,
+			block     INTEGER NOT NULL,
+			log_index INTEGER NOT NULL,
+			hash      TEXT    NOT NULL,
+			data      BLOB    NOT NULL,
+			PRIMARY KEY (address, block, log_index, hash)
+		)`,
+		`CREATE INDEX token_transfers_block ON token_transfers (block)`,
+		`CREATE TABLE checkpoints (
+			name  TEXT PRIMARY KEY,
+			block INTEGER NOT NULL
+		)`,
+	},
+	{
+		`CREATE TABLE nft_transfers (
+			address   TEXT    NOT NULL,
+			block     INTEGER NOT NULL,
+			log_index INTEGER NOT NULL,
+			hash      TEXT    NOT NULL,
+			direction TEXT    NOT NULL,
+			data      BLOB    NOT NULL,
+			PRIMARY KEY (address, block, log_index, hash)
+		)`,
+		`CREATE INDEX nft_transfers_block ON nft_transfers (block)`,
+	},
+	{
+		// transactions stored before are left without method.
+		`ALTER TABLE transactions ADD COLUMN method_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE transactions ADD COLUMN method_name TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		`CREATE TABLE token_metadata (
+			contract TEXT PRIMARY KEY,
+			data     BLOB NOT NULL
+		)`,
+	},
+	{
+		`CREATE TABLE settings (
+			name  TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+	},
+	{
+		// subscriptions saved before have no options.
+		`ALTER TABLE subscriptions ADD COLUMN options BLOB NOT NULL DEFAULT '{}'`,
+	},
+}
+
+// migrate apply the migrations db is missing, each in a transaction along with its version.
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range migrations[version] {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %v", version+1, err)
+			}
+		}
+		// PRAGMA takes no parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/sugarshop/token-gateway/store/storetest"
+	"github.com/tj/assert"
+)
+
+func open(t *testing.T, path string) *Storage {
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	s, err := Open(context.Background(), db)
+	assert.Nil(t, err)
+	return s
+}
+
+func TestStorage(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		s := open(t, filepath.Join(t.TempDir(), "gateway.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_TransactionCounter(t *testing.T) {
+	storetest.RunTransactionCounter(t, func(t *testing.T) store.Storage {
+		s := open(t, filepath.Join(t.TempDir(), "gateway.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_SubscriptionOptions(t *testing.T) {
+	storetest.RunSubscriptionOptions(t, func(t *testing.T) store.Storage {
+		s := open(t, filepath.Join(t.TempDir(), "gateway.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_TokenMetadata(t *testing.T) {
+	storetest.RunTokenMetadata(t, func(t *testing.T) store.TokenMetadataStore {
+		s := open(t, filepath.Join(t.TempDir(), "gateway.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_ChainID(t *testing.T) {
+	storetest.RunChainID(t, func(t *testing.T) store.ChainIDStore {
+		s := open(t, filepath.Join(t.TempDir(), "gateway.db"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gateway.db")
+	s := open(t, path)
+	assert.Nil(t, s.SaveSubscription(ctx, "0xaa"))
+	for block := int64(1); block <= 4; block++ {
+		assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(block, 0, model.DirectionInbound)}))
+		assert.Nil(t, s.SetCheckpoint(ctx, block))
+	}
+	// killed after storing block 5, before its checkpoint.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(5, 0, model.DirectionInbound)}))
+	assert.Nil(t, s.Close())
+
+	s = open(t, path)
+	defer s.Close()
+	addrs, err := s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xaa"}, addrs)
+	block, ok, err := s.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(4), block)
+
+	// block 5 is parsed again on resume.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{storetest.Tx(5, 0, model.DirectionInbound)}))
+	assert.Nil(t, s.SetCheckpoint(ctx, 5))
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0", "0x2_0", "0x3_0", "0x4_0", "0x5_0"}, storetest.Hashes(list))
+}
+
+func TestStorage_Migrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gateway.db")
+	s := open(t, path)
+	var version int
+	assert.Nil(t, s.db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version))
+	assert.Equal(t, len(migrations), version)
+	assert.Nil(t, s.SaveSubscription(ctx, "0xaa"))
+	assert.Nil(t, s.Close())
+
+	// reopening an up to date database keeps its data.
+	s = open(t, path)
+	addrs, err := s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xaa"}, addrs)
+
+	// written by a later version.
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, len(migrations)+1))
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+	_, err = Open(ctx, db)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,180 @@
+// Package store persistence of subscriptions, transactions and progress of the gateway.
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/util"
+)
+
+// NoMaxBlock Query.MaxBlock leaving no transaction out.
+const NoMaxBlock = math.MaxInt64
+
+// ErrInvalidCursor the cursor wasn't returned by GetTransactions.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrCheckpointConflict another instance sharing the storage moved the checkpoint since it was last read.
+var ErrCheckpointConflict = errors.New("checkpoint moved by another instance")
+
+// Storage where ETHService keeps its state, addresses are passed in lowercase.
+// implementations should be safe for concurrent use. values passed in are owned by the storage
+// afterwards, and values returned must not be modified by the caller.
+type Storage interface {
+	// SaveSubscription subscribe address, saving it again is a no-op.
+	SaveSubscription(ctx context.Context, address string) error
+	// DeleteSubscription unsubscribe address, its history is kept.
+	DeleteSubscription(ctx context.Context, address string) error
+	// ListSubscriptions subscribed addresses in sorted order.
+	ListSubscriptions(ctx context.Context) ([]string, error)
+
+	// AppendTransactions store transactions of address. they are kept in chain order whatever the
+	// order they are appended in, and a transaction already stored at the same position is skipped.
+	AppendTransactions(ctx context.Context, address string, transactions []*model.ETHTransaction) error
+	// GetTransactions address's transactions meeting q in chain order, along with the cursor of the
+	// next page, which is empty when there is nothing left.
+	GetTransactions(ctx context.Context, address string, q Query) ([]*model.ETHTransaction, string, error)
+	// AppendTokenTransfers store token transfers of address, in chain order and skipping the ones already stored.
+	AppendTokenTransfers(ctx context.Context, address string, transfers []*model.TokenTransfer) error
+	// GetTokenTransfers address's token transfers in blocks up to maxBlock, in chain order.
+	GetTokenTransfers(ctx context.Context, address string, maxBlock int64) ([]*model.TokenTransfer, error)
+	// AppendNFTTransfers store NFT transfers of address, in chain order and skipping the ones already stored.
+	AppendNFTTransfers(ctx context.Context, address string, transfers []*model.NFTTransfer) error
+	// GetNFTTransfers address's NFT transfers meeting q in chain order, along with the cursor of the next
+	// page like GetTransactions. only the direction of q.Filter applies, transfers have no status.
+	GetNFTTransfers(ctx context.Context, address string, q Query) ([]*model.NFTTransfer, string, error)
+	// DeleteHistory drop the transactions, token and NFT transfers of address.
+	DeleteHistory(ctx context.Context, address string) error
+	// Rollback drop the transactions, token and NFT transfers of every address in blocks after block.
+	Rollback(ctx context.Context, block int64) error
+
+	Checkpointer
+}
+
+// TokenMetadataStore storage keeping the metadata of token contracts as well, optional. metadata is
+// resolved again after a restart when the storage isn't one.
+type TokenMetadataStore interface {
+	// SaveTokenMetadata save metadata, replacing the one saved for its contract.
+	SaveTokenMetadata(ctx context.Context, metadata *model.TokenMetadata) error
+	// GetTokenMetadata metadata of contract, false if none was saved.
+	GetTokenMetadata(ctx context.Context, contract string) (*model.TokenMetadata, bool, error)
+}
+
+// ChainIDStore storage remembering the chain its state belongs to, optional. ETHService refuses to run
+// on a storage of another chain than Config.ChainID, rather than mixing the state of both.
+type ChainIDStore interface {
+	// GetChainID the chain id saved, false if none was.
+	GetChainID(ctx context.Context) (int64, bool, error)
+	// SaveChainID save chainID, replacing the one saved.
+	SaveChainID(ctx context.Context, chainID int64) error
+}
+
+// SubscriptionOptionsStore storage keeping the options of each subscription next to its address, optional.
+// ETHService restores them on restart and picks up the ones set through other instances sharing the
+// storage, without it options live in the memory of the instance setting them.
+type SubscriptionOptionsStore interface {
+	// SaveSubscriptionOptions subscribe address, replacing its options.
+	SaveSubscriptionOptions(ctx context.Context, address string, opts model.SubscriptionOptions) error
+	// ListSubscriptionOptions options of every subscribed address, the zero value for an address saved
+	// with SaveSubscription only.
+	ListSubscriptionOptions(ctx context.Context) (map[string]model.SubscriptionOptions, error)
+}
+
+// TransactionCounter storage counting the transactions of an address without reading them, optional.
+// ETHService reads the whole history of each subscription to list them otherwise.
+type TransactionCounter interface {
+	// CountTransactions number of transactions stored for address and the block of the most recent one,
+	// 0 if there is none.
+	CountTransactions(ctx context.Context, address string) (int, int64, error)
+}
+
+// Evicter storage dropping the history of addresses past a retention, optional. ETHService sweeps it
+// in background and reports its evictions.
+type Evicter interface {
+	// EvictExpired drop the entries past their max age now, rather than on the next append to their address.
+	EvictExpired(ctx context.Context) error
+	// Evictions entries dropped per address past the retention, transactions, token and NFT transfers together.
+	Evictions(ctx context.Context) (map[string]int64, error)
+}
+
+// Query which transactions GetTransactions returns.
+type Query struct {
+	// Cursor next cursor of the previous page, empty to start from the oldest transaction.
+	Cursor string
+	// Limit page size, 0 returns every transaction after Cursor.
+	Limit int
+	// Filter conditions of the returned transactions.
+	Filter model.TxFilter
+	// MaxBlock transactions in later blocks are left out, NoMaxBlock for none.
+	MaxBlock int64
+}
+
+// Position position of a transaction on chain, ordered by block number then transaction index.
+// a token transfer is positioned by its log index instead, which is unique in a block as well.
+type Position struct {
+	Block int64
+	Index int64
+}
+
+// PositionOf position of tx, malformed quantities are treated as 0, a node never returns them for mined transactions.
+// the parsed BlockNum and TxIndex are used when set, the hex quantities otherwise, such as for a transaction
+// stored before they existed.
+func PositionOf(tx *model.ETHTransaction) Position {
+	if tx.BlockNum > 0 {
+		return Position{Block: tx.BlockNum, Index: tx.TxIndex}
+	}
+	block, _ := util.HexToInt64(tx.BlockNumber)
+	index, _ := util.HexToInt64(tx.TransactionIndex)
+	return Position{Block: block, Index: index}
+}
+
+// KeyOf identity of tx among the transactions at its position, its hash, or its TraceID for an internal
+// transfer which shares the hash of its transaction.
+func KeyOf(tx *model.ETHTransaction) string {
+	if tx.Internal {
+		return tx.TraceID
+	}
+	return tx.Hash
+}
+
+// TransferPositionOf position of transfer.
+func TransferPositionOf(transfer *model.TokenTransfer) Position {
+	block, _ := util.HexToInt64(transfer.BlockNumber)
+	index, _ := util.HexToInt64(transfer.LogIndex)
+	return Position{Block: block, Index: index}
+}
+
+// NFTPositionOf position of transfer, by its log index like a token transfer.
+func NFTPositionOf(transfer *model.NFTTransfer) Position {
+	block, _ := util.HexToInt64(transfer.BlockNumber)
+	index, _ := util.HexToInt64(transfer.LogIndex)
+	return Position{Block: block, Index: index}
+}
+
+// After whether p is later on chain than o.
+func (p Position) After(o Position) bool {
+	return p.Block > o.Block || (p.Block == o.Block && p.Index > o.Index)
+}
+
+// EncodeCursor cursor is anchored on the chain position of the last returned transaction rather than
+// a slice offset, so it stays valid while new transactions are appended.
+func EncodeCursor(p Position) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", p.Block, p.Index)))
+}
+
+// DecodeCursor position of the cursor returned by EncodeCursor.
+func DecodeCursor(cursor string) (Position, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Position{}, ErrInvalidCursor
+	}
+	var p Position
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &p.Block, &p.Index); err != nil {
+		return Position{}, ErrInvalidCursor
+	}
+	return p, nil
+}
@@ -0,0 +1,542 @@
+// Package storetest conformance tests every store.Storage implementation should pass.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/sugarshop/token-gateway/store"
+	"github.com/tj/assert"
+)
+
+// Run run the conformance tests against storages returned by newStorage, a new empty one per test.
+func Run(t *testing.T, newStorage func(t *testing.T) store.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s store.Storage)
+	}{
+		{"Subscriptions", testSubscriptions},
+		{"AppendTransactions", testAppendTransactions},
+		{"AppendTransactionsTwice", testAppendTransactionsTwice},
+		{"AppendTransactionsConcurrently", testAppendTransactionsConcurrently},
+		{"AppendInternalTransfers", testAppendInternalTransfers},
+		{"GetTransactionsCursor", testGetTransactionsCursor},
+		{"GetTransactionsInvalidCursor", testGetTransactionsInvalidCursor},
+		{"GetTransactionsFilter", testGetTransactionsFilter},
+		{"GetTransactionsMaxBlock", testGetTransactionsMaxBlock},
+		{"GetTransactionsMethod", testGetTransactionsMethod},
+		{"TokenTransfers", testTokenTransfers},
+		{"NFTTransfers", testNFTTransfers},
+		{"DeleteHistory", testDeleteHistory},
+		{"Rollback", testRollback},
+		{"Checkpoint", testCheckpoint},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+// Tx transaction with hash 0x<block>_<index> at position (block, index) of address.
+func Tx(block, index int64, direction model.Direction) *model.ETHTransaction {
+	return &model.ETHTransaction{
+		Hash:             fmt.Sprintf("0x%d_%d", block, index),
+		BlockNumber:      fmt.Sprintf("0x%x", block),
+		TransactionIndex: fmt.Sprintf("0x%x", index),
+		Direction:        direction,
+	}
+}
+
+// Transfer token transfer with hash 0x<block>_<logIndex> at position (block, logIndex).
+func Transfer(block, logIndex int64) *model.TokenTransfer {
+	return &model.TokenTransfer{
+		TransactionHash: fmt.Sprintf("0x%d_%d", block, logIndex),
+		BlockNumber:     fmt.Sprintf("0x%x", block),
+		LogIndex:        fmt.Sprintf("0x%x", logIndex),
+	}
+}
+
+// NFT NFT transfer with hash 0x<block>_<logIndex> at position (block, logIndex).
+func NFT(block, logIndex int64, direction model.Direction) *model.NFTTransfer {
+	return &model.NFTTransfer{
+		Standard:        model.NFTStandardERC721,
+		TokenIDs:        []string{"1"},
+		Amounts:         []string{"1"},
+		TransactionHash: fmt.Sprintf("0x%d_%d", block, logIndex),
+		BlockNumber:     fmt.Sprintf("0x%x", block),
+		LogIndex:        fmt.Sprintf("0x%x", logIndex),
+		Direction:       direction,
+	}
+}
+
+// Hashes hashes of list, in order.
+func Hashes(list []*model.ETHTransaction) []string {
+	hashes := make([]string, 0, len(list))
+	for _, tx := range list {
+		hashes = append(hashes, tx.Hash)
+	}
+	return hashes
+}
+
+func transferHashes(list []*model.TokenTransfer) []string {
+	hashes := make([]string, 0, len(list))
+	for _, transfer := range list {
+		hashes = append(hashes, transfer.TransactionHash)
+	}
+	return hashes
+}
+
+func nftHashes(list []*model.NFTTransfer) []string {
+	hashes := make([]string, 0, len(list))
+	for _, transfer := range list {
+		hashes = append(hashes, transfer.TransactionHash)
+	}
+	return hashes
+}
+
+func allNFTs(t *testing.T, s store.Storage, address string) []string {
+	list, next, err := s.GetNFTTransfers(context.Background(), address, store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, "", next)
+	return nftHashes(list)
+}
+
+func all(t *testing.T, s store.Storage, address string) []string {
+	list, next, err := s.GetTransactions(context.Background(), address, store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, "", next)
+	return Hashes(list)
+}
+
+func testSubscriptions(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	addrs, err := s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(addrs))
+
+	assert.Nil(t, s.SaveSubscription(ctx, "0xbb"))
+	assert.Nil(t, s.SaveSubscription(ctx, "0xaa"))
+	assert.Nil(t, s.SaveSubscription(ctx, "0xbb"))
+	addrs, err = s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xaa", "0xbb"}, addrs)
+
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(1, 0, model.DirectionInbound)}))
+	assert.Nil(t, s.DeleteSubscription(ctx, "0xaa"))
+	assert.Nil(t, s.DeleteSubscription(ctx, "0xcc"))
+	addrs, err = s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xbb"}, addrs)
+	// history is kept.
+	assert.Equal(t, []string{"0x1_0"}, all(t, s, "0xaa"))
+}
+
+func testAppendTransactions(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	assert.Equal(t, []string{}, all(t, s, "0xaa"))
+
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{
+		Tx(5, 1, model.DirectionInbound),
+		Tx(5, 0, model.DirectionInbound),
+	}))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(7, 0, model.DirectionInbound)}))
+	// backfilled blocks land before the live ones.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{
+		Tx(2, 3, model.DirectionOutbound),
+		Tx(6, 0, model.DirectionOutbound),
+	}))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xbb", []*model.ETHTransaction{Tx(5, 0, model.DirectionOutbound)}))
+	assert.Equal(t, []string{"0x2_3", "0x5_0", "0x5_1", "0x6_0", "0x7_0"}, all(t, s, "0xaa"))
+	assert.Equal(t, []string{"0x5_0"}, all(t, s, "0xbb"))
+}
+
+func testAppendTransactionsTwice(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	batch := []*model.ETHTransaction{Tx(1, 0, model.DirectionInbound), Tx(2, 0, model.DirectionInbound)}
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", batch))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", batch))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(1, 0, model.DirectionInbound)}))
+	assert.Equal(t, []string{"0x1_0", "0x2_0"}, all(t, s, "0xaa"))
+
+	// another transaction at the same position, only a reorg gets there, is kept.
+	other := Tx(2, 0, model.DirectionInbound)
+	other.Hash = "0xother"
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{other}))
+	assert.Equal(t, 3, len(all(t, s, "0xaa")))
+}
+
+func testAppendInternalTransfers(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	// internal transfers share the hash and position of their transaction, keyed by trace.
+	batch := []*model.ETHTransaction{Tx(1, 0, model.DirectionOutbound)}
+	for _, path := range []string{"0", "0.1"} {
+		internal := Tx(1, 0, model.DirectionInbound)
+		internal.Internal = true
+		internal.TraceID = internal.Hash + ":" + path
+		batch = append(batch, internal)
+	}
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", batch))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", batch[1:]))
+	list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, []string{"", "0x1_0:0", "0x1_0:0.1"}, []string{list[0].TraceID, list[1].TraceID, list[2].TraceID})
+}
+
+func testAppendTransactionsConcurrently(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	var batch []*model.ETHTransaction
+	for index := int64(0); index < 20; index++ {
+		batch = append(batch, Tx(1, index, model.DirectionInbound))
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, s.AppendTransactions(ctx, "0xaa", batch))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, Hashes(batch), all(t, s, "0xaa"))
+}
+
+func testGetTransactionsCursor(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	for block := int64(1); block <= 3; block++ {
+		assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{
+			Tx(block, 0, model.DirectionInbound),
+			Tx(block, 1, model.DirectionInbound),
+		}))
+	}
+	var hashes []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		assert.True(t, pages < 4)
+		list, next, err := s.GetTransactions(ctx, "0xaa", store.Query{Cursor: cursor, Limit: 2, MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		assert.True(t, len(list) <= 2)
+		hashes = append(hashes, Hashes(list)...)
+		if len(next) == 0 {
+			break
+		}
+		if pages == 0 {
+			// the cursor stays valid while transactions are appended.
+			assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(4, 0, model.DirectionInbound)}))
+		}
+		cursor = next
+	}
+	assert.Equal(t, []string{"0x1_0", "0x1_1", "0x2_0", "0x2_1", "0x3_0", "0x3_1", "0x4_0"}, hashes)
+}
+
+func testGetTransactionsInvalidCursor(t *testing.T, s store.Storage) {
+	_, _, err := s.GetTransactions(context.Background(), "0xaa", store.Query{Cursor: "not a cursor", MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, store.ErrInvalidCursor, err)
+}
+
+func testGetTransactionsFilter(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	failed := Tx(2, 0, model.DirectionInbound)
+	failed.Status = model.TxStatusFailed
+	succeeded := Tx(3, 0, model.DirectionOutbound)
+	succeeded.Status = model.TxStatusSuccess
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{
+		Tx(1, 0, model.DirectionInbound),
+		failed,
+		succeeded,
+		Tx(4, 0, model.DirectionSelf),
+		Tx(5, 0, model.DirectionInbound),
+	}))
+	query := func(filter model.TxFilter, limit int) []string {
+		list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{Limit: limit, Filter: filter, MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		return Hashes(list)
+	}
+	assert.Equal(t, []string{"0x3_0", "0x4_0"}, query(model.TxFilter{Direction: model.DirectionOutbound}, 0))
+	assert.Equal(t, []string{"0x1_0", "0x2_0"}, query(model.TxFilter{Direction: model.DirectionInbound}, 2))
+	assert.Equal(t, []string{"0x2_0"}, query(model.TxFilter{Status: model.TxStatusFailed}, 0))
+	// without known status, kept.
+	assert.Equal(t, []string{"0x1_0", "0x3_0", "0x4_0", "0x5_0"}, query(model.TxFilter{ExcludeFailed: true}, 0))
+
+	// the next page starts after the last transaction matching the filter.
+	list, next, err := s.GetTransactions(ctx, "0xaa", store.Query{Limit: 1, Filter: model.TxFilter{Direction: model.DirectionOutbound}, MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x3_0"}, Hashes(list))
+	list, next, err = s.GetTransactions(ctx, "0xaa", store.Query{Cursor: next, Limit: 1, Filter: model.TxFilter{Direction: model.DirectionOutbound}, MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x4_0"}, Hashes(list))
+	assert.Equal(t, "", next)
+}
+
+func testGetTransactionsMaxBlock(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	for block := int64(1); block <= 4; block++ {
+		assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(block, 0, model.DirectionInbound)}))
+	}
+	list, next, err := s.GetTransactions(ctx, "0xaa", store.Query{MaxBlock: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0", "0x2_0"}, Hashes(list))
+	assert.Equal(t, "", next)
+
+	list, next, err = s.GetTransactions(ctx, "0xaa", store.Query{Limit: 2, MaxBlock: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_0", "0x2_0"}, Hashes(list))
+	assert.Equal(t, "", next)
+}
+
+func testGetTransactionsMethod(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	transfer := Tx(2, 0, model.DirectionOutbound)
+	transfer.MethodID, transfer.MethodName = "0xa9059cbb", "transfer"
+	unknown := Tx(3, 0, model.DirectionOutbound)
+	unknown.MethodID = "0x12345678"
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(1, 0, model.DirectionInbound), transfer, unknown}))
+	query := func(method string) []string {
+		list, _, err := s.GetTransactions(ctx, "0xaa", store.Query{Filter: model.TxFilter{Method: method}, MaxBlock: store.NoMaxBlock})
+		assert.Nil(t, err)
+		return Hashes(list)
+	}
+	assert.Equal(t, []string{"0x2_0"}, query("transfer"))
+	assert.Equal(t, []string{"0x2_0"}, query("0xA9059CBB"))
+	assert.Equal(t, []string{"0x3_0"}, query("0x12345678"))
+	assert.Equal(t, []string{}, query("approve"))
+}
+
+func testTokenTransfers(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	list, err := s.GetTokenTransfers(ctx, "0xaa", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(list))
+
+	assert.Nil(t, s.AppendTokenTransfers(ctx, "0xaa", []*model.TokenTransfer{Transfer(3, 1), Transfer(3, 0)}))
+	assert.Nil(t, s.AppendTokenTransfers(ctx, "0xaa", []*model.TokenTransfer{Transfer(1, 4), Transfer(3, 0)}))
+	assert.Nil(t, s.AppendTokenTransfers(ctx, "0xaa", []*model.TokenTransfer{Transfer(5, 0)}))
+	list, err = s.GetTokenTransfers(ctx, "0xaa", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_4", "0x3_0", "0x3_1", "0x5_0"}, transferHashes(list))
+	list, err = s.GetTokenTransfers(ctx, "0xaa", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_4", "0x3_0", "0x3_1"}, transferHashes(list))
+	list, err = s.GetTokenTransfers(ctx, "0xbb", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(list))
+}
+
+func testNFTTransfers(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	assert.Equal(t, []string{}, allNFTs(t, s, "0xaa"))
+
+	assert.Nil(t, s.AppendNFTTransfers(ctx, "0xaa", []*model.NFTTransfer{
+		NFT(3, 1, model.DirectionInbound),
+		NFT(3, 0, model.DirectionOutbound),
+	}))
+	assert.Nil(t, s.AppendNFTTransfers(ctx, "0xaa", []*model.NFTTransfer{
+		NFT(1, 4, model.DirectionSelf),
+		NFT(3, 0, model.DirectionOutbound),
+	}))
+	assert.Nil(t, s.AppendNFTTransfers(ctx, "0xaa", []*model.NFTTransfer{NFT(5, 0, model.DirectionInbound)}))
+	assert.Equal(t, []string{"0x1_4", "0x3_0", "0x3_1", "0x5_0"}, allNFTs(t, s, "0xaa"))
+	assert.Equal(t, []string{}, allNFTs(t, s, "0xbb"))
+
+	// paged like transactions.
+	list, next, err := s.GetNFTTransfers(ctx, "0xaa", store.Query{Limit: 3, MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_4", "0x3_0", "0x3_1"}, nftHashes(list))
+	list, next, err = s.GetNFTTransfers(ctx, "0xaa", store.Query{Cursor: next, Limit: 3, MaxBlock: store.NoMaxBlock})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x5_0"}, nftHashes(list))
+	assert.Equal(t, "", next)
+	_, _, err = s.GetNFTTransfers(ctx, "0xaa", store.Query{Cursor: "not a cursor", MaxBlock: store.NoMaxBlock})
+	assert.Equal(t, store.ErrInvalidCursor, err)
+
+	list, _, err = s.GetNFTTransfers(ctx, "0xaa", store.Query{Filter: model.TxFilter{Direction: model.DirectionInbound}, MaxBlock: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0x1_4", "0x3_1"}, nftHashes(list))
+	assert.Equal(t, "1", list[1].TokenIDs[0])
+}
+
+func testDeleteHistory(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	for _, addr := range []string{"0xaa", "0xbb"} {
+		assert.Nil(t, s.AppendTransactions(ctx, addr, []*model.ETHTransaction{Tx(1, 0, model.DirectionInbound)}))
+		assert.Nil(t, s.AppendTokenTransfers(ctx, addr, []*model.TokenTransfer{Transfer(1, 0)}))
+		assert.Nil(t, s.AppendNFTTransfers(ctx, addr, []*model.NFTTransfer{NFT(1, 1, model.DirectionInbound)}))
+	}
+	assert.Nil(t, s.DeleteHistory(ctx, "0xaa"))
+	assert.Nil(t, s.DeleteHistory(ctx, "0xcc"))
+	assert.Equal(t, []string{}, all(t, s, "0xaa"))
+	assert.Equal(t, []string{"0x1_0"}, all(t, s, "0xbb"))
+	transfers, err := s.GetTokenTransfers(ctx, "0xaa", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(transfers))
+	transfers, err = s.GetTokenTransfers(ctx, "0xbb", store.NoMaxBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(transfers))
+	assert.Equal(t, []string{}, allNFTs(t, s, "0xaa"))
+	assert.Equal(t, []string{"0x1_1"}, allNFTs(t, s, "0xbb"))
+}
+
+func testRollback(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	for block := int64(1); block <= 4; block++ {
+		for _, addr := range []string{"0xaa", "0xbb"} {
+			assert.Nil(t, s.AppendTransactions(ctx, addr, []*model.ETHTransaction{Tx(block, 0, model.DirectionInbound)}))
+			assert.Nil(t, s.AppendTokenTransfers(ctx, addr, []*model.TokenTransfer{Transfer(block, 0)}))
+			assert.Nil(t, s.AppendNFTTransfers(ctx, addr, []*model.NFTTransfer{NFT(block, 0, model.DirectionInbound)}))
+		}
+	}
+	assert.Nil(t, s.Rollback(ctx, 2))
+	for _, addr := range []string{"0xaa", "0xbb"} {
+		assert.Equal(t, []string{"0x1_0", "0x2_0"}, all(t, s, addr))
+		transfers, err := s.GetTokenTransfers(ctx, addr, store.NoMaxBlock)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0x1_0", "0x2_0"}, transferHashes(transfers))
+		assert.Equal(t, []string{"0x1_0", "0x2_0"}, allNFTs(t, s, addr))
+	}
+
+	// the replacing blocks are stored again.
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{Tx(3, 0, model.DirectionInbound)}))
+	assert.Equal(t, []string{"0x1_0", "0x2_0", "0x3_0"}, all(t, s, "0xaa"))
+}
+
+func testCheckpoint(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	_, ok, err := s.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, s.SetCheckpoint(ctx, 10))
+	assert.Nil(t, s.SetCheckpoint(ctx, 12))
+	block, ok, err := s.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), block)
+
+	// a rollback moves the checkpoint back.
+	assert.Nil(t, s.SetCheckpoint(ctx, 9))
+	block, _, err = s.GetCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9), block)
+}
+
+// RunTokenMetadata run the conformance tests of store.TokenMetadataStore against storages returned by
+// newStorage, a new empty one per test.
+func RunTokenMetadata(t *testing.T, newStorage func(t *testing.T) store.TokenMetadataStore) {
+	ctx := context.Background()
+	s := newStorage(t)
+	_, ok, err := s.GetTokenMetadata(ctx, "0xcc")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	decimals := 6
+	assert.Nil(t, s.SaveTokenMetadata(ctx, &model.TokenMetadata{Contract: "0xcc", Symbol: "USDC", Decimals: &decimals}))
+	// a token without decimals keeps them unset.
+	assert.Nil(t, s.SaveTokenMetadata(ctx, &model.TokenMetadata{Contract: "0xdd", Name: "Odd"}))
+	metadata, ok, err := s.GetTokenMetadata(ctx, "0xcc")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &model.TokenMetadata{Contract: "0xcc", Symbol: "USDC", Decimals: &decimals}, metadata)
+	metadata, ok, err = s.GetTokenMetadata(ctx, "0xdd")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, metadata.Decimals)
+
+	// saved again, replaced.
+	assert.Nil(t, s.SaveTokenMetadata(ctx, &model.TokenMetadata{Contract: "0xcc", Symbol: "USDC.e", Decimals: &decimals}))
+	metadata, _, err = s.GetTokenMetadata(ctx, "0xcc")
+	assert.Nil(t, err)
+	assert.Equal(t, "USDC.e", metadata.Symbol)
+}
+
+// RunChainID run the conformance tests of store.ChainIDStore against storages returned by newStorage, a
+// new empty one per test.
+func RunChainID(t *testing.T, newStorage func(t *testing.T) store.ChainIDStore) {
+	ctx := context.Background()
+	s := newStorage(t)
+	_, ok, err := s.GetChainID(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, s.SaveChainID(ctx, 1))
+	chainID, ok, err := s.GetChainID(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), chainID)
+
+	// saved again, replaced.
+	assert.Nil(t, s.SaveChainID(ctx, 137))
+	chainID, _, err = s.GetChainID(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(137), chainID)
+}
+
+// RunTransactionCounter run the conformance tests of store.TransactionCounter against storages returned by
+// newStorage, a new empty one per test.
+func RunTransactionCounter(t *testing.T, newStorage func(t *testing.T) store.Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	counter, ok := s.(store.TransactionCounter)
+	assert.True(t, ok)
+	n, lastBlock, err := counter.CountTransactions(ctx, "0xaa")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(0), lastBlock)
+
+	assert.Nil(t, s.AppendTransactions(ctx, "0xaa", []*model.ETHTransaction{
+		Tx(1, 0, model.DirectionInbound), Tx(12, 3, model.DirectionOutbound), Tx(12, 1, model.DirectionInbound),
+	}))
+	assert.Nil(t, s.AppendTransactions(ctx, "0xbb", []*model.ETHTransaction{Tx(2, 0, model.DirectionInbound)}))
+	n, lastBlock, err = counter.CountTransactions(ctx, "0xaa")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(12), lastBlock)
+
+	assert.Nil(t, s.DeleteHistory(ctx, "0xaa"))
+	n, _, err = counter.CountTransactions(ctx, "0xaa")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+// RunSubscriptionOptions run the conformance tests of store.SubscriptionOptionsStore against storages
+// returned by newStorage, a new empty one per test.
+func RunSubscriptionOptions(t *testing.T, newStorage func(t *testing.T) store.Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	optionsStore, ok := s.(store.SubscriptionOptionsStore)
+	assert.True(t, ok)
+	options, err := optionsStore.ListSubscriptionOptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(options))
+
+	opts := model.SubscriptionOptions{Direction: model.DirectionInbound, MinValue: big.NewInt(1000),
+		Label: "hot wallet", WebhookURL: "https://example.com/hook"}
+	assert.Nil(t, optionsStore.SaveSubscriptionOptions(ctx, "0xaa", opts))
+	assert.Nil(t, s.SaveSubscription(ctx, "0xbb"))
+	// saved again without options, they are kept.
+	assert.Nil(t, s.SaveSubscription(ctx, "0xaa"))
+	addrs, err := s.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xaa", "0xbb"}, addrs)
+	options, err = optionsStore.ListSubscriptionOptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]model.SubscriptionOptions{"0xaa": opts, "0xbb": {}}, options)
+
+	// replaced whole.
+	assert.Nil(t, optionsStore.SaveSubscriptionOptions(ctx, "0xaa", model.SubscriptionOptions{Label: "cold wallet"}))
+	// unsubscribed, its options are dropped along.
+	assert.Nil(t, s.DeleteSubscription(ctx, "0xbb"))
+	options, err = optionsStore.ListSubscriptionOptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]model.SubscriptionOptions{"0xaa": {Label: "cold wallet"}}, options)
+	assert.Nil(t, s.DeleteSubscription(ctx, "0xaa"))
+	assert.Nil(t, s.SaveSubscription(ctx, "0xaa"))
+	options, err = optionsStore.ListSubscriptionOptions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]model.SubscriptionOptions{"0xaa": {}}, options)
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ErrInvalidAddress the address is malformed, or its EIP-55 checksum doesn't match.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// IsHexAddress whether address is a 0x prefixed 20 bytes hexadecimal address, in any case.
+func IsHexAddress(address string) bool {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X") {
+		return false
+	}
+	for _, c := range address[2:] {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// ChecksumAddress EIP-55 mixed-case form of a hex address: a letter is uppercase when the nibble at its
+// index in keccak256 of the lowercase address is 8 or more.
+func ChecksumAddress(address string) string {
+	lower := strings.ToLower(address[2:])
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	digest := hex.EncodeToString(hash.Sum(nil))
+	checksum := []byte(lower)
+	for i, c := range checksum {
+		if 'a' <= c && c <= 'f' && digest[i] >= '8' {
+			checksum[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksum)
+}
+
+// NormalizeAddress validate address and return it in lowercase. an all lowercase or all uppercase
+// address is taken as is, a mixed-case one should carry a valid EIP-55 checksum. errors wrap ErrInvalidAddress.
+func NormalizeAddress(address string) (string, error) {
+	if !IsHexAddress(address) {
+		return "", fmt.Errorf("%w %q: want 0x followed by 40 hex digits", ErrInvalidAddress, address)
+	}
+	digits := address[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && ChecksumAddress(address)[2:] != digits {
+		return "", fmt.Errorf("%w %q: EIP-55 checksum mismatch, want %s", ErrInvalidAddress, address, ChecksumAddress(address))
+	}
+	return "0x" + strings.ToLower(digits), nil
+}
+
+// IsENSName whether name looks like an ENS name, labels ending with .eth such as vitalik.eth, in any case.
+func IsENSName(name string) bool {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".eth") || strings.HasPrefix(name, ".") || strings.Contains(name, "..") {
+		return false
+	}
+	return len(name) > len(".eth") && !strings.ContainsAny(name, " /:")
+}
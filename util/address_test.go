@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+// checksumVectors EIP-55 test vectors of the spec.
+var checksumVectors = []string{
+	// all caps
+	"0x52908400098527886E0F7030069857D2E4169EE7",
+	"0x8617E340B3D01FA5F11F306F4090FD50E238070D",
+	// all lower
+	"0xde709f2102306220921060314715629080e2fb77",
+	"0x27b1fdb04752bbc536007a920d24acb045561c26",
+	// normal
+	"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+	"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+	"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+	"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+}
+
+func TestChecksumAddress(t *testing.T) {
+	for _, vector := range checksumVectors {
+		assert.Equal(t, vector, ChecksumAddress(strings.ToLower(vector)))
+		assert.Equal(t, vector, ChecksumAddress("0x"+strings.ToUpper(vector[2:])))
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	for _, vector := range checksumVectors {
+		address, err := NormalizeAddress(vector)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.ToLower(vector), address)
+	}
+	address, err := NormalizeAddress("0X5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", address)
+
+	for _, invalid := range []string{
+		"",
+		"vitalik.eth",
+		"0x123",
+		"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAedff",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg",
+		// one letter of the checksum flipped.
+		"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+	} {
+		_, err := NormalizeAddress(invalid)
+		assert.True(t, errors.Is(err, ErrInvalidAddress), invalid)
+	}
+	_, err = NormalizeAddress("0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	assert.Contains(t, err.Error(), "want 0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+}
+
+func TestIsENSName(t *testing.T) {
+	for _, name := range []string{"vitalik.eth", "Vitalik.ETH", "pay.vitalik.eth"} {
+		assert.True(t, IsENSName(name), name)
+	}
+	for _, name := range []string{"", ".eth", "eth", "vitalik..eth", "vitalik.com", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "a b.eth"} {
+		assert.False(t, IsENSName(name), name)
+	}
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HexToInt64 convert a 0x prefixed hexadecimal quantity, such as block number, to int64.
+func HexToInt64(hexStr string) (int64, error) {
+	if !strings.HasPrefix(hexStr, "0x") && !strings.HasPrefix(hexStr, "0X") {
+		return 0, errors.New("hex string without 0x prefix: " + hexStr)
+	}
+	return strconv.ParseInt(hexStr[2:], 16, 64)
+}
+
+// HexToBigInt convert a 0x prefixed hexadecimal quantity, such as a wei value, which may overflow int64.
+func HexToBigInt(hexStr string) (*big.Int, error) {
+	if !strings.HasPrefix(hexStr, "0x") && !strings.HasPrefix(hexStr, "0X") {
+		return nil, errors.New("hex string without 0x prefix: " + hexStr)
+	}
+	n, ok := new(big.Int).SetString(hexStr[2:], 16)
+	if !ok || n.Sign() < 0 {
+		return nil, errors.New("invalid hex quantity: " + hexStr)
+	}
+	return n, nil
+}
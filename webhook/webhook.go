@@ -0,0 +1,340 @@
+// Package webhook POST matched transactions to the callback URLs of subscriptions, off the parse loop.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sugarshop/token-gateway/logging"
+	"github.com/sugarshop/token-gateway/model"
+)
+
+// SignatureHeader header carrying Sign of the body, set when Config.Secret is.
+const SignatureHeader = "X-Gateway-Signature"
+
+// ErrClosed the Dispatcher is closed.
+var ErrClosed = errors.New("webhook dispatcher closed")
+
+// Config how webhooks are delivered, zero fields take the DefaultConfig value.
+type Config struct {
+	// Workers deliveries in flight at most.
+	Workers int
+	// QueueSize deliveries waiting for a worker at most, more are dropped.
+	QueueSize int
+	// MaxAttempts attempts per delivery, including the first one. network errors, timeouts, 5xx,
+	// 408 and 429 responses are retried, other responses are not.
+	MaxAttempts int
+	// BaseDelay delay before the first retry, doubled after each retry.
+	BaseDelay time.Duration
+	// MaxDelay the upper bound of the delay between two attempts.
+	MaxDelay time.Duration
+	// Timeout of each attempt.
+	Timeout time.Duration
+	// Secret HMAC-SHA256 key signing the payloads, empty sends them unsigned.
+	Secret string
+	// Logger logger of the deliveries given up, nil logs with logging.Std.
+	Logger logging.Logger
+}
+
+// DefaultConfig default delivery settings.
+func DefaultConfig() Config {
+	return Config{
+		Workers:     4,
+		QueueSize:   1024,
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Timeout:     10 * time.Second,
+	}
+}
+
+// EventENSChanged Payload.Event of a subscribed ENS name re-pointed to another address.
+const EventENSChanged = "ens_changed"
+
+// Payload JSON body POSTed for a matched transaction, or for an event of the subscription.
+type Payload struct {
+	Address     string                `json:"address"` // the subscribed address, in lowercase.
+	Direction   model.Direction       `json:"direction"`
+	BlockNumber int64                 `json:"blockNumber"`
+	Transaction *model.ETHTransaction `json:"transaction"`
+	Event       string                `json:"event,omitempty"`    // empty for a transaction, such as EventENSChanged.
+	Name        string                `json:"name,omitempty"`     // the ENS name of EventENSChanged.
+	Previous    string                `json:"previous,omitempty"` // the address the name pointed to before EventENSChanged.
+}
+
+// Sign signature of body keyed with secret: "sha256=" followed by the hex HMAC-SHA256. receivers
+// compute it over the raw body and compare it with SignatureHeader using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Stats deliveries to the webhook of a subscription.
+type Stats struct {
+	Delivered   int64     `json:"delivered"`
+	Retries     int64     `json:"retries"`
+	Failed      int64     `json:"failed"`  // given up, after MaxAttempts or on a response not worth retrying.
+	Dropped     int64     `json:"dropped"` // never attempted, the queue was full.
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+type delivery struct {
+	key  string
+	url  string
+	body []byte
+}
+
+// Dispatcher deliver payloads with a bounded pool of workers, started on the first Send.
+type Dispatcher struct {
+	conf   Config
+	client *http.Client
+	queue  chan delivery
+	ctx    context.Context // cancelled when Close gives up waiting.
+	cancel context.CancelFunc
+
+	// closeMutex read held by Send across the closed check, the start of the workers and the enqueue,
+	// held by Close to close closed, so no payload is queued once the workers may drain the queue and exit.
+	closeMutex sync.RWMutex
+	startOnce  sync.Once
+	closeOnce  sync.Once
+	closed     chan struct{}
+	workers    sync.WaitGroup
+
+	statsMutex sync.Mutex
+	stats      map[string]*Stats
+}
+
+// NewDispatcher return a Dispatcher posting with client, nil uses http.DefaultClient.
+func NewDispatcher(conf Config, client *http.Client) *Dispatcher {
+	def := DefaultConfig()
+	if conf.Workers <= 0 {
+		conf.Workers = def.Workers
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = def.QueueSize
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = def.MaxAttempts
+	}
+	if conf.BaseDelay <= 0 {
+		conf.BaseDelay = def.BaseDelay
+	}
+	if conf.MaxDelay <= 0 {
+		conf.MaxDelay = def.MaxDelay
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = def.Timeout
+	}
+	if conf.Logger == nil {
+		conf.Logger = logging.Std()
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		conf:   conf,
+		client: client,
+		queue:  make(chan delivery, conf.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		closed: make(chan struct{}),
+		stats:  map[string]*Stats{},
+	}
+}
+
+// Send queue payload for url without waiting for it, its outcome is counted in the Stats of key.
+// the payload is dropped if the queue is full.
+func (d *Dispatcher) Send(key, url string, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	d.closeMutex.RLock()
+	defer d.closeMutex.RUnlock()
+	select {
+	case <-d.closed:
+		return ErrClosed
+	default:
+	}
+	d.startOnce.Do(d.start)
+	select {
+	case d.queue <- delivery{key: key, url: url, body: body}:
+		return nil
+	default:
+		d.update(key, func(st *Stats) { st.Dropped++ })
+		return errors.New("webhook queue full")
+	}
+}
+
+// Stats deliveries counted for key, false if nothing was sent for key yet.
+func (d *Dispatcher) Stats(key string) (Stats, bool) {
+	d.statsMutex.Lock()
+	defer d.statsMutex.Unlock()
+	st, ok := d.stats[key]
+	if !ok {
+		return Stats{}, false
+	}
+	return *st, true
+}
+
+// Close stop accepting payloads and wait for the queued ones to be delivered, or until ctx is done.
+// deliveries still running then are cancelled.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.closeMutex.Lock()
+	d.closeOnce.Do(func() { close(d.closed) })
+	d.closeMutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) start() {
+	for i := 0; i < d.conf.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+}
+
+// work deliver queued payloads until closed, then the ones left in the queue.
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for {
+		select {
+		case dl := <-d.queue:
+			d.deliver(dl)
+		case <-d.closed:
+			for {
+				select {
+				case dl := <-d.queue:
+					d.deliver(dl)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver post dl, retrying with backoff.
+func (d *Dispatcher) deliver(dl delivery) {
+	for attempt := 1; ; attempt++ {
+		err := d.post(dl)
+		if err == nil {
+			d.update(dl.key, func(st *Stats) { st.Delivered++ })
+			return
+		}
+		if attempt >= d.conf.MaxAttempts || !retryable(err) || d.ctx.Err() != nil {
+			d.conf.Logger.Error(d.ctx, "[deliver]: Error post, give up", "url", dl.url, "attempt", attempt, "err", err)
+			d.update(dl.key, func(st *Stats) {
+				st.Failed++
+				st.LastError = err.Error()
+				st.LastFailure = time.Now()
+			})
+			return
+		}
+		d.update(dl.key, func(st *Stats) { st.Retries++ })
+		timer := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (d *Dispatcher) post(dl delivery) error {
+	ctx, cancel := context.WithTimeout(d.ctx, d.conf.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.url, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(d.conf.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(d.conf.Secret, dl.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return &transportError{err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{resp.StatusCode}
+	}
+	return nil
+}
+
+// backoff delay before retry number attempt (1-based), a random one between half and the full
+// exponential delay so webhooks failing together aren't retried together.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.conf.BaseDelay
+	for i := 1; i < attempt && delay < d.conf.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.conf.MaxDelay {
+		delay = d.conf.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (d *Dispatcher) update(key string, f func(st *Stats)) {
+	d.statsMutex.Lock()
+	defer d.statsMutex.Unlock()
+	st, ok := d.stats[key]
+	if !ok {
+		st = &Stats{}
+		d.stats[key] = st
+	}
+	f(st)
+}
+
+// statusError the webhook answered with a non 2xx status code.
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected http status %d", e.statusCode)
+}
+
+// transportError the request didn't get any response, timeouts included.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryable whether err is worth trying again.
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= 500 || statusErr.statusCode == http.StatusRequestTimeout ||
+			statusErr.statusCode == http.StatusTooManyRequests
+	}
+	var tErr *transportError
+	return errors.As(err, &tErr)
+}
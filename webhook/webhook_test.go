@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sugarshop/token-gateway/model"
+	"github.com/tj/assert"
+)
+
+func testConfig() Config {
+	return Config{Workers: 2, MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Timeout: time.Second, Secret: "s3cret"}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var bodies []*Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, hmac.Equal([]byte(Sign("s3cret", body)), []byte(r.Header.Get(SignatureHeader))))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		payload := &Payload{}
+		assert.Nil(t, json.Unmarshal(body, payload))
+		mu.Lock()
+		bodies = append(bodies, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testConfig(), nil)
+	tx := &model.ETHTransaction{Hash: "0x1", BlockNumber: "0x2a", Direction: model.DirectionInbound}
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{Address: "0xaa", Direction: tx.Direction, BlockNumber: 42, Transaction: tx}))
+	assert.Nil(t, d.Close(ctx))
+
+	assert.Equal(t, 1, len(bodies))
+	assert.Equal(t, "0xaa", bodies[0].Address)
+	assert.Equal(t, model.DirectionInbound, bodies[0].Direction)
+	assert.Equal(t, int64(42), bodies[0].BlockNumber)
+	assert.Equal(t, "0x1", bodies[0].Transaction.Hash)
+	stats, ok := d.Stats("0xaa")
+	assert.True(t, ok)
+	assert.Equal(t, Stats{Delivered: 1}, stats)
+
+	assert.Equal(t, ErrClosed, d.Send("0xaa", server.URL, &Payload{}))
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	ctx := context.Background()
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fails twice, then succeeds.
+		switch atomic.AddInt64(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testConfig(), nil)
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	assert.Nil(t, d.Close(ctx))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+	stats, _ := d.Stats("0xaa")
+	assert.Equal(t, int64(1), stats.Delivered)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, int64(0), stats.Failed)
+}
+
+func TestDispatcher_GiveUp(t *testing.T) {
+	ctx := context.Background()
+	var calls int64
+	status := int64(http.StatusInternalServerError)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.WriteHeader(int(atomic.LoadInt64(&status)))
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testConfig(), nil)
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	assert.Nil(t, d.Close(ctx))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+	stats, _ := d.Stats("0xaa")
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, "unexpected http status 500", stats.LastError)
+	assert.False(t, stats.LastFailure.IsZero())
+
+	// a 4xx isn't retried.
+	d = NewDispatcher(testConfig(), nil)
+	atomic.StoreInt64(&calls, 0)
+	atomic.StoreInt64(&status, http.StatusBadRequest)
+	assert.Nil(t, d.Send("0xbb", server.URL, &Payload{}))
+	assert.Nil(t, d.Close(ctx))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	stats, _ = d.Stats("0xbb")
+	assert.Equal(t, Stats{Failed: 1, LastError: "unexpected http status 400", LastFailure: stats.LastFailure}, stats)
+}
+
+func TestDispatcher_Timeout(t *testing.T) {
+	ctx := context.Background()
+	var calls int64
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	conf := testConfig()
+	conf.Timeout = 50 * time.Millisecond
+	d := NewDispatcher(conf, nil)
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	assert.Nil(t, d.Close(ctx))
+	stats, _ := d.Stats("0xaa")
+	assert.Equal(t, int64(1), stats.Delivered)
+	assert.Equal(t, int64(1), stats.Retries)
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	conf := testConfig()
+	conf.Workers, conf.QueueSize = 1, 1
+	d := NewDispatcher(conf, nil)
+	// the worker holds one, the queue another, wait for the worker to pick the first.
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	for len(d.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	assert.NotNil(t, d.Send("0xaa", server.URL, &Payload{}))
+	close(release)
+	assert.Nil(t, d.Close(ctx))
+	stats, _ := d.Stats("0xaa")
+	assert.Equal(t, Stats{Delivered: 2, Dropped: 1}, stats)
+}
+
+func TestDispatcher_CloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	d := NewDispatcher(testConfig(), nil)
+	assert.Nil(t, d.Send("0xaa", server.URL, &Payload{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, d.Close(ctx))
+	// the running delivery is cancelled and given up.
+	d.workers.Wait()
+	stats, _ := d.Stats("0xaa")
+	assert.Equal(t, int64(1), stats.Failed)
+}
+
+func TestDispatcher_SendWhileClosing(t *testing.T) {
+	var delivered int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testConfig(), nil)
+	var accepted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if d.Send("0xaa", server.URL, &Payload{}) == nil {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}()
+	}
+	assert.Nil(t, d.Close(context.Background()))
+	wg.Wait()
+	// every payload accepted before Close is delivered, the later ones are refused.
+	assert.Equal(t, atomic.LoadInt32(&accepted), atomic.LoadInt32(&delivered))
+	assert.Equal(t, ErrClosed, d.Send("0xaa", server.URL, &Payload{}))
+}